@@ -0,0 +1,348 @@
+package diff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// An ApplyOption configures how ApplyFileDiff applies a diff.
+type ApplyOption func(*applyOptions)
+
+type applyOptions struct {
+	ignoreFinalNewline bool
+	reverse            bool
+	fuzz               int
+	maxOffset          int
+	rejects            bool
+	stripLevel         int
+}
+
+func newApplyOptions(opts []ApplyOption) *applyOptions {
+	o := &applyOptions{stripLevel: -1}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithIgnoreFinalNewline makes ApplyFileDiff tolerate a mismatch that is
+// solely due to the presence or absence of a newline at the end of the
+// original file (e.g., because an editor added or stripped it). The result
+// still follows the newline state the diff specifies for the new file.
+func WithIgnoreFinalNewline() ApplyOption {
+	return func(o *applyOptions) {
+		o.ignoreFinalNewline = true
+	}
+}
+
+// WithReverse applies diffs in reverse, like patch -R or git apply -R: the
+// content given is that of the new file, and the result that of the original
+// file. With ApplyMultiFileDiff, file creations become deletions, renames are
+// undone, and so on.
+func WithReverse() ApplyOption {
+	return func(o *applyOptions) {
+		o.reverse = true
+	}
+}
+
+// WithFuzz makes hunks apply even if up to n of their leading and trailing
+// context lines do not match, like patch -F n. Each hunk is applied with the
+// least fuzz that makes it match, ignoring 1, then 2, ..., then n lines of
+// context at each end; the ignored lines are left as they are in the
+// original file. ApplyFileDiffWithResults reports the fuzz used.
+func WithFuzz(n int) ApplyOption {
+	return func(o *applyOptions) {
+		o.fuzz = n
+	}
+}
+
+// WithMaxOffset makes hunks apply even if they are up to n lines away from
+// their original positions, like patch, which reports "Hunk #1 succeeded at
+// 12 (offset 3 lines)". Once a hunk has applied at an offset, the following
+// hunks are first looked for at the same offset, and then at increasing
+// distances from it, alternating between later and earlier lines.
+// ApplyFileDiffWithResults reports the line and offset where each hunk
+// applied. Hunks never apply before the end of the previous hunk.
+func WithMaxOffset(n int) ApplyOption {
+	return func(o *applyOptions) {
+		o.maxOffset = n
+	}
+}
+
+// WithRejects makes hunks that cannot be applied be left out instead of
+// failing the whole diff, like patch does. The other hunks are still
+// applied, and the result is returned along with ErrHunksRejected. The
+// results of ApplyFileDiffWithResults give the error of each rejected hunk,
+// and RejectFile formats them as a .rej file. ApplyMultiFileDiff writes such
+// a file next to each file with rejected hunks, named after it with a ".rej"
+// suffix.
+func WithRejects() ApplyOption {
+	return func(o *applyOptions) {
+		o.rejects = true
+	}
+}
+
+// WithStripLevel makes ApplyMultiFileDiff remove the first n components
+// of the file names of diffs to get the paths of the files to apply them to,
+// like patch -pN. For example, with n = 1, "a/foo/bar.go" becomes
+// "foo/bar.go", and "/tmp/foo/bar.go" becomes "tmp/foo/bar.go"; consecutive
+// slashes count as one. A diff whose file names have fewer than n+1
+// components fails to apply. Without this option, git's "a/" and "b/"
+// prefixes are removed if both names have them, and other names are used
+// as they are.
+func WithStripLevel(n int) ApplyOption {
+	return func(o *applyOptions) {
+		o.stripLevel = n
+	}
+}
+
+// RejectFile returns the contents of a .rej file for the hunks of d that
+// were rejected, according to results (see WithRejects): the diff of d
+// with only those hunks. It returns nil if no hunk was rejected.
+func RejectFile(d *FileDiff, results []ApplyResult) ([]byte, error) {
+	rej := *d
+	rej.Hunks = nil
+	for _, r := range results {
+		if r.Err != nil && r.Hunk >= 1 && r.Hunk <= len(d.Hunks) {
+			rej.Hunks = append(rej.Hunks, d.Hunks[r.Hunk-1])
+		}
+	}
+	if len(rej.Hunks) == 0 {
+		return nil, nil
+	}
+	return PrintFileDiff(&rej)
+}
+
+// fileLine is a single line of file content.
+type fileLine struct {
+	// text is the line's content, without its trailing newline.
+	text []byte
+	// noNewline is true if the line is not terminated by a newline.
+	noNewline bool
+}
+
+// splitLines splits content into its lines.
+func splitLines(content []byte) []fileLine {
+	var lines []fileLine
+	for len(content) > 0 {
+		i := bytes.IndexByte(content, '\n')
+		if i == -1 {
+			lines = append(lines, fileLine{text: content, noNewline: true})
+			break
+		}
+		lines = append(lines, fileLine{text: content[:i]})
+		content = content[i+1:]
+	}
+	return lines
+}
+
+// An ApplyError describes why a hunk of a diff could not be applied.
+type ApplyError struct {
+	Hunk int    // 1-based index of the hunk in FileDiff.Hunks
+	Line int    // Line of the original file where the error occurred
+	Want string // For mismatches, the line the hunk expects
+	Got  string // For mismatches, the line in the original file
+	Err  error  // The actual error
+}
+
+func (e *ApplyError) Error() string {
+	return fmt.Sprintf("hunk #%d: original line %d: %s", e.Hunk, e.Line, e.Err)
+}
+
+var (
+	// ErrHunkOutOfRange is when a hunk starts before the end of the previous
+	// one or after the end of the original file.
+	ErrHunkOutOfRange = errors.New("hunk start is out of range")
+
+	// ErrUnexpectedEOF is when the original file ends before a hunk does.
+	ErrUnexpectedEOF = errors.New("unexpected end of file")
+
+	// ErrLineMismatch is when a context or removed line of a hunk differs
+	// from the original file.
+	ErrLineMismatch = errors.New("line does not match the diff")
+
+	// ErrNewlineMismatch is when a context or removed line of a hunk
+	// differs from the original file in whether it ends with a newline.
+	ErrNewlineMismatch = errors.New("trailing newline does not match the diff")
+
+	// ErrHunksRejected is when some hunks of a diff could not be applied
+	// and were left out (see WithRejects).
+	ErrHunksRejected = errors.New("some hunks were rejected")
+
+	// ErrBlobMismatch is when the original file of a binary patch, or the
+	// result of applying it, does not have the object name given on the
+	// diff's "index" line.
+	ErrBlobMismatch = errors.New("content does not match the object name in the diff")
+)
+
+// Apply applies the hunks of fd to src, the contents of the original file,
+// and returns the contents of the new file. It is ApplyFileDiff without
+// options.
+func Apply(src []byte, fd *FileDiff) ([]byte, error) {
+	return ApplyFileDiff(src, fd)
+}
+
+// ApplyFileDiff applies the hunks of d to src, the contents of the original
+// file, and returns the contents of the new file. The context and removed
+// lines of each hunk must match src exactly at the hunk's original position
+// (unless WithFuzz or WithMaxOffset is used); if they do not, the returned
+// error is an *ApplyError.
+func ApplyFileDiff(src []byte, d *FileDiff, opts ...ApplyOption) ([]byte, error) {
+	content, _, err := ApplyFileDiffWithResults(src, d, opts...)
+	return content, err
+}
+
+// An ApplyResult describes how a hunk was applied.
+type ApplyResult struct {
+	Hunk   int // 1-based index of the hunk in FileDiff.Hunks
+	Line   int // Line of the original file where the hunk was applied
+	Offset int // Number of lines between Line and the hunk's original start
+	Fuzz   int // Number of context lines ignored at each end of the hunk
+
+	// Err is why the hunk could not be applied, if it was rejected (see
+	// WithRejects). The other fields are then zero, except for Hunk.
+	Err error
+}
+
+// ApplyFileDiffWithResults is like ApplyFileDiff, but also returns how each
+// hunk was applied, such as which hunks needed fuzz or applied at an offset.
+//
+// If d has a git binary patch (see FileDiff.BinaryPatch), its forward hunk,
+// or its reverse hunk with WithReverse, is applied to src instead, and there
+// are no results. The other options do not apply to binary patches.
+func ApplyFileDiffWithResults(src []byte, d *FileDiff, opts ...ApplyOption) ([]byte, []ApplyResult, error) {
+	o := newApplyOptions(opts)
+	if d.BinaryPatch != nil {
+		content, err := applyBinaryPatch(src, d, o.reverse)
+		return content, nil, err
+	}
+	hunks := d.Hunks
+	if o.reverse {
+		hunks = make([]*Hunk, len(d.Hunks))
+		for i, h := range d.Hunks {
+			hunks[i] = h.reverse()
+		}
+	}
+	return applyHunks(src, hunks, o)
+}
+
+// applyHunks applies hunks to src, ignoring o.reverse.
+func applyHunks(src []byte, hunks []*Hunk, o *applyOptions) ([]byte, []ApplyResult, error) {
+	srcLines := splitLines(src)
+	var buf bytes.Buffer
+	writeLine := func(text []byte, noNewline bool) {
+		buf.Write(text)
+		if !noNewline {
+			buf.WriteByte('\n')
+		}
+	}
+
+	var results []ApplyResult
+	next := 0   // index of the first src line not yet consumed
+	offset := 0 // offset at which the previous hunk applied
+	rejected := false
+	for i, h := range hunks {
+		origLine, _ := h.lineStarts()
+		start := origLine - 1
+
+		lines := h.lines()
+		var exactErr *ApplyError
+		applied := false
+	search:
+		for fuzz := 0; fuzz <= o.fuzz; fuzz++ {
+			lead, trail := fuzzContext(lines, fuzz)
+			if fuzz > 0 && lead < fuzz && trail < fuzz {
+				break // there is no more context to ignore
+			}
+			fuzzed := lines[lead : len(lines)-trail]
+			for dist := 0; dist <= o.maxOffset; dist++ {
+				for _, delta := range []int{dist, -dist} {
+					if dist == 0 && delta < 0 {
+						continue
+					}
+					at := start + offset + delta // where the hunk's first line applies
+					if at < next || at > len(srcLines) {
+						continue
+					}
+					if err := o.matchLines(srcLines, at+lead, fuzzed); err != nil {
+						if fuzz == 0 && dist == 0 {
+							exactErr = err
+						}
+						continue
+					}
+
+					for _, l := range srcLines[next : at+lead] {
+						writeLine(l.text, l.noNewline)
+					}
+					next = at + lead
+					for _, l := range fuzzed {
+						if l.op != '-' {
+							writeLine(l.text, l.noNewline)
+						}
+						if l.op != '+' {
+							next++
+						}
+					}
+					offset = at - start
+					results = append(results, ApplyResult{Hunk: i + 1, Line: at + 1, Offset: offset, Fuzz: fuzz})
+					applied = true
+					break search
+				}
+			}
+		}
+		if !applied {
+			if exactErr == nil {
+				exactErr = &ApplyError{Line: origLine, Err: ErrHunkOutOfRange}
+			}
+			exactErr.Hunk = i + 1
+			if !o.rejects {
+				return nil, nil, exactErr
+			}
+			results = append(results, ApplyResult{Hunk: i + 1, Err: exactErr})
+			rejected = true
+		}
+	}
+	for _, l := range srcLines[next:] {
+		writeLine(l.text, l.noNewline)
+	}
+	if rejected {
+		return buf.Bytes(), results, ErrHunksRejected
+	}
+	return buf.Bytes(), results, nil
+}
+
+// fuzzContext returns the numbers of leading and trailing context lines to
+// ignore in lines with the given fuzz: up to fuzz lines at each end.
+func fuzzContext(lines []hunkLine, fuzz int) (lead, trail int) {
+	for lead < fuzz && lead < len(lines) && lines[lead].op == ' ' {
+		lead++
+	}
+	for trail < fuzz && lead+trail < len(lines) && lines[len(lines)-1-trail].op == ' ' {
+		trail++
+	}
+	return lead, trail
+}
+
+// matchLines checks that the context and removed lines of lines match
+// srcLines starting at index pos. It returns an *ApplyError (with Hunk
+// unset) for the first mismatch.
+func (o *applyOptions) matchLines(srcLines []fileLine, pos int, lines []hunkLine) *ApplyError {
+	for _, l := range lines {
+		if l.op == '+' {
+			continue
+		}
+		if pos >= len(srcLines) {
+			return &ApplyError{Line: pos + 1, Want: string(l.text), Err: ErrUnexpectedEOF}
+		}
+		s := srcLines[pos]
+		if !bytes.Equal(s.text, l.text) {
+			return &ApplyError{Line: pos + 1, Want: string(l.text), Got: string(s.text), Err: ErrLineMismatch}
+		}
+		if s.noNewline != l.noNewline && !(o.ignoreFinalNewline && pos == len(srcLines)-1) {
+			return &ApplyError{Line: pos + 1, Want: string(l.text), Got: string(s.text), Err: ErrNewlineMismatch}
+		}
+		pos++
+	}
+	return nil
+}
@@ -0,0 +1,331 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func parseFileDiffString(t *testing.T, diff string) *FileDiff {
+	t.Helper()
+	fd, err := ParseFileDiff([]byte(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+func TestApplyFileDiff(t *testing.T) {
+	tests := map[string]struct {
+		src     string
+		diff    string
+		want    string
+		wantErr bool
+	}{
+		"multiple hunks": {
+			src: "a\nb\nc\nd\ne\nf\ng\n",
+			diff: `--- a/f
++++ b/f
+@@ -1,2 +1,3 @@
+ a
++a2
+ b
+@@ -6,2 +7,1 @@
+ f
+-g
+`,
+			want: "a\na2\nb\nc\nd\ne\nf\n",
+		},
+		"add to empty file": {
+			src: "",
+			diff: `--- /dev/null
++++ b/f
+@@ -0,0 +1,2 @@
++a
++b
+`,
+			want: "a\nb\n",
+		},
+		"remove newline at end of file": {
+			src: "a\nb\n",
+			diff: `--- a/f
++++ b/f
+@@ -1,2 +1,2 @@
+ a
+-b
++b
+\ No newline at end of file
+`,
+			want: "a\nb",
+		},
+		"context mismatch": {
+			src: "a\nx\n",
+			diff: `--- a/f
++++ b/f
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+`,
+			wantErr: true,
+		},
+		"start out of range": {
+			src: "a\n",
+			diff: `--- a/f
++++ b/f
+@@ -5,1 +5,1 @@
+-a
++b
+`,
+			wantErr: true,
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			got, err := ApplyFileDiff([]byte(test.src), parseFileDiffString(t, test.diff))
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("got no error, want error (result %q)", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("applied content mismatch (-want +got):\n%s", cmp.Diff(test.want, string(got)))
+			}
+		})
+	}
+}
+
+func TestApplyFileDiff_Reverse(t *testing.T) {
+	d := parseFileDiffString(t, "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n+b2\n c\n@@ -6,2 +7,1 @@\n f\n-g\n")
+	got, err := ApplyFileDiff([]byte("a\nB\nb2\nc\nd\ne\nf\n"), d, WithReverse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a\nb\nc\nd\ne\nf\ng\n"; string(got) != want {
+		t.Errorf("applied content mismatch (-want +got):\n%s", cmp.Diff(want, string(got)))
+	}
+
+	if _, err := ApplyFileDiff([]byte("a\nb\nc\nd\ne\nf\ng\n"), d, WithReverse()); err == nil {
+		t.Error("reverse applying to the original file: got no error")
+	}
+}
+
+func TestApplyFileDiff_Fuzz(t *testing.T) {
+	d := parseFileDiffString(t, `--- a/f
++++ b/f
+@@ -1,5 +1,5 @@
+ a
+ b
+-c
++C
+ d
+ e
+@@ -8,3 +8,3 @@
+ h
+-i
++I
+ j
+`)
+	// The first and last context lines of the first hunk have changed.
+	src := "A\nb\nc\nd\nE\nf\ng\nh\ni\nj\n"
+
+	if _, err := ApplyFileDiff([]byte(src), d); err == nil {
+		t.Error("without fuzz: got no error")
+	}
+	got, results, err := ApplyFileDiffWithResults([]byte(src), d, WithFuzz(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "A\nb\nC\nd\nE\nf\ng\nh\nI\nj\n"; string(got) != want {
+		t.Errorf("applied content mismatch (-want +got):\n%s", cmp.Diff(want, string(got)))
+	}
+	wantResults := []ApplyResult{{Hunk: 1, Line: 1, Fuzz: 1}, {Hunk: 2, Line: 8}}
+	if !cmp.Equal(results, wantResults) {
+		t.Errorf("results mismatch (-want +got):\n%s", cmp.Diff(wantResults, results))
+	}
+
+	// Ignoring context does not help if the removed line has changed.
+	if _, err := ApplyFileDiff([]byte("a\nb\nX\nd\ne\nf\ng\nh\ni\nj\n"), d, WithFuzz(2)); err == nil {
+		t.Error("changed removed line: got no error")
+	}
+}
+
+func TestApplyFileDiff_MaxOffset(t *testing.T) {
+	d := parseFileDiffString(t, `--- a/f
++++ b/f
+@@ -2,3 +2,3 @@
+ b
+-c
++C
+ d
+@@ -8,3 +8,3 @@
+ h
+-i
++I
+ j
+`)
+	// Three lines were inserted at the start of the file, and one removed
+	// between the hunks.
+	src := "x\ny\nz\na\nb\nc\nd\ne\ng\nh\ni\nj\n"
+
+	if _, err := ApplyFileDiff([]byte(src), d, WithMaxOffset(2)); err == nil {
+		t.Error("offset too large: got no error")
+	}
+	got, results, err := ApplyFileDiffWithResults([]byte(src), d, WithMaxOffset(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "x\ny\nz\na\nb\nC\nd\ne\ng\nh\nI\nj\n"; string(got) != want {
+		t.Errorf("applied content mismatch (-want +got):\n%s", cmp.Diff(want, string(got)))
+	}
+	wantResults := []ApplyResult{{Hunk: 1, Line: 5, Offset: 3}, {Hunk: 2, Line: 10, Offset: 2}}
+	if !cmp.Equal(results, wantResults) {
+		t.Errorf("results mismatch (-want +got):\n%s", cmp.Diff(wantResults, results))
+	}
+}
+
+func TestApplyFileDiff_Rejects(t *testing.T) {
+	d := parseFileDiffString(t, `--- a/f
++++ b/f
+@@ -1,2 +1,2 @@
+-a
++A
+ b
+@@ -4,2 +4,2 @@
+ d
+-e
++E
+`)
+	got, results, err := ApplyFileDiffWithResults([]byte("a\nb\nc\nd\nx\n"), d, WithRejects())
+	if err != ErrHunksRejected {
+		t.Fatalf("got error %v, want %v", err, ErrHunksRejected)
+	}
+	if want := "A\nb\nc\nd\nx\n"; string(got) != want {
+		t.Errorf("applied content mismatch (-want +got):\n%s", cmp.Diff(want, string(got)))
+	}
+	if len(results) != 2 || results[0].Err != nil || results[1].Err == nil {
+		t.Fatalf("got results %+v, want the second hunk to be rejected", results)
+	}
+
+	rej, err := RejectFile(d, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--- a/f\n+++ b/f\n@@ -4,2 +4,2 @@\n d\n-e\n+E\n"
+	if string(rej) != want {
+		t.Errorf("reject file mismatch (-want +got):\n%s", cmp.Diff(want, string(rej)))
+	}
+	if rej, err := RejectFile(d, results[:1]); err != nil || rej != nil {
+		t.Errorf("no rejected hunks: got %q, %v", rej, err)
+	}
+}
+
+func TestApply_Error(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		diff string
+		want ApplyError
+	}{
+		"line mismatch": {
+			src:  "a\nx\nc\n",
+			diff: "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want: ApplyError{Hunk: 1, Line: 2, Want: "b", Got: "x", Err: ErrLineMismatch},
+		},
+		"newline mismatch": {
+			src:  "a\nb",
+			diff: "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+			want: ApplyError{Hunk: 1, Line: 2, Want: "b", Got: "b", Err: ErrNewlineMismatch},
+		},
+		"unexpected end of file": {
+			src:  "a\n",
+			diff: "--- a/f\n+++ b/f\n@@ -1,2 +1,1 @@\n a\n-b\n",
+			want: ApplyError{Hunk: 1, Line: 2, Want: "b", Err: ErrUnexpectedEOF},
+		},
+		"out of range": {
+			src:  "a\nb\n",
+			diff: "--- a/f\n+++ b/f\n@@ -1,1 +1,1 @@\n-a\n+A\n@@ -1,1 +1,1 @@\n-a\n+A\n",
+			want: ApplyError{Hunk: 2, Line: 1, Err: ErrHunkOutOfRange},
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			_, err := Apply([]byte(test.src), parseFileDiffString(t, test.diff))
+			aerr, ok := err.(*ApplyError)
+			if !ok {
+				t.Fatalf("got error %v, want an *ApplyError", err)
+			}
+			if *aerr != test.want {
+				t.Errorf("got %+v, want %+v", *aerr, test.want)
+			}
+		})
+	}
+
+	err := &ApplyError{Hunk: 1, Line: 2, Err: ErrLineMismatch}
+	if got, want := err.Error(), "hunk #1: original line 2: line does not match the diff"; got != want {
+		t.Errorf("got message %q, want %q", got, want)
+	}
+}
+
+func TestApplyFileDiff_IgnoreFinalNewline(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		diff string
+		want string
+	}{
+		"file has newline, diff does not": {
+			src: "a\nb\n",
+			diff: `--- a/f
++++ b/f
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++c
+\ No newline at end of file
+`,
+			want: "a\nc",
+		},
+		"diff has newline, file does not": {
+			src: "a\nb",
+			diff: `--- a/f
++++ b/f
+@@ -1,2 +1,3 @@
+ a
+-b
++c
++d
+`,
+			want: "a\nc\nd\n",
+		},
+		"final context line": {
+			src: "a\nb",
+			diff: `--- a/f
++++ b/f
+@@ -1,2 +1,2 @@
+-a
++x
+ b
+`,
+			want: "x\nb\n",
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			fd := parseFileDiffString(t, test.diff)
+			if _, err := ApplyFileDiff([]byte(test.src), fd); err == nil {
+				t.Errorf("without WithIgnoreFinalNewline: got no error, want error")
+			}
+			got, err := ApplyFileDiff([]byte(test.src), fd, WithIgnoreFinalNewline())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("applied content mismatch (-want +got):\n%s", cmp.Diff(test.want, string(got)))
+			}
+		})
+	}
+}
@@ -0,0 +1,354 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ApplyMultiFileDiff applies ds to the files in the directory dir, like git
+// apply: it modifies, creates and deletes files, performs renames and copies,
+// and changes file modes as described by the diffs' hunks, file names and git
+// extended headers. File names are taken relative to dir, without git's "a/"
+// and "b/" prefixes. Files with a symlink mode are applied as symlinks whose
+// target is the file's content. "Only in" messages are ignored.
+//
+// Paths that would escape dir are rejected (see ValidatePaths).
+//
+// All diffs apply to the directory as it was before ApplyMultiFileDiff was
+// called. They are all applied in memory first, so if any of them fails to
+// apply (see ApplyFileDiff), or describes a binary change without a git
+// binary patch, which cannot be applied, the directory is left unchanged.
+// With WithRejects, the hunks that do not apply are written to .rej files
+// instead, and ApplyMultiFileDiff returns ErrHunksRejected after applying
+// the rest. CheckApply reports whether they would apply without changing
+// anything.
+func ApplyMultiFileDiff(dir string, ds []*FileDiff, opts ...ApplyOption) error {
+	o := newApplyOptions(opts)
+	if err := o.validatePaths(ds, dir); err != nil {
+		return err
+	}
+	var changes []*fileChange
+	rejected := false
+	for i, d := range ds {
+		c, _, err := applyToDir(dir, d, o)
+		if err == ErrHunksRejected {
+			rejected = true
+		} else if err != nil {
+			return fmt.Errorf("file #%d (%s): %s", i, d.path(), err)
+		}
+		if c != nil {
+			changes = append(changes, c)
+		}
+	}
+
+	for _, c := range changes {
+		if c.remove != "" {
+			if err := os.Remove(filepath.Join(dir, filepath.FromSlash(c.remove))); err != nil {
+				return err
+			}
+		}
+	}
+	for _, c := range changes {
+		if c.path == "" {
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(c.path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if fi, err := os.Lstat(path); err == nil && (c.symlink || fi.Mode()&os.ModeSymlink != 0) {
+			// Replace the file rather than writing through a symlink.
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+		if c.symlink {
+			if err := os.Symlink(string(c.content), path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := ioutil.WriteFile(path, c.content, c.mode); err != nil {
+			return err
+		}
+		// WriteFile does not change the mode of an existing file.
+		if err := os.Chmod(path, c.mode); err != nil {
+			return err
+		}
+	}
+	for _, c := range changes {
+		if c.reject != nil {
+			if err := ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(c.rejectPath))+".rej", c.reject, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	if rejected {
+		return ErrHunksRejected
+	}
+	return nil
+}
+
+// ValidatePaths checks that applying ds to the directory root (see
+// ApplyMultiFileDiff, whose options it accepts) only reads and writes files
+// within root. It fails if a file name of a diff is absolute or has ".."
+// components that leave root, if a directory on the way to a file is a
+// symlink, either already in root or created by one of the diffs, or if a
+// file name is not a valid relative path.
+func ValidatePaths(ds []*FileDiff, root string, opts ...ApplyOption) error {
+	return newApplyOptions(opts).validatePaths(ds, root)
+}
+
+func (o *applyOptions) validatePaths(ds []*FileDiff, root string) error {
+	symlinks := o.createdSymlinks(ds)
+	for i, d := range ds {
+		if err := o.validatePath(d, root, symlinks); err != nil {
+			return fmt.Errorf("file #%d (%s): %s", i, d.path(), err)
+		}
+	}
+	return nil
+}
+
+// createdSymlinks returns the cleaned paths of the symlinks that ds create.
+func (o *applyOptions) createdSymlinks(ds []*FileDiff) map[string]bool {
+	symlinks := make(map[string]bool)
+	for _, d := range ds {
+		if d.Type() == OnlyIn {
+			continue
+		}
+		if _, new, err := o.paths(d); err == nil && new != devNull && d.NewMode&modeTypeMask == modeSymlink {
+			symlinks[filepath.ToSlash(filepath.Clean(filepath.FromSlash(new)))] = true
+		}
+	}
+	return symlinks
+}
+
+// validatePath checks the paths of d as validatePaths does, given the
+// symlinks that the diffs create.
+func (o *applyOptions) validatePath(d *FileDiff, root string, symlinks map[string]bool) error {
+	if d.Type() == OnlyIn {
+		return nil
+	}
+	orig, new, err := o.paths(d)
+	if err != nil {
+		return err
+	}
+	for _, path := range []string{orig, new} {
+		if path == devNull {
+			continue
+		}
+		clean := filepath.ToSlash(filepath.Clean(filepath.FromSlash(path)))
+		for dir := pathDir(clean); dir != "."; dir = pathDir(dir) {
+			if symlinks[dir] {
+				return fmt.Errorf("path %q is beyond a symlink created by the diff", path)
+			}
+			fi, err := os.Lstat(filepath.Join(root, filepath.FromSlash(dir)))
+			if err == nil && fi.Mode()&os.ModeSymlink != 0 {
+				return fmt.Errorf("path %q is beyond a symlink", path)
+			}
+		}
+	}
+	return nil
+}
+
+// pathDir returns the parent of the clean, relative slash-separated path, or
+// "." if it has none.
+func pathDir(path string) string {
+	i := strings.LastIndexByte(path, '/')
+	if i == -1 {
+		return "."
+	}
+	return path[:i]
+}
+
+// A CheckResult describes whether a diff applies to a file.
+type CheckResult struct {
+	Path  string        // Path of the file, as in a diff stat
+	Hunks []ApplyResult // How each hunk applied, if they all did
+	Err   error         // Why the diff does not apply, or nil if it does
+}
+
+// CheckApply reports whether each diff in ds would apply to the files in
+// the directory dir, like git apply --check, without changing anything. It
+// returns a result per diff, in the order of ds, and checks all of them even
+// if some fail. A diff whose paths would escape dir fails without reading
+// any file (see ValidatePaths). The results of "Only in" messages are always
+// successful.
+func CheckApply(dir string, ds []*FileDiff, opts ...ApplyOption) []CheckResult {
+	o := newApplyOptions(opts)
+	symlinks := o.createdSymlinks(ds)
+	results := make([]CheckResult, len(ds))
+	for i, d := range ds {
+		if err := o.validatePath(d, dir, symlinks); err != nil {
+			results[i] = CheckResult{Path: d.path(), Err: err}
+			continue
+		}
+		_, hunks, err := applyToDir(dir, d, o)
+		results[i] = CheckResult{Path: d.path(), Hunks: hunks, Err: err}
+	}
+	return results
+}
+
+// CheckApplyFileDiff reports whether d would apply to src, the contents of
+// the original file.
+func CheckApplyFileDiff(src []byte, d *FileDiff, opts ...ApplyOption) CheckResult {
+	_, hunks, err := ApplyFileDiffWithResults(src, d, opts...)
+	return CheckResult{Path: d.path(), Hunks: hunks, Err: err}
+}
+
+// A fileChange is a change to make to a directory to apply a diff.
+type fileChange struct {
+	remove string // path of a file to remove, if any
+
+	// path is the file to write, if any, with its content and mode.
+	path    string
+	content []byte
+	mode    os.FileMode
+	symlink bool
+
+	// reject is the content of the .rej file to write for rejectPath, if
+	// some hunks were rejected.
+	reject     []byte
+	rejectPath string
+}
+
+// applyToDir applies d in memory to the files in dir and returns the change
+// to make to dir, or nil if there is none.
+func applyToDir(dir string, d *FileDiff, o *applyOptions) (*fileChange, []ApplyResult, error) {
+	if d.Type() == OnlyIn {
+		return nil, nil, nil
+	}
+	if d.IsBinary() && d.BinaryPatch == nil {
+		return nil, nil, errors.New("cannot apply binary changes without a GIT binary patch")
+	}
+	if o.reverse {
+		var err error
+		if d, err = ReverseFileDiff(d); err != nil {
+			return nil, nil, err
+		}
+	}
+	typ := d.Type()
+	orig, new, err := o.paths(d)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var src []byte
+	var srcMode os.FileMode = 0644
+	if typ != Added {
+		src, srcMode, err = readApplySource(filepath.Join(dir, filepath.FromSlash(orig)))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	var content []byte
+	var results []ApplyResult
+	if d.BinaryPatch != nil {
+		content, err = applyBinaryPatch(src, d, false)
+	} else {
+		content, results, err = applyHunks(src, d.Hunks, o)
+	}
+	c := &fileChange{}
+	if err == ErrHunksRejected {
+		if c.reject, err = RejectFile(d, results); err != nil {
+			return nil, nil, err
+		}
+		c.rejectPath = new
+		if typ == Deleted {
+			// Keep the file, with the hunks that did apply.
+			c.path, c.content, c.mode, c.rejectPath = orig, content, srcMode&^os.ModeSymlink, orig
+			c.symlink = srcMode&os.ModeSymlink != 0
+			return c, results, ErrHunksRejected
+		}
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	switch typ {
+	case Deleted:
+		if len(content) > 0 {
+			return nil, nil, errors.New("deleted file is not empty after applying the diff")
+		}
+		c.remove = orig
+		return c, results, nil
+	case Renamed:
+		c.remove = orig
+	}
+	c.path, c.content = new, content
+	c.mode, c.symlink = srcMode&^os.ModeSymlink, srcMode&os.ModeSymlink != 0
+	if d.NewMode != 0 {
+		c.mode = os.FileMode(d.NewMode & 0777)
+		c.symlink = d.NewMode&modeTypeMask == modeSymlink
+	}
+	if c.reject != nil {
+		return c, results, ErrHunksRejected
+	}
+	return c, results, nil
+}
+
+// paths returns the paths, relative to the directory to apply to, of the
+// original and new files of d, or /dev/null for a missing side.
+func (o *applyOptions) paths(d *FileDiff) (orig, new string, err error) {
+	orig, new = d.OrigName, d.NewName
+	if o.stripLevel < 0 {
+		orig, new = d.paths()
+	}
+	for _, path := range []*string{&orig, &new} {
+		if *path == devNull {
+			continue
+		}
+		if o.stripLevel > 0 {
+			stripped, ok := stripPath(*path, o.stripLevel)
+			if !ok {
+				return "", "", fmt.Errorf("cannot strip %d components from path %q", o.stripLevel, *path)
+			}
+			*path = stripped
+		}
+		if !isLocalPath(*path) {
+			return "", "", fmt.Errorf("invalid path %q", *path)
+		}
+	}
+	return orig, new, nil
+}
+
+// stripPath removes the first n components of the slash-separated path,
+// like patch -pN. It reports false if the path has too few components.
+func stripPath(path string, n int) (string, bool) {
+	for i := 0; i < n; i++ {
+		j := strings.IndexByte(path, '/')
+		if j == -1 {
+			return "", false
+		}
+		path = strings.TrimLeft(path[j+1:], "/")
+	}
+	return path, path != ""
+}
+
+// readApplySource returns the contents and mode of the file at path. The
+// contents of a symlink are its target.
+func readApplySource(path string) ([]byte, os.FileMode, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		return []byte(target), os.ModeSymlink | 0777, err
+	}
+	content, err := ioutil.ReadFile(path)
+	return content, fi.Mode().Perm(), err
+}
+
+// isLocalPath reports whether the slash-separated path stays within the
+// directory it is relative to.
+func isLocalPath(path string) bool {
+	if path == "" || strings.HasPrefix(path, "/") || filepath.IsAbs(filepath.FromSlash(path)) {
+		return false
+	}
+	clean := filepath.ToSlash(filepath.Clean(filepath.FromSlash(path)))
+	return clean != ".." && !strings.HasPrefix(clean, "../")
+}
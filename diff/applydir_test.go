@@ -0,0 +1,398 @@
+package diff
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const applyDirTestDiff = `diff --git a/changed.txt b/changed.txt
+index 1111111..2222222 100644
+--- a/changed.txt
++++ b/changed.txt
+@@ -1,2 +1,2 @@
+ a
+-b
++B
+diff --git a/new.sh b/new.sh
+new file mode 100755
+index 0000000..3333333
+--- /dev/null
++++ b/new.sh
+@@ -0,0 +1 @@
++echo hi
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+index 4444444..0000000
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/old.txt b/dir/renamed.txt
+similarity index 50%
+rename from old.txt
+rename to dir/renamed.txt
+index 5555555..6666666 100644
+--- a/old.txt
++++ b/dir/renamed.txt
+@@ -1,2 +1,2 @@
+ x
+-y
++z
+diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+`
+
+func TestApplyMultiFileDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTree(t, dir, map[string]string{
+		"changed.txt": "a\nb\n",
+		"gone.txt":    "bye\n",
+		"old.txt":     "x\ny\n",
+		"run.sh":      "exit\n",
+	})
+	ds, err := ParseMultiFileDiff([]byte(applyDirTestDiff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ApplyMultiFileDiff(dir, ds); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"changed.txt":     "a\nB\n",
+		"new.sh":          "echo hi\n",
+		"dir/renamed.txt": "x\nz\n",
+		"run.sh":          "exit\n",
+	} {
+		got, err := ioutil.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Error(err)
+		} else if string(got) != want {
+			t.Errorf("%s: got %q, want %q", path, got, want)
+		}
+	}
+	for _, path := range []string{"gone.txt", "old.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); !os.IsNotExist(err) {
+			t.Errorf("%s: got error %v, want the file to be removed", path, err)
+		}
+	}
+	for path, want := range map[string]os.FileMode{"new.sh": 0755, "run.sh": 0755, "changed.txt": 0644} {
+		if fi, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			t.Error(err)
+		} else if got := fi.Mode().Perm(); got != want {
+			t.Errorf("%s: got mode %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestApplyMultiFileDiff_Reverse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	orig := map[string]string{
+		"changed.txt": "a\nb\n",
+		"gone.txt":    "bye\n",
+		"old.txt":     "x\ny\n",
+		"run.sh":      "exit\n",
+	}
+	writeTree(t, dir, orig)
+	ds, err := ParseMultiFileDiff([]byte(applyDirTestDiff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ApplyMultiFileDiff(dir, ds); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyMultiFileDiff(dir, ds, WithReverse()); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range orig {
+		got, err := ioutil.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Error(err)
+		} else if string(got) != want {
+			t.Errorf("%s: got %q, want %q", path, got, want)
+		}
+	}
+	for _, path := range []string{"new.sh", "dir/renamed.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); !os.IsNotExist(err) {
+			t.Errorf("%s: got error %v, want the file to be removed", path, err)
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "run.sh")); err != nil {
+		t.Error(err)
+	} else if got := fi.Mode().Perm(); got != 0644 {
+		t.Errorf("run.sh: got mode %v, want %v", got, os.FileMode(0644))
+	}
+}
+
+func TestApplyMultiFileDiff_Error(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// old.txt does not match the diff, so nothing may be changed.
+	writeTree(t, dir, map[string]string{
+		"changed.txt": "a\nb\n",
+		"gone.txt":    "bye\n",
+		"old.txt":     "x\nq\n",
+		"run.sh":      "exit\n",
+	})
+	ds, err := ParseMultiFileDiff([]byte(applyDirTestDiff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ApplyMultiFileDiff(dir, ds); err == nil {
+		t.Fatal("got no error")
+	}
+	if got, err := ioutil.ReadFile(filepath.Join(dir, "changed.txt")); err != nil || string(got) != "a\nb\n" {
+		t.Errorf("changed.txt was modified: %q, %v", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.sh")); !os.IsNotExist(err) {
+		t.Errorf("new.sh was created")
+	}
+
+	escape := parseFileDiffString(t, "--- a/../escape.txt\n+++ b/../escape.txt\n@@ -0,0 +1 @@\n+x\n")
+	if err := ApplyMultiFileDiff(dir, []*FileDiff{escape}); err == nil {
+		t.Error("path outside the directory: got no error")
+	}
+}
+
+func TestCheckApply(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// old.txt does not match the diff.
+	files := map[string]string{
+		"changed.txt": "a\nb\n",
+		"gone.txt":    "bye\n",
+		"old.txt":     "x\nq\n",
+		"run.sh":      "exit\n",
+	}
+	writeTree(t, dir, files)
+	ds, err := ParseMultiFileDiff([]byte(applyDirTestDiff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := CheckApply(dir, ds)
+	if len(results) != len(ds) {
+		t.Fatalf("got %d results, want %d", len(results), len(ds))
+	}
+	for i, r := range results {
+		if wantErr := r.Path == "old.txt => dir/renamed.txt"; (r.Err != nil) != wantErr {
+			t.Errorf("result #%d (%s): got error %v", i, r.Path, r.Err)
+		}
+	}
+	if err, ok := results[3].Err.(*ApplyError); !ok || err.Err != ErrLineMismatch {
+		t.Errorf("rename: got error %v, want a line mismatch", results[3].Err)
+	}
+	if want := []ApplyResult{{Hunk: 1, Line: 1}}; !cmp.Equal(results[0].Hunks, want) {
+		t.Errorf("changed.txt: hunk results mismatch (-want +got):\n%s", cmp.Diff(want, results[0].Hunks))
+	}
+
+	for path, want := range files {
+		if got, err := ioutil.ReadFile(filepath.Join(dir, path)); err != nil || string(got) != want {
+			t.Errorf("%s was modified: %q, %v", path, got, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.sh")); !os.IsNotExist(err) {
+		t.Errorf("new.sh was created")
+	}
+
+	r := CheckApplyFileDiff([]byte("a\nb\n"), ds[0])
+	if r.Err != nil || r.Path != "changed.txt" {
+		t.Errorf("got %+v, want changed.txt to apply", r)
+	}
+}
+
+func TestCheckApply_Symlink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outside, err := ioutil.TempDir("", "go-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outside)
+	writeTree(t, outside, map[string]string{"secret.txt": "a\n"})
+	writeTree(t, dir, map[string]string{"f.txt": "a\n"})
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Skip(err)
+	}
+	// The diff of link/secret.txt matches the file outside dir.
+	ds, err := ParseMultiFileDiff([]byte(`--- a/link/secret.txt
++++ b/link/secret.txt
+@@ -1,1 +1,1 @@
+-a
++b
+--- a/f.txt
++++ b/f.txt
+@@ -1,1 +1,1 @@
+-a
++b
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := CheckApply(dir, ds)
+	if results[0].Err == nil || results[0].Hunks != nil {
+		t.Errorf("link/secret.txt: got %+v, want an error about the symlink", results[0])
+	}
+	if results[1].Err != nil {
+		t.Errorf("f.txt: got error %v", results[1].Err)
+	}
+}
+
+func TestApplyMultiFileDiff_Rejects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTree(t, dir, map[string]string{"f.txt": "a\nb\nc\nd\nx\n"})
+	ds, err := ParseMultiFileDiff([]byte(`diff --git a/f.txt b/f.txt
+--- a/f.txt
++++ b/f.txt
+@@ -1,2 +1,2 @@
+-a
++A
+ b
+@@ -4,2 +4,2 @@
+ d
+-e
++E
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ApplyMultiFileDiff(dir, ds, WithRejects()); err != ErrHunksRejected {
+		t.Fatalf("got error %v, want %v", err, ErrHunksRejected)
+	}
+	for path, want := range map[string]string{
+		"f.txt":     "A\nb\nc\nd\nx\n",
+		"f.txt.rej": "diff --git a/f.txt b/f.txt\n--- a/f.txt\n+++ b/f.txt\n@@ -4,2 +4,2 @@\n d\n-e\n+E\n",
+	} {
+		got, err := ioutil.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Error(err)
+		} else if string(got) != want {
+			t.Errorf("%s mismatch (-want +got):\n%s", path, cmp.Diff(want, string(got)))
+		}
+	}
+}
+
+func TestApplyMultiFileDiff_StripLevel(t *testing.T) {
+	tests := map[string]struct {
+		name    string
+		level   int
+		wantErr bool
+	}{
+		"git prefix":      {name: "a/src/f.txt", level: 1},
+		"deeper":          {name: "x/y/src/f.txt", level: 2},
+		"no prefix":       {name: "src/f.txt", level: 0},
+		"absolute":        {name: "/tmp/x/src/f.txt", level: 3},
+		"double slash":    {name: "x//src/f.txt", level: 1},
+		"too few":         {name: "src/f.txt", level: 2, wantErr: true},
+		"absolute at -p0": {name: "/src/f.txt", level: 0, wantErr: true},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "go-diff")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			writeTree(t, dir, map[string]string{"src/f.txt": "a\n"})
+			d := parseFileDiffString(t, "--- "+test.name+"\n+++ "+test.name+"\n@@ -1 +1 @@\n-a\n+b\n")
+
+			err = ApplyMultiFileDiff(dir, []*FileDiff{d}, WithStripLevel(test.level))
+			if test.wantErr {
+				if err == nil {
+					t.Error("got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := ioutil.ReadFile(filepath.Join(dir, "src", "f.txt")); err != nil || string(got) != "b\n" {
+				t.Errorf("got %q, %v, want the file to be changed", got, err)
+			}
+		})
+	}
+}
+
+func TestValidatePaths(t *testing.T) {
+	root, err := ioutil.TempDir("", "go-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writeTree(t, root, map[string]string{"src/f.txt": "a\n"})
+	if err := os.Symlink(os.TempDir(), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	edit := func(name string) string {
+		return "--- a/" + name + "\n+++ b/" + name + "\n@@ -1 +1 @@\n-a\n+b\n"
+	}
+	tests := map[string]struct {
+		diff    string
+		wantErr bool
+	}{
+		"inside":         {diff: edit("src/f.txt")},
+		"dot dot inside": {diff: edit("src/../src/f.txt")},
+		"dot dot":        {diff: edit("../f.txt"), wantErr: true},
+		"nested dot dot": {diff: edit("src/../../f.txt"), wantErr: true},
+		"absolute":       {diff: "--- /etc/passwd\n+++ /etc/passwd\n@@ -1 +1 @@\n-a\n+b\n", wantErr: true},
+		"symlink":        {diff: edit("link/f.txt"), wantErr: true},
+		"created symlink": {
+			diff: "diff --git a/out b/out\nnew file mode 120000\n--- /dev/null\n+++ b/out\n@@ -0,0 +1 @@\n+/etc\n\\ No newline at end of file\n" +
+				"diff --git a/out/passwd b/out/passwd\nnew file mode 100644\n--- /dev/null\n+++ b/out/passwd\n@@ -0,0 +1 @@\n+x\n",
+			wantErr: true,
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			ds, err := ParseMultiFileDiff([]byte(test.diff))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ValidatePaths(ds, root)
+			if test.wantErr && err == nil {
+				t.Error("got no error")
+			} else if !test.wantErr && err != nil {
+				t.Errorf("got error %v", err)
+			}
+		})
+	}
+
+	ds, err := ParseMultiFileDiff([]byte(edit("link/f.txt")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyMultiFileDiff(root, ds); err == nil {
+		t.Error("ApplyMultiFileDiff through a symlink: got no error")
+	}
+}
@@ -0,0 +1,479 @@
+package diff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A BinaryPatch is the payload of a git binary patch (the lines that follow
+// "GIT binary patch" in a diff produced with git diff --binary).
+type BinaryPatch struct {
+	// the hunk that turns the original file into the new one
+	Forward *BinaryHunk
+	// the hunk that turns the new file back into the original one (nil if
+	// not present)
+	Reverse *BinaryHunk
+}
+
+// BinaryHunkType is the encoding of a BinaryHunk.
+type BinaryHunkType int
+
+const (
+	// BinaryLiteral is a hunk whose data is the full contents of the file
+	// it produces.
+	BinaryLiteral BinaryHunkType = iota
+	// BinaryDelta is a hunk whose data is a git delta against the file it
+	// applies to.
+	BinaryDelta
+)
+
+func (t BinaryHunkType) String() string {
+	switch t {
+	case BinaryLiteral:
+		return "literal"
+	case BinaryDelta:
+		return "delta"
+	}
+	return fmt.Sprintf("BinaryHunkType(%d)", int(t))
+}
+
+// A BinaryHunk is one half of a git binary patch, such as
+//
+//	literal 7
+//	OcmZQzWKK*<P5}S|@Bxni
+//
+// Its data is stored decoded and inflated; the size git prints after the
+// type is len(Data).
+type BinaryHunk struct {
+	// whether Data is the new contents (BinaryLiteral) or a delta
+	// (BinaryDelta)
+	Type BinaryHunkType
+	// the inflated data of the hunk
+	Data []byte
+}
+
+// ErrBadBinaryPatch is when the payload of a git binary patch is malformed.
+var ErrBadBinaryPatch = errors.New("bad GIT binary patch")
+
+// base85Alphabet is the alphabet of git's base85 encoding, in order of value.
+const base85Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz!#$%&()*+-;<=>?@^_`{|}~"
+
+// base85Values maps each byte of base85Alphabet to its value plus one (0
+// for bytes outside the alphabet).
+var base85Values [256]byte
+
+func init() {
+	for i := 0; i < len(base85Alphabet); i++ {
+		base85Values[base85Alphabet[i]] = byte(i + 1)
+	}
+}
+
+// parseBinaryPatch sets fd.BinaryPatch from the lines following "GIT binary
+// patch" in fd's extended headers, if any. It is called with r positioned
+// just after the extended headers, which it uses to report the position of
+// a malformed line.
+func (r *FileDiffReader) parseBinaryPatch(fd *FileDiff) error {
+	start := -1
+	for i := len(fd.Extended) - len(gitExtendedHeaders(fd.Extended)); i < len(fd.Extended); i++ {
+		if fd.Extended[i] == "GIT binary patch" {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	bp, i, ok := decodeBinaryPatch(fd.Extended[start:])
+	if !ok {
+		// Work out the position of the bad line from the end of the
+		// extended headers.
+		line, offset := r.line, r.offset
+		for j := len(fd.Extended) - 1; j >= start+i; j-- {
+			line--
+			offset -= int64(len(fd.Extended[j]))
+		}
+		return &ParseError{line + 1, offset, ErrBadBinaryPatch}
+	}
+	fd.BinaryPatch = bp
+	return nil
+}
+
+// decodeBinaryPatch decodes the forward and (optional) reverse hunks of a
+// git binary patch from lines. Lines after the hunks, such as the signature
+// of a patch email, are ignored. If it fails, it returns the index of the
+// line at fault.
+func decodeBinaryPatch(lines []string) (*BinaryPatch, int, bool) {
+	forward, n, ok := decodeBinaryHunk(lines)
+	if !ok {
+		return nil, n, false
+	}
+	bp := &BinaryPatch{Forward: forward}
+	if rest := lines[n:]; len(rest) > 0 && isBinaryHunkHeader(rest[0]) {
+		reverse, m, ok := decodeBinaryHunk(rest)
+		if !ok {
+			return nil, n + m, false
+		}
+		bp.Reverse = reverse
+	}
+	return bp, 0, true
+}
+
+// isBinaryHunkHeader reports whether line starts a binary hunk.
+func isBinaryHunkHeader(line string) bool {
+	return strings.HasPrefix(line, "literal ") || strings.HasPrefix(line, "delta ")
+}
+
+// decodeBinaryHunk decodes the binary hunk at the start of lines: a
+// "literal <size>" or "delta <size>" line, base85 data lines, and the empty
+// line that ends the hunk (which may be missing at the end of lines). It
+// returns the hunk and the number of lines it spans or, if it fails, the
+// index of the line at fault.
+func decodeBinaryHunk(lines []string) (*BinaryHunk, int, bool) {
+	if len(lines) == 0 {
+		return nil, 0, false
+	}
+	h := &BinaryHunk{}
+	var sizeStr string
+	switch {
+	case strings.HasPrefix(lines[0], "literal "):
+		h.Type, sizeStr = BinaryLiteral, lines[0][len("literal "):]
+	case strings.HasPrefix(lines[0], "delta "):
+		h.Type, sizeStr = BinaryDelta, lines[0][len("delta "):]
+	default:
+		return nil, 0, false
+	}
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil || size < 0 {
+		return nil, 0, false
+	}
+
+	var compressed []byte
+	i := 1
+	for ; i < len(lines) && lines[i] != ""; i++ {
+		b, ok := decodeBase85Line(lines[i])
+		if !ok {
+			return nil, i, false
+		}
+		compressed = append(compressed, b...)
+	}
+
+	// The data must inflate to exactly size bytes.
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, 1, false
+	}
+	h.Data = make([]byte, size)
+	if _, err := io.ReadFull(zr, h.Data); err != nil {
+		return nil, 1, false
+	}
+	if n, err := zr.Read(make([]byte, 1)); n > 0 || err != io.EOF {
+		return nil, 1, false
+	}
+	if i < len(lines) {
+		i++ // the empty line
+	}
+	return h, i, true
+}
+
+// decodeBase85Line decodes one data line of a binary hunk: a character
+// giving the number of decoded bytes ('A' to 'Z' for 1 to 26, 'a' to 'z' for
+// 27 to 52), followed by the bytes in git's base85 encoding, 4 bytes (padded
+// with zeros) to each 5 characters.
+func decodeBase85Line(line string) ([]byte, bool) {
+	if line == "" {
+		return nil, false
+	}
+	var n int
+	switch c := line[0]; {
+	case 'A' <= c && c <= 'Z':
+		n = int(c-'A') + 1
+	case 'a' <= c && c <= 'z':
+		n = int(c-'a') + 27
+	default:
+		return nil, false
+	}
+	enc := line[1:]
+	if len(enc) != (n+3)/4*5 {
+		return nil, false
+	}
+
+	out := make([]byte, 0, len(enc)/5*4)
+	for ; enc != ""; enc = enc[5:] {
+		var acc uint64
+		for j := 0; j < 5; j++ {
+			v := base85Values[enc[j]]
+			if v == 0 {
+				return nil, false
+			}
+			acc = acc*85 + uint64(v-1)
+		}
+		if acc > 0xffffffff {
+			return nil, false
+		}
+		out = append(out, byte(acc>>24), byte(acc>>16), byte(acc>>8), byte(acc))
+	}
+	return out[:n], true
+}
+
+// PrintBinaryPatch prints bp as the payload of a git binary patch, as git
+// diff --binary prints it after the "GIT binary patch" line: each hunk's
+// type and size, its zlib-compressed data in git's base85 encoding, 52 bytes
+// to a line, and an empty line.
+//
+// Since the data is compressed with compress/zlib rather than with git's
+// zlib, the encoded lines can differ from git's, but they decode to the same
+// data. A FileDiff that was parsed keeps its original lines in Extended, and
+// PrintFileDiff prints those instead.
+func PrintBinaryPatch(bp *BinaryPatch) ([]byte, error) {
+	if bp.Forward == nil {
+		return nil, errors.New("binary patch has no forward hunk")
+	}
+	var buf bytes.Buffer
+	for _, h := range []*BinaryHunk{bp.Forward, bp.Reverse} {
+		if h == nil {
+			continue
+		}
+		if err := printBinaryHunk(&buf, h); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// printBinaryHunk writes h to w in the format read by decodeBinaryHunk.
+func printBinaryHunk(w io.Writer, h *BinaryHunk) error {
+	if h.Type != BinaryLiteral && h.Type != BinaryDelta {
+		return fmt.Errorf("invalid binary hunk type %s", h.Type)
+	}
+	if _, err := fmt.Fprintf(w, "%s %d\n", h.Type, len(h.Data)); err != nil {
+		return err
+	}
+
+	// git compresses binary patches with Z_BEST_SPEED.
+	var compressed bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&compressed, zlib.BestSpeed)
+	if err != nil {
+		return err
+	}
+	if _, err := zw.Write(h.Data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	for data := compressed.Bytes(); len(data) > 0; {
+		n := len(data)
+		if n > 52 {
+			n = 52
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", encodeBase85Line(data[:n])); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// encodeBase85Line encodes data (at most 52 bytes) as one data line of a
+// binary hunk, the inverse of decodeBase85Line.
+func encodeBase85Line(data []byte) string {
+	out := make([]byte, 1, 1+(len(data)+3)/4*5)
+	if len(data) <= 26 {
+		out[0] = 'A' + byte(len(data)-1)
+	} else {
+		out[0] = 'a' + byte(len(data)-27)
+	}
+	for i := 0; i < len(data); i += 4 {
+		var acc uint32
+		for j := 0; j < 4; j++ {
+			acc <<= 8
+			if i+j < len(data) {
+				acc |= uint32(data[i+j])
+			}
+		}
+		var group [5]byte
+		for j := 4; j >= 0; j-- {
+			group[j] = base85Alphabet[acc%85]
+			acc /= 85
+		}
+		out = append(out, group[:]...)
+	}
+	return string(out)
+}
+
+// ErrBadBinaryDelta is when a git binary delta is malformed or does not
+// apply to the content it is applied to.
+var ErrBadBinaryDelta = errors.New("bad binary delta")
+
+// ApplyBinaryDelta applies delta, the data of a BinaryDelta hunk, to src and
+// returns the result. A git delta starts with the sizes of src and of the
+// result, and is followed by instructions that either copy a range of src
+// or insert bytes given in the delta. If src does not have the size the
+// delta expects, or the delta is malformed, ApplyBinaryDelta returns
+// ErrBadBinaryDelta.
+func ApplyBinaryDelta(src, delta []byte) ([]byte, error) {
+	srcSize, delta, ok := readDeltaSize(delta)
+	if !ok || srcSize != uint64(len(src)) {
+		return nil, ErrBadBinaryDelta
+	}
+	dstSize, delta, ok := readDeltaSize(delta)
+	if !ok {
+		return nil, ErrBadBinaryDelta
+	}
+
+	dst := make([]byte, 0, dstSize)
+	for len(delta) > 0 {
+		cmd := delta[0]
+		delta = delta[1:]
+		switch {
+		case cmd&0x80 != 0:
+			// Copy: bits 0-3 say which bytes of the offset follow, and
+			// bits 4-6 which bytes of the size, least significant first.
+			var offset, size uint64
+			for i := uint(0); i < 7; i++ {
+				if cmd&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, ErrBadBinaryDelta
+				}
+				if i < 4 {
+					offset |= uint64(delta[0]) << (8 * i)
+				} else {
+					size |= uint64(delta[0]) << (8 * (i - 4))
+				}
+				delta = delta[1:]
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(src)) || uint64(len(dst))+size > dstSize {
+				return nil, ErrBadBinaryDelta
+			}
+			dst = append(dst, src[offset:offset+size]...)
+		case cmd != 0:
+			// Insert the next cmd bytes.
+			n := int(cmd)
+			if n > len(delta) || uint64(len(dst)+n) > dstSize {
+				return nil, ErrBadBinaryDelta
+			}
+			dst = append(dst, delta[:n]...)
+			delta = delta[n:]
+		default:
+			return nil, ErrBadBinaryDelta // reserved by git
+		}
+	}
+	if uint64(len(dst)) != dstSize {
+		return nil, ErrBadBinaryDelta
+	}
+	return dst, nil
+}
+
+// readDeltaSize reads a size at the start of a git delta, encoded 7 bits to
+// a byte, least significant first, with the high bit set on all bytes but
+// the last. It returns the size and the rest of the delta.
+func readDeltaSize(delta []byte) (uint64, []byte, bool) {
+	var size uint64
+	for i := uint(0); i < 64; i += 7 {
+		if len(delta) == 0 {
+			return 0, nil, false
+		}
+		b := delta[0]
+		delta = delta[1:]
+		size |= uint64(b&0x7f) << i
+		if b&0x80 == 0 {
+			return size, delta, true
+		}
+	}
+	return 0, nil, false
+}
+
+// apply applies h to src: it returns the data of a literal hunk, and the
+// result of applying the delta of a delta hunk to src.
+func (h *BinaryHunk) apply(src []byte) ([]byte, error) {
+	switch h.Type {
+	case BinaryLiteral:
+		return append([]byte(nil), h.Data...), nil
+	case BinaryDelta:
+		return ApplyBinaryDelta(src, h.Data)
+	}
+	return nil, fmt.Errorf("invalid binary hunk type %s", h.Type)
+}
+
+// applyBinaryPatch applies the binary patch of d to src, or its reverse
+// hunk if reverse is true. If the "index" line of d gives full object names,
+// they must match src and the result, as git apply checks.
+func applyBinaryPatch(src []byte, d *FileDiff, reverse bool) ([]byte, error) {
+	h := d.BinaryPatch.Forward
+	origName, newName := d.binaryObjectNames()
+	if reverse {
+		h = d.BinaryPatch.Reverse
+		origName, newName = newName, origName
+	}
+	if h == nil {
+		return nil, errors.New("binary patch has no hunk to apply")
+	}
+	if !blobMatches(src, origName) {
+		return nil, ErrBlobMismatch
+	}
+	content, err := h.apply(src)
+	if err != nil {
+		return nil, err
+	}
+	if !blobMatches(content, newName) {
+		return nil, ErrBlobMismatch
+	}
+	return content, nil
+}
+
+// binaryObjectNames returns the object names on the "index" line of d, if
+// they are full (unabbreviated) SHA-1 names. Otherwise, it returns empty
+// names.
+func (d *FileDiff) binaryObjectNames() (orig, new string) {
+	for _, xheader := range gitExtendedHeaders(d.Extended) {
+		if !strings.HasPrefix(xheader, "index ") {
+			continue
+		}
+		fields := strings.Fields(xheader)
+		if len(fields) < 2 {
+			continue
+		}
+		names := strings.Split(fields[1], "..")
+		if len(names) == 2 && isFullObjectName(names[0]) && isFullObjectName(names[1]) {
+			return names[0], names[1]
+		}
+	}
+	return "", ""
+}
+
+// isFullObjectName reports whether name is a full SHA-1 object name.
+func isFullObjectName(name string) bool {
+	if len(name) != 2*sha1.Size {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+// blobMatches reports whether content is the blob with the given object
+// name. An empty name, or the all-zero name git uses for a missing file,
+// matches any content.
+func blobMatches(content []byte, name string) bool {
+	if name == "" || strings.Trim(name, "0") == "" {
+		return true
+	}
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil)) == name
+}
@@ -0,0 +1,277 @@
+package diff
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseFileDiff_BinaryPatch(t *testing.T) {
+	tests := map[string]struct {
+		filename string
+		want     *BinaryPatch
+	}{
+		"literal": {
+			filename: "sample_format_patch_binary.diff",
+			want: &BinaryPatch{
+				Forward: &BinaryHunk{Type: BinaryLiteral, Data: []byte("\x00\x01\x03abcd")},
+				Reverse: &BinaryHunk{Type: BinaryLiteral, Data: []byte("\x00\x01\x02abc")},
+			},
+		},
+		"delta": {
+			filename: "sample_binary_delta.diff",
+			want: &BinaryPatch{
+				Forward: &BinaryHunk{Type: BinaryDelta, Data: []byte("\xac\x02\xac\x02\x90d\x04\x00\x00\x00\x00\x91h\xc4")},
+				Reverse: &BinaryHunk{Type: BinaryDelta, Data: []byte("\xac\x02\xac\x02\x90d\x04Z\\.\x82\x91h\xc4")},
+			},
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			diffData, err := ioutil.ReadFile(filepath.Join("testdata", test.filename))
+			if err != nil {
+				t.Fatal(err)
+			}
+			diffs, err := ParseMultiFileDiff(diffData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := diffs[0].BinaryPatch; !cmp.Equal(got, test.want) {
+				t.Errorf("binary patch mismatch (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+
+			// The payload lines are kept in the extended headers, so the
+			// patch prints as it was read.
+			printed, err := PrintFileDiff(diffs[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			reparsed, err := ParseFileDiff(printed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(reparsed.BinaryPatch, test.want) {
+				t.Errorf("binary patch after round trip mismatch (-want +got):\n%s", cmp.Diff(test.want, reparsed.BinaryPatch))
+			}
+		})
+	}
+}
+
+func TestParseFileDiff_BadBinaryPatch(t *testing.T) {
+	const header = "diff --git a/f.bin b/f.bin\nindex 1111111..2222222 100644\nGIT binary patch\n"
+	tests := map[string]struct {
+		payload string
+		want    error
+	}{
+		"bad hunk header": {
+			payload: "copy 7\nOcmZQzWKK*<P5}S|@Bxni\n\n",
+			want:    &ParseError{4, 71, ErrBadBinaryPatch},
+		},
+		"bad base85 character": {
+			payload: "literal 7\nOcmZQzWKK*<P5}S|@Bxn\"\n\n",
+			want:    &ParseError{5, 80, ErrBadBinaryPatch},
+		},
+		"wrong line length": {
+			payload: "literal 7\nAcmZQzWKK*<P5}S|@Bxni\n\n",
+			want:    &ParseError{5, 80, ErrBadBinaryPatch},
+		},
+		"wrong size": {
+			payload: "literal 8\nOcmZQzWKK*<P5}S|@Bxni\n\n",
+			want:    &ParseError{5, 80, ErrBadBinaryPatch},
+		},
+		"bad reverse hunk": {
+			payload: "literal 7\nOcmZQzWKK*<P5}S|@Bxni\n\nliteral 6\nNcmZQzWJ*j*1^@zG0V)6\n\n",
+			want:    &ParseError{8, 110, ErrBadBinaryPatch},
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			_, err := ParseFileDiff([]byte(header + test.payload))
+			if !reflect.DeepEqual(err, test.want) {
+				t.Errorf("got error %#v, want %#v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestPrintBinaryPatch(t *testing.T) {
+	// The compressed data of "literal 7" in sample_format_patch_binary.diff
+	// encodes to the line git printed.
+	compressed, ok := decodeBase85Line("OcmZQzWKK*<P5}S|@Bxni")
+	if !ok {
+		t.Fatal("could not decode line")
+	}
+	if got, want := encodeBase85Line(compressed), "OcmZQzWKK*<P5}S|@Bxni"; got != want {
+		t.Errorf("got line %q, want %q", got, want)
+	}
+
+	long := make([]byte, 300)
+	for i := range long {
+		long[i] = byte(i * 7)
+	}
+	d := &FileDiff{
+		OrigName: "a/f.bin",
+		NewName:  "b/f.bin",
+		Extended: []string{"diff --git a/f.bin b/f.bin", "index 1111111..2222222 100644"},
+		BinaryPatch: &BinaryPatch{
+			Forward: &BinaryHunk{Type: BinaryLiteral, Data: long},
+			Reverse: &BinaryHunk{Type: BinaryDelta, Data: []byte("\xac\x02\xac\x02\x90d\x04Z\\.\x82\x91h\xc4")},
+		},
+	}
+	printed, err := PrintFileDiff(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseFileDiff(printed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(got.BinaryPatch, d.BinaryPatch) {
+		t.Errorf("binary patch mismatch (-want +got):\n%s", cmp.Diff(d.BinaryPatch, got.BinaryPatch))
+	}
+
+	// Printing the parsed diff keeps the lines it was read from.
+	reprinted, err := PrintFileDiff(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reprinted) != string(printed) {
+		t.Errorf("reprinted diff mismatch (-want +got):\n%s", cmp.Diff(string(printed), string(reprinted)))
+	}
+
+	if _, err := PrintBinaryPatch(&BinaryPatch{}); err == nil {
+		t.Error("got no error for a binary patch without a forward hunk")
+	}
+}
+
+func TestApplyBinaryDelta(t *testing.T) {
+	src := []byte("hello world")
+	tests := map[string]struct {
+		delta   string
+		want    string
+		wantErr bool
+	}{
+		"copy and insert": {
+			// Copy 6 bytes at offset 0, then insert "there".
+			delta: "\x0b\x0b\x91\x00\x06\x05there",
+			want:  "hello there",
+		},
+		"copy with implicit offset": {
+			delta: "\x0b\x05\x90\x05",
+			want:  "hello",
+		},
+		"wrong source size": {
+			delta:   "\x0c\x05\x90\x05",
+			wantErr: true,
+		},
+		"copy out of range": {
+			delta:   "\x0b\x05\x91\x08\x05",
+			wantErr: true,
+		},
+		"truncated insert": {
+			delta:   "\x0b\x05\x05hel",
+			wantErr: true,
+		},
+		"wrong result size": {
+			delta:   "\x0b\x06\x90\x05",
+			wantErr: true,
+		},
+		"reserved instruction": {
+			delta:   "\x0b\x00\x00",
+			wantErr: true,
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			got, err := ApplyBinaryDelta(src, []byte(test.delta))
+			if test.wantErr {
+				if err != ErrBadBinaryDelta {
+					t.Errorf("got error %v, want %v", err, ErrBadBinaryDelta)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyFileDiff_BinaryPatch(t *testing.T) {
+	diffData, err := ioutil.ReadFile(filepath.Join("testdata", "sample_format_patch_binary.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := ParseMultiFileDiff(diffData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := diffs[0]
+	orig, new := "\x00\x01\x02abc", "\x00\x01\x03abcd"
+
+	got, err := ApplyFileDiff([]byte(orig), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != new {
+		t.Errorf("got %q, want %q", got, new)
+	}
+	got, err = ApplyFileDiff([]byte(new), d, WithReverse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != orig {
+		t.Errorf("reverse: got %q, want %q", got, orig)
+	}
+
+	// The literal would apply to anything, but the original file must
+	// have the object name on the "index" line.
+	if _, err := ApplyFileDiff([]byte("other"), d); err != ErrBlobMismatch {
+		t.Errorf("got error %v, want %v", err, ErrBlobMismatch)
+	}
+
+	// A reversed diff encodes the swapped hunks.
+	r, err := ReverseFileDiff(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed, err := PrintFileDiff(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reparsed, err := ParseFileDiff(printed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = ApplyFileDiff([]byte(new), reparsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != orig {
+		t.Errorf("reversed diff: got %q, want %q", got, orig)
+	}
+
+	dir, err := ioutil.TempDir("", "go-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTree(t, dir, map[string]string{"img.bin": orig, "t.txt": "text\n"})
+	if err := ApplyMultiFileDiff(dir, diffs); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "img.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != new {
+		t.Errorf("applied to directory: got %q, want %q", content, new)
+	}
+}
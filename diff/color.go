@@ -0,0 +1,155 @@
+package diff
+
+import (
+	"bytes"
+	"sort"
+)
+
+// A ColorScheme is the set of ANSI escape sequences (such as "\x1b[31m"
+// for red) used by WithColor to color each kind of line. An empty sequence
+// leaves that kind of line uncolored.
+type ColorScheme struct {
+	Meta    string // extended headers and "---"/"+++" file headers
+	Frag    string // "@@ -1,2 +1,2 @@" hunk headers, without the section
+	Old     string // deleted lines
+	New     string // added lines
+	Context string // context lines and "\ No newline at end of file"
+
+	// OldHighlight and NewHighlight mark the changed parts of removed and
+	// added lines with WithIntraLineHighlight, on top of Old and New.
+	OldHighlight string
+	NewHighlight string
+}
+
+// DefaultColorScheme is the color scheme used by git by default: bold file
+// headers, cyan hunk headers, red deletions and green additions. Changes
+// within lines are highlighted in reverse video, as by git's diff-highlight.
+var DefaultColorScheme = ColorScheme{
+	Meta:         "\x1b[1m",
+	Frag:         "\x1b[36m",
+	Old:          "\x1b[31m",
+	New:          "\x1b[32m",
+	OldHighlight: "\x1b[7m",
+	NewHighlight: "\x1b[7m",
+}
+
+// colorReset ends a colored line.
+const colorReset = "\x1b[m"
+
+// WithColor prints diffs with ANSI colors from scheme, like git's --color.
+// Each colored line is followed by a reset sequence before its newline, so
+// the colors never carry over to other lines. Colored diffs are meant for
+// display, and cannot be parsed.
+func WithColor(scheme ColorScheme) PrintOption {
+	return func(o *printOptions) {
+		o.color = &scheme
+	}
+}
+
+// WithIntraLineHighlight makes WithColor highlight the changed parts of
+// removed and added lines that replace each other, like git's
+// diff-highlight. The parts are those in the hunks' Refinements, or those
+// computed by Hunk.Refine for hunks without any.
+func WithIntraLineHighlight() PrintOption {
+	return func(o *printOptions) {
+		o.intraLineHighlight = true
+	}
+}
+
+// lineRefinements returns the refinements of h to highlight, by line.
+func (o *printOptions) lineRefinements(h *Hunk) map[int][]Refinement {
+	if !o.intraLineHighlight || o.color == nil {
+		return nil
+	}
+	refinements := h.Refinements
+	if refinements == nil {
+		refinements = h.Refine()
+	}
+	byLine := make(map[int][]Refinement)
+	for _, r := range refinements {
+		byLine[r.Line] = append(byLine[r.Line], r)
+	}
+	for _, rs := range byLine {
+		sort.Slice(rs, func(i, j int) bool { return rs[i].Start < rs[j].Start })
+	}
+	return byLine
+}
+
+// writeColored writes the lines in b to buf, each wrapped in color. The last
+// line is not followed by a newline if b does not end with one.
+func writeColored(buf textWriter, color string, b []byte) {
+	if color == "" {
+		buf.Write(b)
+		return
+	}
+	for len(b) > 0 {
+		line := b
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			line = b[:i]
+		}
+		buf.WriteString(color)
+		buf.Write(line)
+		buf.WriteString(colorReset)
+		b = b[len(line):]
+		if len(b) > 0 {
+			buf.WriteByte('\n')
+			b = b[1:]
+		}
+	}
+}
+
+// writeColoredBody writes the lines of a hunk body to buf, each in the color
+// of scheme for its kind, with the parts in refinements highlighted. line is
+// the index of the first line of body in the hunk body, and the index of
+// the line after the last one is returned.
+func writeColoredBody(buf textWriter, scheme *ColorScheme, body []byte, line int, refinements map[int][]Refinement) int {
+	for len(body) > 0 {
+		n := len(body)
+		if i := bytes.IndexByte(body, '\n'); i >= 0 {
+			n = i + 1
+		}
+		color, highlight := scheme.Context, ""
+		switch body[0] {
+		case '-':
+			color, highlight = scheme.Old, scheme.OldHighlight
+		case '+':
+			color, highlight = scheme.New, scheme.NewHighlight
+		}
+		if rs := refinements[line]; len(rs) > 0 && highlight != "" {
+			writeHighlighted(buf, color, highlight, body[:n], rs)
+		} else {
+			writeColored(buf, color, body[:n])
+		}
+		if body[0] != '\\' {
+			line++
+		}
+		body = body[n:]
+	}
+	return line
+}
+
+// writeHighlighted writes a hunk body line to buf in color, with the parts
+// of its text in refinements (which are sorted) in highlight as well.
+// Refinements that are out of range or overlap earlier ones are ignored.
+func writeHighlighted(buf textWriter, color, highlight string, line []byte, refinements []Refinement) {
+	text := bytes.TrimSuffix(line[1:], []byte{'\n'})
+	buf.WriteString(color)
+	buf.WriteByte(line[0])
+	pos := 0
+	for _, r := range refinements {
+		if r.Start < pos || r.End < r.Start || r.End > len(text) {
+			continue
+		}
+		buf.Write(text[pos:r.Start])
+		buf.WriteString(highlight)
+		buf.Write(text[r.Start:r.End])
+		buf.WriteString(colorReset)
+		buf.WriteString(color)
+		pos = r.End
+	}
+	buf.Write(text[pos:])
+	buf.WriteString(colorReset)
+	if len(text) < len(line)-1 {
+		buf.WriteByte('\n')
+	}
+}
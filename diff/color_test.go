@@ -0,0 +1,90 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPrintFileDiff_Color(t *testing.T) {
+	d := parseFileDiffString(t, "diff --git a/f b/f\nindex de98044..36ef1ba 100644\n--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@ func\n a\n-b\n-c\n+B\n+c\n\\ No newline at end of file\n")
+
+	tests := map[string]struct {
+		scheme ColorScheme
+		want   string
+	}{
+		"default": {
+			scheme: DefaultColorScheme,
+			want: "\x1b[1mdiff --git a/f b/f\x1b[m\n" +
+				"\x1b[1mindex de98044..36ef1ba 100644\x1b[m\n" +
+				"\x1b[1m--- a/f\x1b[m\n" +
+				"\x1b[1m+++ b/f\x1b[m\n" +
+				"\x1b[36m@@ -1,3 +1,3 @@\x1b[m func\n" +
+				" a\n" +
+				"\x1b[31m-b\x1b[m\n" +
+				"\x1b[31m-c\x1b[m\n" +
+				"\x1b[32m+B\x1b[m\n" +
+				"\x1b[32m+c\x1b[m\n" +
+				"\\ No newline at end of file\n",
+		},
+		"custom": {
+			scheme: ColorScheme{Old: "<", New: ">", Context: "="},
+			want: "diff --git a/f b/f\n" +
+				"index de98044..36ef1ba 100644\n" +
+				"--- a/f\n" +
+				"+++ b/f\n" +
+				"@@ -1,3 +1,3 @@ func\n" +
+				"= a\x1b[m\n" +
+				"<-b\x1b[m\n" +
+				"<-c\x1b[m\n" +
+				">+B\x1b[m\n" +
+				">+c\x1b[m\n" +
+				"=\\ No newline at end of file\x1b[m\n",
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			printed, err := PrintFileDiff(d, WithColor(test.scheme))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(printed); got != test.want {
+				t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestPrintFileDiff_IntraLineHighlight(t *testing.T) {
+	d := parseFileDiffString(t, "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n x := 1\n-y := f(x)\n+y := g(x)\n z\n")
+	want := []Refinement{{Line: 1, Start: 5, End: 6}, {Line: 2, Start: 5, End: 6}}
+	if got := d.Hunks[0].Refine(); !cmp.Equal(got, want) {
+		t.Errorf("refinements mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	printed, err := PrintFileDiff(d, WithColor(DefaultColorScheme), WithIntraLineHighlight())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPrinted := "\x1b[1m--- a/f\x1b[m\n" +
+		"\x1b[1m+++ b/f\x1b[m\n" +
+		"\x1b[36m@@ -1,3 +1,3 @@\x1b[m\n" +
+		" x := 1\n" +
+		"\x1b[31m-y := \x1b[7mf\x1b[m\x1b[31m(x)\x1b[m\n" +
+		"\x1b[32m+y := \x1b[7mg\x1b[m\x1b[32m(x)\x1b[m\n" +
+		" z\n"
+	if got := string(printed); got != wantPrinted {
+		t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(wantPrinted, got))
+	}
+
+	// Refinements that are already set are used instead.
+	d.Hunks[0].Refinements = []Refinement{{Line: 2, Start: 0, End: 1}}
+	printed, err = PrintFileDiff(d, WithColor(DefaultColorScheme), WithIntraLineHighlight())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\x1b[31m-y := f(x)\x1b[m\n\x1b[32m+\x1b[7my\x1b[m\x1b[32m := g(x)\x1b[m\n"; !strings.Contains(string(printed), want) {
+		t.Errorf("got %q, want it to contain %q", printed, want)
+	}
+}
@@ -0,0 +1,472 @@
+package diff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrBadCombinedHunkLine is when a line of a combined diff hunk does not
+// start with one ' ', '-' or '+' column per parent, has both '-' and '+'
+// columns, or does not fit in the hunk's ranges.
+var ErrBadCombinedHunkLine = errors.New("bad combined diff hunk line")
+
+// A CombinedFileDiff represents the combined diff of a single file in a
+// merge, as printed by git diff --cc or git show -c, which compares the
+// merge result with all of its parents at once:
+//
+//	diff --cc f.go
+//	index 2089fc5,f2bf830..ed17028
+//	--- a/f.go
+//	+++ b/f.go
+//	@@@ -1,5 -1,5 +1,7 @@@
+//	  package main
+//	- func a() {}
+//	+ func a() { side() }
+//	 -func b() {}
+//	 +func b() { main() }
+type CombinedFileDiff struct {
+	// the original name of the file, from the "--- " header (empty if not
+	// present)
+	OrigName string
+	// the new name of the file, from the "+++ " header (empty if not
+	// present)
+	NewName string
+	// extended header lines (e.g., "diff --cc <path>", "index
+	// <parent1>,<parent2>..<result>", "mode <mode>,<mode>..<mode>", etc.)
+	Extended []string
+	// hunks that were changed from the parents to the result
+	Hunks []*CombinedHunk
+}
+
+// A CombinedHunk represents a series of changes in a file's combined diff.
+// Each line of its body starts with one column per parent: in a line of the
+// result, the column is '+' if the parent does not have the line and ' ' if
+// it does; in a line that the result does not have, the column is '-' if the
+// parent has the line and ' ' if it does not.
+type CombinedHunk struct {
+	// starting line number in each original (parent) file, in order
+	OrigStartLines []int32
+	// number of lines the hunk applies to in each original file
+	OrigLines []int32
+	// for each original file, if > 0, the file had a 'No newline at end of
+	// file' mark at this offset in Body (nil if no original file had one)
+	OrigNoNewlineAt []int32
+	// starting line number in the new (result) file
+	NewStartLine int32
+	// number of lines the hunk applies to in the new file
+	NewLines int32
+	// if > 0, the new file had a 'No newline at end of file' mark at this
+	// offset in Body
+	NewNoNewlineAt int32
+	// optional section heading, i.e., the text following the hunk range's
+	// closing "@@@ " (verbatim)
+	Section string
+	// true if the hunk header has a space after the closing "@@@" but no
+	// section heading
+	EmptySection bool
+	// 0-indexed line offset in the combined file diff, as for
+	// Hunk.StartPosition
+	StartPosition int32
+	// hunk body (lines prefixed with one column per parent); unlike in a
+	// Hunk, every line ends with a newline, and the 'No newline at end of
+	// file' marks are only recorded by OrigNoNewlineAt and NewNoNewlineAt,
+	// since a line may be the last of several of the files
+	Body []byte
+}
+
+// Parents returns the number of parents of the merge that h compares the
+// result with.
+func (h *CombinedHunk) Parents() int {
+	return len(h.OrigLines)
+}
+
+// ParseFileDiffCombined parses the combined diff of a single file. See
+// ParseMultiFileDiffCombined.
+func ParseFileDiffCombined(diff []byte) (*CombinedFileDiff, error) {
+	ds, err := ParseMultiFileDiffCombined(diff)
+	if err != nil {
+		return nil, err
+	}
+	switch len(ds) {
+	case 0:
+		return nil, &ParseError{0, 0, ErrNoFileHeader}
+	case 1:
+		return ds[0], nil
+	}
+	return nil, fmt.Errorf("combined diff has %d files, want 1", len(ds))
+}
+
+// ParseMultiFileDiffCombined parses a multi-file combined diff, such as the
+// output of git show -c or git diff --cc for a merge. Each file's diff
+// starts with a "diff --cc <path>" or "diff --combined <path>" line, which
+// is kept in Extended with the header lines that follow it and any
+// preceding lines that are not part of a file's diff (such as the commit
+// header printed by git show). Lines after the last file are ignored. The
+// hunks are parsed as by ParseHunksCombined.
+func ParseMultiFileDiffCombined(diff []byte) ([]*CombinedFileDiff, error) {
+	p := newLineParser(diff)
+	var ds []*CombinedFileDiff
+	var extended []string
+	for p.i < len(p.lines) {
+		line := p.text(p.i)
+		if !isCombinedDiffHeader(line) {
+			extended = append(extended, line)
+			p.next()
+			continue
+		}
+
+		d, err := p.readCombinedFile()
+		if err != nil {
+			return nil, err
+		}
+		d.Extended = append(extended, d.Extended...)
+		extended = nil
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
+
+// isCombinedDiffHeader reports whether line starts the combined diff of a
+// file.
+func isCombinedDiffHeader(line string) bool {
+	return strings.HasPrefix(line, "diff --cc ") || strings.HasPrefix(line, "diff --combined ")
+}
+
+// readCombinedFile reads the headers and the hunks of a file's combined
+// diff, starting at its "diff --cc" or "diff --combined" line.
+func (p *lineParser) readCombinedFile() (*CombinedFileDiff, error) {
+	d := &CombinedFileDiff{Extended: []string{p.text(p.i)}}
+	p.next()
+	for p.i < len(p.lines) {
+		line := p.text(p.i)
+		if isCombinedDiffHeader(line) || strings.HasPrefix(line, "diff --git ") || strings.HasPrefix(line, "@@@") {
+			break
+		}
+		if strings.HasPrefix(line, "--- ") && p.i+1 < len(p.lines) && strings.HasPrefix(p.text(p.i+1), "+++ ") {
+			d.OrigName = unquoteFilename(line[len("--- "):])
+			d.NewName = unquoteFilename(p.text(p.i + 1)[len("+++ "):])
+			p.next()
+			p.next()
+			break
+		}
+		d.Extended = append(d.Extended, line)
+		p.next()
+	}
+
+	hunks, err := p.readCombinedHunks()
+	if err != nil {
+		return nil, err
+	}
+	d.Hunks = hunks
+	return d, nil
+}
+
+// ParseHunksCombined parses the hunks of a combined diff, without the file
+// headers, such as those of git diff --cc or git show -c for a merge.
+//
+// git does not print 'No newline at end of file' marks in combined diffs,
+// but they are accepted after any line, and apply to each file that has the
+// line.
+func ParseHunksCombined(diff []byte) ([]*CombinedHunk, error) {
+	p := newLineParser(diff)
+	hunks, err := p.readCombinedHunks()
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(p.lines) {
+		return nil, p.parseError(&ErrBadHunkHeader{header: p.text(p.i)})
+	}
+	return hunks, nil
+}
+
+// readCombinedHunks reads the combined diff hunks that start at the next
+// line.
+func (p *lineParser) readCombinedHunks() ([]*CombinedHunk, error) {
+	var hunks []*CombinedHunk
+	var position int32
+	for p.i < len(p.lines) && strings.HasPrefix(p.text(p.i), "@@@") {
+		h, err := p.readCombinedHunk()
+		if err != nil {
+			return nil, err
+		}
+		position++ // account for the hunk header line
+		h.StartPosition = position
+		position += int32(bytes.Count(h.Body, []byte{'\n'}))
+		hunks = append(hunks, h)
+	}
+	return hunks, nil
+}
+
+// unquoteFilename returns name unquoted if it is quoted, as git quotes names
+// with special characters, and unchanged otherwise.
+func unquoteFilename(name string) string {
+	if unquoted, err := strconv.Unquote(name); err == nil {
+		return unquoted
+	}
+	return name
+}
+
+// readCombinedHunk reads a combined diff hunk, starting at its header.
+func (p *lineParser) readCombinedHunk() (*CombinedHunk, error) {
+	header := p.text(p.i)
+	h, ok := parseCombinedHunkHeader(header)
+	if !ok {
+		return nil, p.parseError(&ErrBadHunkHeader{header: header})
+	}
+	p.next()
+
+	n := h.Parents()
+	origLeft := make([]int32, n) // the lines of each parent not yet read
+	copy(origLeft, h.OrigLines)
+	newLeft := h.NewLines
+	remaining := func() bool {
+		for _, left := range origLeft {
+			if left > 0 {
+				return true
+			}
+		}
+		return newLeft > 0
+	}
+
+	var body []byte
+	for remaining() {
+		if p.i == len(p.lines) {
+			return nil, p.parseError(ErrBadCombinedHunkLine)
+		}
+		line := p.text(p.i)
+		if line == "" {
+			// A context line whose trailing spaces were stripped.
+			line = strings.Repeat(" ", n)
+		}
+		if len(line) < n || strings.Trim(line[:n], " -+") != "" || (strings.Contains(line[:n], "-") && strings.Contains(line[:n], "+")) {
+			return nil, p.parseError(ErrBadCombinedHunkLine)
+		}
+		inOrig, inNew := combinedLineFiles(line[:n])
+		for i, ok := range inOrig {
+			if ok {
+				origLeft[i]--
+			}
+		}
+		if inNew {
+			newLeft--
+		}
+		for _, left := range origLeft {
+			if left < 0 {
+				return nil, p.parseError(ErrBadCombinedHunkLine)
+			}
+		}
+		if newLeft < 0 {
+			return nil, p.parseError(ErrBadCombinedHunkLine)
+		}
+		p.next()
+		body = append(body, line...)
+		body = append(body, '\n')
+
+		if p.i < len(p.lines) && strings.HasPrefix(p.lines[p.i], `\ `) {
+			at := int32(len(body))
+			for i, ok := range inOrig {
+				if !ok {
+					continue
+				}
+				if h.OrigNoNewlineAt == nil {
+					h.OrigNoNewlineAt = make([]int32, n)
+				}
+				h.OrigNoNewlineAt[i] = at
+			}
+			if inNew {
+				h.NewNoNewlineAt = at
+			}
+			p.next()
+		}
+	}
+	h.Body = body
+	return h, nil
+}
+
+// combinedLineFiles returns which of the parents, and whether the result,
+// have a line of a combined diff hunk with the given columns.
+func combinedLineFiles(columns string) (inOrig []bool, inNew bool) {
+	removed := strings.Contains(columns, "-")
+	inOrig = make([]bool, len(columns))
+	for i := 0; i < len(columns); i++ {
+		inOrig[i] = columns[i] == '-' || (columns[i] == ' ' && !removed)
+	}
+	return inOrig, !removed
+}
+
+// parseCombinedHunkHeader parses a combined diff hunk header of the form
+// "@@@ -a,b -c,d +e,f @@@ section", with one more '@' in each marker than
+// there are parents, and one "-" range per parent. A range's count may be
+// omitted if it is 1.
+func parseCombinedHunkHeader(header string) (*CombinedHunk, bool) {
+	n := len(header) - len(strings.TrimLeft(header, "@"))
+	if n < 3 {
+		return nil, false
+	}
+	marker := header[:n]
+	rest := header[n:]
+	end := strings.Index(rest, " "+marker)
+	if end == -1 {
+		return nil, false
+	}
+	ranges := strings.Fields(rest[:end])
+	after := rest[end+1+n:]
+	if len(ranges) != n || !strings.HasPrefix(rest, " ") {
+		return nil, false
+	}
+
+	h := &CombinedHunk{}
+	if after != "" {
+		if after[0] != ' ' {
+			return nil, false
+		}
+		h.Section = after[1:]
+		h.EmptySection = h.Section == ""
+	}
+	for i, r := range ranges {
+		prefix := byte('-')
+		if i == n-1 {
+			prefix = '+'
+		}
+		if r[0] != prefix {
+			return nil, false
+		}
+		start, lines, ok := parseCombinedRange(r[1:])
+		if !ok {
+			return nil, false
+		}
+		if i == n-1 {
+			h.NewStartLine, h.NewLines = start, lines
+		} else {
+			h.OrigStartLines = append(h.OrigStartLines, start)
+			h.OrigLines = append(h.OrigLines, lines)
+		}
+	}
+	return h, true
+}
+
+// parseCombinedRange parses the range "start[,count]" of a combined diff
+// hunk header.
+func parseCombinedRange(s string) (start, lines int32, ok bool) {
+	parts := strings.SplitN(s, ",", 2)
+	a, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil || a < 0 {
+		return 0, 0, false
+	}
+	if len(parts) == 1 {
+		return int32(a), 1, true
+	}
+	b, err := strconv.ParseInt(parts[1], 10, 32)
+	if err != nil || b < 0 {
+		return 0, 0, false
+	}
+	return int32(a), int32(b), true
+}
+
+// PrintMultiFileDiffCombined prints a multi-file combined diff (see
+// PrintFileDiffCombined).
+func PrintMultiFileDiffCombined(ds []*CombinedFileDiff, opts ...PrintOption) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, d := range ds {
+		diff, err := PrintFileDiffCombined(d, opts...)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(diff)
+	}
+	return buf.Bytes(), nil
+}
+
+// PrintFileDiffCombined prints a CombinedFileDiff in the format that
+// ParseMultiFileDiffCombined parses: its extended headers, as they are, the
+// "---" and "+++" file headers if it has file names, and its hunks (see
+// PrintHunksCombined). File names are quoted as by PrintFileDiff, and
+// WithQuoter is the only option that applies.
+func PrintFileDiffCombined(d *CombinedFileDiff, opts ...PrintOption) ([]byte, error) {
+	o := newPrintOptions(opts)
+	var buf bytes.Buffer
+	for _, xheader := range d.Extended {
+		fmt.Fprintln(&buf, xheader)
+	}
+	if d.OrigName != "" || d.NewName != "" {
+		quote := func(name string) string {
+			if name == devNull {
+				return name
+			}
+			return o.quote(name)
+		}
+		fmt.Fprintf(&buf, "--- %s\n+++ %s\n", quote(d.OrigName), quote(d.NewName))
+	}
+	hunks, err := PrintHunksCombined(d.Hunks)
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(hunks)
+	return buf.Bytes(), nil
+}
+
+// PrintHunksCombined prints combined diff hunks. Each header has one more
+// '@' in its markers than there are parents, and gives the count of every
+// range, as git does. A 'No newline at end of file' mark is printed after
+// each line that is the last line of a file without a newline; it is only
+// printed once for a line that several of the files end with.
+func PrintHunksCombined(hunks []*CombinedHunk) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, h := range hunks {
+		if h.Parents() < 2 {
+			return nil, fmt.Errorf("combined hunk has %d parents, want at least 2", h.Parents())
+		}
+		if len(h.OrigStartLines) != h.Parents() {
+			return nil, fmt.Errorf("combined hunk has %d parents but %d original start lines", h.Parents(), len(h.OrigStartLines))
+		}
+		if h.OrigNoNewlineAt != nil && len(h.OrigNoNewlineAt) != h.Parents() {
+			return nil, fmt.Errorf("combined hunk has %d parents but %d original no-newline offsets", h.Parents(), len(h.OrigNoNewlineAt))
+		}
+
+		marker := strings.Repeat("@", h.Parents()+1)
+		buf.WriteString(marker)
+		for i := range h.OrigLines {
+			fmt.Fprintf(&buf, " -%d,%d", h.OrigStartLines[i], h.OrigLines[i])
+		}
+		fmt.Fprintf(&buf, " +%d,%d %s", h.NewStartLine, h.NewLines, marker)
+		if h.Section != "" || h.EmptySection {
+			fmt.Fprint(&buf, " ", h.Section)
+		}
+		buf.WriteByte('\n')
+
+		body := h.Body
+		for offset := 0; len(body) > 0; {
+			line := body
+			if i := bytes.IndexByte(body, '\n'); i != -1 {
+				line = body[:i+1]
+			}
+			body = body[len(line):]
+			offset += len(line)
+			buf.Write(line)
+			if !bytes.HasSuffix(line, []byte{'\n'}) {
+				buf.WriteByte('\n')
+			}
+			if h.endsFile(int32(offset)) {
+				buf.WriteString(noNewlineMessage + "\n")
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// endsFile reports whether the line of h's body that ends at offset is the
+// last line of any of the files, which lacks a newline.
+func (h *CombinedHunk) endsFile(offset int32) bool {
+	if h.NewNoNewlineAt > 0 && h.NewNoNewlineAt == offset {
+		return true
+	}
+	for _, at := range h.OrigNoNewlineAt {
+		if at > 0 && at == offset {
+			return true
+		}
+	}
+	return false
+}
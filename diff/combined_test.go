@@ -0,0 +1,239 @@
+package diff
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseHunksCombined(t *testing.T) {
+	// The hunk of git show -c for a merge whose parents and result all end
+	// without a newline: git prints no 'No newline at end of file' marks.
+	hunk := "@@@ -1,2 -1,3 +1,3 @@@\n  x\n- Y\n -y\n++Y\n+ z\n"
+	hunks, err := ParseHunksCombined([]byte(hunk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*CombinedHunk{{
+		OrigStartLines: []int32{1, 1},
+		OrigLines:      []int32{2, 3},
+		NewStartLine:   1,
+		NewLines:       3,
+		StartPosition:  1,
+		Body:           []byte("  x\n- Y\n -y\n++Y\n+ z\n"),
+	}}
+	if !cmp.Equal(hunks, want) {
+		t.Errorf("hunks mismatch (-want +got):\n%s", cmp.Diff(want, hunks))
+	}
+
+	printed, err := PrintHunksCombined(hunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(printed); got != hunk {
+		t.Errorf("printed hunks mismatch (-want +got):\n%s", cmp.Diff(hunk, got))
+	}
+
+	if _, err := ParseHunksCombined([]byte(hunk + "junk\n")); err == nil {
+		t.Error("got no error for a line after the hunks")
+	}
+}
+
+func TestParseHunksCombined_NoNewline(t *testing.T) {
+	// The second parent and the result end in "z" without a newline, and
+	// the first parent in "Y".
+	hunks, err := ParseHunksCombined([]byte(`@@@ -1,2 -1,3 +1,3 @@@ section
+  x
+- Y
+\ No newline at end of file
+ -y
+++Y
++ z
+\ No newline at end of file
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := hunks[0]
+	if want := []int32{8, 20}; !reflect.DeepEqual(h.OrigNoNewlineAt, want) {
+		t.Errorf("got OrigNoNewlineAt %v, want %v", h.OrigNoNewlineAt, want)
+	}
+	if want := int32(20); h.NewNoNewlineAt != want {
+		t.Errorf("got NewNoNewlineAt %d, want %d", h.NewNoNewlineAt, want)
+	}
+	if want := "section"; h.Section != want {
+		t.Errorf("got section %q, want %q", h.Section, want)
+	}
+}
+
+func TestPrintHunksCombined_NoNewline(t *testing.T) {
+	tests := map[string]string{
+		// The marks after lines that only the first parent, both parents,
+		// or the second parent and the result end with.
+		"two parents": `@@@ -1,2 -1,3 +1,3 @@@ section
+  x
+- Y
+\ No newline at end of file
+ -y
+++Y
++ z
+\ No newline at end of file
+`,
+		"three parents": `@@@@ -1,1 -1,1 -1,1 +1,1 @@@@
+-- a
+\ No newline at end of file
+  -b
++++c
+`,
+	}
+	for label, diff := range tests {
+		t.Run(label, func(t *testing.T) {
+			hunks, err := ParseHunksCombined([]byte(diff))
+			if err != nil {
+				t.Fatal(err)
+			}
+			printed, err := PrintHunksCombined(hunks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(printed); got != diff {
+				t.Errorf("printed hunks mismatch (-want +got):\n%s", cmp.Diff(diff, got))
+			}
+		})
+	}
+}
+
+func TestParseMultiFileDiffCombined(t *testing.T) {
+	diffData, err := ioutil.ReadFile(filepath.Join("testdata", "sample_combined.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := ParseMultiFileDiffCombined(diffData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 {
+		t.Fatalf("got %d file diffs, want 2", len(diffs))
+	}
+
+	want := []*CombinedFileDiff{
+		{
+			OrigName: "a/f.go",
+			NewName:  "b/f.go",
+			Extended: []string{
+				"commit f0f2e201f41c3686d7dda38f0b9558b027a94dfd",
+				"Merge: 75ebf13 cdaa659",
+				"Author: Jane Doe <jane@example.com>",
+				"Date:   Thu Jan 2 03:04:05 2020 +0000",
+				"",
+				"    Merge branch 'side'",
+				"",
+				"diff --combined f.go",
+				"index 2089fc5,f2bf830..ed17028",
+			},
+			Hunks: []*CombinedHunk{{
+				OrigStartLines: []int32{1, 1},
+				OrigLines:      []int32{5, 5},
+				NewStartLine:   1,
+				NewLines:       7,
+				StartPosition:  1,
+				Body:           []byte("  package main\n  \n- func a() {}\n+ func a() { side() }\n  \n -func b() {}\n +func b() { main() }\n++\n++func c() {}\n"),
+			}},
+		},
+		{
+			OrigName: "a/g.txt",
+			NewName:  "b/g.txt",
+			Extended: []string{"diff --combined g.txt", "index 7061c57,66455a1..250eaab"},
+			Hunks: []*CombinedHunk{{
+				OrigStartLines: []int32{1, 1},
+				OrigLines:      []int32{2, 3},
+				NewStartLine:   1,
+				NewLines:       3,
+				StartPosition:  1,
+				Body:           []byte("  x\n -y\n +Y\n+ z\n"),
+			}},
+		},
+	}
+	if !cmp.Equal(diffs, want) {
+		t.Errorf("diffs mismatch (-want +got):\n%s", cmp.Diff(want, diffs))
+	}
+}
+
+func TestParseMultiFileDiffCombined_Error(t *testing.T) {
+	header := "diff --cc f\n--- a/f\n+++ b/f\n"
+	tests := map[string]struct {
+		diff string
+		want error
+	}{
+		"bad header": {
+			diff: header + "@@@ -1 +1 @@@\n",
+			want: &ParseError{4, 28, &ErrBadHunkHeader{header: "@@@ -1 +1 @@@"}},
+		},
+		"mixed columns": {
+			diff: header + "@@@ -1 -1 +1 @@@\n-+a\n",
+			want: &ParseError{5, 45, ErrBadCombinedHunkLine},
+		},
+		"out of range": {
+			diff: header + "@@@ -1 -1,0 +1 @@@\n  a\n",
+			want: &ParseError{5, 47, ErrBadCombinedHunkLine},
+		},
+		"truncated": {
+			diff: header + "@@@ -1,2 -1 +1 @@@\n  a\n",
+			want: &ParseError{6, 51, ErrBadCombinedHunkLine},
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			_, err := ParseMultiFileDiffCombined([]byte(test.diff))
+			if !reflect.DeepEqual(err, test.want) {
+				t.Errorf("got error %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestPrintMultiFileDiffCombined(t *testing.T) {
+	diffData, err := ioutil.ReadFile(filepath.Join("testdata", "sample_combined.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := ParseMultiFileDiffCombined(diffData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed, err := PrintMultiFileDiffCombined(diffs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(printed); got != string(diffData) {
+		t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(string(diffData), got))
+	}
+}
+
+func TestPrintMultiFileDiffCombined_Merge(t *testing.T) {
+	// The output of git show -c and git show --cc for a merge whose parents
+	// and result all end without a newline.
+	for _, filename := range []string{"combined_merge.diff", "combined_merge_cc.diff"} {
+		diffData, err := ioutil.ReadFile(filepath.Join("testdata", filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		diffs, err := ParseMultiFileDiffCombined(diffData)
+		if err != nil {
+			t.Fatalf("%s: %s", filename, err)
+		}
+		if len(diffs) != 1 || len(diffs[0].Hunks) != 1 {
+			t.Fatalf("%s: got %d file diffs, want 1 with 1 hunk", filename, len(diffs))
+		}
+		printed, err := PrintMultiFileDiffCombined(diffs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(printed); got != string(diffData) {
+			t.Errorf("%s: printed diff mismatch (-want +got):\n%s", filename, cmp.Diff(string(diffData), got))
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// A CompareOption is an option for Equal and Compare.
+type CompareOption func(*compareOptions)
+
+type compareOptions struct {
+	whitespace    WhitespaceMode
+	ignoreContext bool
+}
+
+// WithIgnoreWhitespace makes Equal and Compare ignore the whitespace
+// differences that mode ignores between the lines of the hunks compared.
+func WithIgnoreWhitespace(mode WhitespaceMode) CompareOption {
+	return func(o *compareOptions) {
+		o.whitespace = mode
+	}
+}
+
+// WithIgnoreContext makes Equal and Compare compare the hunks of diffs as
+// normalized by Normalize, so that only the changes they make matter, and
+// not their context lines, section headings or the way they are split into
+// hunks. The changes are compared where the patches place them.
+func WithIgnoreContext() CompareOption {
+	return func(o *compareOptions) {
+		o.ignoreContext = true
+	}
+}
+
+// A Difference is a way in which two patches compared by Compare differ.
+type Difference struct {
+	// Path is the path of the file whose diffs differ, as in a diff stat.
+	Path string
+	// Description says how they differ, such as "hunk 2 differs".
+	Description string
+}
+
+func (d Difference) String() string {
+	return d.Path + ": " + d.Description
+}
+
+// Equal reports whether the patches a and b make the same changes, as
+// determined by Compare.
+func Equal(a, b []*FileDiff, opts ...CompareOption) bool {
+	return len(Compare(a, b, opts...)) == 0
+}
+
+// Compare compares the patches a and b, and returns the differences
+// between the changes they make, if any. The diffs of the two patches are
+// paired up by their paths, regardless of their order, and the diffs of a
+// file are taken to make the same change if they change it in the same way
+// (they add, delete, rename, copy or modify it, and give it the same modes,
+// if both have modes), change it in binary diffs with the same payloads (if
+// they have payloads), and have the same hunks. Timestamps, object names,
+// similarity scores and other extended headers are ignored. The differences
+// of the files in a come first, in order, followed by those of the files
+// only in b.
+func Compare(a, b []*FileDiff, opts ...CompareOption) []Difference {
+	o := &compareOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	byPath := make(map[string][]*FileDiff)
+	for _, d := range b {
+		if !d.isOnlyIn() {
+			byPath[d.path()] = append(byPath[d.path()], d)
+		}
+	}
+	var diffs []Difference
+	for _, da := range a {
+		if da.isOnlyIn() {
+			continue
+		}
+		path := da.path()
+		if len(byPath[path]) == 0 {
+			diffs = append(diffs, Difference{Path: path, Description: "only in the first patch"})
+			continue
+		}
+		db := byPath[path][0]
+		byPath[path] = byPath[path][1:]
+		if description := o.compare(da, db); description != "" {
+			diffs = append(diffs, Difference{Path: path, Description: description})
+		}
+	}
+	// The diffs of b that were not paired up are the last ones of each
+	// path.
+	for _, d := range b {
+		if rest := byPath[d.path()]; len(rest) > 0 && rest[0] == d {
+			diffs = append(diffs, Difference{Path: d.path(), Description: "only in the second patch"})
+			byPath[d.path()] = rest[1:]
+		}
+	}
+	return diffs
+}
+
+// compare returns how the diffs a and b of the same file differ, or "" if
+// they make the same change.
+func (o *compareOptions) compare(a, b *FileDiff) string {
+	if ta, tb := a.Type(), b.Type(); ta != tb {
+		return fmt.Sprintf("%s in the first patch, %s in the second", ta, tb)
+	}
+	// Modes are only compared if both diffs have them.
+	if a.OrigMode != 0 && b.OrigMode != 0 && a.OrigMode != b.OrigMode {
+		return fmt.Sprintf("original mode %06o in the first patch, %06o in the second", a.OrigMode, b.OrigMode)
+	}
+	if a.NewMode != 0 && b.NewMode != 0 && a.NewMode != b.NewMode {
+		return fmt.Sprintf("new mode %06o in the first patch, %06o in the second", a.NewMode, b.NewMode)
+	}
+	if a.IsBinary() != b.IsBinary() {
+		return "binary in only one patch"
+	}
+	if pa, pb := a.BinaryPatch, b.BinaryPatch; pa != nil && pb != nil && !equalBinaryHunks(pa.Forward, pb.Forward) {
+		return "binary patches differ"
+	}
+
+	if (a.Hunks == nil && a.RawHunks != nil) || (b.Hunks == nil && b.RawHunks != nil) {
+		return errHunksNotLoaded.Error()
+	}
+	ha, hb := a.Hunks, b.Hunks
+	if o.ignoreContext {
+		na, _ := Normalize(a)
+		nb, _ := Normalize(b)
+		ha, hb = na.Hunks, nb.Hunks
+	}
+	if len(ha) != len(hb) {
+		return fmt.Sprintf("%d hunks in the first patch, %d in the second", len(ha), len(hb))
+	}
+	for i := range ha {
+		if !o.equalHunks(ha[i], hb[i]) {
+			return fmt.Sprintf("hunk %d differs (%s in the first patch, %s in the second)", i+1, hunkRange(ha[i]), hunkRange(hb[i]))
+		}
+	}
+	return ""
+}
+
+// equalHunks reports whether the hunks a and b have the same line numbers
+// and lines, ignoring the whitespace that o ignores and their section
+// headings.
+func (o *compareOptions) equalHunks(a, b *Hunk) bool {
+	if a.OrigStartLine != b.OrigStartLine || a.OrigLines != b.OrigLines || a.NewStartLine != b.NewStartLine || a.NewLines != b.NewLines {
+		return false
+	}
+	la, lb := a.lines(), b.lines()
+	if len(la) != len(lb) {
+		return false
+	}
+	for i := range la {
+		if la[i].op != lb[i].op || la[i].noNewline != lb[i].noNewline {
+			return false
+		}
+		if o.whitespace.normalize(string(la[i].text)) != o.whitespace.normalize(string(lb[i].text)) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalBinaryHunks(a, b *BinaryHunk) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Type == b.Type && bytes.Equal(a.Data, b.Data)
+}
+
+// hunkRange returns the "-1,2 +1,2" range of the hunk h.
+func hunkRange(h *Hunk) string {
+	return fmt.Sprintf("-%d,%d +%d,%d", h.OrigStartLine, h.OrigLines, h.NewStartLine, h.NewLines)
+}
@@ -0,0 +1,190 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompare(t *testing.T) {
+	parse := func(s string) []*FileDiff {
+		t.Helper()
+		ds, err := ParseMultiFileDiff([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ds
+	}
+	base := parse(`diff --git a/f b/f
+index 1111111..2222222 100644
+--- a/f
++++ b/f
+@@ -1,4 +1,4 @@
+ a
+ b
+-c
++C
+ d
+diff --git a/g b/g
+new file mode 100755
+index 0000000..3333333
+--- /dev/null
++++ b/g
+@@ -0,0 +1 @@
++x
+`)
+
+	tests := map[string]struct {
+		other string
+		opts  []CompareOption
+		want  []Difference
+	}{
+		"reordered, with other metadata": {
+			other: `diff --git a/g b/g
+new file mode 100755
+index 0000000..4444444
+--- /dev/null	2009-10-11 15:12:20.000000000 -0700
++++ b/g	2009-10-11 15:12:20.000000000 -0700
+@@ -0,0 +1 @@ section
++x
+diff --git a/f b/f
+index 5555555..6666666 100644
+--- a/f
++++ b/f
+@@ -1,4 +1,4 @@
+ a
+ b
+-c
++C
+ d
+`,
+		},
+		"other context": {
+			other: `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -2,3 +2,3 @@
+ b
+-c
++C
+ d
+diff --git a/g b/g
+new file mode 100755
+--- /dev/null
++++ b/g
+@@ -0,0 +1 @@
++x
+`,
+			want: []Difference{{Path: "f", Description: "hunk 1 differs (-1,4 +1,4 in the first patch, -2,3 +2,3 in the second)"}},
+		},
+		"other context, ignored": {
+			other: `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -2,3 +2,3 @@
+ b
+-c
++C
+ d
+diff --git a/g b/g
+new file mode 100755
+--- /dev/null
++++ b/g
+@@ -0,0 +1 @@
++x
+`,
+			opts: []CompareOption{WithIgnoreContext()},
+		},
+		"whitespace": {
+			other: `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -1,4 +1,4 @@
+ a
+ b
+-c
++C  
+ d
+diff --git a/g b/g
+new file mode 100755
+--- /dev/null
++++ b/g
+@@ -0,0 +1 @@
++x
+`,
+			want: []Difference{{Path: "f", Description: "hunk 1 differs (-1,4 +1,4 in the first patch, -1,4 +1,4 in the second)"}},
+		},
+		"whitespace, ignored": {
+			other: `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -1,4 +1,4 @@
+ a
+ b
+-c
++C  
+ d
+diff --git a/g b/g
+new file mode 100755
+--- /dev/null
++++ b/g
+@@ -0,0 +1 @@
++x
+`,
+			opts: []CompareOption{WithIgnoreWhitespace(IgnoreSpaceAtEOL)},
+		},
+		"other files and modes": {
+			other: `diff --git a/g b/g
+new file mode 100644
+--- /dev/null
++++ b/g
+@@ -0,0 +1 @@
++x
+diff --git a/h b/h
+deleted file mode 100644
+--- a/h
++++ /dev/null
+@@ -1 +0,0 @@
+-y
+`,
+			want: []Difference{
+				{Path: "f", Description: "only in the first patch"},
+				{Path: "g", Description: "new mode 100755 in the first patch, 100644 in the second"},
+				{Path: "h", Description: "only in the second patch"},
+			},
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			got := Compare(base, parse(test.other), test.opts...)
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("differences mismatch (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+			if equal := Equal(base, parse(test.other), test.opts...); equal != (len(test.want) == 0) {
+				t.Errorf("got Equal %v, want %v", equal, len(test.want) == 0)
+			}
+		})
+	}
+}
+
+func TestCompare_IgnoreContextInsertion(t *testing.T) {
+	// Two patches inserting an x after the second of five, with different
+	// context.
+	orig := "x\nx\nx\nx\nx\ny\n"
+	a := []*FileDiff{parseFileDiffString(t, "--- a/f\n+++ b/f\n@@ -2,2 +2,3 @@\n x\n+x\n x\n")}
+	b := []*FileDiff{parseFileDiffString(t, "--- a/f\n+++ b/f\n@@ -1,5 +1,6 @@\n x\n x\n+x\n x\n x\n x\n")}
+	appliedA, err := ApplyFileDiff([]byte(orig), a[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	appliedB, err := ApplyFileDiff([]byte(orig), b[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(appliedA) != string(appliedB) {
+		t.Fatalf("the patches make different changes: %q and %q", appliedA, appliedB)
+	}
+	if got := Compare(a, b, WithIgnoreContext()); len(got) != 0 {
+		t.Errorf("got differences %v, want none", got)
+	}
+}
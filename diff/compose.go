@@ -0,0 +1,212 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Compose returns a patch equivalent to applying the patch a and then the
+// patch b, such as to squash two patches of a series, computed without the
+// files they apply to. The opts set the context and algorithm of the hunks,
+// as for NewFileDiff.
+//
+// Each diff of b is paired up with the diff of a that gives the file it
+// changes, following renames: a diff of b changing the new path of a diff of
+// a (or its path, if a deletes the file) is composed with it into a single
+// diff, from the original file of the diff of a to the new file of the diff
+// of b. As with Interdiff, the original file is pieced together from the
+// lines that the hunks of both diffs show, so the context of the composed
+// hunks may be shorter than asked for. A file added by a and deleted by b,
+// or changed back to what it was, is left out. The other diffs are kept as
+// they are, those of a first, and "Only in" messages are dropped.
+//
+// The composed diffs have no extended headers (see WithGitHeaders); their
+// names are the original name of the diff of a and the new name of the diff
+// of b. Compose returns an error if the hunks of a pair disagree about the
+// file, if b does not apply to the file as a patches it, or if a pair
+// changes a binary file or b copies a file that a changes.
+func Compose(a, b []*FileDiff, opts ...DiffOption) ([]*FileDiff, error) {
+	o, err := newDiffOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	byPath := make(map[string][]*FileDiff) // the diffs of b by the path they change
+	for _, d := range b {
+		if !d.isOnlyIn() {
+			byPath[composeOrigPath(d)] = append(byPath[composeOrigPath(d)], d)
+		}
+	}
+	var ds []*FileDiff
+	for _, da := range a {
+		if da.isOnlyIn() {
+			continue
+		}
+		path := composeNewPath(da)
+		if len(byPath[path]) == 0 {
+			ds = append(ds, da)
+			continue
+		}
+		db := byPath[path][0]
+		byPath[path] = byPath[path][1:]
+		d, err := composeFile(da, db, o)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			ds = append(ds, d)
+		}
+	}
+	// The diffs of b that were not paired up are the last ones of each
+	// path.
+	for _, d := range b {
+		if d.isOnlyIn() {
+			continue
+		}
+		if rest := byPath[composeOrigPath(d)]; len(rest) > 0 && rest[0] == d {
+			ds = append(ds, d)
+			byPath[composeOrigPath(d)] = rest[1:]
+		}
+	}
+	return ds, nil
+}
+
+// composeOrigPath returns the path of the file that d changes: its original
+// path, or its new path if d adds the file.
+func composeOrigPath(d *FileDiff) string {
+	orig, new := d.paths()
+	if d.Type() == Added {
+		return new
+	}
+	return orig
+}
+
+// composeNewPath returns the path of the file that d gives: its new path, or
+// its original path if d deletes the file.
+func composeNewPath(d *FileDiff) string {
+	orig, new := d.paths()
+	if d.Type() == Deleted {
+		return orig
+	}
+	return new
+}
+
+// composeFile returns the diff that makes the changes of a and then those
+// of b to the same file, or nil if together they change nothing.
+func composeFile(a, b *FileDiff, o *diffOptions) (*FileDiff, error) {
+	path := composeNewPath(a)
+	if (a.Hunks == nil && a.RawHunks != nil) || (b.Hunks == nil && b.RawHunks != nil) {
+		return nil, errHunksNotLoaded
+	}
+	if a.IsBinary() || b.IsBinary() {
+		return nil, fmt.Errorf("cannot compose the changes to binary file %s", path)
+	}
+	if b.Type() == Copied {
+		return nil, fmt.Errorf("cannot compose the changes to %s with a copy of it", path)
+	}
+
+	base, placeholder, err := composeBase(a, b)
+	if err != nil {
+		return nil, err
+	}
+	mid, err := ApplyFileDiff(base, a)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compose the changes to %s: %s", path, err)
+	}
+	final, err := ApplyFileDiff(mid, b)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compose the changes to %s: %s", path, err)
+	}
+
+	d := &FileDiff{
+		OrigName:       a.OrigName,
+		OrigTime:       a.OrigTime,
+		OrigTimeLayout: a.OrigTimeLayout,
+		NewName:        b.NewName,
+		NewTime:        b.NewTime,
+		NewTimeLayout:  b.NewTimeLayout,
+		OrigMode:       a.OrigMode,
+		NewMode:        b.NewMode,
+	}
+	if d.IsDevNullOrig() && d.IsDevNullNew() {
+		return nil, nil
+	}
+	// A diff without modes leaves the mode as it is.
+	if d.OrigMode == 0 && a.NewMode == 0 && a.Type() != Added {
+		d.OrigMode = b.OrigMode
+	}
+	if d.NewMode == 0 && b.OrigMode == 0 && b.Type() != Deleted {
+		d.NewMode = a.NewMode
+	}
+	if a.Type() == Copied {
+		d.Status = "C"
+	}
+
+	d.Hunks, err = computeHunksBetweenPlaceholders(splitLines(base), splitLines(final), placeholder, o)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compose the changes to %s: %s", path, err)
+	}
+	if orig, new := d.paths(); len(d.Hunks) == 0 && d.Type() == Modified && orig == new && !d.ModeChanged() {
+		return nil, nil
+	}
+	return d, nil
+}
+
+// composeBase pieces together the original file of the diff a from the
+// context and removed lines of its hunks and of those of b, which applies
+// to the file as a patches it. Each line that none shows is replaced by a
+// unique placeholder line, which placeholder recognizes.
+func composeBase(a, b *FileDiff) (base []byte, placeholder func([]byte) bool, err error) {
+	known := make(map[int]fileLine)
+	size := 0
+	addLine := func(origLine int, l hunkLine) error {
+		line := fileLine{text: l.text, noNewline: l.noNewline}
+		if k, ok := known[origLine]; ok && (!bytes.Equal(k.text, line.text) || k.noNewline != line.noNewline) {
+			return fmt.Errorf("cannot compose the changes to %s: the patches disagree about line %d of the original file", composeNewPath(a), origLine)
+		}
+		known[origLine] = line
+		if origLine > size {
+			size = origLine
+		}
+		return nil
+	}
+	for _, h := range a.Hunks {
+		origLine, _ := h.lineStarts()
+		if end := origLine - 1 + int(h.OrigLines); end > size {
+			size = end
+		}
+		for _, l := range h.lines() {
+			if l.op == '+' {
+				continue
+			}
+			if err := addLine(origLine, l); err != nil {
+				return nil, nil, err
+			}
+			origLine++
+		}
+	}
+	// The lines of b that a does not add are lines of the original file,
+	// which must be long enough for b to apply where it does.
+	for _, h := range b.Hunks {
+		midLine, _ := h.lineStarts()
+		if end := midLine - 1 + int(h.OrigLines); end > 0 {
+			if origLine, added := a.MapLineReverse(end); !added && origLine > size {
+				size = origLine
+			}
+		}
+		for _, l := range h.lines() {
+			if l.op == '+' {
+				continue
+			}
+			if origLine, added := a.MapLineReverse(midLine); !added {
+				if err := addLine(origLine, l); err != nil {
+					return nil, nil, err
+				}
+			}
+			midLine++
+		}
+	}
+
+	base, placeholder = placeholderFile(known, size)
+	return base, placeholder, nil
+}
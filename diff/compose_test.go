@@ -0,0 +1,189 @@
+package diff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompose(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 30; i++ {
+		lines = append(lines, "line "+string(rune('a'+i%26))+strings.Repeat("x", i%4))
+	}
+	orig := strings.Join(lines, "\n") + "\n"
+
+	// a changes line 3, adds two lines after line 10 and renames f to g;
+	// b changes line 25 of g (line 23 of f) and one of the added lines.
+	midLines := append(append(append([]string(nil), lines[:10]...), "added 1", "added 2"), lines[10:]...)
+	midLines[2] = "three"
+	mid := strings.Join(midLines, "\n") + "\n"
+	finalLines := append([]string(nil), midLines...)
+	finalLines[11] = "added two"
+	finalLines[24] = "twenty-three"
+	final := strings.Join(finalLines, "\n") + "\n"
+
+	a, err := NewFileDiff("a/f", "b/g", []byte(orig), []byte(mid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewFileDiff("a/g", "b/g", []byte(mid), []byte(final), WithContext(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addH, err := NewFileDiff("/dev/null", "b/h", nil, []byte("h\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleteH, err := NewFileDiff("a/h", "/dev/null", []byte("h\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewFileDiff("a/other", "b/other", []byte("x\n"), []byte("y\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := Compose([]*FileDiff{a, addH}, []*FileDiff{other, deleteH, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 2 {
+		t.Fatalf("got %d diffs, want 2", len(ds))
+	}
+	if ds[0].OrigName != "a/f" || ds[0].NewName != "b/g" {
+		t.Errorf("got names %q and %q, want a/f and b/g", ds[0].OrigName, ds[0].NewName)
+	}
+	if ds[1] != other {
+		t.Errorf("got %s, want the diff of other", ds[1].path())
+	}
+	applied, err := ApplyFileDiff([]byte(orig), ds[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(applied) != final {
+		t.Errorf("applied mismatch (-want +got):\n%s", cmp.Diff(final, string(applied)))
+	}
+	// The composed hunks only show the lines that the patches show: those
+	// around line 3 and the added lines, as shown by a, and those around
+	// line 23, as shown by b.
+	if got := hunkRange(ds[0].Hunks[2]); got != "-22,3 +24,3" {
+		t.Errorf("got hunk 3 %s, want -22,3 +24,3", got)
+	}
+
+	t.Run("deleted and added back", func(t *testing.T) {
+		del, err := NewFileDiff("a/f", "/dev/null", []byte("1\n2\n"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		add, err := NewFileDiff("/dev/null", "b/f", nil, []byte("1\ntwo\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ds, err := Compose([]*FileDiff{del}, []*FileDiff{add})
+		if err != nil {
+			t.Fatal(err)
+		}
+		printed, err := PrintMultiFileDiff(ds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `--- a/f
++++ b/f
+@@ -1,2 +1,2 @@
+ 1
+-2
++two
+`
+		if diff := cmp.Diff(want, string(printed)); diff != "" {
+			t.Errorf("printed mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("disagreement", func(t *testing.T) {
+		b, err := NewFileDiff("a/g", "b/g", []byte(strings.Replace(mid, lines[4], "something else", 1)), []byte(final))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Compose([]*FileDiff{a}, []*FileDiff{b}); err == nil {
+			t.Error("got no error for patches that disagree")
+		}
+	})
+}
+
+func TestCompose_Apply(t *testing.T) {
+	cases := []struct {
+		orig, a, b, want string
+	}{
+		// b inserts lines after the last line that a shows.
+		{
+			orig: "d\na\n",
+			a:    "--- a/f\n+++ b/f\n@@ -1,1 +1,1 @@\n-d\n+e\n",
+			b:    "--- a/f\n+++ b/f\n@@ -2,0 +3,1 @@\n+z\n",
+			want: "e\na\nz\n",
+		},
+		// b adds back the line that a removes, before an unknown line.
+		{
+			orig: "b\nc\n",
+			a:    "--- a/f\n+++ b/f\n@@ -2,1 +1,0 @@\n-c\n",
+			b:    "--- a/f\n+++ b/f\n@@ -0,0 +1,1 @@\n+c\n",
+			want: "c\nb\n",
+		},
+	}
+	rnd := rand.New(rand.NewSource(1))
+	randomContent := func() string {
+		var lines []string
+		for i := rnd.Intn(12); i > 0; i-- {
+			lines = append(lines, string(rune('a'+rnd.Intn(5))))
+		}
+		return strings.Join(lines, "\n") + "\n"
+	}
+	for i := 0; i < 300; i++ {
+		orig, mid, final := randomContent(), randomContent(), randomContent()
+		for _, context := range []int{0, 1, 3} {
+			a, err := NewFileDiff("a/f", "b/f", []byte(orig), []byte(mid), WithContext(context))
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := NewFileDiff("a/f", "b/f", []byte(mid), []byte(final), WithContext(context))
+			if err != nil {
+				t.Fatal(err)
+			}
+			printedA, err := PrintFileDiff(a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			printedB, err := PrintFileDiff(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cases = append(cases, struct{ orig, a, b, want string }{orig, string(printedA), string(printedB), final})
+		}
+	}
+	for _, c := range cases {
+		a, err := ParseMultiFileDiff([]byte(c.a))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseMultiFileDiff([]byte(c.b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ds, err := Compose(a, b)
+		if err != nil {
+			t.Errorf("%q, %q, %q: Compose: %s", c.orig, c.a, c.b, err)
+			continue
+		}
+		got := []byte(c.orig)
+		for _, d := range ds {
+			if got, err = ApplyFileDiff(got, d); err != nil {
+				t.Errorf("%q, %q, %q: ApplyFileDiff: %s", c.orig, c.a, c.b, err)
+			}
+		}
+		if string(got) != c.want {
+			t.Errorf("%q, %q, %q: applying the composed patch gave %q, want %q", c.orig, c.a, c.b, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package diff
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// WithConcurrency makes ParseMultiFileDiff split the diff into the diffs of
+// its files and parse them with up to n goroutines at once. The result is
+// the same as without the option: the files are in the order they appear
+// in, and if any of them fails to parse, the whole diff is parsed again in
+// a single goroutine, so that the error is the same too. The diff is split
+// before its "diff --git" lines or, if it has none, before each "---" and
+// "+++" file header that is followed by a hunk header. It has no effect on
+// readers created from an io.Reader.
+func WithConcurrency(n int) ParseOption {
+	return func(o *parseOptions) {
+		o.concurrency = n
+	}
+}
+
+// A parsedChunk is the result of parsing one of the parts of a multi-file
+// diff split by splitMultiFileDiff.
+type parsedChunk struct {
+	ds []*FileDiff
+	// trailing is the content after the last file, which belongs to the
+	// extended headers of the first file of the next part.
+	trailing []string
+	// ok is false if the part failed to parse, or ended in a way that
+	// depends on the next part.
+	ok bool
+}
+
+// parseMultiFileDiffConcurrently parses diff as ParseMultiFileDiff does,
+// with o.concurrency goroutines.
+func parseMultiFileDiffConcurrently(diff []byte, o *parseOptions) ([]*FileDiff, error) {
+	sequential := func() ([]*FileDiff, error) {
+		return (&MultiFileDiffReader{reader: o.lineReader(diff), opts: o}).ReadAllFiles()
+	}
+	chunks := splitMultiFileDiff(diff)
+	if len(chunks) < 2 {
+		return sequential()
+	}
+
+	results := make([]parsedChunk, len(chunks))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < o.concurrency && w < len(chunks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = parseChunk(chunks[i], o, i == len(chunks)-1)
+			}
+		}()
+	}
+	for i := range chunks {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var ds []*FileDiff
+	var pending []string
+	for _, c := range results {
+		if !c.ok {
+			return sequential()
+		}
+		if len(c.ds) > 0 && len(pending) > 0 {
+			// The modes may be given by the extended headers that were
+			// in the previous part.
+			d := c.ds[0]
+			d.Extended = append(pending, d.Extended...)
+			d.OrigMode, d.NewMode = 0, 0
+			parseModes(d)
+			pending = nil
+		}
+		ds = append(ds, c.ds...)
+		pending = append(pending, c.trailing...)
+	}
+	return ds, nil
+}
+
+// parseChunk parses a part of a multi-file diff split by
+// splitMultiFileDiff.
+func parseChunk(chunk []byte, o *parseOptions, last bool) parsedChunk {
+	r := &MultiFileDiffReader{reader: o.lineReader(chunk), opts: o}
+	var c parsedChunk
+	for {
+		d, trailing, err := r.readFile()
+		if d != nil {
+			c.ds = append(c.ds, d)
+		}
+		if err == io.EOF {
+			// Content that includes the start of a file's diff cannot be
+			// moved to the next part, as that file would have been read
+			// from it.
+			for _, line := range trailing {
+				if !last && strings.HasPrefix(line, "diff --git ") {
+					return c
+				}
+			}
+			c.trailing = trailing
+			c.ok = true
+			return c
+		}
+		if err != nil {
+			return c
+		}
+	}
+}
+
+// splitMultiFileDiff splits diff before each line that starts the diff of a
+// file however the lines before it are parsed (see WithConcurrency). It
+// does not split after a "--- " line, whose diff would be parsed
+// differently if it were the last line.
+func splitMultiFileDiff(diff []byte) [][]byte {
+	var starts []int // the offsets of the lines of diff
+	for i := 0; i < len(diff); {
+		starts = append(starts, i)
+		if j := bytes.IndexByte(diff[i:], '\n'); j != -1 {
+			i += j + 1
+		} else {
+			i = len(diff)
+		}
+	}
+	line := func(i int) []byte {
+		if i >= len(starts) {
+			return nil
+		}
+		return diff[starts[i]:]
+	}
+
+	isGit := bytes.HasPrefix(diff, []byte("diff --git ")) || bytes.Contains(diff, []byte("\ndiff --git "))
+	var chunks [][]byte
+	chunkStart := 0
+	for i := 1; i < len(starts); i++ {
+		if bytes.HasPrefix(line(i-1), []byte("--- ")) {
+			continue
+		}
+		var split bool
+		if isGit {
+			split = bytes.HasPrefix(line(i), []byte("diff --git "))
+		} else {
+			split = bytes.HasPrefix(line(i), []byte("--- ")) && bytes.HasPrefix(line(i+1), []byte("+++ ")) && bytes.HasPrefix(line(i+2), hunkPrefix)
+		}
+		if split {
+			chunks = append(chunks, diff[chunkStart:starts[i]])
+			chunkStart = starts[i]
+		}
+	}
+	return append(chunks, diff[chunkStart:])
+}
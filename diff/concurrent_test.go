@@ -0,0 +1,72 @@
+package diff
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseMultiFileDiff_Concurrency(t *testing.T) {
+	filenames, err := filepath.Glob(filepath.Join("testdata", "*.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A git log -p style diff, with commit headers between the files.
+	gitLog := []byte(`commit 1
+
+    first
+
+diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -1 +1 @@
+-a
++b
+commit 2
+
+    second
+
+diff --git a/g b/g
+new file mode 100644
+index 0000000..e69de29
+diff --git a/h b/h
+--- a/h
++++ b/h
+@@ -1 +1 @@
+-x
++y
+`)
+	inputs := map[string][]byte{"git log": gitLog}
+	for _, filename := range filenames {
+		if inputs[filename], err = ioutil.ReadFile(filename); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, diffData := range inputs {
+		want, wantErr := ParseMultiFileDiff(diffData)
+		got, err := ParseMultiFileDiff(diffData, WithConcurrency(4))
+		if !reflect.DeepEqual(err, wantErr) {
+			t.Errorf("%s: got error %v, want %v", name, err, wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: concurrently parsed diffs mismatch (-want +got):\n%s", name, cmp.Diff(want, got))
+		}
+	}
+
+	// The commit header before the first file is a part of its own.
+	if got := len(splitMultiFileDiff(gitLog)); got != 4 {
+		t.Errorf("got %d parts of the git log diff, want 4", got)
+	}
+	if got := len(splitMultiFileDiff(inputs[filepath.Join("testdata", "sample_multi_file_without_extended.diff")])); got != 2 {
+		t.Errorf("got %d parts of the diff without extended headers, want 2", got)
+	}
+	if got := bytes.Join(splitMultiFileDiff(gitLog), nil); !bytes.Equal(got, gitLog) {
+		t.Errorf("parts do not add up to the diff: %q", got)
+	}
+}
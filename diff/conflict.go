@@ -0,0 +1,106 @@
+package diff
+
+import "bytes"
+
+// A LineRange is a range of lines in a file, from Start to End inclusive,
+// numbered from 1.
+type LineRange struct {
+	Start, End int
+}
+
+// conflictMarkerLen is the length of the conflict markers that git writes.
+const conflictMarkerLen = 7
+
+// conflictMarker returns the conflict marker character that line starts
+// with ('<', '|', '=' or '>'), or 0 if the line is not a conflict marker.
+// As for git diff --check, a marker is 7 identical characters that end the
+// line or, except for "=======", are followed by a space.
+func conflictMarker(line []byte) byte {
+	if len(line) < conflictMarkerLen {
+		return 0
+	}
+	c := line[0]
+	switch c {
+	case '<', '|', '=', '>':
+	default:
+		return 0
+	}
+	if !bytes.Equal(line[:conflictMarkerLen], bytes.Repeat([]byte{c}, conflictMarkerLen)) {
+		return 0
+	}
+	rest := line[conflictMarkerLen:]
+	if len(rest) == 0 || (c != '=' && rest[0] == ' ') {
+		return c
+	}
+	return 0
+}
+
+// HasConflictMarkers reports whether d adds any lines that are merge
+// conflict markers ("<<<<<<<", "|||||||", "=======" or ">>>>>>>"), which
+// usually means it would commit an unresolved conflict.
+func (d *FileDiff) HasConflictMarkers() bool {
+	for _, h := range d.Hunks {
+		for _, l := range h.lines() {
+			if l.op == '+' && conflictMarker(l.text) != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ConflictRegions returns the ranges of lines in the new file that are
+// unresolved merge conflicts added by d. A region spans from an added
+// "<<<<<<<" marker line to the next added ">>>>>>>" marker line in the same
+// hunk. An added marker line that is not part of such a region is returned
+// as a region by itself.
+func (d *FileDiff) ConflictRegions() []LineRange {
+	var regions []LineRange
+	for _, h := range d.Hunks {
+		_, newLine := h.lineStarts()
+		start := 0      // line of the open region's "<<<<<<<" marker, if any
+		var stray []int // other markers in the open region
+		for _, l := range h.lines() {
+			if l.op == '-' {
+				continue
+			}
+			if l.op == '+' {
+				switch conflictMarker(l.text) {
+				case '<':
+					if start != 0 {
+						regions = appendLineRanges(regions, start)
+						regions = appendLineRanges(regions, stray...)
+					}
+					start, stray = newLine, nil
+				case '>':
+					if start != 0 {
+						regions = append(regions, LineRange{Start: start, End: newLine})
+						start, stray = 0, nil
+					} else {
+						regions = appendLineRanges(regions, newLine)
+					}
+				case '|', '=':
+					if start != 0 {
+						stray = append(stray, newLine)
+					} else {
+						regions = appendLineRanges(regions, newLine)
+					}
+				}
+			}
+			newLine++
+		}
+		if start != 0 {
+			regions = appendLineRanges(regions, start)
+			regions = appendLineRanges(regions, stray...)
+		}
+	}
+	return regions
+}
+
+// appendLineRanges appends a single-line range for each of the given lines.
+func appendLineRanges(ranges []LineRange, lines ...int) []LineRange {
+	for _, line := range lines {
+		ranges = append(ranges, LineRange{Start: line, End: line})
+	}
+	return ranges
+}
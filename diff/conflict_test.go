@@ -0,0 +1,66 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileDiff_ConflictRegions(t *testing.T) {
+	tests := map[string]struct {
+		diff        string
+		wantRegions []LineRange
+	}{
+		"conflict added": {
+			diff: `--- a/f
++++ b/f
+@@ -1,3 +1,7 @@
+ a
++<<<<<<< HEAD
+ b
++=======
++B
++>>>>>>> topic
+ c
+@@ -10,1 +14,2 @@
+ j
++=======
+`,
+			wantRegions: []LineRange{{Start: 2, End: 6}, {Start: 15, End: 15}},
+		},
+		"unterminated conflict": {
+			diff: `--- a/f
++++ b/f
+@@ -1,1 +1,4 @@
+ a
++<<<<<<< HEAD
++b
++=======
+`,
+			wantRegions: []LineRange{{Start: 2, End: 2}, {Start: 4, End: 4}},
+		},
+		"no conflict": {
+			diff: `--- a/f
++++ b/f
+@@ -1,3 +1,5 @@
+-<<<<<<< HEAD
++a
+ ========
++<<<<<<<<
++>>>>>>>x
+ c
+`,
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			d := parseFileDiffString(t, test.diff)
+			if got, want := d.HasConflictMarkers(), len(test.wantRegions) > 0; got != want {
+				t.Errorf("got HasConflictMarkers %v, want %v", got, want)
+			}
+			if got := d.ConflictRegions(); !cmp.Equal(got, test.wantRegions) {
+				t.Errorf("regions mismatch (-want +got):\n%s", cmp.Diff(test.wantRegions, got))
+			}
+		})
+	}
+}
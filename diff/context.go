@@ -0,0 +1,495 @@
+package diff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrBadContextHunkRange is when a hunk of a context diff has a
+	// malformed or inconsistent "*** a,b ****" or "--- a,b ----" range.
+	ErrBadContextHunkRange = errors.New("bad context diff hunk range")
+
+	// ErrBadContextHunkLine is when a line of a context diff hunk does not
+	// start with "  ", "- ", "+ " or "! ", or when the two sides of a hunk
+	// do not agree on its context lines.
+	ErrBadContextHunkLine = errors.New("bad context diff hunk line")
+)
+
+var (
+	contextOrigRange = regexp.MustCompile(`^\*\*\* (\d+)(?:,(\d+))? \*\*\*\*$`)
+	contextNewRange  = regexp.MustCompile(`^--- (\d+)(?:,(\d+))? ----$`)
+)
+
+// contextHunkSeparator is the line that starts each hunk of a context diff,
+// optionally followed by a space and a section heading.
+const contextHunkSeparator = "***************"
+
+// ParseFileDiffContext parses the diff of a single file in the context
+// format printed by diff -c:
+//
+//	*** oldname	Sun Oct 11 15:12:20 2009
+//	--- newname	Sun Oct 11 15:12:30 2009
+//	***************
+//	*** 1,3 ****
+//	  a
+//	! b
+//	  c
+//	--- 1,3 ----
+//	  a
+//	! B
+//	  c
+//
+// The result uses the same model as ParseFileDiff: each hunk's body is in
+// unified format, with the changed ("!") lines of the original side as
+// removed lines followed by those of the new side as added lines. Lines
+// before the file header are kept in Extended.
+func ParseFileDiffContext(diff []byte) (*FileDiff, error) {
+	ds, err := ParseMultiFileDiffContext(diff)
+	if err != nil {
+		return nil, err
+	}
+	switch len(ds) {
+	case 0:
+		return nil, &ParseError{0, 0, ErrNoFileHeader}
+	case 1:
+		return ds[0], nil
+	}
+	return nil, fmt.Errorf("context diff has %d files, want 1", len(ds))
+}
+
+// ParseMultiFileDiffContext parses a multi-file diff in the context format,
+// such as the output of diff -c -r. "Only in" messages are parsed as by
+// ParseMultiFileDiff; other lines between files (such as the "diff -c -r"
+// command lines) are kept in the Extended field of the following file, and
+// lines after the last file are ignored. See ParseFileDiffContext.
+func ParseMultiFileDiffContext(diff []byte) ([]*FileDiff, error) {
+	p := newLineParser(diff)
+
+	var ds []*FileDiff
+	var extended []string
+	for p.i < len(p.lines) {
+		line := p.text(p.i)
+		if ok, source, filename := parseOnlyInMessage([]byte(line)); ok {
+			ds = append(ds, &FileDiff{OrigName: filepath.Join(string(source), string(filename)), Extended: extended})
+			extended = nil
+			p.next()
+			continue
+		}
+		if !strings.HasPrefix(line, "*** ") || p.i+1 == len(p.lines) || !strings.HasPrefix(p.text(p.i+1), "--- ") {
+			extended = append(extended, line)
+			p.next()
+			continue
+		}
+
+		d, err := p.readFile()
+		if err != nil {
+			return nil, err
+		}
+		d.Extended = extended
+		extended = nil
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
+
+// A lineParser reads a diff in a line-based format (such as the context
+// format) line by line.
+type lineParser struct {
+	lines  []string // the lines of the diff, with their newlines
+	i      int      // the index of the next line
+	offset int64    // the offset of the next line
+}
+
+func newLineParser(diff []byte) *lineParser {
+	lines := strings.SplitAfter(string(diff), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return &lineParser{lines: lines}
+}
+
+// text returns line i without its newline.
+func (p *lineParser) text(i int) string {
+	return strings.TrimSuffix(p.lines[i], "\n")
+}
+
+// next advances to the next line.
+func (p *lineParser) next() {
+	p.offset += int64(len(p.lines[p.i]))
+	p.i++
+}
+
+// parseError returns a *ParseError for the next line.
+func (p *lineParser) parseError(err error) error {
+	return &ParseError{p.i + 1, p.offset, err}
+}
+
+// readFile reads the file header and the hunks of a file's diff.
+func (p *lineParser) readFile() (*FileDiff, error) {
+	d := &FileDiff{}
+	var err error
+	d.OrigName, d.OrigTime, d.OrigTimeLayout, err = p.readFileHeader("*** ")
+	if err != nil {
+		return nil, err
+	}
+	d.NewName, d.NewTime, d.NewTimeLayout, err = p.readFileHeader("--- ")
+	if err != nil {
+		return nil, err
+	}
+
+	var position int32
+	for p.i < len(p.lines) && strings.HasPrefix(p.text(p.i), contextHunkSeparator) {
+		h, err := p.readHunk()
+		if err != nil {
+			return nil, err
+		}
+		position++ // account for the hunk header line
+		h.StartPosition = position
+		position += int32(bytes.Count(h.Body, []byte{'\n'}))
+		d.Hunks = append(d.Hunks, h)
+	}
+	return d, nil
+}
+
+// readFileHeader reads a "*** " or "--- " file header line.
+func (p *lineParser) readFileHeader(prefix string) (string, *time.Time, string, error) {
+	line := p.text(p.i)
+	if !strings.HasPrefix(line, prefix) {
+		return "", nil, "", p.parseError(ErrBadFileHeader)
+	}
+	parts := strings.SplitN(strings.TrimSpace(line[len(prefix):]), "\t", 2)
+	var timestamp *time.Time
+	var layout string
+	if len(parts) == 2 {
+		ts, l, err := parseTimestamp(parts[1])
+		if err != nil {
+			return "", nil, "", err
+		}
+		timestamp, layout = &ts, l
+	}
+	p.next()
+	return parts[0], timestamp, layout, nil
+}
+
+// A contextSection is the original or new side of a context diff hunk.
+type contextSection struct {
+	start, count int  // from the range header; count is -1 if not given
+	omitted      bool // true if the hunk has no lines for this side
+	lines        []hunkLine
+}
+
+// readHunk reads a context diff hunk, starting at its separator line, and
+// converts it to a unified hunk.
+func (p *lineParser) readHunk() (*Hunk, error) {
+	// Errors about the hunk as a whole are reported at its separator line.
+	hunkError := &ParseError{p.i + 1, p.offset, ErrBadContextHunkLine}
+
+	h := &Hunk{}
+	if rest := p.text(p.i)[len(contextHunkSeparator):]; rest != "" {
+		if rest[0] != ' ' {
+			return nil, p.parseError(ErrBadContextHunkRange)
+		}
+		h.Section = rest[1:]
+		h.EmptySection = h.Section == ""
+	}
+	p.next()
+
+	orig, err := p.readSection(contextOrigRange, "-!", contextNewRange)
+	if err != nil {
+		return nil, err
+	}
+	new, err := p.readSection(contextNewRange, "+!", nil)
+	if err != nil {
+		return nil, err
+	}
+	if orig.omitted && new.omitted {
+		return nil, hunkError
+	}
+
+	// A side without lines has only the context lines of the other.
+	for _, s := range []struct{ omitted, other *contextSection }{{orig, new}, {new, orig}} {
+		if !s.omitted.omitted {
+			continue
+		}
+		for _, l := range s.other.lines {
+			if l.op == ' ' {
+				s.omitted.lines = append(s.omitted.lines, l)
+			}
+		}
+		if s.omitted.count >= 0 && s.omitted.count != len(s.omitted.lines) {
+			hunkError.Err = ErrBadContextHunkRange
+			return nil, hunkError
+		}
+	}
+
+	lines, ok := mergeContextSections(orig.lines, new.lines)
+	if !ok {
+		return nil, hunkError
+	}
+	h.setLines(lines)
+	h.OrigStartLine, h.OrigLines = int32(orig.start), int32(len(orig.lines))
+	h.NewStartLine, h.NewLines = int32(new.start), int32(len(new.lines))
+	return h, nil
+}
+
+// readSection reads the range header matching rangeRE and the lines of one
+// side of a hunk, whose change markers are in ops. The side has no lines if
+// the line after the range header matches nextRE, the range header of the
+// other side, or, if nextRE is nil, if it is not a line of the side. Context
+// lines whose trailing space was stripped are accepted, except as the first
+// line of the new side. Removed, added and changed lines are returned with
+// the op '-', '+' and '!'.
+func (p *lineParser) readSection(rangeRE *regexp.Regexp, ops string, nextRE *regexp.Regexp) (*contextSection, error) {
+	if p.i == len(p.lines) {
+		return nil, p.parseError(ErrBadContextHunkRange)
+	}
+	m := rangeRE.FindStringSubmatch(p.text(p.i))
+	if m == nil {
+		return nil, p.parseError(ErrBadContextHunkRange)
+	}
+	s := &contextSection{count: -1}
+	s.start, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		end, _ := strconv.Atoi(m[2])
+		if end < s.start {
+			return nil, p.parseError(ErrBadContextHunkRange)
+		}
+		s.count = end - s.start + 1
+	}
+	p.next()
+
+	isLine := func(line string) bool {
+		return len(line) >= 2 && line[1] == ' ' && (line[0] == ' ' || strings.IndexByte(ops, line[0]) >= 0)
+	}
+	if p.i == len(p.lines) || nextRE != nil && nextRE.MatchString(p.text(p.i)) || nextRE == nil && !isLine(p.text(p.i)) {
+		s.omitted = true
+		return s, nil
+	}
+
+	want := s.count
+	if want < 0 {
+		want = 1 // a single line number with lines is a one-line range
+	}
+	for len(s.lines) < want {
+		if p.i == len(p.lines) {
+			return nil, p.parseError(ErrBadContextHunkLine)
+		}
+		line := p.text(p.i)
+		if !isLine(line) && line != "" && line != " " {
+			return nil, p.parseError(ErrBadContextHunkLine)
+		}
+		l := hunkLine{op: ' '}
+		if len(line) >= 2 {
+			l.op, l.text = line[0], []byte(line[2:])
+		}
+		p.next()
+		if p.i < len(p.lines) && strings.HasPrefix(p.lines[p.i], `\ `) {
+			l.noNewline = true
+			p.next()
+		}
+		s.lines = append(s.lines, l)
+	}
+	return s, nil
+}
+
+// mergeContextSections merges the lines of the original and new sides of
+// a context diff hunk into the lines of a unified hunk. It reports false if
+// the sides do not have the same context lines.
+func mergeContextSections(orig, new []hunkLine) ([]hunkLine, bool) {
+	var lines []hunkLine
+	i, j := 0, 0
+	for i < len(orig) || j < len(new) {
+		switch {
+		case i < len(orig) && orig[i].op != ' ':
+			// Removed lines, and changed lines, which come before the
+			// lines that replace them.
+			for ; i < len(orig) && orig[i].op != ' '; i++ {
+				l := orig[i]
+				l.op = '-'
+				lines = append(lines, l)
+			}
+		case j < len(new) && new[j].op != ' ':
+			for ; j < len(new) && new[j].op != ' '; j++ {
+				l := new[j]
+				l.op = '+'
+				lines = append(lines, l)
+			}
+		case i < len(orig) && j < len(new):
+			if !bytes.Equal(orig[i].text, new[j].text) {
+				return nil, false
+			}
+			if orig[i].noNewline != new[j].noNewline {
+				// Only the newline differs, so this is a change.
+				lines = append(lines, hunkLine{op: '-', text: orig[i].text, noNewline: orig[i].noNewline},
+					hunkLine{op: '+', text: new[j].text, noNewline: new[j].noNewline})
+			} else {
+				lines = append(lines, orig[i])
+			}
+			i++
+			j++
+		default:
+			return nil, false
+		}
+	}
+	return lines, true
+}
+
+// contextTimeFormatLayout is the layout used to print file header
+// timestamps in context diffs, unless the FileDiff records the layout it
+// was parsed with. It is the traditional ctime format diff -c uses.
+const contextTimeFormatLayout = time.ANSIC
+
+// PrintMultiFileDiffContext prints a multi-file diff in the context format
+// (see PrintFileDiffContext).
+func PrintMultiFileDiffContext(ds []*FileDiff, opts ...PrintOption) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, d := range ds {
+		diff, err := PrintFileDiffContext(d, opts...)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(diff)
+	}
+	return buf.Bytes(), nil
+}
+
+// PrintFileDiffContext prints a FileDiff in the context format printed by
+// diff -c, which ParseFileDiffContext parses. Each hunk is printed as its
+// original lines followed by its new lines; a side is left out if it has no
+// removed (or added) lines. Runs of removed and added lines that replace
+// each other are marked with "!", other removed lines with "-" and added
+// lines with "+". Extended headers are printed as they are.
+func PrintFileDiffContext(d *FileDiff, opts ...PrintOption) ([]byte, error) {
+	var buf bytes.Buffer
+	o := newPrintOptions(opts)
+	for _, xheader := range o.extended(d) {
+		fmt.Fprintln(&buf, xheader)
+	}
+	if d.isOnlyIn() {
+		fmt.Fprintf(&buf, onlyInMessage, filepath.Dir(d.OrigName), filepath.Base(d.OrigName))
+		return buf.Bytes(), nil
+	}
+	if d.Hunks == nil {
+		return buf.Bytes(), nil
+	}
+	hunks := o.hunks(d.Hunks)
+	if o.skipEmptyHunks && len(hunks) == 0 {
+		return buf.Bytes(), nil
+	}
+
+	origName, newName := o.prefixed(d.OrigName, d.NewName)
+	if d.IsDevNullOrig() {
+		origName = devNull
+	} else {
+		origName = o.quote(origName)
+	}
+	if d.IsDevNullNew() {
+		newName = devNull
+	} else {
+		newName = o.quote(newName)
+	}
+	origTime, origLayout := o.timestamp(d.OrigTime, d.OrigTimeLayout)
+	printContextFileHeader(&buf, "*** ", origName, origTime, origLayout)
+	newTime, newLayout := o.timestamp(d.NewTime, d.NewTimeLayout)
+	printContextFileHeader(&buf, "--- ", newName, newTime, newLayout)
+	for _, h := range hunks {
+		printContextHunk(&buf, h)
+	}
+	return buf.Bytes(), nil
+}
+
+// printContextFileHeader prints a "*** " or "--- " file header line.
+func printContextFileHeader(w io.Writer, prefix, filename string, timestamp *time.Time, layout string) {
+	fmt.Fprint(w, prefix, filename)
+	if timestamp != nil {
+		if layout == "" {
+			layout = contextTimeFormatLayout
+		}
+		fmt.Fprint(w, "\t", timestamp.Format(layout))
+	}
+	fmt.Fprintln(w)
+}
+
+// printContextHunk prints h in the context format.
+func printContextHunk(buf *bytes.Buffer, h *Hunk) {
+	buf.WriteString(contextHunkSeparator)
+	if h.Section != "" || h.EmptySection {
+		buf.WriteString(" " + h.Section)
+	}
+	buf.WriteByte('\n')
+
+	// Split the lines into the two sides, marking runs that have both
+	// removed and added lines with '!'.
+	lines := h.lines()
+	var orig, new []hunkLine
+	hasRemoved, hasAdded := false, false
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			orig, new = append(orig, lines[i]), append(new, lines[i])
+			i++
+			continue
+		}
+		j := i
+		var removed, added []hunkLine
+		for ; j < len(lines) && lines[j].op != ' '; j++ {
+			if lines[j].op == '-' {
+				removed = append(removed, lines[j])
+			} else {
+				added = append(added, lines[j])
+			}
+		}
+		hasRemoved = hasRemoved || len(removed) > 0
+		hasAdded = hasAdded || len(added) > 0
+		if len(removed) > 0 && len(added) > 0 {
+			for k := range removed {
+				removed[k].op = '!'
+			}
+			for k := range added {
+				added[k].op = '!'
+			}
+		}
+		orig, new = append(orig, removed...), append(new, added...)
+		i = j
+	}
+
+	fmt.Fprintf(buf, "*** %s ****\n", contextRange(h.OrigStartLine, h.OrigLines))
+	if hasRemoved {
+		printContextLines(buf, orig)
+	}
+	fmt.Fprintf(buf, "--- %s ----\n", contextRange(h.NewStartLine, h.NewLines))
+	if hasAdded {
+		printContextLines(buf, new)
+	}
+}
+
+// contextRange formats the range of a hunk side with the given start and
+// number of lines as a context diff does: "a,b" for lines a to b, and just
+// "a" for a single line or for an empty range after line a.
+func contextRange(start, lines int32) string {
+	if lines <= 1 {
+		return strconv.Itoa(int(start))
+	}
+	return fmt.Sprintf("%d,%d", start, start+lines-1)
+}
+
+// printContextLines prints the lines of one side of a context diff hunk,
+// each prefixed with its op and a space.
+func printContextLines(buf *bytes.Buffer, lines []hunkLine) {
+	for _, l := range lines {
+		buf.WriteByte(l.op)
+		buf.WriteByte(' ')
+		buf.Write(l.text)
+		buf.WriteByte('\n')
+		if l.noNewline {
+			buf.WriteString(noNewlineMessage + "\n")
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package diff
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseMultiFileDiffContext(t *testing.T) {
+	diffData, err := ioutil.ReadFile(filepath.Join("testdata", "sample_context.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := ParseMultiFileDiffContext(diffData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 3 {
+		t.Fatalf("got %d file diffs, want 3", len(diffs))
+	}
+	if got := diffs[2].Type(); got != OnlyIn {
+		t.Errorf("got type %s for the last diff, want %s", got, OnlyIn)
+	}
+
+	// The same diff in unified format.
+	want := `diff -c -r a/f.txt b/f.txt
+--- a/f.txt	Sun Oct 11 15:12:20 2009
++++ b/f.txt	Sun Oct 11 15:12:20 2009
+@@ -1,8 +1,9 @@
+ a
+-b
++B
+ c
+ d
+ e
+ f
+ g
+ h
++x
+\ No newline at end of file
+diff -c -r a/g.txt b/g.txt
+--- a/g.txt	Sun Oct 11 15:12:20 2009
++++ b/g.txt	Sun Oct 11 15:12:20 2009
+@@ -1,2 +1,1 @@
+ one
+-two
+Only in a: gone.txt
+`
+	printed, err := PrintMultiFileDiff(diffs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(printed); got != want {
+		t.Errorf("unified diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	unified, err := ParseMultiFileDiff([]byte(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range unified {
+		if !cmp.Equal(diffs[i].Hunks, unified[i].Hunks) {
+			t.Errorf("file #%d: hunks mismatch (-unified +context):\n%s", i, cmp.Diff(unified[i].Hunks, diffs[i].Hunks))
+		}
+	}
+}
+
+func TestParseFileDiffContext(t *testing.T) {
+	tests := map[string]struct {
+		diff string
+		want string // the hunks in unified format
+	}{
+		"insertion into an empty file": {
+			diff: "*** e\n--- f\n***************\n*** 0 ****\n--- 1 ----\n+ x\n",
+			want: "@@ -0,0 +1,1 @@\n+x\n",
+		},
+		"deletion without context": {
+			diff: "*** p\n--- q\n***************\n*** 5 ****\n- 5\n--- 4 ----\n",
+			want: "@@ -5,1 +4,0 @@\n-5\n",
+		},
+		"insertion without context": {
+			diff: "*** q\n--- p\n***************\n*** 4 ****\n--- 5 ----\n+ 5\n",
+			want: "@@ -4,0 +5,1 @@\n+5\n",
+		},
+		"section heading": {
+			diff: "*** a\n--- b\n*************** func f()\n*** 2,3 ****\n  x\n- y\n--- 2 ----\n",
+			want: "@@ -2,2 +2,1 @@ func f()\n x\n-y\n",
+		},
+		"stripped context line": {
+			diff: "*** a\n--- b\n***************\n*** 1,3 ****\n\n! y\n\n--- 1,3 ----\n  \n! z\n\n",
+			want: "@@ -1,3 +1,3 @@\n \n-y\n+z\n \n",
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			d, err := ParseFileDiffContext([]byte(test.diff))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(d.HunksOnly()); got != test.want {
+				t.Errorf("hunks mismatch (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestParseFileDiffContext_Error(t *testing.T) {
+	tests := map[string]struct {
+		diff string
+		want error
+	}{
+		"bad range": {
+			diff: "*** a\n--- b\n***************\n*** 1,x ****\n",
+			want: &ParseError{4, 28, ErrBadContextHunkRange},
+		},
+		"wrong line count": {
+			diff: "*** a\n--- b\n***************\n*** 1,2 ****\n- x\n--- 1 ----\n",
+			want: &ParseError{6, 45, ErrBadContextHunkLine},
+		},
+		"context mismatch": {
+			diff: "*** a\n--- b\n***************\n*** 1,2 ****\n  x\n- y\n--- 1,2 ----\n  z\n+ w\n",
+			want: &ParseError{3, 12, ErrBadContextHunkLine},
+		},
+		"no changes": {
+			diff: "*** a\n--- b\n***************\n*** 1 ****\n--- 1 ----\n",
+			want: &ParseError{3, 12, ErrBadContextHunkLine},
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			_, err := ParseFileDiffContext([]byte(test.diff))
+			if !reflect.DeepEqual(err, test.want) {
+				t.Errorf("got error %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestPrintFileDiffContext(t *testing.T) {
+	// Printing the parsed sample gives back diff -c's output.
+	diffData, err := ioutil.ReadFile(filepath.Join("testdata", "sample_context.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := ParseMultiFileDiffContext(diffData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed, err := PrintMultiFileDiffContext(diffs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(printed), string(diffData); got != want {
+		t.Errorf("context diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	// A unified diff, with the output of diff -c -p for the same files.
+	d, err := ParseFileDiff([]byte(`--- a/f.c	2009-10-11 15:12:20.000000000 -0700
++++ b/f.c	2009-10-11 15:12:30.000000000 -0700
+@@ -1,4 +1,5 @@ int main()
++x
+ a
+-b
+-c
++C
+ d
+@@ -9 +9,0 @@ int f()
+-z
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed, err = PrintFileDiffContext(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `*** a/f.c	2009-10-11 15:12:20.000000000 -0700
+--- b/f.c	2009-10-11 15:12:30.000000000 -0700
+*************** int main()
+*** 1,4 ****
+  a
+! b
+! c
+  d
+--- 1,5 ----
++ x
+  a
+! C
+  d
+*************** int f()
+*** 9 ****
+- z
+--- 9 ----
+`
+	if got := string(printed); got != want {
+		t.Errorf("context diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
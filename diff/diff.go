@@ -9,21 +9,51 @@ import (
 //
 // A file unified diff has a header that resembles the following:
 //
-//   --- oldname	2009-10-11 15:12:20.000000000 -0700
-//   +++ newname	2009-10-11 15:12:30.000000000 -0700
+//	--- oldname	2009-10-11 15:12:20.000000000 -0700
+//	+++ newname	2009-10-11 15:12:30.000000000 -0700
+//
+// An added file has "/dev/null" as its OrigName, and a deleted file has
+// "/dev/null" as its NewName (see IsDevNullOrig and IsDevNullNew). A
+// FileDiff with an empty NewName instead represents an "Only in <dir>:
+// <name>" message (as printed by diff -r), with the path of the file in
+// OrigName.
 type FileDiff struct {
 	// the original name of the file
 	OrigName string
 	// the original timestamp (nil if not present)
 	OrigTime *time.Time
-	// the new name of the file (often same as OrigName)
+	// the layout OrigTime was parsed with (empty if not present), used to
+	// print the timestamp the way it was read
+	OrigTimeLayout string
+	// the new name of the file (often same as OrigName; empty for an "Only
+	// in" message)
 	NewName string
 	// the new timestamp (nil if not present)
 	NewTime *time.Time
+	// the layout NewTime was parsed with (empty if not present)
+	NewTimeLayout string
 	// extended header lines (e.g., git's "new mode <mode>", "rename from <path>", etc.)
 	Extended []string
+	// the original file mode, as a git mode such as 0100644 (0 if not known)
+	OrigMode uint32
+	// the new file mode (0 if not known)
+	NewMode uint32
+	// the original blob's object name, as abbreviated by git (empty if not known)
+	OrigSHA string
+	// the new blob's object name (empty if not known)
+	NewSHA string
+	// the change status as reported by git diff --raw, e.g. "M", "A", "D", or
+	// "R086" for a rename with its similarity score (empty if not known)
+	Status string
 	// hunks that were changed from orig to new
 	Hunks []*Hunk
+	// the unparsed text of the hunks, set instead of Hunks when the diff is
+	// parsed with WithLazyHunks (nil otherwise); see LoadHunks
+	RawHunks []byte
+	// the decoded payload of a "GIT binary patch" (nil if not present), whose
+	// lines are also kept in Extended; if Extended has no "GIT binary patch"
+	// line, PrintFileDiff encodes it with PrintBinaryPatch
+	BinaryPatch *BinaryPatch
 }
 
 // A Hunk represents a series of changes (additions or deletions) in a file's
@@ -39,8 +69,12 @@ type Hunk struct {
 	NewStartLine int32
 	// number of lines the hunk applies to in the new file
 	NewLines int32
-	// optional section heading
+	// optional section heading, i.e., the text following the hunk range's
+	// closing "@@ " (verbatim, including any trailing whitespace)
 	Section string
+	// true if the hunk header has a space after the closing "@@" but no
+	// section heading, so that it is printed the same way
+	EmptySection bool
 	// 0-indexed line offset in unified file diff (including section headers); this is
 	// only set when Hunks are read from entire file diff (i.e., when ReadAllHunks is
 	// called) This accounts for hunk headers, too, so the StartPosition of the first
@@ -48,6 +82,10 @@ type Hunk struct {
 	StartPosition int32
 	// hunk body (lines prefixed with '-', '+', or ' ')
 	Body []byte
+	// the changed parts of removed and added lines that replace each other,
+	// for showing changes within lines; only set by NewFileDiff with
+	// WithIntraLineRefinement, and only printed with WithIntraLineHighlight
+	Refinements []Refinement
 }
 
 // A Stat is a diff stat that represents the number of lines added/changed/deleted.
@@ -60,16 +98,109 @@ type Stat struct {
 	Deleted int32
 }
 
+// devNull is the name used in file headers for the missing side of an
+// added or deleted file.
+const devNull = "/dev/null"
+
+// IsDevNullOrig reports whether the original side of d is /dev/null, i.e.,
+// whether d adds a new file. An empty OrigName is treated the same way (and
+// printed as /dev/null), so a FileDiff constructed for an added file may
+// leave it unset.
+func (d *FileDiff) IsDevNullOrig() bool {
+	return d.OrigName == devNull || (d.OrigName == "" && d.NewName != "")
+}
+
+// IsDevNullNew reports whether the new side of d is /dev/null, i.e., whether
+// d deletes a file. An empty NewName is not treated this way: it denotes an
+// "Only in" message.
+func (d *FileDiff) IsDevNullNew() bool {
+	return d.NewName == devNull
+}
+
+// IsEmptiedFile reports whether d removes all the content of a file that it
+// does not delete, e.g., because the file was truncated. This is the case if
+// d has hunks and they all remove lines, leaving none in the new file: each
+// new range is empty and starts before the first line. A hunk without
+// context cannot show whether lines follow it, so it is taken to empty the
+// file.
+func (d *FileDiff) IsEmptiedFile() bool {
+	if d.IsDevNullNew() || d.isOnlyIn() || len(d.Hunks) == 0 {
+		return false
+	}
+	for _, h := range d.Hunks {
+		if h.NewLines != 0 || h.NewStartLine != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ModeChanged reports whether d changes the mode of a file, i.e., whether
+// both its original and new modes are known and they differ. It is false
+// for added and deleted files, which have only one mode.
+func (d *FileDiff) ModeChanged() bool {
+	return d.OrigMode != 0 && d.NewMode != 0 && d.OrigMode != d.NewMode
+}
+
+// isOnlyIn reports whether d represents an "Only in <dir>: <name>" message
+// rather than a diff.
+func (d *FileDiff) isOnlyIn() bool {
+	return d.NewName == ""
+}
+
 // Stat computes the number of lines added/changed/deleted in all
-// hunks in this file's diff.
+// hunks in this file's diff. If the hunks have not been loaded (see
+// WithLazyHunks), they are counted in RawHunks without parsing them.
 func (d *FileDiff) Stat() Stat {
 	total := Stat{}
+	if d.Hunks == nil && d.RawHunks != nil {
+		for _, h := range rawHunkBodies(d.RawHunks) {
+			total.add(h.Stat())
+		}
+		return total
+	}
 	for _, h := range d.Hunks {
 		total.add(h.Stat())
 	}
 	return total
 }
 
+// LoadHunks parses the hunks in RawHunks into Hunks, if they have not been
+// parsed yet (see WithLazyHunks), and clears RawHunks. The options should
+// be the same as those the diff was parsed with.
+func (d *FileDiff) LoadHunks(opts ...ParseOption) error {
+	if d.Hunks != nil || d.RawHunks == nil {
+		return nil
+	}
+	hunks, err := ParseHunks(d.RawHunks, opts...)
+	if err != nil {
+		return err
+	}
+	d.Hunks, d.RawHunks = hunks, nil
+	return nil
+}
+
+// rawHunkBodies returns hunks with the bodies of the hunks in raw, for
+// counting their lines. The 'No newline at end of file' marks are dropped.
+func rawHunkBodies(raw []byte) []*Hunk {
+	var hunks []*Hunk
+	for len(raw) > 0 {
+		line := raw
+		if i := bytes.IndexByte(raw, '\n'); i != -1 {
+			line = raw[:i+1]
+		}
+		raw = raw[len(line):]
+		switch {
+		case bytes.HasPrefix(line, hunkPrefix):
+			hunks = append(hunks, &Hunk{})
+		case len(hunks) > 0 && !bytes.HasPrefix(line, []byte{'\\'}):
+			h := hunks[len(hunks)-1]
+			h.Body = append(h.Body, line...)
+		}
+	}
+	return hunks
+}
+
 // Stat computes the number of lines added/changed/deleted in this
 // hunk.
 func (h *Hunk) Stat() Stat {
@@ -107,21 +238,98 @@ func (h *Hunk) Stat() Stat {
 	return st
 }
 
+// hunkLine is a single line of a hunk body.
+type hunkLine struct {
+	// op is the line's prefix: ' ' (context), '-' (removed) or '+' (added).
+	op byte
+	// text is the line's content, without its prefix or trailing newline.
+	text []byte
+	// noNewline is true if the line is not terminated by a newline (i.e., it
+	// was followed by a 'No newline at end of file' mark).
+	noNewline bool
+}
+
+// lines splits the hunk body into its lines, resolving the 'No newline at
+// end of file' marks recorded by the parser. Empty lines are treated as
+// context lines, as some editors strip the leading space from them.
+func (h *Hunk) lines() []hunkLine {
+	var lines []hunkLine
+	body := h.Body
+	offset := 0
+	for len(body) > 0 {
+		var line hunkLine
+		var raw []byte
+		if i := bytes.IndexByte(body, '\n'); i == -1 {
+			raw, body = body, nil
+			line.noNewline = true
+			offset += len(raw)
+		} else {
+			raw, body = body[:i], body[i+1:]
+			offset += i + 1
+			line.noNewline = h.OrigNoNewlineAt > 0 && offset == int(h.OrigNoNewlineAt)
+		}
+		if len(raw) == 0 {
+			line.op = ' '
+		} else {
+			line.op, line.text = raw[0], raw[1:]
+		}
+		if line.op == '\\' {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// setLines sets the hunk body (and OrigNoNewlineAt) to the given lines. It
+// is the inverse of lines.
+func (h *Hunk) setLines(lines []hunkLine) {
+	var body []byte
+	h.OrigNoNewlineAt = 0
+	for _, l := range lines {
+		body = append(body, l.op)
+		body = append(body, l.text...)
+		switch {
+		case !l.noNewline:
+			body = append(body, '\n')
+		case l.op == '-':
+			// Retain the newline in the body, as the parser does, and record
+			// that the original had none.
+			body = append(body, '\n')
+			h.OrigNoNewlineAt = int32(len(body))
+		}
+	}
+	h.Body = body
+}
+
 var (
 	hunkPrefix          = []byte("@@ ")
 	onlyInMessagePrefix = []byte("Only in ")
+
+	// signatureSeparator is the line that separates a patch from the
+	// signature in git format-patch output.
+	signatureSeparator = []byte("-- ")
 )
 
 const hunkHeader = "@@ -%d,%d +%d,%d @@"
 const onlyInMessage = "Only in %s: %s\n"
 
-// diffTimeParseLayout is the layout used to parse the time in unified diff file
-// header timestamps.
+// diffTimeParseLayouts are the layouts tried, in order, to parse the time in
+// unified diff file header timestamps. GNU diff includes nanoseconds by
+// default, but other producers (such as git with --date=iso) omit the
+// fractional seconds and sometimes the timezone. diff -c prints the
+// traditional ctime format.
 // See https://www.gnu.org/software/diffutils/manual/html_node/Detailed-Unified.html.
-const diffTimeParseLayout = "2006-01-02 15:04:05 -0700"
+var diffTimeParseLayouts = []string{
+	"2006-01-02 15:04:05.000000000 -0700",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05.000000000",
+	"2006-01-02 15:04:05",
+	time.ANSIC,
+}
 
 // diffTimeFormatLayout is the layout used to format (i.e., print) the time in unified diff file
-// header timestamps.
+// header timestamps, unless the FileDiff records the layout it was parsed with.
 // See https://www.gnu.org/software/diffutils/manual/html_node/Detailed-Unified.html.
 const diffTimeFormatLayout = "2006-01-02 15:04:05.000000000 -0700"
 
@@ -2,6 +2,7 @@ package diff
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"io/ioutil"
 	"path/filepath"
@@ -76,6 +77,7 @@ func TestParseHunksAndPrintHunks(t *testing.T) {
 		{filename: "empty.diff"},
 		{filename: "sample_hunk_lines_start_with_minuses.diff"},
 		{filename: "sample_hunk_lines_start_with_minuses_pluses.diff"},
+		{filename: "sample_hunks_section_whitespace.diff"},
 	}
 	for _, test := range tests {
 		diffData, err := ioutil.ReadFile(filepath.Join("testdata", test.filename))
@@ -101,6 +103,33 @@ func TestParseHunksAndPrintHunks(t *testing.T) {
 	}
 }
 
+func TestParseHunksSection(t *testing.T) {
+	diffData, err := ioutil.ReadFile(filepath.Join("testdata", "sample_hunks_section_whitespace.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hunks, err := ParseHunks(diffData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		section      string
+		emptySection bool
+	}{
+		{section: "func foo() {  "},
+		{emptySection: true},
+		{},
+	}
+	if len(hunks) != len(want) {
+		t.Fatalf("got %d hunks, want %d", len(hunks), len(want))
+	}
+	for i, h := range hunks {
+		if h.Section != want[i].section || h.EmptySection != want[i].emptySection {
+			t.Errorf("hunk %d: got Section %q and EmptySection %v, want %q and %v", i, h.Section, h.EmptySection, want[i].section, want[i].emptySection)
+		}
+	}
+}
+
 func TestParseFileDiffHeaders(t *testing.T) {
 	tests := []struct {
 		filename string
@@ -109,32 +138,40 @@ func TestParseFileDiffHeaders(t *testing.T) {
 		{
 			filename: "sample_file.diff",
 			wantDiff: &FileDiff{
-				OrigName: "oldname",
-				OrigTime: unix(1255273940), // 2009-10-11 15:12:20
-				NewName:  "newname",
-				NewTime:  unix(1255273950), // 2009-10-11 15:12:30
+				OrigName:       "oldname",
+				OrigTime:       unix(1255273940), // 2009-10-11 15:12:20
+				OrigTimeLayout: "2006-01-02 15:04:05.000000000 -0700",
+				NewName:        "newname",
+				NewTime:        unix(1255273950), // 2009-10-11 15:12:30
+				NewTimeLayout:  "2006-01-02 15:04:05.000000000 -0700",
 			},
 		},
 		{
 			filename: "sample_file_no_fractional_seconds.diff",
 			wantDiff: &FileDiff{
-				OrigName: "goyaml.go",
-				OrigTime: unix(1322164040), // 2011-11-24 19:47:20
-				NewName:  "goyaml.go",
-				NewTime:  unix(1322486679), // 2011-11-28 13:24:39
+				OrigName:       "goyaml.go",
+				OrigTime:       unix(1322164040), // 2011-11-24 19:47:20
+				OrigTimeLayout: "2006-01-02 15:04:05 -0700",
+				NewName:        "goyaml.go",
+				NewTime:        unix(1322486679), // 2011-11-28 13:24:39
+				NewTimeLayout:  "2006-01-02 15:04:05 -0700",
 			},
 		},
 		{
 			filename: "sample_file_extended.diff",
 			wantDiff: &FileDiff{
-				OrigName: "oldname",
-				OrigTime: unix(1255273940), // 2009-10-11 15:12:20
-				NewName:  "newname",
-				NewTime:  unix(1255273950), // 2009-10-11 15:12:30
+				OrigName:       "oldname",
+				OrigTime:       unix(1255273940), // 2009-10-11 15:12:20
+				OrigTimeLayout: "2006-01-02 15:04:05.000000000 -0700",
+				NewName:        "newname",
+				NewTime:        unix(1255273950), // 2009-10-11 15:12:30
+				NewTimeLayout:  "2006-01-02 15:04:05.000000000 -0700",
 				Extended: []string{
 					"diff --git a/vcs/git_cmd.go b/vcs/git_cmd.go",
 					"index aa4de15..7c048ab 100644",
 				},
+				OrigMode: 0100644,
+				NewMode:  0100644,
 			},
 		},
 		{
@@ -149,6 +186,7 @@ func TestParseFileDiffHeaders(t *testing.T) {
 					"new file mode 100644",
 					"index 0000000..e69de29",
 				},
+				NewMode: 0100644,
 			},
 		},
 		{
@@ -163,6 +201,8 @@ func TestParseFileDiffHeaders(t *testing.T) {
 					"old mode 100644",
 					"new mode 100755",
 				},
+				OrigMode: 0100644,
+				NewMode:  0100755,
 			},
 		},
 		{
@@ -178,6 +218,7 @@ func TestParseFileDiffHeaders(t *testing.T) {
 					"index 0000000..b51756e",
 					"Binary files /dev/null and b/diff/binary-image.png differ",
 				},
+				NewMode: 0100644,
 			},
 		},
 		{
@@ -192,6 +233,7 @@ func TestParseFileDiffHeaders(t *testing.T) {
 					"deleted file mode 100644",
 					"index e69de29..0000000",
 				},
+				OrigMode: 0100644,
 			},
 		},
 		{
@@ -207,6 +249,7 @@ func TestParseFileDiffHeaders(t *testing.T) {
 					"index aebdfc7..0000000",
 					"Binary files a/187/player/random/gopher-0.png and /dev/null differ",
 				},
+				OrigMode: 0100644,
 			},
 		},
 		{
@@ -239,6 +282,8 @@ func TestParseFileDiffHeaders(t *testing.T) {
 					"rename from textfile.txt",
 					"rename to textfile2.txt",
 				},
+				OrigMode: 0100644,
+				NewMode:  0100755,
 			},
 		},
 		{
@@ -252,6 +297,8 @@ func TestParseFileDiffHeaders(t *testing.T) {
 					"diff --git \"a/\\345\\225\\206\\345\\223\\201\\350\\257\\246\\346\\203\\205.txt\" \"b/\\345\\225\\206\\345\\223\\201\\350\\257\\246\\346\\203\\205.txt\"",
 					"index e69de29..c67479b 100644",
 				},
+				OrigMode: 0100644,
+				NewMode:  0100644,
 			},
 		},
 		{
@@ -269,6 +316,8 @@ func TestParseFileDiffHeaders(t *testing.T) {
 					"index 17a971d..599f8dd 100644",
 					"Binary files a/data/Font.png and b/data/Other.png differ",
 				},
+				OrigMode: 0100644,
+				NewMode:  0100644,
 			},
 		},
 	}
@@ -309,6 +358,7 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"new file mode 100644",
 						"index 0000000..3be2928",
 					},
+					NewMode: 0100644,
 				},
 				{
 					OrigName: "/dev/null",
@@ -320,6 +370,7 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"new file mode 100644",
 						"index 0000000..e69de29",
 					},
+					NewMode: 0100644,
 				},
 				{
 					OrigName: "/dev/null",
@@ -331,6 +382,7 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"new file mode 100644",
 						"index 0000000..ee946eb",
 					},
+					NewMode: 0100644,
 				},
 			},
 		},
@@ -347,6 +399,7 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"deleted file mode 100644",
 						"index 3be2928..0000000",
 					},
+					OrigMode: 0100644,
 				},
 				{
 					OrigName: "a/vendor/go/build/testdata/empty/dummy",
@@ -358,6 +411,7 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"deleted file mode 100644",
 						"index e69de29..0000000",
 					},
+					OrigMode: 0100644,
 				},
 				{
 					OrigName: "a/vendor/go/build/testdata/multi/file.go",
@@ -369,6 +423,7 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"deleted file mode 100644",
 						"index ee946eb..0000000",
 					},
+					OrigMode: 0100644,
 				},
 			},
 		},
@@ -379,11 +434,15 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 					OrigName: "a/sample.sh",
 					NewName:  "b/sample.sh",
 					Extended: []string{"diff --git a/sample.sh b/sample.sh", "old mode 100755", "new mode 100644"},
+					OrigMode: 0100755,
+					NewMode:  0100644,
 				},
 				{
 					OrigName: "a/sample2.sh",
 					NewName:  "b/sample2.sh",
 					Extended: []string{"diff --git a/sample2.sh b/sample2.sh", "old mode 100755", "new mode 100644"},
+					OrigMode: 0100755,
+					NewMode:  0100644,
 				},
 			},
 		},
@@ -399,6 +458,8 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"diff --git a/README.md b/README.md",
 						"index 5f3d591..96a24fa 100644",
 					},
+					OrigMode: 0100644,
+					NewMode:  0100644,
 				},
 				{
 					OrigName: "a/docs/integrations/Email_Notifications.md",
@@ -421,6 +482,8 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"diff --git a/release_notes.md b/release_notes.md",
 						"index f2ff13f..f060cb5 100644",
 					},
+					OrigMode: 0100644,
+					NewMode:  0100644,
 				},
 			},
 		},
@@ -436,6 +499,8 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"diff --git a/README.md b/README.md",
 						"index 7b73e04..36cde13 100644",
 					},
+					OrigMode: 0100644,
+					NewMode:  0100644,
 				},
 				{
 					OrigName: "a/data/Font.png",
@@ -447,6 +512,8 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"index 17a971d..599f8dd 100644",
 						"Binary files a/data/Font.png and b/data/Font.png differ",
 					},
+					OrigMode: 0100644,
+					NewMode:  0100644,
 				},
 				{
 					OrigName: "a/main.go",
@@ -457,6 +524,8 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"diff --git a/main.go b/main.go",
 						"index 1aced1e..98a982e 100644",
 					},
+					OrigMode: 0100644,
+					NewMode:  0100644,
 				},
 			},
 		},
@@ -480,6 +549,11 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"HcmV?d00001",
 						"",
 					},
+					OrigMode: 0100644,
+					BinaryPatch: &BinaryPatch{
+						Forward: &BinaryHunk{Type: BinaryLiteral, Data: []byte{}},
+						Reverse: &BinaryHunk{Type: BinaryLiteral, Data: []byte{}},
+					},
 				},
 				{
 					OrigName: "a/logo-old.png",
@@ -497,6 +571,12 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"HcmV?d00001",
 						"",
 					},
+					OrigMode: 0100644,
+					NewMode:  0100644,
+					BinaryPatch: &BinaryPatch{
+						Forward: &BinaryHunk{Type: BinaryLiteral, Data: []byte{}},
+						Reverse: &BinaryHunk{Type: BinaryLiteral, Data: []byte{}},
+					},
 				},
 				{
 					OrigName: "a/logo.png",
@@ -527,6 +607,11 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"HcmV?d00001",
 						"",
 					},
+					NewMode: 0100644,
+					BinaryPatch: &BinaryPatch{
+						Forward: &BinaryHunk{Type: BinaryLiteral, Data: []byte{}},
+						Reverse: &BinaryHunk{Type: BinaryLiteral, Data: []byte{}},
+					},
 				},
 			},
 		},
@@ -543,6 +628,7 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"new file mode 100644",
 						"index 0000000..3be2928",
 					},
+					NewMode: 0100644,
 				},
 				{
 					OrigName: "/dev/null",
@@ -554,6 +640,7 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"new file mode 100644",
 						"index 0000000..e69de29",
 					},
+					NewMode: 0100644,
 				},
 				{
 					OrigName: "/dev/null",
@@ -565,6 +652,7 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"new file mode 100644",
 						"index 0000000..ee946eb",
 					},
+					NewMode: 0100644,
 				},
 			},
 		},
@@ -715,6 +803,7 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"new file mode 100644",
 						"index 0000000..e69de29",
 					},
+					NewMode: 0100644,
 				},
 				{
 					OrigName: "/dev/null",
@@ -724,6 +813,7 @@ func TestParseMultiFileDiffHeaders(t *testing.T) {
 						"new file mode 100644",
 						"index 0000000..c3ed4be",
 					},
+					NewMode: 0100644,
 				},
 				{
 					OrigName: "a/existing file with spaces",
@@ -796,6 +886,7 @@ func TestParseFileDiffAndPrintFileDiff(t *testing.T) {
 	}{
 		{filename: "sample_file.diff"},
 		{filename: "sample_file_no_timestamp.diff"},
+		{filename: "sample_file_no_fractional_seconds.diff"},
 		{filename: "sample_file_extended.diff"},
 		{filename: "sample_file_extended_empty_new.diff"},
 		{filename: "sample_file_extended_empty_new_binary.diff"},
@@ -1049,3 +1140,508 @@ func TestFileDiff_Stat(t *testing.T) {
 		}
 	}
 }
+
+func TestParseFileDiffTimestampLayouts(t *testing.T) {
+	tests := map[string]struct {
+		timestamp  string
+		wantTime   time.Time
+		wantLayout string
+	}{
+		"default long form": {
+			timestamp:  "2009-10-11 15:12:20.000000000 -0700",
+			wantTime:   time.Date(2009, 10, 11, 22, 12, 20, 0, time.UTC),
+			wantLayout: "2006-01-02 15:04:05.000000000 -0700",
+		},
+		"iso form": {
+			timestamp:  "2023-01-02 15:04:05 +0100",
+			wantTime:   time.Date(2023, 1, 2, 14, 4, 5, 0, time.UTC),
+			wantLayout: "2006-01-02 15:04:05 -0700",
+		},
+		"no timezone": {
+			timestamp:  "2023-01-02 15:04:05",
+			wantTime:   time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC),
+			wantLayout: "2006-01-02 15:04:05",
+		},
+		"no timezone with fractional seconds": {
+			timestamp:  "2023-01-02 15:04:05.500000000",
+			wantTime:   time.Date(2023, 1, 2, 15, 4, 5, 500000000, time.UTC),
+			wantLayout: "2006-01-02 15:04:05.000000000",
+		},
+		"milliseconds": {
+			timestamp:  "2023-01-02 15:04:05.123 +0100",
+			wantTime:   time.Date(2023, 1, 2, 14, 4, 5, 123000000, time.UTC),
+			wantLayout: "2006-01-02 15:04:05.000 -0700",
+		},
+		"microseconds with no timezone": {
+			timestamp:  "2023-01-02 15:04:05.120000",
+			wantTime:   time.Date(2023, 1, 2, 15, 4, 5, 120000000, time.UTC),
+			wantLayout: "2006-01-02 15:04:05.000000",
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			input := "--- a.txt\t" + test.timestamp + "\n" +
+				"+++ b.txt\t" + test.timestamp + "\n" +
+				"@@ -1,1 +1,1 @@\n" +
+				"-a\n" +
+				"+b\n"
+			diff, err := ParseFileDiff([]byte(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff.OrigTime == nil || !diff.OrigTime.Equal(test.wantTime) {
+				t.Errorf("got OrigTime %v, want %v", diff.OrigTime, test.wantTime)
+			}
+			if diff.NewTime == nil || !diff.NewTime.Equal(test.wantTime) {
+				t.Errorf("got NewTime %v, want %v", diff.NewTime, test.wantTime)
+			}
+			if diff.OrigTimeLayout != test.wantLayout || diff.NewTimeLayout != test.wantLayout {
+				t.Errorf("got layouts %q and %q, want %q", diff.OrigTimeLayout, diff.NewTimeLayout, test.wantLayout)
+			}
+
+			printed, err := PrintFileDiff(diff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(printed) != input {
+				t.Errorf("printed file diff != original file diff\n\n# PrintFileDiff output - Original:\n%s", cmp.Diff(input, string(printed)))
+			}
+		})
+	}
+}
+
+func TestFileDiff_HunksOnly(t *testing.T) {
+	tests := map[string]string{
+		"single hunk": `diff --git a/f b/f
+index aa4de15..7c048ab 100644
+--- a/f
++++ b/f
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+`,
+		"multiple hunks with no newline": `diff --git a/f b/f
+index aa4de15..7c048ab 100644
+--- a/f
++++ b/f
+@@ -1,2 +1,2 @@ section
+-a
++b
+ c
+@@ -10,2 +10,2 @@
+ d
+-e
+\ No newline at end of file
++f
+\ No newline at end of file
+`,
+	}
+	for label, input := range tests {
+		t.Run(label, func(t *testing.T) {
+			diff, err := ParseFileDiff([]byte(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := PrintHunks(diff.Hunks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := diff.HunksOnly()
+			if !bytes.Equal(got, want) {
+				t.Errorf("HunksOnly != PrintHunks\n\n# HunksOnly output - PrintHunks output:\n%s", cmp.Diff(want, got))
+			}
+			if !bytes.HasPrefix(got, hunkPrefix) || !strings.HasSuffix(input, string(got)) {
+				t.Errorf("HunksOnly output is not exactly the hunks of the input:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestFileDiff_IsDevNull(t *testing.T) {
+	tests := []struct {
+		filename          string
+		wantOrig, wantNew bool
+	}{
+		{filename: "sample_file_extended_empty_new.diff", wantOrig: true},
+		{filename: "sample_file_extended_empty_deleted.diff", wantNew: true},
+		{filename: "sample_file_extended.diff"},
+	}
+	for _, test := range tests {
+		t.Run(test.filename, func(t *testing.T) {
+			diffData, err := ioutil.ReadFile(filepath.Join("testdata", test.filename))
+			if err != nil {
+				t.Fatal(err)
+			}
+			diff, err := ParseFileDiff(diffData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := diff.IsDevNullOrig(); got != test.wantOrig {
+				t.Errorf("got IsDevNullOrig %v, want %v", got, test.wantOrig)
+			}
+			if got := diff.IsDevNullNew(); got != test.wantNew {
+				t.Errorf("got IsDevNullNew %v, want %v", got, test.wantNew)
+			}
+		})
+	}
+}
+
+func TestPrintFileDiff_DevNull(t *testing.T) {
+	hunks := []*Hunk{{OrigStartLine: 0, OrigLines: 0, NewStartLine: 1, NewLines: 1, Body: []byte("+a\n")}}
+	tests := map[string]struct {
+		diff       *FileDiff
+		want       string
+		wantOrig   bool
+		wantNew    bool
+		wantOnlyIn bool
+	}{
+		"parsed add": {
+			diff:     &FileDiff{OrigName: "/dev/null", NewName: "b/f", Hunks: hunks},
+			want:     "--- /dev/null\n+++ b/f\n@@ -0,0 +1,1 @@\n+a\n",
+			wantOrig: true,
+		},
+		"add with empty orig name": {
+			diff:     &FileDiff{NewName: "b/f", Hunks: hunks},
+			want:     "--- /dev/null\n+++ b/f\n@@ -0,0 +1,1 @@\n+a\n",
+			wantOrig: true,
+		},
+		"only in": {
+			diff:       &FileDiff{OrigName: "dir/f"},
+			want:       "Only in dir: f\n",
+			wantOnlyIn: true,
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			if got := test.diff.IsDevNullOrig(); got != test.wantOrig {
+				t.Errorf("got IsDevNullOrig %v, want %v", got, test.wantOrig)
+			}
+			if got := test.diff.IsDevNullNew(); got != test.wantNew {
+				t.Errorf("got IsDevNullNew %v, want %v", got, test.wantNew)
+			}
+			if got := test.diff.isOnlyIn(); got != test.wantOnlyIn {
+				t.Errorf("got isOnlyIn %v, want %v", got, test.wantOnlyIn)
+			}
+			printed, err := PrintFileDiff(test.diff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(printed) != test.want {
+				t.Errorf("printed file diff mismatch (-want +got):\n%s", cmp.Diff(test.want, string(printed)))
+			}
+		})
+	}
+}
+
+func TestFileDiff_ModeChanged(t *testing.T) {
+	tests := map[string]struct {
+		diff              string
+		wantOrig, wantNew uint32
+		wantChanged       bool
+	}{
+		"add with mode": {
+			diff: `diff --git a/run.sh b/run.sh
+new file mode 100755
+index 0000000..1111111
+--- /dev/null
++++ b/run.sh
+@@ -0,0 +1,1 @@
++echo hi
+`,
+			wantNew: 0100755,
+		},
+		"only new mode": {
+			diff: `diff --git a/run.sh b/run.sh
+new mode 100755
+--- /dev/null
++++ b/run.sh
+@@ -0,0 +1,1 @@
++echo hi
+`,
+			wantNew: 0100755,
+		},
+		"delete with mode": {
+			diff: `diff --git a/run.sh b/run.sh
+deleted file mode 100755
+index 1111111..0000000
+--- a/run.sh
++++ /dev/null
+@@ -1,1 +0,0 @@
+-echo hi
+`,
+			wantOrig: 0100755,
+		},
+		"mode flip": {
+			diff: `diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+index 1111111..2222222
+--- a/run.sh
++++ b/run.sh
+@@ -1,1 +1,1 @@
+-echo hi
++echo hello
+`,
+			wantOrig:    0100644,
+			wantNew:     0100755,
+			wantChanged: true,
+		},
+		"unchanged mode": {
+			diff: `diff --git a/run.sh b/run.sh
+index 1111111..2222222 100755
+--- a/run.sh
++++ b/run.sh
+@@ -1,1 +1,1 @@
+-echo hi
++echo hello
+`,
+			wantOrig: 0100755,
+			wantNew:  0100755,
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			diff, err := ParseFileDiff([]byte(test.diff))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff.OrigMode != test.wantOrig || diff.NewMode != test.wantNew {
+				t.Errorf("got modes %o and %o, want %o and %o", diff.OrigMode, diff.NewMode, test.wantOrig, test.wantNew)
+			}
+			if got := diff.ModeChanged(); got != test.wantChanged {
+				t.Errorf("got ModeChanged %v, want %v", got, test.wantChanged)
+			}
+		})
+	}
+}
+
+func TestFileDiff_IsEmptiedFile(t *testing.T) {
+	tests := map[string]struct {
+		diff string
+		want bool
+	}{
+		"truncated": {
+			diff: "diff --git a/f b/f\nindex 1111111..e69de29 100644\n--- a/f\n+++ b/f\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+			want: true,
+		},
+		"deleted": {
+			diff: "diff --git a/f b/f\ndeleted file mode 100644\nindex 1111111..0000000\n--- a/f\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		"lines remain": {
+			diff: "--- a/f\n+++ b/f\n@@ -1,3 +1,1 @@\n-a\n-b\n c\n",
+		},
+		"lines replaced": {
+			diff: "--- a/f\n+++ b/f\n@@ -1,2 +1,1 @@\n-a\n-b\n+c\n",
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			diff, err := ParseFileDiff([]byte(test.diff))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := diff.IsEmptiedFile(); got != test.want {
+				t.Errorf("got IsEmptiedFile %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseMultiFileDiff_FormatPatchBinary(t *testing.T) {
+	diffData, err := ioutil.ReadFile(filepath.Join("testdata", "sample_format_patch_binary.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := ParseMultiFileDiff(diffData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 {
+		t.Fatalf("got %d file diffs, want 2", len(diffs))
+	}
+
+	bin := diffs[0]
+	if bin.OrigName != "a/img.bin" || bin.NewName != "b/img.bin" {
+		t.Errorf("got names %q and %q, want a/img.bin and b/img.bin", bin.OrigName, bin.NewName)
+	}
+	if !bin.IsBinary() || bin.Hunks != nil {
+		t.Errorf("got IsBinary %v and %d hunks, want a binary diff without hunks", bin.IsBinary(), len(bin.Hunks))
+	}
+	wantPatch := []string{"GIT binary patch", "literal 7", "OcmZQzWKK*<P5}S|@Bxni", "", "literal 6", "NcmZQzWJ*j*1^@zG0V)6h", ""}
+	if got := bin.Extended[len(bin.Extended)-len(wantPatch):]; !cmp.Equal(got, wantPatch) {
+		t.Errorf("binary patch mismatch (-want +got):\n%s", cmp.Diff(wantPatch, got))
+	}
+	if bin.OrigMode != 0100644 || bin.NewMode != 0100644 {
+		t.Errorf("got modes %o and %o, want 100644", bin.OrigMode, bin.NewMode)
+	}
+
+	// The signature that follows the last hunk is not part of it.
+	if got, want := string(diffs[1].HunksOnly()), "@@ -1,1 +1,2 @@\n text\n+more\n"; got != want {
+		t.Errorf("got hunks %q, want %q", got, want)
+	}
+}
+
+func TestScanMultiFileDiff(t *testing.T) {
+	diffData, err := ioutil.ReadFile(filepath.Join("testdata", "sample_multi_file_rename.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ParseMultiFileDiff(diffData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []*FileDiff
+	err = ScanMultiFileDiff(bytes.NewReader(diffData), func(d *FileDiff) error {
+		got = append(got, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("scanned diffs mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	// An error from the callback stops the scan.
+	stop := errors.New("stop")
+	n := 0
+	err = ScanMultiFileDiff(bytes.NewReader(diffData), func(d *FileDiff) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("got error %v after %d files, want %v after 1", err, n, stop)
+	}
+}
+
+func TestParseMultiFileDiff_ZeroCopy(t *testing.T) {
+	filenames, err := filepath.Glob(filepath.Join("testdata", "*.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range filenames {
+		diffData, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, wantErr := ParseMultiFileDiff(diffData)
+		got, err := ParseMultiFileDiff(diffData, WithZeroCopy())
+		if !reflect.DeepEqual(err, wantErr) {
+			t.Errorf("%s: got error %v, want %v", filename, err, wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: zero-copy diffs mismatch (-want +got):\n%s", filename, cmp.Diff(want, got))
+		}
+	}
+
+	diffData := []byte("--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n\\ No newline at end of file\n")
+	d, err := ParseFileDiff(diffData, WithZeroCopy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := d.Hunks[0].Body
+	if want := " a\n-b\n+c"; string(body) != want {
+		t.Fatalf("got body %q, want %q", body, want)
+	}
+	if &body[0] != &diffData[len("--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n")] {
+		t.Error("body is not a sub-slice of the diff")
+	}
+
+	// Appending to the body must not overwrite the diff.
+	_ = append(body, 'x')
+	if diffData[len(diffData)-len(noNewlineMessage)-2] != '\n' {
+		t.Error("appending to the body overwrote the diff")
+	}
+}
+
+func TestParseMultiFileDiff_LazyHunks(t *testing.T) {
+	filenames, err := filepath.Glob(filepath.Join("testdata", "*.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range filenames {
+		diffData, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, wantErr := ParseMultiFileDiff(diffData)
+		got, err := ParseMultiFileDiff(diffData, WithLazyHunks())
+		if !reflect.DeepEqual(err, wantErr) {
+			t.Errorf("%s: got error %v, want %v", filename, err, wantErr)
+			continue
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: got %d file diffs, want %d", filename, len(got), len(want))
+			continue
+		}
+		for i, d := range got {
+			if d.Hunks != nil {
+				t.Errorf("%s: file #%d: hunks were parsed eagerly", filename, i)
+			}
+			if got, want := d.Stat(), want[i].Stat(); got != want {
+				t.Errorf("%s: file #%d: got stat %+v before loading hunks, want %+v", filename, i, got, want)
+			}
+			if err := d.LoadHunks(); err != nil {
+				t.Fatalf("%s: file #%d: %s", filename, i, err)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: lazily parsed diffs mismatch (-want +got):\n%s", filename, cmp.Diff(want, got))
+		}
+	}
+
+	d, err := ParseMultiFileDiff([]byte("--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n"), WithLazyHunks())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PrintFileDiff(d[0]); err == nil {
+		t.Error("PrintFileDiff succeeded before the hunks were loaded")
+	}
+}
+
+func TestParseFileDiff_ZeroBasedLines(t *testing.T) {
+	oneBased := `--- a/f
++++ b/f
+@@ -1,2 +1,3 @@
+ a
++b
+ c
+@@ -5,0 +7,1 @@
++x
+@@ -10,1 +11,0 @@
+-y
+`
+	zeroBased := `--- a/f
++++ b/f
+@@ -0,2 +0,3 @@
+ a
++b
+ c
+@@ -5,0 +6,1 @@
++x
+@@ -9,1 +11,0 @@
+-y
+`
+	want, err := ParseFileDiff([]byte(oneBased))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseFileDiff([]byte(zeroBased), WithZeroBasedLines())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("0-based diff parsed differently (-1-based +0-based):\n%s", cmp.Diff(want, got))
+	}
+
+	printed, err := PrintFileDiff(got, WithZeroBasedOutput())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(printed) != zeroBased {
+		t.Errorf("printed 0-based diff mismatch (-want +got):\n%s", cmp.Diff(zeroBased, string(printed)))
+	}
+}
@@ -0,0 +1,229 @@
+package diff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// ErrBadEdCommand is when an ed script has a malformed command, a command
+// that is out of range of the original file, or commands that are not in
+// descending order of line numbers (as diff -e prints them, so that each
+// command's line numbers are not shifted by the commands before it).
+var ErrBadEdCommand = errors.New("bad ed script command")
+
+var edCommand = regexp.MustCompile(`^(\d+)(?:,(\d+))?([acd])$`)
+
+// An edChange is a change made by an ed script command: the replacement of
+// the original lines [lo, hi) (1-based) with text.
+type edChange struct {
+	lo, hi int
+	text   [][]byte
+}
+
+// ParseHunksEd parses an ed script, as printed by diff -e, and returns the
+// hunks it makes to orig, the contents of the original file, which gives
+// the lines the script removes. Each command becomes a hunk without context
+// lines, as in a unified diff produced with -U0, and the hunks are in
+// ascending order. Lines that are a lone ".", which diff -e prints as ".."
+// followed by "s/.//" (and "a" to continue appending), are supported. Since
+// ed scripts cannot express a missing newline at the end of the file, all
+// added lines end with a newline, and text appended after a last line
+// without a newline gives it one, as ed does: the hunk removes the line and
+// adds it back.
+func ParseHunksEd(script, orig []byte) ([]*Hunk, error) {
+	p := newLineParser(script)
+	origLines := splitLines(orig)
+
+	var changes []edChange
+	for p.i < len(p.lines) {
+		cmdError := p.parseError(ErrBadEdCommand)
+		m := edCommand.FindStringSubmatch(p.text(p.i))
+		if m == nil {
+			if line := p.text(p.i); (line == "w" || line == "q") && p.i == len(p.lines)-1 {
+				break // as appended to run the script with ed
+			}
+			return nil, cmdError
+		}
+		start, _ := strconv.Atoi(m[1])
+		end := start
+		if m[2] != "" {
+			if end, _ = strconv.Atoi(m[2]); end <= start {
+				return nil, cmdError
+			}
+		}
+		c := edChange{lo: start, hi: end + 1}
+		if m[3] == "a" {
+			if m[2] != "" {
+				return nil, cmdError
+			}
+			c.lo, c.hi = start+1, start+1
+		} else if start == 0 {
+			return nil, cmdError
+		}
+		if c.hi > len(origLines)+1 || (len(changes) > 0 && c.hi > changes[len(changes)-1].lo) {
+			return nil, cmdError
+		}
+		p.next()
+
+		if m[3] != "d" {
+			text, err := p.readEdText()
+			if err != nil {
+				return nil, err
+			}
+			c.text = text
+		}
+		changes = append(changes, c)
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].lo < changes[j].lo })
+	// ed gives the last line a newline if text is appended after it, so
+	// the hunk must remove it and add it back with one.
+	if n := len(changes); n > 0 && len(origLines) > 0 && origLines[len(origLines)-1].noNewline {
+		last := &changes[n-1]
+		if last.lo == len(origLines)+1 && len(last.text) > 0 && (n == 1 || changes[n-2].hi <= len(origLines)) {
+			last.lo--
+			last.text = append([][]byte{origLines[len(origLines)-1].text}, last.text...)
+		}
+	}
+	var hunks []*Hunk
+	var position int32
+	delta := 0 // number of lines added minus removed by the previous hunks
+	for _, c := range changes {
+		h := &Hunk{
+			OrigStartLine: int32(c.lo),
+			OrigLines:     int32(c.hi - c.lo),
+			NewStartLine:  int32(c.lo + delta),
+			NewLines:      int32(len(c.text)),
+		}
+		if h.OrigLines == 0 {
+			h.OrigStartLine--
+		}
+		if h.NewLines == 0 {
+			h.NewStartLine--
+		}
+		var lines []hunkLine
+		for _, l := range origLines[c.lo-1 : c.hi-1] {
+			lines = append(lines, hunkLine{op: '-', text: l.text, noNewline: l.noNewline})
+		}
+		for _, text := range c.text {
+			lines = append(lines, hunkLine{op: '+', text: text})
+		}
+		h.setLines(lines)
+		delta += len(c.text) - (c.hi - c.lo)
+
+		position++ // account for the hunk header line
+		h.StartPosition = position
+		position += int32(bytes.Count(h.Body, []byte{'\n'}))
+		hunks = append(hunks, h)
+	}
+	return hunks, nil
+}
+
+// readEdText reads the text of an "a" or "c" command, up to the "." line
+// that ends it, including any "s/.//" and "a" commands that follow to
+// insert lone "." lines.
+func (p *lineParser) readEdText() ([][]byte, error) {
+	var text [][]byte
+	for {
+		if p.i == len(p.lines) {
+			return nil, p.parseError(ErrBadEdCommand)
+		}
+		line := p.text(p.i)
+		p.next()
+		if line != "." {
+			text = append(text, []byte(line))
+			continue
+		}
+
+		if p.i == len(p.lines) || p.text(p.i) != "s/.//" {
+			return text, nil
+		}
+		if len(text) == 0 || string(text[len(text)-1]) != ".." {
+			return nil, p.parseError(ErrBadEdCommand)
+		}
+		text[len(text)-1] = []byte(".")
+		p.next()
+		if p.i == len(p.lines) || p.text(p.i) != "a" {
+			return text, nil
+		}
+		p.next()
+	}
+}
+
+// PrintHunksEd prints hunks as an ed script, as diff -e does: each run of
+// removed and added lines becomes an "a", "c" or "d" command with the line
+// numbers of the original file, and the commands are in descending order,
+// so that ed can run them one after the other. The missing newline at the
+// end of a file cannot be expressed, and is ignored.
+func PrintHunksEd(hunks []*Hunk) ([]byte, error) {
+	var changes []edChange
+	for _, h := range hunks {
+		orig := int(h.OrigStartLine) // the number of the next original line
+		if h.OrigLines == 0 {
+			orig++
+		}
+		lines := h.lines()
+		for i := 0; i < len(lines); {
+			if lines[i].op == ' ' {
+				orig++
+				i++
+				continue
+			}
+			c := edChange{lo: orig, hi: orig}
+			for ; i < len(lines) && lines[i].op != ' '; i++ {
+				if lines[i].op == '-' {
+					c.hi++
+				} else {
+					c.text = append(c.text, lines[i].text)
+				}
+			}
+			orig = c.hi
+			changes = append(changes, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	for i := len(changes) - 1; i >= 0; i-- {
+		c := changes[i]
+		switch {
+		case c.lo == c.hi:
+			fmt.Fprintf(&buf, "%da\n", c.lo-1)
+		case c.hi-c.lo == 1:
+			fmt.Fprintf(&buf, "%d%c\n", c.lo, edOp(c))
+		default:
+			fmt.Fprintf(&buf, "%d,%d%c\n", c.lo, c.hi-1, edOp(c))
+		}
+		if len(c.text) == 0 {
+			continue
+		}
+		for j, text := range c.text {
+			if string(text) != "." {
+				buf.Write(text)
+				buf.WriteByte('\n')
+				continue
+			}
+			// A lone "." would end the text, so insert ".." and remove
+			// its first "." afterwards.
+			buf.WriteString("..\n.\ns/.//\n")
+			if j < len(c.text)-1 {
+				buf.WriteString("a\n")
+			}
+		}
+		if string(c.text[len(c.text)-1]) != "." {
+			buf.WriteString(".\n")
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// edOp returns the command letter of a change that removes lines.
+func edOp(c edChange) byte {
+	if len(c.text) == 0 {
+		return 'd'
+	}
+	return 'c'
+}
@@ -0,0 +1,150 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseHunksEd(t *testing.T) {
+	orig := "a\nb\nc\nd\ne\n"
+	// The output of diff -e for orig and "a\n.\nB\nc\ne\nf\n".
+	script := "5a\nf\n.\n4d\n2c\n..\n.\ns/.//\na\nB\n.\n"
+
+	hunks, err := ParseHunksEd([]byte(script), []byte(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "@@ -2,1 +2,2 @@\n-b\n+.\n+B\n@@ -4,1 +4,0 @@\n-d\n@@ -5,0 +6,1 @@\n+f\n"
+	printed, err := PrintHunks(hunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(printed) != want {
+		t.Errorf("hunks mismatch (-want +got):\n%s", cmp.Diff(want, string(printed)))
+	}
+
+	got, err := PrintHunksEd(hunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != script {
+		t.Errorf("ed script mismatch (-want +got):\n%s", cmp.Diff(script, string(got)))
+	}
+}
+
+func TestParseHunksEd_NoNewline(t *testing.T) {
+	// Appending after a last line without a newline gives it one.
+	orig := "a\nb"
+	hunks, err := ParseHunksEd([]byte("2a\nc\n.\n"), []byte(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "@@ -2,1 +2,2 @@\n-b\n\\ No newline at end of file\n+b\n+c\n"
+	printed, err := PrintHunks(hunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(printed) != want {
+		t.Errorf("hunks mismatch (-want +got):\n%s", cmp.Diff(want, string(printed)))
+	}
+	applied, err := ApplyFileDiff([]byte(orig), &FileDiff{Hunks: hunks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a\nb\nc\n"; string(applied) != want {
+		t.Errorf("got %q, want %q", applied, want)
+	}
+
+	// A change of the last line already gives it a newline.
+	hunks, err = ParseHunksEd([]byte("2a\nc\n.\n2c\nB\n.\n"), []byte(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err = ApplyFileDiff([]byte(orig), &FileDiff{Hunks: hunks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a\nB\nc\n"; string(applied) != want {
+		t.Errorf("got %q, want %q", applied, want)
+	}
+}
+
+func TestPrintHunksEd(t *testing.T) {
+	// Context lines are left out, and the commands use the original line
+	// numbers, last first.
+	hunks, err := ParseHunks([]byte(`@@ -1,6 +1,6 @@
++new
+ a
+-b
+ c
+-d
+-e
++E
+ f
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := PrintHunksEd(hunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "4,5c\nE\n.\n2d\n0a\nnew\n.\n"
+	if string(got) != want {
+		t.Errorf("ed script mismatch (-want +got):\n%s", cmp.Diff(want, string(got)))
+	}
+
+	// Applying the hunks parsed back gives the same result as the original
+	// hunks.
+	orig := []byte("a\nb\nc\nd\ne\nf\n")
+	parsed, err := ParseHunksEd(got, orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want1, err := ApplyFileDiff(orig, &FileDiff{Hunks: hunks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got1, err := ApplyFileDiff(orig, &FileDiff{Hunks: parsed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got1) != string(want1) {
+		t.Errorf("got %q, want %q", got1, want1)
+	}
+}
+
+func TestParseHunksEd_Error(t *testing.T) {
+	orig := []byte("a\nb\nc\n")
+	tests := map[string]struct {
+		script string
+		want   error
+	}{
+		"ascending order": {
+			script: "1d\n3d\n",
+			want:   &ParseError{2, 3, ErrBadEdCommand},
+		},
+		"out of range": {
+			script: "3,4d\n",
+			want:   &ParseError{1, 0, ErrBadEdCommand},
+		},
+		"unterminated text": {
+			script: "1a\nx\n",
+			want:   &ParseError{3, 5, ErrBadEdCommand},
+		},
+		"unknown command": {
+			script: "1p\n",
+			want:   &ParseError{1, 0, ErrBadEdCommand},
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			_, err := ParseHunksEd([]byte(test.script), orig)
+			if !reflect.DeepEqual(err, test.want) {
+				t.Errorf("got error %v, want %v", err, test.want)
+			}
+		})
+	}
+}
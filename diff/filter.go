@@ -0,0 +1,214 @@
+package diff
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Filter returns the diffs of ds whose files match the include patterns
+// (or all of them, if include is empty) and none of the exclude patterns,
+// like the filterdiff tool. A diff matches a pattern if its original or new
+// path does (as in a diff stat, without git's "a/" and "b/" prefixes), so
+// that renames are kept or dropped whichever side matches.
+//
+// The patterns are globs as in a .gitignore file:
+//
+//   - a pattern without a slash, such as "*.go", matches a file or directory
+//     with that name at any depth;
+//   - a pattern with a slash, such as "cmd/*.go", matches paths relative to
+//     the root of the diff (a leading slash is ignored);
+//   - "**" matches any number of directories, as in "**/testdata" or
+//     "docs/**/*.md";
+//   - a pattern ending with a slash only matches directories.
+//
+// A pattern that matches a directory matches the files it contains. Filter
+// returns an error if a pattern is malformed.
+func Filter(ds []*FileDiff, include, exclude []string) ([]*FileDiff, error) {
+	for _, patterns := range []struct {
+		kind string
+		list []string
+	}{{"include", include}, {"exclude", exclude}} {
+		for _, pattern := range patterns.list {
+			if err := checkPathPattern(pattern); err != nil {
+				return nil, fmt.Errorf("invalid %s pattern %q: %s", patterns.kind, pattern, err)
+			}
+		}
+	}
+
+	var filtered []*FileDiff
+	for _, d := range ds {
+		if (len(include) == 0 || d.matchesAny(include)) && !d.matchesAny(exclude) {
+			filtered = append(filtered, d)
+		}
+	}
+	return filtered, nil
+}
+
+// matchesAny reports whether the original or new path of d matches any of
+// the patterns (see Filter).
+func (d *FileDiff) matchesAny(patterns []string) bool {
+	orig, new := d.paths()
+	for _, pattern := range patterns {
+		for _, p := range []string{orig, new} {
+			if p != "" && p != devNull && matchPathPattern(pattern, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkPathPattern returns an error if pattern is malformed.
+func checkPathPattern(pattern string) error {
+	for _, elem := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		if _, err := path.Match(elem, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchPathPattern reports whether the path name, or one of the directories
+// it is in, matches pattern (see Filter).
+func matchPathPattern(pattern, name string) bool {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	patternElems := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	nameElems := strings.Split(name, "/")
+	for n := 1; n <= len(nameElems); n++ {
+		if n == len(nameElems) && dirOnly {
+			break
+		}
+		if matchPathElems(patternElems, nameElems[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPathElems reports whether the elements of a path match those of a
+// pattern, where "**" matches any number of elements.
+func matchPathElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchPathElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// containsLine reports whether line is in one of the ranges rs.
+func containsLine(rs []LineRange, line int) bool {
+	for _, r := range rs {
+		if r.Start <= line && line <= r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterLines returns a copy of d that only makes the changes of d that
+// touch the given lines, so that tools can extract the change around a
+// line: a change (a run of removed and added lines) is kept if it removes a
+// line of the original file in origRanges or adds a line of the new file in
+// newRanges, as numbered in d. The other changes are left out (their
+// removed lines become context lines), and each hunk is split around the
+// changes it keeps with as many context lines as it had, dropping the hunks
+// without changes. The new line numbers are recomputed as by Renumber, and
+// the hunks keep the section headings of the hunks of d they come from.
+func (d *FileDiff) FilterLines(origRanges, newRanges []LineRange) (*FileDiff, error) {
+	if d.Hunks == nil && d.RawHunks != nil {
+		return nil, errHunksNotLoaded
+	}
+	r := *d
+	r.Hunks = nil
+	for _, h := range d.Hunks {
+		lines := h.lines()
+		origLine, newLine := h.lineStarts()
+
+		// Find which changes to keep, turning the others into context.
+		var kept []hunkLine
+		o, n := origLine, newLine
+		for i := 0; i < len(lines); {
+			if lines[i].op == ' ' {
+				kept = append(kept, lines[i])
+				o, n = o+1, n+1
+				i++
+				continue
+			}
+			end, keep := i, false
+			for ; end < len(lines) && lines[end].op != ' '; end++ {
+				switch lines[end].op {
+				case '-':
+					keep = keep || containsLine(origRanges, o)
+					o++
+				case '+':
+					keep = keep || containsLine(newRanges, n)
+					n++
+				}
+			}
+			for _, l := range lines[i:end] {
+				switch {
+				case keep:
+					kept = append(kept, l)
+				case l.op == '-':
+					l.op = ' '
+					kept = append(kept, l)
+				}
+			}
+			i = end
+		}
+
+		// Split the hunk around the changes left, with the context of the
+		// hunk.
+		context := 0
+		for _, l := range lines {
+			if l.op != ' ' {
+				break
+			}
+			context++
+		}
+		trailing := 0
+		for i := len(lines) - 1; i >= 0 && lines[i].op == ' '; i-- {
+			trailing++
+		}
+		if trailing > context {
+			context = trailing
+		}
+		var a, b []fileLine
+		var ops []editOp
+		for _, l := range kept {
+			ops = append(ops, editOp{op: l.op, orig: len(a), new: len(b)})
+			line := fileLine{text: l.text, noNewline: l.noNewline}
+			if l.op != '+' {
+				a = append(a, line)
+			}
+			if l.op != '-' {
+				b = append(b, line)
+			}
+		}
+		for _, nh := range editHunks(ops, a, b, context) {
+			nh.OrigStartLine += int32(origLine - 1)
+			nh.Section, nh.EmptySection = h.Section, h.EmptySection
+			r.Hunks = append(r.Hunks, nh)
+		}
+	}
+	r.Renumber()
+	return &r, nil
+}
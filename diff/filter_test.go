@@ -0,0 +1,174 @@
+package diff
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFilter(t *testing.T) {
+	ds := []*FileDiff{
+		{OrigName: "a/main.go", NewName: "b/main.go"},
+		{OrigName: "a/cmd/tool/tool.go", NewName: "b/cmd/tool/tool.go"},
+		{OrigName: "a/docs/guide/intro.md", NewName: "b/docs/guide/intro.md"},
+		{OrigName: "a/diff/testdata/sample.diff", NewName: "b/diff/testdata/sample.diff"},
+		{OrigName: "/dev/null", NewName: "b/vendor/lib/lib.go"},
+		{OrigName: "a/old/name.txt", NewName: "b/new/name.txt", Extended: []string{"rename from old/name.txt", "rename to new/name.txt"}},
+	}
+	tests := map[string]struct {
+		include, exclude []string
+		want             []string
+	}{
+		"all": {
+			want: []string{"main.go", "cmd/tool/tool.go", "docs/guide/intro.md", "diff/testdata/sample.diff", "vendor/lib/lib.go", "old/name.txt => new/name.txt"},
+		},
+		"base name at any depth": {
+			include: []string{"*.go"},
+			want:    []string{"main.go", "cmd/tool/tool.go", "vendor/lib/lib.go"},
+		},
+		"anchored": {
+			include: []string{"/*.go", "cmd/*/tool.go"},
+			want:    []string{"main.go", "cmd/tool/tool.go"},
+		},
+		"double star": {
+			include: []string{"docs/**/*.md", "**/testdata"},
+			want:    []string{"docs/guide/intro.md", "diff/testdata/sample.diff"},
+		},
+		"directory": {
+			exclude: []string{"vendor/", "testdata/", "name.txt/"},
+			want:    []string{"main.go", "cmd/tool/tool.go", "docs/guide/intro.md", "old/name.txt => new/name.txt"},
+		},
+		"either side of a rename": {
+			include: []string{"old"},
+			want:    []string{"old/name.txt => new/name.txt"},
+		},
+		"include and exclude": {
+			include: []string{"*.go"},
+			exclude: []string{"cmd", "vendor/**"},
+			want:    []string{"main.go"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			filtered, err := Filter(ds, test.include, test.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, d := range filtered {
+				got = append(got, d.path())
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("paths mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := Filter(ds, nil, []string{"[a-"}); err == nil {
+		t.Error("got no error for a malformed pattern")
+	}
+}
+
+func TestFileDiff_FilterLines(t *testing.T) {
+	var orig strings.Builder
+	for i := 1; i <= 20; i++ {
+		orig.WriteString(strconv.Itoa(i) + "\n")
+	}
+	// The diff -U3 output for changes to lines 5, 9 and 17.
+	d := parseFileDiffString(t, `--- a
++++ b
+@@ -2,11 +2,12 @@ first
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+-9
++nine
++nine and a half
+ 10
+ 11
+ 12
+@@ -14,7 +15,6 @@ second
+ 14
+ 15
+ 16
+-17
+ 18
+ 19
+ 20
+`)
+
+	tests := map[string]struct {
+		origRanges, newRanges []LineRange
+		want                  string
+	}{
+		"part of a hunk": {
+			newRanges: []LineRange{{Start: 5, End: 5}},
+			want: `--- a
++++ b
+@@ -2,7 +2,7 @@ first
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`,
+		},
+		"changes after a dropped change": {
+			origRanges: []LineRange{{Start: 17, End: 17}},
+			newRanges:  []LineRange{{Start: 10, End: 12}},
+			want: `--- a
++++ b
+@@ -6,7 +6,8 @@ first
+ 6
+ 7
+ 8
+-9
++nine
++nine and a half
+ 10
+ 11
+ 12
+@@ -14,7 +15,6 @@ second
+ 14
+ 15
+ 16
+-17
+ 18
+ 19
+ 20
+`,
+		},
+		"no changes": {
+			origRanges: []LineRange{{Start: 1, End: 4}},
+			want:       "",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := d.FilterLines(test.origRanges, test.newRanges)
+			if err != nil {
+				t.Fatal(err)
+			}
+			printed, err := PrintFileDiff(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, string(printed)); diff != "" {
+				t.Errorf("printed mismatch (-want +got):\n%s", diff)
+			}
+			if _, err := ApplyFileDiff([]byte(orig.String()), f); err != nil {
+				t.Errorf("ApplyFileDiff: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package diff
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// Fingerprint returns a hex-encoded SHA-256 hash of the hunks of d, as
+// printed by HunksOnly. It identifies the changes d makes independently of
+// its file names, timestamps and extended headers, so two diffs that apply
+// the same hunks have the same fingerprint.
+func (d *FileDiff) Fingerprint() string {
+	sum := sha256.Sum256(d.HunksOnly())
+	return hex.EncodeToString(sum[:])
+}
+
+// ApplyCacheKey returns a key for caching the result of applying d to a
+// file, given origHash, a hash of the original file's contents (in any
+// format the caller chooses). Applying diffs with the same fingerprint to
+// files with the same hash gives the same result, and such pairs have the
+// same key.
+func (d *FileDiff) ApplyCacheKey(origHash string) string {
+	h := sha256.New()
+	// The fingerprint has a fixed length, so the concatenation is
+	// unambiguous.
+	h.Write([]byte(d.Fingerprint()))
+	h.Write([]byte(origHash))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Signature returns a hex-encoded SHA-256 hash of the lines the hunk
+// removes and adds, ignoring its context lines, line numbers and section
+// heading, and whether its last lines end in a newline. Hunks that make the
+// same textual change, such as the same edit applied to many files, have
+// the same signature.
+func (h *Hunk) Signature() string {
+	s := sha256.New()
+	for _, l := range h.lines() {
+		if l.op == ' ' {
+			continue
+		}
+		s.Write([]byte{l.op})
+		s.Write(l.text)
+		s.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(s.Sum(nil))
+}
+
+// PatchID returns the patch ID of ds, as computed by git patch-id --stable:
+// a hex-encoded SHA-1 hash of the diffs, as printed by PrintMultiFileDiff,
+// that ignores whitespace, line numbers, object names and the order of the
+// files. Patches with the same changes, such as a commit before and after
+// a rebase, have the same patch ID. As in git, the "diff --git" line of a
+// file that follows a binary one is not hashed, so moving files before or
+// after a binary one can change the patch ID. Diffs without extended
+// headers are hashed as if they had the headers in FileDiff.GitHeaders, and
+// without timestamps. PatchID returns "" if ds has no changes to hash.
+func PatchID(ds []*FileDiff) (string, error) {
+	var buf bytes.Buffer
+	if err := writeMultiFileDiff(&buf, ds, newPrintOptions([]PrintOption{WithGitHeaders(), WithoutTimestamps()})); err != nil {
+		return "", err
+	}
+
+	// This follows get_one_patchid in git's builtin/patch-id.c. Each
+	// file is hashed separately, and the hashes are added up.
+	var result [sha1.Size]byte
+	h := sha1.New()
+	flush := func() {
+		var carry uint
+		sum := h.Sum(nil)
+		for i := range result {
+			carry += uint(result[i]) + uint(sum[i])
+			result[i] = byte(carry)
+			carry >>= 8
+		}
+		h.Reset()
+	}
+	patchLen := 0
+	before, after := -1, -1
+	binary := false
+	var origSHA, newSHA string
+lines:
+	for _, line := range bytes.SplitAfter(buf.Bytes(), []byte{'\n'}) {
+		if len(line) == 0 || bytes.HasPrefix(line, []byte(`\ `)) {
+			continue
+		}
+		if patchLen == 0 && !bytes.HasPrefix(line, []byte("diff ")) {
+			continue
+		}
+		if before == -1 {
+			switch {
+			case bytes.HasPrefix(line, []byte("GIT binary patch")) || bytes.HasPrefix(line, []byte("Binary files")):
+				binary = true
+				before = 0
+				h.Write([]byte(origSHA))
+				h.Write([]byte(newSHA))
+				flush()
+				continue
+			case bytes.HasPrefix(line, []byte("index ")):
+				index := strings.TrimSuffix(string(line[len("index "):]), "\n")
+				if i := strings.Index(index, " "); i >= 0 {
+					index = index[:i]
+				}
+				if i := strings.Index(index, ".."); i >= 0 {
+					origSHA, newSHA = index[:i], index[i+len(".."):]
+				}
+				continue
+			case bytes.HasPrefix(line, []byte("--- ")):
+				before, after = 1, 1
+			case !isASCIILetter(line[0]):
+				break lines
+			}
+		}
+
+		if binary {
+			// As in git, the "diff" line of the file after a binary
+			// one is not hashed.
+			if bytes.HasPrefix(line, []byte("diff ")) {
+				binary = false
+				before = -1
+			}
+			continue
+		}
+
+		if before == 0 && after == 0 {
+			if bytes.HasPrefix(line, []byte("@@ -")) {
+				before, after = scanHunkLineCounts(string(line))
+				continue
+			}
+			if !bytes.HasPrefix(line, []byte("diff ")) {
+				break lines
+			}
+			flush()
+			before, after = -1, -1
+		}
+
+		if line[0] == '-' || line[0] == ' ' {
+			before--
+		}
+		if line[0] == '+' || line[0] == ' ' {
+			after--
+		}
+		stripped := line[:0:0]
+		for _, b := range line {
+			if !isASCIISpace(b) {
+				stripped = append(stripped, b)
+			}
+		}
+		patchLen += len(stripped)
+		h.Write(stripped)
+	}
+	if patchLen == 0 {
+		return "", nil
+	}
+	flush()
+	return hex.EncodeToString(result[:]), nil
+}
+
+// scanHunkLineCounts returns the numbers of original and new lines in a
+// "@@ -1,2 +1,2 @@" hunk header, in which a missing count is 1.
+func scanHunkLineCounts(header string) (origLines, newLines int) {
+	count := func(s string) int {
+		if i := strings.IndexAny(s, " @"); i >= 0 {
+			s = s[:i]
+		}
+		i := strings.Index(s, ",")
+		if i < 0 {
+			return 1
+		}
+		n, _ := strconv.Atoi(s[i+1:])
+		return n
+	}
+	header = strings.TrimPrefix(header, "@@ -")
+	origLines = count(header)
+	if i := strings.Index(header, " +"); i >= 0 {
+		newLines = count(header[i+len(" +"):])
+	}
+	return origLines, newLines
+}
+
+func isASCIILetter(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// isASCIISpace reports whether b is whitespace in the C locale, as by
+// isspace.
+func isASCIISpace(b byte) bool {
+	return b == ' ' || '\t' <= b && b <= '\r'
+}
@@ -0,0 +1,104 @@
+package diff
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileDiff_ApplyCacheKey(t *testing.T) {
+	d := parseFileDiffString(t, "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n")
+	renamed := parseFileDiffString(t, "--- a/g\t2009-10-11 15:12:20.000000000 -0700\n+++ b/g\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n")
+	other := parseFileDiffString(t, "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+d\n")
+
+	key := d.ApplyCacheKey("hash1")
+	if got := d.ApplyCacheKey("hash1"); got != key {
+		t.Errorf("key is not stable: got %s, then %s", key, got)
+	}
+	if got := renamed.ApplyCacheKey("hash1"); got != key {
+		t.Errorf("diff with the same hunks got key %s, want %s", got, key)
+	}
+	if got := d.ApplyCacheKey("hash2"); got == key {
+		t.Error("key did not change with the original hash")
+	}
+	if got := other.ApplyCacheKey("hash1"); got == key {
+		t.Error("key did not change with the diff content")
+	}
+	if d.Fingerprint() == other.Fingerprint() {
+		t.Error("diffs with different hunks have the same fingerprint")
+	}
+}
+
+func TestHunk_Signature(t *testing.T) {
+	hunks, err := ParseHunks([]byte(`@@ -1,3 +1,3 @@ func a()
+ x
+-	oldAPI()
++	newAPI()
+ y
+@@ -40,2 +40,2 @@ func b()
+ z
+-	oldAPI()
++	newAPI()
+@@ -50,1 +50,1 @@
+-	oldAPI()
++	otherAPI()
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hunks[0].Signature() != hunks[1].Signature() {
+		t.Error("hunks making the same change have different signatures")
+	}
+	if hunks[0].Signature() == hunks[2].Signature() {
+		t.Error("hunks making different changes have the same signature")
+	}
+}
+
+func TestPatchID(t *testing.T) {
+	diffData, err := ioutil.ReadFile(filepath.Join("testdata", "patch_id.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := ParseMultiFileDiff(diffData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The output of git patch-id --stable.
+	const want = "66c24d7e9afff1ce694363850308c0b9c47607fb"
+	if got, err := PatchID(ds); err != nil {
+		t.Fatal(err)
+	} else if got != want {
+		t.Errorf("got patch ID %s, want %s", got, want)
+	}
+
+	// The patch ID ignores the order of the files, line numbers and
+	// whitespace.
+	reordered := []*FileDiff{ds[4], ds[2], ds[3], ds[0], ds[1]}
+	reordered[4] = parseFileDiffString(t, strings.NewReplacer("@@ -12,7 +12,7 @@", "@@ -22,7 +25,7 @@", "  fifteen", "fif teen\t").Replace(string(diffData[strings.Index(string(diffData), "diff --git a/f.txt"):strings.Index(string(diffData), "diff --git a/img.bin")])))
+	if got, err := PatchID(reordered); err != nil {
+		t.Fatal(err)
+	} else if got != want {
+		t.Errorf("got patch ID %s for the reordered diffs, want %s", got, want)
+	}
+
+	// As in git, the file after a binary one is hashed without its "diff"
+	// line (this is the output of git patch-id --stable for the diff with
+	// git diff -O putting added.txt after img.bin).
+	const wantAfterBinary = "5db43465aafef26c747e118e37cff1177297432a"
+	if got, err := PatchID([]*FileDiff{ds[4], ds[2], ds[0], ds[3], ds[1]}); err != nil {
+		t.Fatal(err)
+	} else if got != wantAfterBinary {
+		t.Errorf("got patch ID %s with added.txt after img.bin, want %s", got, wantAfterBinary)
+	}
+
+	if got, err := PatchID(ds[:4]); err != nil {
+		t.Fatal(err)
+	} else if got == want {
+		t.Error("got the same patch ID for a different patch")
+	}
+
+	if got, err := PatchID(nil); err != nil || got != "" {
+		t.Errorf("got patch ID %q, %v for no diffs, want empty", got, err)
+	}
+}
@@ -0,0 +1,651 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// A DiffOption configures how diffs are computed.
+type DiffOption func(*diffOptions)
+
+type diffOptions struct {
+	context       int
+	exclude       []string
+	absentAsEmpty bool
+
+	ignoreBlankLines bool
+	refine           bool
+	algorithm        Algorithm
+}
+
+func newDiffOptions(opts []DiffOption) (*diffOptions, error) {
+	o := &diffOptions{context: 3}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.context < 0 {
+		return nil, fmt.Errorf("invalid number of context lines: %d", o.context)
+	}
+	if o.algorithm < Myers || o.algorithm > Histogram {
+		return nil, fmt.Errorf("invalid diff algorithm: %d", o.algorithm)
+	}
+	for _, pattern := range o.exclude {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %s", pattern, err)
+		}
+	}
+	return o, nil
+}
+
+// WithContext sets the number of unchanged lines shown around each change
+// (3 by default), like diff -U. With 0, each change gets its own hunk
+// containing only the removed and added lines.
+func WithContext(n int) DiffOption {
+	return func(o *diffOptions) {
+		o.context = n
+	}
+}
+
+// WithExclude makes DiffDirs skip files and directories whose base names
+// match any of the given filepath.Match patterns, like diff --exclude.
+func WithExclude(patterns ...string) DiffOption {
+	return func(o *diffOptions) {
+		o.exclude = append(o.exclude, patterns...)
+	}
+}
+
+// WithAbsentAsEmpty makes DiffDirs treat a file missing from one of the
+// directories as empty, like diff --new-file, so that it is reported as a
+// diff adding or deleting the file instead of an "Only in" message.
+func WithAbsentAsEmpty() DiffOption {
+	return func(o *diffOptions) {
+		o.absentAsEmpty = true
+	}
+}
+
+// WithIgnoreBlankLines makes changes that only insert or delete blank
+// lines (lines that are empty or contain only whitespace) produce no hunks,
+// like diff --ignore-blank-lines. Such changes are still shown if they fall
+// within a hunk for another change.
+func WithIgnoreBlankLines() DiffOption {
+	return func(o *diffOptions) {
+		o.ignoreBlankLines = true
+	}
+}
+
+// An Algorithm is a diff algorithm, used to compute which lines were
+// removed and added.
+type Algorithm int
+
+const (
+	// Myers is the default algorithm, which finds a minimal set of
+	// changes, like git diff.
+	Myers Algorithm = iota
+	// Patience is the patience diff algorithm, as in git diff --patience.
+	// It aligns the lines that occur once in each file, such as function
+	// signatures, before anything else, which often gives more readable
+	// diffs of code than a minimal set of changes. The ranges between such
+	// lines that have no unique lines in common are diffed with Myers'
+	// algorithm, which breaks ties between equally short edit scripts
+	// differently from git's, so the changes in them may be placed
+	// differently than git places them.
+	Patience
+	// Histogram is the histogram diff algorithm, like git diff --histogram.
+	// It extends Patience to also align lines that occur a few times in
+	// the original file, preferring the rarest ones.
+	Histogram
+)
+
+// WithAlgorithm sets the algorithm used to compute diffs (Myers by
+// default).
+func WithAlgorithm(a Algorithm) DiffOption {
+	return func(o *diffOptions) {
+		o.algorithm = a
+	}
+}
+
+// WithIntraLineRefinement makes NewFileDiff set the Refinements of each
+// hunk, marking the words that differ between the removed and added lines
+// of each change. The hunks themselves are unaffected.
+func WithIntraLineRefinement() DiffOption {
+	return func(o *diffOptions) {
+		o.refine = true
+	}
+}
+
+// NewFileDiff computes the unified diff between orig and new, the contents
+// of the files named origName and newName. The returned FileDiff has no
+// hunks if the contents are identical.
+func NewFileDiff(origName, newName string, orig, new []byte, opts ...DiffOption) (*FileDiff, error) {
+	d, err := Compute(orig, new, opts...)
+	if err != nil {
+		return nil, err
+	}
+	d.OrigName, d.NewName = origName, newName
+	return d, nil
+}
+
+// Compute computes the unified diff between the contents orig and new,
+// using the algorithm set by WithAlgorithm (Myers' algorithm by default).
+// It is like NewFileDiff, but leaves the file names of the returned
+// FileDiff empty, for callers to fill in before printing it.
+func Compute(orig, new []byte, opts ...DiffOption) (*FileDiff, error) {
+	o, err := newDiffOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &FileDiff{Hunks: computeHunks(splitLines(orig), splitLines(new), o)}, nil
+}
+
+// editOp is a single step of an edit script.
+type editOp struct {
+	// op is ' ' (keep), '-' (delete) or '+' (insert).
+	op byte
+	// orig and new are the indexes of the line in the original and new
+	// files; for insertions and deletions, they are the index the line
+	// would have on the other side.
+	orig, new int
+	// ignorable is true for insertions and deletions that should not cause
+	// a hunk to be emitted by themselves.
+	ignorable bool
+}
+
+// computeEdits computes an edit script transforming a into b. Within each
+// change, deletions precede insertions.
+func computeEdits(a, b []fileLine, algorithm Algorithm) []editOp {
+	ids := make(map[string]int)
+	tokens := func(lines []fileLine) []int {
+		ts := make([]int, len(lines))
+		for i, l := range lines {
+			key := string(l.text)
+			if !l.noNewline {
+				key += "\n"
+			}
+			id, ok := ids[key]
+			if !ok {
+				id = len(ids)
+				ids[key] = id
+			}
+			ts[i] = id
+		}
+		return ts
+	}
+	diff := myers
+	switch algorithm {
+	case Patience:
+		diff = patience
+	case Histogram:
+		diff = histogram
+	}
+	ta, tb := tokens(a), tokens(b)
+	deleted, inserted := diff(ta, tb)
+	compactChanges(deleted, inserted, ta)
+	compactChanges(inserted, deleted, tb)
+
+	var ops []editOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && deleted[i]:
+			ops = append(ops, editOp{op: '-', orig: i, new: j})
+			i++
+		case j < len(b) && inserted[j]:
+			ops = append(ops, editOp{op: '+', orig: i, new: j})
+			j++
+		default:
+			ops = append(ops, editOp{op: ' ', orig: i, new: j})
+			i++
+			j++
+		}
+	}
+	return ops
+}
+
+// compactChanges slides each group of consecutive changed lines of a file
+// (given by its tokens) up or down over matching lines, merging groups that
+// meet, the way git does after computing a diff: each group is moved as far
+// down as possible, unless it can be aligned with a group of changes in the
+// other file, whose lines are given by otherChanged. This gives the same
+// output regardless of how the algorithm placed such changes.
+func compactChanges(changed, otherChanged []bool, tokens []int) {
+	c := &changeGroups{changed: changed, tokens: tokens}
+	o := &changeGroups{changed: otherChanged}
+	g, og := c.first(), o.first()
+	for {
+		if g.end > g.start {
+			var earliestEnd, endMatchingOther int
+			for {
+				size := g.end - g.start
+				endMatchingOther = -1
+				for c.slideUp(&g) {
+					o.previous(&og)
+				}
+				earliestEnd = g.end
+				if og.end > og.start {
+					endMatchingOther = g.end
+				}
+				for c.slideDown(&g) {
+					o.next(&og)
+					if og.end > og.start {
+						endMatchingOther = g.end
+					}
+				}
+				if g.end-g.start == size {
+					break
+				}
+			}
+			if g.end != earliestEnd && endMatchingOther != -1 {
+				for og.end == og.start {
+					c.slideUp(&g)
+					o.previous(&og)
+				}
+			}
+		}
+		if !c.next(&g) {
+			break
+		}
+		o.next(&og)
+	}
+}
+
+// changeGroups iterates over the groups of changed lines of a file. A group
+// is a maximal run of changed lines, possibly empty: there is one between
+// each pair of consecutive unchanged lines, which keeps the groups of the
+// two files of a diff in sync.
+type changeGroups struct {
+	changed []bool
+	tokens  []int
+}
+
+// changeGroup is the run of changed lines [start, end).
+type changeGroup struct {
+	start, end int
+}
+
+func (c *changeGroups) isChanged(i int) bool {
+	return i >= 0 && i < len(c.changed) && c.changed[i]
+}
+
+func (c *changeGroups) first() changeGroup {
+	var g changeGroup
+	for c.isChanged(g.end) {
+		g.end++
+	}
+	return g
+}
+
+func (c *changeGroups) next(g *changeGroup) bool {
+	if g.end == len(c.changed) {
+		return false
+	}
+	g.start = g.end + 1
+	for g.end = g.start; c.isChanged(g.end); g.end++ {
+	}
+	return true
+}
+
+func (c *changeGroups) previous(g *changeGroup) bool {
+	if g.start == 0 {
+		return false
+	}
+	g.end = g.start - 1
+	for g.start = g.end; c.isChanged(g.start - 1); g.start-- {
+	}
+	return true
+}
+
+// slideUp moves g up by a line if the line before it matches its last line,
+// merging it with the group before it if they meet.
+func (c *changeGroups) slideUp(g *changeGroup) bool {
+	if g.start == 0 || c.tokens[g.start-1] != c.tokens[g.end-1] {
+		return false
+	}
+	g.start--
+	g.end--
+	c.changed[g.start], c.changed[g.end] = true, false
+	for c.isChanged(g.start - 1) {
+		g.start--
+	}
+	return true
+}
+
+// slideDown moves g down by a line if the line after it matches its first
+// line, merging it with the group after it if they meet.
+func (c *changeGroups) slideDown(g *changeGroup) bool {
+	if g.end == len(c.changed) || c.tokens[g.start] != c.tokens[g.end] {
+		return false
+	}
+	c.changed[g.start], c.changed[g.end] = false, true
+	g.start++
+	g.end++
+	for c.isChanged(g.end) {
+		g.end++
+	}
+	return true
+}
+
+// computeHunks computes the hunks of the unified diff between a and b.
+func computeHunks(a, b []fileLine, o *diffOptions) []*Hunk {
+	ops := computeEdits(a, b, o.algorithm)
+	if o.ignoreBlankLines {
+		markBlankChanges(ops, a, b)
+	}
+	hunks := editHunks(ops, a, b, o.context)
+	if o.refine {
+		for _, h := range hunks {
+			h.Refinements = refineLines(h.lines())
+		}
+	}
+	return hunks
+}
+
+// markBlankChanges marks as ignorable each change (run of insertions and
+// deletions) in ops that only inserts or deletes blank lines.
+func markBlankChanges(ops []editOp, a, b []fileLine) {
+	for i := 0; i < len(ops); {
+		if ops[i].op == ' ' {
+			i++
+			continue
+		}
+		end, blank := i, true
+		for ; end < len(ops) && ops[end].op != ' '; end++ {
+			l := b[ops[end].new]
+			if ops[end].op == '-' {
+				l = a[ops[end].orig]
+			}
+			if len(bytes.TrimSpace(l.text)) > 0 {
+				blank = false
+			}
+		}
+		for ; i < end; i++ {
+			ops[i].ignorable = blank
+		}
+	}
+}
+
+// editHunks groups an edit script into hunks with the given number of
+// context lines. Changes separated by at most 2*context unchanged lines
+// share a hunk. Ignorable changes are treated as unchanged lines, except
+// that a hunk never includes only part of one.
+func editHunks(ops []editOp, a, b []fileLine, context int) []*Hunk {
+	isChange := func(i int) bool {
+		return ops[i].op != ' ' && !ops[i].ignorable
+	}
+	var hunks []*Hunk
+	position := int32(0)
+	floor := 0 // ops before floor belong to the previous hunk
+	for i := 0; i < len(ops); {
+		if !isChange(i) {
+			i++
+			continue
+		}
+
+		start := i - context
+		if start < floor {
+			start = floor
+		}
+		for start > floor && ops[start].op != ' ' && ops[start-1].op != ' ' {
+			start--
+		}
+		end := i
+		for {
+			for end < len(ops) && ops[end].op != ' ' {
+				end++
+			}
+			next := end
+			for next < len(ops) && !isChange(next) {
+				next++
+			}
+			if next == len(ops) || next-end > 2*context {
+				break
+			}
+			end = next
+		}
+		end += context
+		if end > len(ops) {
+			end = len(ops)
+		}
+		for end < len(ops) && ops[end].op != ' ' && ops[end-1].op != ' ' {
+			end++
+		}
+
+		h := &Hunk{}
+		var lines []hunkLine
+		for _, op := range ops[start:end] {
+			var l fileLine
+			switch op.op {
+			case ' ':
+				l = a[op.orig]
+				h.OrigLines++
+				h.NewLines++
+			case '-':
+				l = a[op.orig]
+				h.OrigLines++
+			case '+':
+				l = b[op.new]
+				h.NewLines++
+			}
+			lines = append(lines, hunkLine{op: op.op, text: l.text, noNewline: l.noNewline})
+		}
+		h.setLines(lines)
+		h.OrigStartLine, h.NewStartLine = int32(ops[start].orig), int32(ops[start].new)
+		if h.OrigLines > 0 {
+			h.OrigStartLine++
+		}
+		if h.NewLines > 0 {
+			h.NewStartLine++
+		}
+		position++ // account for the hunk header line
+		h.StartPosition = position
+		position += int32(bytes.Count(h.Body, []byte{'\n'}))
+
+		hunks = append(hunks, h)
+		floor, i = end, end
+	}
+	return hunks
+}
+
+// DiffDirs recursively compares the directories origDir and newDir, like
+// diff -ru, and returns the diffs of the files that differ, sorted by path.
+// Files are paired by their paths relative to the directories. A file or
+// directory present in only one of them is reported as an "Only in" message
+// (see FileDiff), or with WithAbsentAsEmpty, as a diff adding or deleting
+// each file it contains. Binary files that differ are reported with a
+// "Binary files ... differ" extended header and no hunks.
+func DiffDirs(origDir, newDir string, opts ...DiffOption) ([]*FileDiff, error) {
+	o, err := newDiffOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range []string{origDir, newDir} {
+		if fi, err := os.Stat(dir); err != nil {
+			return nil, err
+		} else if !fi.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", dir)
+		}
+	}
+	w := &dirDiffer{origDir: origDir, newDir: newDir, opts: o}
+	if err := w.diffDir("."); err != nil {
+		return nil, err
+	}
+	return w.diffs, nil
+}
+
+type dirDiffer struct {
+	origDir, newDir string
+	opts            *diffOptions
+	diffs           []*FileDiff
+}
+
+func (w *dirDiffer) excluded(name string) bool {
+	for _, pattern := range w.opts.exclude {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// diffDir compares the directory at the relative path rel in both trees.
+func (w *dirDiffer) diffDir(rel string) error {
+	origEntries, err := readDirEntries(filepath.Join(w.origDir, rel))
+	if err != nil {
+		return err
+	}
+	newEntries, err := readDirEntries(filepath.Join(w.newDir, rel))
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range origEntries {
+		names = append(names, name)
+	}
+	for name := range newEntries {
+		if _, ok := origEntries[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if w.excluded(name) {
+			continue
+		}
+		path := filepath.Join(rel, name)
+		origFi, newFi := origEntries[name], newEntries[name]
+		switch {
+		case origFi != nil && newFi != nil && origFi.IsDir() && newFi.IsDir():
+			err = w.diffDir(path)
+		case origFi != nil && newFi != nil && !origFi.IsDir() && !newFi.IsDir():
+			err = w.diffFile(path)
+		default:
+			// The path is missing from one side, or is a file on one side
+			// and a directory on the other.
+			if origFi != nil {
+				if err := w.onlyIn(w.origDir, path, origFi, true); err != nil {
+					return err
+				}
+			}
+			if newFi != nil {
+				err = w.onlyIn(w.newDir, path, newFi, false)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readDirEntries returns the regular files and directories in dir, keyed by
+// name. Other kinds of files (such as symlinks) are ignored.
+func readDirEntries(dir string) (map[string]os.FileInfo, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]os.FileInfo, len(fis))
+	for _, fi := range fis {
+		if fi.IsDir() || fi.Mode().IsRegular() {
+			entries[fi.Name()] = fi
+		}
+	}
+	return entries, nil
+}
+
+// diffFile compares the file at the relative path rel in both trees.
+func (w *dirDiffer) diffFile(rel string) error {
+	origPath, newPath := filepath.Join(w.origDir, rel), filepath.Join(w.newDir, rel)
+	orig, err := ioutil.ReadFile(origPath)
+	if err != nil {
+		return err
+	}
+	new, err := ioutil.ReadFile(newPath)
+	if err != nil {
+		return err
+	}
+	return w.addDiff(origPath, newPath, orig, new)
+}
+
+// onlyIn reports the file or directory at the relative path rel, which only
+// exists in dir.
+func (w *dirDiffer) onlyIn(dir, rel string, fi os.FileInfo, isOrig bool) error {
+	path := filepath.Join(dir, rel)
+	if !w.opts.absentAsEmpty {
+		w.diffs = append(w.diffs, &FileDiff{OrigName: path})
+		return nil
+	}
+
+	if fi.IsDir() {
+		entries, err := readDirEntries(path)
+		if err != nil {
+			return err
+		}
+		var names []string
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if w.excluded(name) {
+				continue
+			}
+			if err := w.onlyIn(dir, filepath.Join(rel, name), entries[name], isOrig); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if isOrig {
+		return w.addDiff(path, devNull, data, nil)
+	}
+	return w.addDiff(devNull, path, nil, data)
+}
+
+// addDiff adds the diff between the given file contents, if they differ.
+func (w *dirDiffer) addDiff(origName, newName string, orig, new []byte) error {
+	if bytes.Equal(orig, new) && origName != devNull && newName != devNull {
+		return nil
+	}
+	if isBinary(orig) || isBinary(new) {
+		w.diffs = append(w.diffs, &FileDiff{
+			OrigName: origName,
+			NewName:  newName,
+			Extended: []string{fmt.Sprintf("Binary files %s and %s differ", origName, newName)},
+		})
+		return nil
+	}
+	d := &FileDiff{
+		OrigName: origName,
+		NewName:  newName,
+		Hunks:    computeHunks(splitLines(orig), splitLines(new), w.opts),
+	}
+	if d.Hunks == nil {
+		// Adding or deleting an empty file changes no lines.
+		return nil
+	}
+	w.diffs = append(w.diffs, d)
+	return nil
+}
+
+// binaryCheckSize is how much of a file's content is checked for NUL bytes
+// to decide if it is binary, as git does.
+const binaryCheckSize = 8000
+
+// isBinary reports whether data looks like the content of a binary file.
+func isBinary(data []byte) bool {
+	if len(data) > binaryCheckSize {
+		data = data[:binaryCheckSize]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
@@ -0,0 +1,671 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewFileDiff(t *testing.T) {
+	tests := map[string]struct {
+		orig, new string
+		want      string
+	}{
+		"identical": {
+			orig: "a\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		"nearby changes share a hunk": {
+			orig: "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n",
+			new:  "a\nB\nc\nd\ne\nf\ng\nh\nj\n",
+			want: `--- a/f
++++ b/f
+@@ -1,10 +1,9 @@
+ a
+-b
++B
+ c
+ d
+ e
+ f
+ g
+ h
+-i
+ j
+`,
+		},
+		"distant changes get separate hunks": {
+			orig: "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n",
+			new:  "A\nb\nc\nd\ne\nf\ng\nh\ni\nj\nK\n",
+			want: `--- a/f
++++ b/f
+@@ -1,4 +1,4 @@
+-a
++A
+ b
+ c
+ d
+@@ -8,4 +8,4 @@
+ h
+ i
+ j
+-k
++K
+`,
+		},
+		"add newline at end of file": {
+			orig: "a\nb",
+			new:  "a\nb\n",
+			want: `--- a/f
++++ b/f
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++b
+`,
+		},
+		"no newline at end of either file": {
+			orig: "a\nb",
+			new:  "x\nb",
+			want: `--- a/f
++++ b/f
+@@ -1,2 +1,2 @@
+-a
++x
+ b
+\ No newline at end of file
+`,
+		},
+		"new file": {
+			orig: "",
+			new:  "a\nb\n",
+			want: `--- a/f
++++ b/f
+@@ -0,0 +1,2 @@
++a
++b
+`,
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			d, err := NewFileDiff("a/f", "b/f", []byte(test.orig), []byte(test.new))
+			if err != nil {
+				t.Fatal(err)
+			}
+			printed, err := PrintFileDiff(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(printed); got != test.want {
+				t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestCompute(t *testing.T) {
+	d, err := Compute([]byte("a\nb\nc\n"), []byte("a\nB\nc\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.OrigName != "" || d.NewName != "" {
+		t.Errorf("got names %q and %q, want none", d.OrigName, d.NewName)
+	}
+	printed, err := PrintHunks(d.Hunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"
+	if got := string(printed); got != want {
+		t.Errorf("printed hunks mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	if _, err := Compute(nil, nil, WithContext(-1)); err == nil {
+		t.Error("negative context: got no error")
+	}
+}
+
+func TestNewFileDiff_Patience(t *testing.T) {
+	// From Bram Cohen's description of patience diff. Myers' algorithm aligns
+	// the braces of frobnitz and fib; patience keeps frobnitz intact.
+	orig := `#include <stdio.h>
+
+// Frobs foo heartily
+int frobnitz(int foo)
+{
+    int i;
+    for(i = 0; i < 10; i++)
+    {
+        printf("Your answer is: ");
+        printf("%d\n", foo);
+    }
+}
+
+int fact(int n)
+{
+    if(n > 1)
+    {
+        return fact(n-1) * n;
+    }
+    return 1;
+}
+
+int main(int argc, char **argv)
+{
+    frobnitz(fact(10));
+}
+`
+	new := `#include <stdio.h>
+
+int fib(int n)
+{
+    if(n > 2)
+    {
+        return fib(n-1) + fib(n-2);
+    }
+    return 1;
+}
+
+// Frobs foo heartily
+int frobnitz(int foo)
+{
+    int i;
+    for(i = 0; i < 10; i++)
+    {
+        printf("%d\n", foo);
+    }
+}
+
+int main(int argc, char **argv)
+{
+    frobnitz(fib(10));
+}
+`
+	// Output of git diff --patience.
+	want := "--- a/f.c\n+++ b/f.c\n" + `@@ -1,26 +1,25 @@
+ #include <stdio.h>
+ 
++int fib(int n)
++{
++    if(n > 2)
++    {
++        return fib(n-1) + fib(n-2);
++    }
++    return 1;
++}
++
+ // Frobs foo heartily
+ int frobnitz(int foo)
+ {
+     int i;
+     for(i = 0; i < 10; i++)
+     {
+-        printf("Your answer is: ");
+         printf("%d\n", foo);
+     }
+ }
+ 
+-int fact(int n)
+-{
+-    if(n > 1)
+-    {
+-        return fact(n-1) * n;
+-    }
+-    return 1;
+-}
+-
+ int main(int argc, char **argv)
+ {
+-    frobnitz(fact(10));
++    frobnitz(fib(10));
+ }
+`
+
+	d, err := NewFileDiff("a/f.c", "b/f.c", []byte(orig), []byte(new), WithAlgorithm(Patience))
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed, err := PrintFileDiff(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(printed); got != want {
+		t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	if _, err := NewFileDiff("a/f.c", "b/f.c", nil, nil, WithAlgorithm(Algorithm(-1))); err == nil {
+		t.Error("invalid algorithm: got no error")
+	}
+}
+
+func TestNewFileDiff_Histogram(t *testing.T) {
+	orig := `func a() {
+	return nil
+}
+
+func b() {
+	return nil
+}
+
+func c() {
+	return nil
+}
+`
+	new := `func b() {
+	return nil
+}
+
+func a() {
+	return nil
+}
+
+func a() {
+	return nil
+}
+
+func c() {
+	return err
+}
+`
+	// Output of git diff --histogram.
+	want := "--- a/f.go\n+++ b/f.go\n" + `@@ -1,11 +1,15 @@
+-func a() {
+-	return nil
+-}
+-
+ func b() {
+ 	return nil
+ }
+ 
+-func c() {
++func a() {
+ 	return nil
+ }
++
++func a() {
++	return nil
++}
++
++func c() {
++	return err
++}
+`
+
+	d, err := NewFileDiff("a/f.go", "b/f.go", []byte(orig), []byte(new), WithAlgorithm(Histogram))
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed, err := PrintFileDiff(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(printed); got != want {
+		t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+func BenchmarkNewFileDiff(b *testing.B) {
+	// A large source file with many repeated lines, and a copy of it with
+	// scattered edits.
+	var orig, new bytes.Buffer
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&orig, "func f%d(x int) int {\n\tif x > %d {\n\t\treturn x\n\t}\n\treturn 0\n}\n\n", i, i%10)
+		if i%50 == 0 {
+			fmt.Fprintf(&new, "// f%d is new.\nfunc f%d(x int) int {\n\treturn x\n}\n\n", i, i)
+			continue
+		}
+		fmt.Fprintf(&new, "func f%d(x int) int {\n\tif x > %d {\n\t\treturn x\n\t}\n\treturn 0\n}\n\n", i, i%10)
+	}
+
+	for _, alg := range []struct {
+		name      string
+		algorithm Algorithm
+	}{
+		{"Myers", Myers},
+		{"Patience", Patience},
+		{"Histogram", Histogram},
+	} {
+		b.Run(alg.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := NewFileDiff("a/f.go", "b/f.go", orig.Bytes(), new.Bytes(), WithAlgorithm(alg.algorithm)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewFileDiff_ZeroContext(t *testing.T) {
+	orig := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+	tests := map[string]struct {
+		new  string
+		want string
+	}{
+		"insertion": {
+			new: "1\n2\n3\n4\n5\nx\ny\n6\n7\n8\n9\n",
+			want: `@@ -5,0 +6,2 @@
++x
++y
+`,
+		},
+		"insertion at start": {
+			new: "x\n1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			want: `@@ -0,0 +1,1 @@
++x
+`,
+		},
+		"deletion": {
+			new: "1\n2\n3\n6\n7\n8\n9\n",
+			want: `@@ -4,2 +3,0 @@
+-4
+-5
+`,
+		},
+		"modifications": {
+			new: "1\nII\n3\n4\n5\n6\n7\nVIII\n9\n",
+			want: `@@ -2,1 +2,1 @@
+-2
++II
+@@ -8,1 +8,1 @@
+-8
++VIII
+`,
+		},
+		"adjacent changes": {
+			new: "1\n2\nIII\n4\nV\n6\n7\n8\n9\n",
+			want: `@@ -3,1 +3,1 @@
+-3
++III
+@@ -5,1 +5,1 @@
+-5
++V
+`,
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			d, err := NewFileDiff("a/f", "b/f", []byte(orig), []byte(test.new), WithContext(0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(d.HunksOnly()); got != test.want {
+				t.Errorf("hunks mismatch (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+			applied, err := ApplyFileDiff([]byte(orig), d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(applied) != test.new {
+				t.Errorf("applying the diff gave %q, want %q", applied, test.new)
+			}
+		})
+	}
+}
+
+func TestNewFileDiff_IgnoreBlankLines(t *testing.T) {
+	tests := map[string]struct {
+		orig, new string
+		want      string
+	}{
+		"only blank lines inserted": {
+			orig: "a\nb\nc\n",
+			new:  "a\n\nb\n \t\nc\n\n",
+			want: "",
+		},
+		"only blank lines deleted": {
+			orig: "a\n\n\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		"real change next to blank lines": {
+			orig: "a\nb\n\nc\nd\ne\nf\ng\nh\ni\n\nj\n",
+			new:  "a\nB\n\nc\nd\ne\nf\ng\nh\ni\nj\n",
+			want: `--- a/f
++++ b/f
+@@ -1,4 +1,4 @@
+ a
+-b
++B
+ 
+ c
+`,
+		},
+		"blank change within a hunk": {
+			orig: "a\nb\nc\n",
+			new:  "a\n\nB\nc\n",
+			want: `--- a/f
++++ b/f
+@@ -1,3 +1,4 @@
+ a
+-b
++
++B
+ c
+`,
+		},
+		"blank change partly in context": {
+			orig: "a\nb\nc\nd\n",
+			new:  "a\n\n\nb\nC\nd\n",
+			want: `--- a/f
++++ b/f
+@@ -2,3 +2,5 @@
++
++
+ b
+-c
++C
+ d
+`,
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			d, err := NewFileDiff("a/f", "b/f", []byte(test.orig), []byte(test.new), WithIgnoreBlankLines(), WithContext(2))
+			if err != nil {
+				t.Fatal(err)
+			}
+			printed, err := PrintFileDiff(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(printed); got != test.want {
+				t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestNewFileDiff_Apply(t *testing.T) {
+	pairs := [][2]string{
+		{"", ""},
+		{"a\n", ""},
+		{"", "a"},
+		{"a\nb\nc\n", "c\nb\na\n"},
+		{"a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n", "a\nx\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\ny\n"},
+		{"x\na\nb\nx\nc\nx\n", "a\nx\nb\nc\nx\nx\nd"},
+		{strings.Repeat("a\nb\n", 50), strings.Repeat("b\na\n", 50)},
+		{"one\ntwo\nthree\nfour\n", "zero\none\nthree\nfive\nfour"},
+	}
+	rnd := rand.New(rand.NewSource(1))
+	randomContent := func() string {
+		var lines []string
+		for i := rnd.Intn(30); i > 0; i-- {
+			lines = append(lines, string(rune('a'+rnd.Intn(4))))
+		}
+		return strings.Join(lines, "\n")
+	}
+	for i := 0; i < 200; i++ {
+		pairs = append(pairs, [2]string{randomContent(), randomContent()})
+	}
+	for _, pair := range pairs {
+		orig, new := pair[0], pair[1]
+		for _, context := range []int{0, 1, 3} {
+			d, err := NewFileDiff("a/f", "b/f", []byte(orig), []byte(new), WithContext(context))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ApplyFileDiff([]byte(orig), d)
+			if err != nil {
+				t.Errorf("%q -> %q (context %d): ApplyFileDiff: %s", orig, new, context, err)
+				continue
+			}
+			if string(got) != new {
+				t.Errorf("%q -> %q (context %d): applying the computed diff gave %q", orig, new, context, got)
+			}
+
+			if d.Hunks == nil {
+				continue
+			}
+			printed, err := PrintFileDiff(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := ParseFileDiff(printed)
+			if err != nil {
+				t.Fatalf("%q -> %q (context %d): ParseFileDiff: %s\n%s", orig, new, context, err, printed)
+			}
+			if !cmp.Equal(parsed.Hunks, d.Hunks) {
+				t.Errorf("%q -> %q (context %d): parsed hunks differ from computed hunks:\n%s", orig, new, context, cmp.Diff(d.Hunks, parsed.Hunks))
+			}
+		}
+	}
+}
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiffDirs(t *testing.T) {
+	root, err := ioutil.TempDir("", "go-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	origDir, newDir := filepath.Join(root, "a"), filepath.Join(root, "b")
+	writeTree(t, origDir, map[string]string{
+		"changed.txt":          "a\nb\n",
+		"same.txt":             "same\n",
+		"deleted.txt":          "gone\n",
+		"image.bin":            "\x00\x01",
+		"sub/changed.txt":      "x\n",
+		"onlyorig/file.txt":    "1\n",
+		"ignored.log":          "old\n",
+		"sub/ignored.log":      "old\n",
+		"ignoreddir/file.txt":  "old\n",
+		"sub/deeper/same.txt":  "same\n",
+		"sub/deeper/other.txt": "a\n",
+	})
+	writeTree(t, newDir, map[string]string{
+		"changed.txt":          "a\nc\n",
+		"same.txt":             "same\n",
+		"added.txt":            "new\n",
+		"image.bin":            "\x00\x02",
+		"sub/changed.txt":      "y\n",
+		"ignored.log":          "new\n",
+		"sub/deeper/same.txt":  "same\n",
+		"sub/deeper/other.txt": "b\n",
+	})
+
+	tests := map[string]struct {
+		opts []DiffOption
+		want string
+	}{
+		"only in": {
+			opts: []DiffOption{WithExclude("*.log", "ignoreddir")},
+			want: `Only in b: added.txt
+--- a/changed.txt
++++ b/changed.txt
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+Only in a: deleted.txt
+Binary files a/image.bin and b/image.bin differ
+Only in a: onlyorig
+--- a/sub/changed.txt
++++ b/sub/changed.txt
+@@ -1,1 +1,1 @@
+-x
++y
+--- a/sub/deeper/other.txt
++++ b/sub/deeper/other.txt
+@@ -1,1 +1,1 @@
+-a
++b
+`,
+		},
+		"absent as empty": {
+			opts: []DiffOption{WithExclude("*.log", "ignoreddir"), WithAbsentAsEmpty(), WithContext(0)},
+			want: `--- /dev/null
++++ b/added.txt
+@@ -0,0 +1,1 @@
++new
+--- a/changed.txt
++++ b/changed.txt
+@@ -2,1 +2,1 @@
+-b
++c
+--- a/deleted.txt
++++ /dev/null
+@@ -1,1 +0,0 @@
+-gone
+Binary files a/image.bin and b/image.bin differ
+--- a/onlyorig/file.txt
++++ /dev/null
+@@ -1,1 +0,0 @@
+-1
+--- a/sub/changed.txt
++++ b/sub/changed.txt
+@@ -1,1 +1,1 @@
+-x
++y
+--- a/sub/deeper/other.txt
++++ b/sub/deeper/other.txt
+@@ -1,1 +1,1 @@
+-a
++b
+`,
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			ds, err := DiffDirs(origDir, newDir, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			printed, err := PrintMultiFileDiff(ds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := strings.Replace(string(printed), root+string(filepath.Separator), "", -1)
+			got = filepath.ToSlash(got)
+			if got != test.want {
+				t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+
+	if _, err := DiffDirs(origDir, filepath.Join(root, "missing")); err == nil {
+		t.Error("got no error for a missing directory")
+	}
+}
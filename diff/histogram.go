@@ -0,0 +1,140 @@
+package diff
+
+// histogramMaxChain is the number of occurrences in a of an element beyond
+// which histogram does not use it to align a and b, as in git.
+const histogramMaxChain = 64
+
+// histogram computes an edit script transforming a into b using the
+// histogram diff algorithm (as git diff --histogram does), returning its
+// result in the same form as myers. It is an extension of patience diff that
+// aligns a and b on the longest common run of elements that contains the
+// rarest elements of a, and recurses on the ranges before and after it.
+// Ranges whose elements are all too frequent are compared with myers.
+func histogram(a, b []int) (deleted, inserted []bool) {
+	h := &histogramState{
+		a:        a,
+		b:        b,
+		deleted:  make([]bool, len(a)),
+		inserted: make([]bool, len(b)),
+	}
+	h.compare(0, len(a), 0, len(b))
+	return h.deleted, h.inserted
+}
+
+type histogramState struct {
+	a, b              []int
+	deleted, inserted []bool
+}
+
+// compare marks the differences between a[xoff:xlim] and b[yoff:ylim].
+func (h *histogramState) compare(xoff, xlim, yoff, ylim int) {
+	for {
+		switch {
+		case xoff == xlim:
+			for y := yoff; y < ylim; y++ {
+				h.inserted[y] = true
+			}
+			return
+		case yoff == ylim:
+			for x := xoff; x < xlim; x++ {
+				h.deleted[x] = true
+			}
+			return
+		}
+
+		lcs, ok := h.findLCS(xoff, xlim, yoff, ylim)
+		if !ok {
+			deleted, inserted := myers(h.a[xoff:xlim], h.b[yoff:ylim])
+			copy(h.deleted[xoff:], deleted)
+			copy(h.inserted[yoff:], inserted)
+			return
+		}
+		if lcs.xend == lcs.xbegin {
+			// There is nothing in common.
+			for x := xoff; x < xlim; x++ {
+				h.deleted[x] = true
+			}
+			for y := yoff; y < ylim; y++ {
+				h.inserted[y] = true
+			}
+			return
+		}
+		h.compare(xoff, lcs.xbegin, yoff, lcs.ybegin)
+		xoff, yoff = lcs.xend, lcs.yend
+	}
+}
+
+// A histogramRegion is a run of elements common to a[xbegin:xend] and
+// b[ybegin:yend].
+type histogramRegion struct {
+	xbegin, xend, ybegin, yend int
+}
+
+// findLCS finds the run of common elements of a[xoff:xlim] and
+// b[yoff:ylim] on which to align them: the longest one among those with the
+// fewest occurrences of their rarest element in a. It returns an empty
+// region if there are no common elements, and false if all the common
+// elements occur more than histogramMaxChain times in a.
+func (h *histogramState) findLCS(xoff, xlim, yoff, ylim int) (histogramRegion, bool) {
+	// For each element of a, the number of times it occurs and the
+	// position of its next occurrence (or -1).
+	count := make(map[int]int)
+	first := make(map[int]int)
+	next := make([]int, xlim-xoff)
+	for x := xlim - 1; x >= xoff; x-- {
+		if f, ok := first[h.a[x]]; ok {
+			next[x-xoff] = f
+		} else {
+			next[x-xoff] = -1
+		}
+		first[h.a[x]] = x
+		count[h.a[x]]++
+	}
+
+	var lcs histogramRegion
+	minCount := histogramMaxChain + 1
+	hasCommon := false
+	for y := yoff; y < ylim; {
+		ynext := y + 1
+		elem := h.b[y]
+		if c, ok := count[elem]; ok {
+			hasCommon = true
+			if c <= minCount {
+				for x := first[elem]; x != -1; {
+					xbegin, ybegin, xend, yend := x, y, x+1, y+1
+					rarest := c
+					for xbegin > xoff && ybegin > yoff && h.a[xbegin-1] == h.b[ybegin-1] {
+						xbegin--
+						ybegin--
+						if rarest > 1 && count[h.a[xbegin]] < rarest {
+							rarest = count[h.a[xbegin]]
+						}
+					}
+					for xend < xlim && yend < ylim && h.a[xend] == h.b[yend] {
+						if rarest > 1 && count[h.a[xend]] < rarest {
+							rarest = count[h.a[xend]]
+						}
+						xend++
+						yend++
+					}
+					if ynext < yend {
+						ynext = yend
+					}
+					if lcs.xend-lcs.xbegin < xend-xbegin || rarest < minCount {
+						lcs = histogramRegion{xbegin, xend, ybegin, yend}
+						minCount = rarest
+					}
+
+					// Skip the occurrences of elem within the run.
+					for x = next[x-xoff]; x != -1 && x < xend; x = next[x-xoff] {
+					}
+				}
+			}
+		}
+		y = ynext
+	}
+	if hasCommon && minCount > histogramMaxChain {
+		return histogramRegion{}, false
+	}
+	return lcs, true
+}
@@ -0,0 +1,149 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+)
+
+// PrintSideBySideHTML renders ds as HTML for display, with the original and
+// new versions of each hunk side by side. Each file is a
+// <div class="diff-file"> holding a <div class="diff-file-header"> with its
+// path (as in a diff stat) and, if it has hunks, a
+// <table class="diff-side-by-side"> with four columns: the original line
+// number and text, and the new line number and text. Each hunk starts with
+// a row of class "hunk" holding its header. Text cells have the class
+// "ctx", "del" or "add", and line number cells the class "num"; within
+// each change, removed and added lines are paired up, and the cells beside
+// the unpaired ones are left empty, with the class "empty". All text is
+// HTML-escaped. The print options that drop or merge hunks apply.
+func PrintSideBySideHTML(ds []*FileDiff, opts ...PrintOption) ([]byte, error) {
+	o := newPrintOptions(opts)
+	var buf bytes.Buffer
+	for _, d := range ds {
+		if d.Hunks == nil && d.RawHunks != nil {
+			return nil, errHunksNotLoaded
+		}
+		buf.WriteString(`<div class="diff-file">` + "\n")
+		writeHTMLFileHeader(&buf, d, o)
+		if hunks := o.hunks(d.Hunks); len(hunks) > 0 {
+			buf.WriteString(`<table class="diff-side-by-side">` + "\n")
+			for _, h := range hunks {
+				fmt.Fprintf(&buf, `<tr class="hunk"><td colspan="4">%s</td></tr>`+"\n", html.EscapeString(hunkHeaderText(h)))
+				writeSideBySideRows(&buf, h)
+			}
+			buf.WriteString("</table>\n")
+		}
+		buf.WriteString("</div>\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// PrintInlineHTML renders ds as HTML for display, in a single column like a
+// unified diff. The i-th file (from 0) is a
+// <div class="diff-file" id="diff-i"> holding a
+// <div class="diff-file-header"> with its path (as in a diff stat) and, if
+// it has hunks, a <table class="diff-inline"> with three columns: the
+// original line number, the new line number and the text. The j-th hunk
+// of the file starts with a row of class "hunk" and id "diff-i-j" holding
+// its header, which links to the row itself for deep-linking. Text cells
+// have the class "ctx", "del" or "add", and line number cells the class
+// "num"; the number cells of lines missing from one side are empty. All
+// text is HTML-escaped. The print options that drop or merge hunks apply.
+func PrintInlineHTML(ds []*FileDiff, opts ...PrintOption) ([]byte, error) {
+	o := newPrintOptions(opts)
+	var buf bytes.Buffer
+	for i, d := range ds {
+		if d.Hunks == nil && d.RawHunks != nil {
+			return nil, errHunksNotLoaded
+		}
+		fmt.Fprintf(&buf, `<div class="diff-file" id="diff-%d">`+"\n", i)
+		writeHTMLFileHeader(&buf, d, o)
+		if hunks := o.hunks(d.Hunks); len(hunks) > 0 {
+			buf.WriteString(`<table class="diff-inline">` + "\n")
+			for j, h := range hunks {
+				fmt.Fprintf(&buf, `<tr class="hunk" id="diff-%d-%d"><td colspan="3"><a href="#diff-%[1]d-%[2]d">%s</a></td></tr>`+"\n", i, j, html.EscapeString(hunkHeaderText(h)))
+				writeInlineRows(&buf, h)
+			}
+			buf.WriteString("</table>\n")
+		}
+		buf.WriteString("</div>\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// writeInlineRows writes the table rows of the lines of h.
+func writeInlineRows(buf *bytes.Buffer, h *Hunk) {
+	origLine, newLine := h.lineStarts()
+	for _, l := range h.lines() {
+		buf.WriteString("<tr>")
+		switch l.op {
+		case ' ':
+			writeHTMLNumber(buf, origLine)
+			writeHTMLCells(buf, newLine, "ctx", l.text)
+			origLine++
+			newLine++
+		case '-':
+			writeHTMLNumber(buf, origLine)
+			writeHTMLCells(buf, 0, "del", l.text)
+			origLine++
+		case '+':
+			writeHTMLNumber(buf, 0)
+			writeHTMLCells(buf, newLine, "add", l.text)
+			newLine++
+		}
+		buf.WriteString("</tr>\n")
+	}
+}
+
+// writeSideBySideRows writes the table rows of the lines of h.
+func writeSideBySideRows(buf *bytes.Buffer, h *Hunk) {
+	for _, row := range sideBySideRows(h) {
+		buf.WriteString("<tr>")
+		for _, c := range []sideBySideCell{row.orig, row.new} {
+			class := "empty"
+			switch c.op {
+			case ' ':
+				class = "ctx"
+			case '-':
+				class = "del"
+			case '+':
+				class = "add"
+			}
+			writeHTMLCells(buf, c.line, class, c.text)
+		}
+		buf.WriteString("</tr>\n")
+	}
+}
+
+// writeHTMLFileHeader writes the header of the HTML rendering of d, with its
+// path quoted with o.quote.
+func writeHTMLFileHeader(buf *bytes.Buffer, d *FileDiff, o *printOptions) {
+	fmt.Fprintf(buf, `<div class="diff-file-header">%s</div>`+"\n", html.EscapeString(d.statPath(o.quote)))
+}
+
+// writeHTMLCells writes a line number cell (empty if line is 0) and a text
+// cell of the given class.
+func writeHTMLCells(buf *bytes.Buffer, line int, class string, text []byte) {
+	writeHTMLNumber(buf, line)
+	fmt.Fprintf(buf, `<td class="%s">%s</td>`, class, html.EscapeString(string(text)))
+}
+
+// writeHTMLNumber writes a line number cell, which is empty if line is 0.
+func writeHTMLNumber(buf *bytes.Buffer, line int) {
+	if line > 0 {
+		fmt.Fprintf(buf, `<td class="num">%d</td>`, line)
+	} else {
+		buf.WriteString(`<td class="num"></td>`)
+	}
+}
+
+// hunkHeaderText returns the "@@ -1,2 +1,2 @@" header of h, followed by its
+// section heading.
+func hunkHeaderText(h *Hunk) string {
+	header := fmt.Sprintf(hunkHeader, h.OrigStartLine, h.OrigLines, h.NewStartLine, h.NewLines)
+	if h.Section != "" || h.EmptySection {
+		header += " " + h.Section
+	}
+	return header
+}
@@ -0,0 +1,61 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPrintSideBySideHTML(t *testing.T) {
+	d := parseFileDiffString(t, "--- a/f.go\n+++ b/f.go\n@@ -1,4 +1,4 @@ func <main>\n x\n-a < b\n-c\n+a > b\n y\n+\"z\"\n")
+	printed, err := PrintSideBySideHTML([]*FileDiff{d})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<div class="diff-file">
+<div class="diff-file-header">f.go</div>
+<table class="diff-side-by-side">
+<tr class="hunk"><td colspan="4">@@ -1,4 +1,4 @@ func &lt;main&gt;</td></tr>
+<tr><td class="num">1</td><td class="ctx">x</td><td class="num">1</td><td class="ctx">x</td></tr>
+<tr><td class="num">2</td><td class="del">a &lt; b</td><td class="num">2</td><td class="add">a &gt; b</td></tr>
+<tr><td class="num">3</td><td class="del">c</td><td class="num"></td><td class="empty"></td></tr>
+<tr><td class="num">4</td><td class="ctx">y</td><td class="num">3</td><td class="ctx">y</td></tr>
+<tr><td class="num"></td><td class="empty"></td><td class="num">4</td><td class="add">&#34;z&#34;</td></tr>
+</table>
+</div>
+`
+	if got := string(printed); got != want {
+		t.Errorf("printed HTML mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestPrintInlineHTML(t *testing.T) {
+	d := parseFileDiffString(t, "--- a/f.go\n+++ b/f.go\n@@ -1,3 +1,3 @@\n x\n-a < b\n+a > b\n y\n@@ -10,0 +11,1 @@\n+z\n")
+	renamed := parseFileDiffString(t, "diff --git a/dir/a<b b/dir/a>b\nsimilarity index 100%\nrename from dir/a<b\nrename to dir/a>b\n")
+	printed, err := PrintInlineHTML([]*FileDiff{{OrigName: "a/e", NewName: "b/e"}, renamed, d})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<div class="diff-file" id="diff-0">
+<div class="diff-file-header">e</div>
+</div>
+<div class="diff-file" id="diff-1">
+<div class="diff-file-header">dir/{a&lt;b =&gt; a&gt;b}</div>
+</div>
+<div class="diff-file" id="diff-2">
+<div class="diff-file-header">f.go</div>
+<table class="diff-inline">
+<tr class="hunk" id="diff-2-0"><td colspan="3"><a href="#diff-2-0">@@ -1,3 +1,3 @@</a></td></tr>
+<tr><td class="num">1</td><td class="num">1</td><td class="ctx">x</td></tr>
+<tr><td class="num">2</td><td class="num"></td><td class="del">a &lt; b</td></tr>
+<tr><td class="num"></td><td class="num">2</td><td class="add">a &gt; b</td></tr>
+<tr><td class="num">3</td><td class="num">3</td><td class="ctx">y</td></tr>
+<tr class="hunk" id="diff-2-1"><td colspan="3"><a href="#diff-2-1">@@ -10,0 +11,1 @@</a></td></tr>
+<tr><td class="num"></td><td class="num">11</td><td class="add">z</td></tr>
+</table>
+</div>
+`
+	if got := string(printed); got != want {
+		t.Errorf("printed HTML mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,202 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// Interdiff returns the diff between two versions of a patch against the
+// same files, like the interdiff tool: the changes to make to the files as
+// patched by old to get the files as patched by new, computed without the
+// original files. The opts set the context and algorithm of the hunks, as
+// for NewFileDiff.
+//
+// The diffs of the patches are paired up by their paths (as in a diff
+// stat). The original files are pieced together from the lines that the
+// hunks of each pair show, and the hunks of the result only show those
+// lines, so their context may be shorter than asked for. A file only
+// patched by old is reverted (see ReverseFileDiff), and one only patched by
+// new is patched as in new. Files patched the same way by both are left
+// out, and so are "Only in" messages; changes of mode are not compared, and
+// the diffs returned for paired files have no extended headers. Interdiff
+// returns an error if the hunks of a pair disagree about the original
+// file, or if they change a binary file in different ways.
+func Interdiff(old, new []*FileDiff, opts ...DiffOption) ([]*FileDiff, error) {
+	o, err := newDiffOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	byPath := make(map[string][]*FileDiff)
+	for _, d := range new {
+		if !d.isOnlyIn() {
+			byPath[d.path()] = append(byPath[d.path()], d)
+		}
+	}
+	var ds []*FileDiff
+	for _, dOld := range old {
+		if dOld.isOnlyIn() {
+			continue
+		}
+		path := dOld.path()
+		if len(byPath[path]) == 0 {
+			r, err := ReverseFileDiff(dOld)
+			if err != nil {
+				return nil, err
+			}
+			ds = append(ds, r)
+			continue
+		}
+		dNew := byPath[path][0]
+		byPath[path] = byPath[path][1:]
+		d, err := interdiffFile(dOld, dNew, o)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			ds = append(ds, d)
+		}
+	}
+	// The diffs of new that were not paired up are the last ones of each
+	// path.
+	for _, d := range new {
+		if rest := byPath[d.path()]; len(rest) > 0 && rest[0] == d {
+			ds = append(ds, d)
+			byPath[d.path()] = rest[1:]
+		}
+	}
+	return ds, nil
+}
+
+// interdiffFile returns the diff between the new files of the diffs old
+// and new of the same file, or nil if they are the same.
+func interdiffFile(old, new *FileDiff, o *diffOptions) (*FileDiff, error) {
+	if (old.Hunks == nil && old.RawHunks != nil) || (new.Hunks == nil && new.RawHunks != nil) {
+		return nil, errHunksNotLoaded
+	}
+	if old.IsBinary() || new.IsBinary() {
+		if Equal([]*FileDiff{old}, []*FileDiff{new}) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot compute the interdiff of binary file %s", old.path())
+	}
+
+	base, placeholder, err := interdiffBase(old, new)
+	if err != nil {
+		return nil, err
+	}
+	oldContent, err := ApplyFileDiff(base, old)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compute the interdiff of %s: %s", old.path(), err)
+	}
+	newContent, err := ApplyFileDiff(base, new)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compute the interdiff of %s: %s", old.path(), err)
+	}
+
+	// The names are the new names of the diffs, with git's prefixes if
+	// they have them.
+	name := func(d *FileDiff, prefix string) string {
+		if _, p := d.paths(); p != d.NewName && p != devNull {
+			return prefix + p
+		}
+		return d.NewName
+	}
+	d := &FileDiff{OrigName: name(old, "a/"), NewName: name(new, "b/")}
+	hunks, err := computeHunksBetweenPlaceholders(splitLines(oldContent), splitLines(newContent), placeholder, o)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compute the interdiff of %s: %s", old.path(), err)
+	}
+	d.Hunks = hunks
+	if len(d.Hunks) == 0 {
+		return nil, nil
+	}
+	return d, nil
+}
+
+// interdiffBase pieces together the original file of the diffs old and new
+// from the context and removed lines of their hunks. Each line that neither
+// shows is replaced by a unique placeholder line, which placeholder
+// recognizes.
+func interdiffBase(old, new *FileDiff) (base []byte, placeholder func([]byte) bool, err error) {
+	known := make(map[int]fileLine)
+	size := 0
+	for _, d := range []*FileDiff{old, new} {
+		for _, h := range d.Hunks {
+			origLine, _ := h.lineStarts()
+			if end := origLine - 1 + int(h.OrigLines); end > size {
+				size = end
+			}
+			for _, l := range h.lines() {
+				if l.op == '+' {
+					continue
+				}
+				line := fileLine{text: l.text, noNewline: l.noNewline}
+				if k, ok := known[origLine]; ok && (!bytes.Equal(k.text, line.text) || k.noNewline != line.noNewline) {
+					return nil, nil, fmt.Errorf("cannot compute the interdiff of %s: the patches disagree about line %d of the original file", old.path(), origLine)
+				}
+				known[origLine] = line
+				origLine++
+			}
+		}
+	}
+
+	base, placeholder = placeholderFile(known, size)
+	return base, placeholder, nil
+}
+
+// placeholderFile returns a file of size lines, with the known lines at
+// their line numbers and a unique placeholder line, which placeholder
+// recognizes, at each other line.
+func placeholderFile(known map[int]fileLine, size int) (file []byte, placeholder func([]byte) bool) {
+	// The placeholders start with a NUL byte, which is unlikely in text
+	// files, and are numbered to make them unique.
+	const prefix = "\x00interdiff placeholder "
+	var buf bytes.Buffer
+	for i := 1; i <= size; i++ {
+		l, ok := known[i]
+		if !ok {
+			l = fileLine{text: []byte(prefix + strconv.Itoa(i))}
+		}
+		buf.Write(l.text)
+		if !l.noNewline {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), func(text []byte) bool { return bytes.HasPrefix(text, []byte(prefix)) }
+}
+
+// computeHunksBetweenPlaceholders computes the hunks of the diff between a
+// and b, which have the same placeholder lines in the same order, without
+// changing the placeholders: a and b are split at the placeholders, and
+// each part of a is diffed with the matching part of b, so that no hunk
+// shows a placeholder. It returns an error if the placeholders of a and b
+// differ.
+func computeHunksBetweenPlaceholders(a, b []fileLine, placeholder func([]byte) bool, o *diffOptions) ([]*Hunk, error) {
+	var hunks []*Hunk
+	position := int32(0)
+	i, j := 0, 0 // the starts of the parts of a and b
+	for i <= len(a) || j <= len(b) {
+		endA, endB := i, j
+		for endA < len(a) && !placeholder(a[endA].text) {
+			endA++
+		}
+		for endB < len(b) && !placeholder(b[endB].text) {
+			endB++
+		}
+		if (endA == len(a)) != (endB == len(b)) || endA < len(a) && !bytes.Equal(a[endA].text, b[endB].text) {
+			return nil, fmt.Errorf("line %d is not in the patches", endA+1)
+		}
+		for _, h := range computeHunks(a[i:endA], b[j:endB], o) {
+			h.OrigStartLine += int32(i)
+			h.NewStartLine += int32(j)
+			position++ // account for the hunk header line
+			h.StartPosition = position
+			position += int32(bytes.Count(h.Body, []byte{'\n'}))
+			hunks = append(hunks, h)
+		}
+		i, j = endA+1, endB+1
+	}
+	return hunks, nil
+}
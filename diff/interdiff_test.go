@@ -0,0 +1,171 @@
+package diff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInterdiff(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 30; i++ {
+		lines = append(lines, "line "+strings.Repeat("x", i%3)+string(rune('a'+i%26)))
+	}
+	orig := strings.Join(lines, "\n") + "\n"
+	edit := func(changes map[int]string) string {
+		edited := append([]string(nil), lines...)
+		for i, s := range changes {
+			edited[i-1] = s
+		}
+		return strings.Join(edited, "\n") + "\n"
+	}
+	// v1 changes lines 3 and 20; v2 changes line 3 differently, keeps the
+	// change to line 20 and changes line 28.
+	v1 := edit(map[int]string{3: "three", 20: "twenty"})
+	v2 := edit(map[int]string{3: "THREE", 20: "twenty", 28: "twenty-eight"})
+
+	dOld, err := NewFileDiff("a/f", "b/f", []byte(orig), []byte(v1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second version has less context, so that the patches show
+	// different parts of the original file.
+	dNew, err := NewFileDiff("a/f", "b/f", []byte(orig), []byte(v2), WithContext(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, err := NewFileDiff("/dev/null", "b/g", nil, []byte("g\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := NewFileDiff("a/h", "/dev/null", []byte("h\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := Interdiff([]*FileDiff{dOld, deleted}, []*FileDiff{added, dNew})
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed, err := PrintMultiFileDiff(ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `--- a/f
++++ b/f
+@@ -1,6 +1,6 @@
+ ` + lines[0] + `
+ ` + lines[1] + `
+-three
++THREE
+ ` + lines[3] + `
+ ` + lines[4] + `
+ ` + lines[5] + `
+@@ -27,3 +27,3 @@
+ ` + lines[26] + `
+-` + lines[27] + `
++twenty-eight
+ ` + lines[28] + `
+--- /dev/null
++++ b/h
+@@ -0,0 +1,1 @@
++h
+--- /dev/null
++++ b/g
+@@ -0,0 +1,1 @@
++g
+`
+	if got := string(printed); got != want {
+		t.Errorf("interdiff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	// Lines that neither patch shows are left out of the context.
+	tenth, err := NewFileDiff("a/f", "b/f", []byte(orig), []byte(edit(map[int]string{10: "ten"})), WithContext(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	twelfth, err := NewFileDiff("a/f", "b/f", []byte(orig), []byte(edit(map[int]string{12: "twelve"})), WithContext(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds, err = Interdiff([]*FileDiff{tenth}, []*FileDiff{twelfth}); err != nil {
+		t.Fatal(err)
+	}
+	if printed, err = PrintMultiFileDiff(ds); err != nil {
+		t.Fatal(err)
+	}
+	want = `--- a/f
++++ b/f
+@@ -10,1 +10,1 @@
+-ten
++` + lines[9] + `
+@@ -12,1 +12,1 @@
+-` + lines[11] + `
++twelve
+`
+	if got := string(printed); got != want {
+		t.Errorf("interdiff without context mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	// The interdiff of a patch with itself is empty.
+	if ds, err := Interdiff([]*FileDiff{dOld}, []*FileDiff{dOld}); err != nil || len(ds) != 0 {
+		t.Errorf("got %d diffs, %v for the interdiff of a patch with itself, want none", len(ds), err)
+	}
+
+	// Patches against different files cannot be compared.
+	other, err := NewFileDiff("a/f", "b/f", []byte(strings.Replace(orig, lines[1], "other", 1)), []byte(v1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Interdiff([]*FileDiff{other}, []*FileDiff{dNew}); err == nil {
+		t.Error("got no error for patches against different files")
+	}
+}
+
+func TestInterdiff_Apply(t *testing.T) {
+	cases := [][3]string{
+		// Without context, Myers deletes and re-adds an unknown line if
+		// the whole files are diffed.
+		{"d\na\n", "a\nd\na\ne\nb\na\n", "c\ne\na\na\n"},
+	}
+	rnd := rand.New(rand.NewSource(1))
+	randomContent := func() string {
+		var lines []string
+		for i := rnd.Intn(12); i > 0; i-- {
+			lines = append(lines, string(rune('a'+rnd.Intn(5))))
+		}
+		return strings.Join(lines, "\n") + "\n"
+	}
+	for i := 0; i < 300; i++ {
+		cases = append(cases, [3]string{randomContent(), randomContent(), randomContent()})
+	}
+	for _, c := range cases {
+		orig, v1, v2 := c[0], c[1], c[2]
+		for _, context := range []int{0, 1, 3} {
+			dOld, err := NewFileDiff("a/f", "b/f", []byte(orig), []byte(v1), WithContext(context))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dNew, err := NewFileDiff("a/f", "b/f", []byte(orig), []byte(v2), WithContext(context))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ds, err := Interdiff([]*FileDiff{dOld}, []*FileDiff{dNew}, WithContext(context))
+			if err != nil {
+				t.Errorf("%q -> %q, %q (context %d): Interdiff: %s", orig, v1, v2, context, err)
+				continue
+			}
+			got := []byte(v1)
+			for _, d := range ds {
+				if got, err = ApplyFileDiff(got, d); err != nil {
+					t.Errorf("%q -> %q, %q (context %d): ApplyFileDiff: %s", orig, v1, v2, context, err)
+				}
+			}
+			if string(got) != v2 {
+				t.Errorf("%q -> %q, %q (context %d): applying the interdiff gave %q", orig, v1, v2, context, got)
+			}
+		}
+	}
+}
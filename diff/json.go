@@ -0,0 +1,248 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// JSONSchemaVersion is the version of the JSON encoding of FileDiff, Hunk
+// and Stat. It is written in the "version" field of each encoded FileDiff,
+// and is only incremented for changes that older decoders cannot read.
+// Decoding a FileDiff with a later version fails; one without a version is
+// taken to have the current version.
+//
+// A FileDiff is encoded as an object with the fields "version",
+// "orig_name", "orig_time", "orig_time_layout", "new_name", "new_time",
+// "new_time_layout", "extended", "orig_mode", "new_mode", "orig_sha",
+// "new_sha", "status", "hunks", "raw_hunks" and "binary_patch"; a Hunk
+// with the fields "orig_start_line", "orig_lines", "orig_no_newline_at",
+// "new_start_line", "new_lines", "section", "empty_section",
+// "start_position", "body" and "refinements"; and a Stat with the fields
+// "added", "changed" and "deleted". Fields with zero values (other than
+// the line numbers and counts of hunks and stats) are omitted. Times are
+// in RFC 3339 format. Hunk bodies (and raw hunks) that are not valid UTF-8
+// are encoded in base64 in "body_base64" (and "raw_hunks_base64") instead.
+const JSONSchemaVersion = 1
+
+type fileDiffJSON struct {
+	Version        int              `json:"version"`
+	OrigName       string           `json:"orig_name,omitempty"`
+	OrigTime       *time.Time       `json:"orig_time,omitempty"`
+	OrigTimeLayout string           `json:"orig_time_layout,omitempty"`
+	NewName        string           `json:"new_name,omitempty"`
+	NewTime        *time.Time       `json:"new_time,omitempty"`
+	NewTimeLayout  string           `json:"new_time_layout,omitempty"`
+	Extended       []string         `json:"extended,omitempty"`
+	OrigMode       uint32           `json:"orig_mode,omitempty"`
+	NewMode        uint32           `json:"new_mode,omitempty"`
+	OrigSHA        string           `json:"orig_sha,omitempty"`
+	NewSHA         string           `json:"new_sha,omitempty"`
+	Status         string           `json:"status,omitempty"`
+	Hunks          []*Hunk          `json:"hunks,omitempty"`
+	RawHunks       string           `json:"raw_hunks,omitempty"`
+	RawHunksBase64 []byte           `json:"raw_hunks_base64,omitempty"`
+	BinaryPatch    *binaryPatchJSON `json:"binary_patch,omitempty"`
+}
+
+type hunkJSON struct {
+	OrigStartLine   int32            `json:"orig_start_line"`
+	OrigLines       int32            `json:"orig_lines"`
+	OrigNoNewlineAt int32            `json:"orig_no_newline_at,omitempty"`
+	NewStartLine    int32            `json:"new_start_line"`
+	NewLines        int32            `json:"new_lines"`
+	Section         string           `json:"section,omitempty"`
+	EmptySection    bool             `json:"empty_section,omitempty"`
+	StartPosition   int32            `json:"start_position,omitempty"`
+	Body            string           `json:"body,omitempty"`
+	BodyBase64      []byte           `json:"body_base64,omitempty"`
+	Refinements     []refinementJSON `json:"refinements,omitempty"`
+}
+
+type refinementJSON struct {
+	Line  int `json:"line"`
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type statJSON struct {
+	Added   int32 `json:"added"`
+	Changed int32 `json:"changed"`
+	Deleted int32 `json:"deleted"`
+}
+
+type binaryPatchJSON struct {
+	Forward *binaryHunkJSON `json:"forward,omitempty"`
+	Reverse *binaryHunkJSON `json:"reverse,omitempty"`
+}
+
+// binaryHunkJSON is a BinaryHunk, with its type as "literal" or "delta"
+// and its data in base64.
+type binaryHunkJSON struct {
+	Type string `json:"type"`
+	Data []byte `json:"data"`
+}
+
+// MarshalJSON encodes d in the schema described by JSONSchemaVersion.
+func (d FileDiff) MarshalJSON() ([]byte, error) {
+	v := fileDiffJSON{
+		Version:        JSONSchemaVersion,
+		OrigName:       d.OrigName,
+		OrigTime:       d.OrigTime,
+		OrigTimeLayout: d.OrigTimeLayout,
+		NewName:        d.NewName,
+		NewTime:        d.NewTime,
+		NewTimeLayout:  d.NewTimeLayout,
+		Extended:       d.Extended,
+		OrigMode:       d.OrigMode,
+		NewMode:        d.NewMode,
+		OrigSHA:        d.OrigSHA,
+		NewSHA:         d.NewSHA,
+		Status:         d.Status,
+		Hunks:          d.Hunks,
+	}
+	v.RawHunks, v.RawHunksBase64 = encodeText(d.RawHunks)
+	if bp := d.BinaryPatch; bp != nil {
+		v.BinaryPatch = &binaryPatchJSON{Forward: binaryHunkToJSON(bp.Forward), Reverse: binaryHunkToJSON(bp.Reverse)}
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes d from the schema described by JSONSchemaVersion.
+func (d *FileDiff) UnmarshalJSON(data []byte) error {
+	var v fileDiffJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Version > JSONSchemaVersion {
+		return fmt.Errorf("unsupported file diff JSON schema version %d (want at most %d)", v.Version, JSONSchemaVersion)
+	}
+	*d = FileDiff{
+		OrigName:       v.OrigName,
+		OrigTime:       v.OrigTime,
+		OrigTimeLayout: v.OrigTimeLayout,
+		NewName:        v.NewName,
+		NewTime:        v.NewTime,
+		NewTimeLayout:  v.NewTimeLayout,
+		Extended:       v.Extended,
+		OrigMode:       v.OrigMode,
+		NewMode:        v.NewMode,
+		OrigSHA:        v.OrigSHA,
+		NewSHA:         v.NewSHA,
+		Status:         v.Status,
+		Hunks:          v.Hunks,
+		RawHunks:       decodeText(v.RawHunks, v.RawHunksBase64),
+	}
+	if bp := v.BinaryPatch; bp != nil {
+		var err error
+		d.BinaryPatch = &BinaryPatch{}
+		if d.BinaryPatch.Forward, err = binaryHunkFromJSON(bp.Forward); err != nil {
+			return err
+		}
+		if d.BinaryPatch.Reverse, err = binaryHunkFromJSON(bp.Reverse); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes h in the schema described by JSONSchemaVersion.
+func (h Hunk) MarshalJSON() ([]byte, error) {
+	v := hunkJSON{
+		OrigStartLine:   h.OrigStartLine,
+		OrigLines:       h.OrigLines,
+		OrigNoNewlineAt: h.OrigNoNewlineAt,
+		NewStartLine:    h.NewStartLine,
+		NewLines:        h.NewLines,
+		Section:         h.Section,
+		EmptySection:    h.EmptySection,
+		StartPosition:   h.StartPosition,
+	}
+	v.Body, v.BodyBase64 = encodeText(h.Body)
+	for _, r := range h.Refinements {
+		v.Refinements = append(v.Refinements, refinementJSON{Line: r.Line, Start: r.Start, End: r.End})
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes h from the schema described by JSONSchemaVersion.
+func (h *Hunk) UnmarshalJSON(data []byte) error {
+	var v hunkJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*h = Hunk{
+		OrigStartLine:   v.OrigStartLine,
+		OrigLines:       v.OrigLines,
+		OrigNoNewlineAt: v.OrigNoNewlineAt,
+		NewStartLine:    v.NewStartLine,
+		NewLines:        v.NewLines,
+		Section:         v.Section,
+		EmptySection:    v.EmptySection,
+		StartPosition:   v.StartPosition,
+		Body:            decodeText(v.Body, v.BodyBase64),
+	}
+	for _, r := range v.Refinements {
+		h.Refinements = append(h.Refinements, Refinement{Line: r.Line, Start: r.Start, End: r.End})
+	}
+	return nil
+}
+
+// MarshalJSON encodes s in the schema described by JSONSchemaVersion.
+func (s Stat) MarshalJSON() ([]byte, error) {
+	return json.Marshal(statJSON{Added: s.Added, Changed: s.Changed, Deleted: s.Deleted})
+}
+
+// UnmarshalJSON decodes s from the schema described by JSONSchemaVersion.
+func (s *Stat) UnmarshalJSON(data []byte) error {
+	var v statJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = Stat{Added: v.Added, Changed: v.Changed, Deleted: v.Deleted}
+	return nil
+}
+
+// encodeText returns b as a string if it is valid UTF-8, and otherwise as
+// bytes to be encoded in base64.
+func encodeText(b []byte) (string, []byte) {
+	if utf8.Valid(b) {
+		return string(b), nil
+	}
+	return "", b
+}
+
+// decodeText is the inverse of encodeText. It returns nil for empty text.
+func decodeText(s string, b []byte) []byte {
+	if len(b) > 0 {
+		return b
+	}
+	if s == "" {
+		return nil
+	}
+	return []byte(s)
+}
+
+func binaryHunkToJSON(h *BinaryHunk) *binaryHunkJSON {
+	if h == nil {
+		return nil
+	}
+	return &binaryHunkJSON{Type: h.Type.String(), Data: h.Data}
+}
+
+func binaryHunkFromJSON(v *binaryHunkJSON) (*BinaryHunk, error) {
+	if v == nil {
+		return nil, nil
+	}
+	h := &BinaryHunk{Data: v.Data}
+	switch v.Type {
+	case BinaryLiteral.String():
+		h.Type = BinaryLiteral
+	case BinaryDelta.String():
+		h.Type = BinaryDelta
+	default:
+		return nil, fmt.Errorf("unknown binary hunk type %q", v.Type)
+	}
+	return h, nil
+}
@@ -0,0 +1,71 @@
+package diff
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileDiff_JSON(t *testing.T) {
+	filenames, err := filepath.Glob(filepath.Join("testdata", "*.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range filenames {
+		diffData, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diffs, err := ParseMultiFileDiff(diffData)
+		if err != nil {
+			continue
+		}
+		data, err := json.Marshal(diffs)
+		if err != nil {
+			t.Fatalf("%s: %s", filename, err)
+		}
+		var got []*FileDiff
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s: %s", filename, err)
+		}
+		// Comparing the encodings is much faster than comparing the diffs
+		// for large ones.
+		data2, err := json.Marshal(got)
+		if err != nil {
+			t.Fatalf("%s: %s", filename, err)
+		}
+		if string(data2) != string(data) {
+			t.Errorf("%s: decoded diffs mismatch (-want +got):\n%s", filename, cmp.Diff(diffs, got))
+		}
+	}
+}
+
+func TestFileDiff_JSONSchema(t *testing.T) {
+	d, err := ParseFileDiff([]byte("diff --git a/f b/f\nindex 0123456..789abcd 100644\n--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@ func\n a\n-b\n+\xff\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"version":1,"orig_name":"a/f","new_name":"b/f","extended":["diff --git a/f b/f","index 0123456..789abcd 100644"],"orig_mode":33188,"new_mode":33188,"hunks":[{"orig_start_line":1,"orig_lines":2,"new_start_line":1,"new_lines":2,"section":"func","start_position":1,"body_base64":"IGEKLWIKK/8K"}]}`
+	var d2 FileDiff
+	if err := json.Unmarshal([]byte(want), &d2); err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(&d2, d) {
+		t.Errorf("decoded diff mismatch (-want +got):\n%s", cmp.Diff(d, &d2))
+	}
+	if got, err := json.Marshal(d); err != nil || string(got) != want {
+		t.Errorf("got JSON %s (error %v), want %s", got, err, want)
+	}
+
+	stat, err := json.Marshal(d.Stat())
+	if want := `{"added":0,"changed":1,"deleted":0}`; err != nil || string(stat) != want {
+		t.Errorf("got stat JSON %s (error %v), want %s", stat, err, want)
+	}
+
+	if err := json.Unmarshal([]byte(`{"version":2}`), &d2); err == nil {
+		t.Error("decoded a diff with a later schema version")
+	}
+}
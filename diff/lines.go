@@ -0,0 +1,318 @@
+package diff
+
+import "strconv"
+
+// An Anchor is a context line that a hunk relies on to locate itself in the
+// original file.
+type Anchor struct {
+	// Hunk is the index of the hunk in FileDiff.Hunks.
+	Hunk int
+	// OrigLine is the line number of the context line in the original file.
+	OrigLine int
+	// Text is the content of the context line, without its newline.
+	Text string
+}
+
+// ContextAnchors returns the first and last context lines of each hunk in
+// d, along with the line numbers where the hunk expects to find them in the
+// original file. A hunk with a single context line yields one anchor, and a
+// hunk without context yields none.
+func (d *FileDiff) ContextAnchors() []Anchor {
+	var anchors []Anchor
+	for i, h := range d.Hunks {
+		lines := h.ContextLines()
+		if len(lines) == 0 {
+			continue
+		}
+		first, last := lines[0], lines[len(lines)-1]
+		anchors = append(anchors, Anchor{Hunk: i, OrigLine: first.OrigLine, Text: first.Text})
+		if len(lines) > 1 {
+			anchors = append(anchors, Anchor{Hunk: i, OrigLine: last.OrigLine, Text: last.Text})
+		}
+	}
+	return anchors
+}
+
+// A NumberedLine is a line of a hunk with its line numbers in the original
+// and new files.
+type NumberedLine struct {
+	// OrigLine is the line number in the original file.
+	OrigLine int
+	// NewLine is the line number in the new file.
+	NewLine int
+	// Text is the content of the line, without its prefix or newline.
+	Text string
+}
+
+// ContextLines returns the context (unchanged) lines of the hunk, with
+// their line numbers in both the original and new files.
+func (h *Hunk) ContextLines() []NumberedLine {
+	var lines []NumberedLine
+	origLine, newLine := h.lineStarts()
+	for _, l := range h.lines() {
+		switch l.op {
+		case ' ':
+			lines = append(lines, NumberedLine{OrigLine: origLine, NewLine: newLine, Text: string(l.text)})
+			origLine++
+			newLine++
+		case '-':
+			origLine++
+		case '+':
+			newLine++
+		}
+	}
+	return lines
+}
+
+// AddedTrailingWhitespaceLines returns the lines added by d that end in a
+// space or tab, with their line numbers in the new file (OrigLine is 0).
+// Context and removed lines are never reported, so that only trailing
+// whitespace introduced by d is flagged.
+func (d *FileDiff) AddedTrailingWhitespaceLines() []NumberedLine {
+	var lines []NumberedLine
+	for _, op := range d.Operations() {
+		if op.Op != OpInsert || op.Text == "" {
+			continue
+		}
+		if last := op.Text[len(op.Text)-1]; last == ' ' || last == '\t' {
+			lines = append(lines, NumberedLine{NewLine: op.NewLine, Text: op.Text})
+		}
+	}
+	return lines
+}
+
+// An Op is the operation a diff performs on a line.
+type Op int
+
+const (
+	// OpKeep leaves a (context) line unchanged.
+	OpKeep Op = iota
+	// OpInsert adds a line to the new file.
+	OpInsert
+	// OpDelete removes a line from the original file.
+	OpDelete
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpKeep:
+		return "keep"
+	case OpInsert:
+		return "insert"
+	case OpDelete:
+		return "delete"
+	}
+	return "Op(" + strconv.Itoa(int(op)) + ")"
+}
+
+// A LineOp is an operation on a single line of a file.
+type LineOp struct {
+	// OrigLine is the line number in the original file (0 for an insertion).
+	OrigLine int
+	// NewLine is the line number in the new file (0 for a deletion).
+	NewLine int
+	// Op is the operation performed on the line.
+	Op Op
+	// Text is the content of the line, without its prefix or newline.
+	Text string
+}
+
+// Operations returns the lines of all of d's hunks as a single sequence of
+// operations, in order, with their line numbers in the original and new
+// files. Only the lines in hunks are included, not those in the gaps between
+// them.
+func (d *FileDiff) Operations() []LineOp {
+	var ops []LineOp
+	for _, h := range d.Hunks {
+		ops = append(ops, h.operations()...)
+	}
+	return ops
+}
+
+// operations returns the lines of h as operations (see FileDiff.Operations).
+func (h *Hunk) operations() []LineOp {
+	var ops []LineOp
+	origLine, newLine := h.lineStarts()
+	for _, l := range h.lines() {
+		op := LineOp{Text: string(l.text)}
+		switch l.op {
+		case ' ':
+			op.Op, op.OrigLine, op.NewLine = OpKeep, origLine, newLine
+			origLine++
+			newLine++
+		case '-':
+			op.Op, op.OrigLine = OpDelete, origLine
+			origLine++
+		case '+':
+			op.Op, op.NewLine = OpInsert, newLine
+			newLine++
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// AlignmentPairs returns the (original, new) line number pairs of the lines
+// that d leaves unchanged, in order: the context lines of its hunks and the
+// lines in the gaps before and between them. Changed lines have no pair.
+// Lines after the last hunk are not included, as the diff does not record
+// where the files end.
+func (d *FileDiff) AlignmentPairs() [][2]int {
+	var pairs [][2]int
+	origLine, newLine := 1, 1 // the first lines not yet paired or skipped
+	for _, h := range d.Hunks {
+		origStart, newStart := h.lineStarts()
+		for ; origLine < origStart; origLine++ {
+			pairs = append(pairs, [2]int{origLine, newLine})
+			newLine++
+		}
+		origLine, newLine = origStart, newStart
+		for _, l := range h.lines() {
+			switch l.op {
+			case ' ':
+				pairs = append(pairs, [2]int{origLine, newLine})
+				origLine++
+				newLine++
+			case '-':
+				origLine++
+			case '+':
+				newLine++
+			}
+		}
+	}
+	return pairs
+}
+
+// MapLine returns the line number in the new file of the given line of the
+// original file, with deleted set if d removes the line. For a deleted line,
+// newLine is the number of the new file's line that follows the deletion.
+// Lines outside the hunks are shifted by the lines added and removed before
+// them.
+func (d *FileDiff) MapLine(origLine int) (newLine int, deleted bool) {
+	return d.mapLine(origLine, '-')
+}
+
+// MapLineReverse is the inverse of MapLine: it returns the line number in
+// the original file of the given line of the new file, with added set if d
+// adds the line. For an added line, origLine is the number of the original
+// file's line that follows the insertion.
+func (d *FileDiff) MapLineReverse(newLine int) (origLine int, added bool) {
+	return d.mapLine(newLine, '+')
+}
+
+// A LineState is what a diff does to a line mapped by MapOrigToNew or
+// MapNewToOrig.
+type LineState int
+
+const (
+	// LineUnchanged is a line that the diff keeps, as a context line or
+	// outside its hunks.
+	LineUnchanged LineState = iota
+	// LineDeleted is a line of the original file that the diff removes.
+	LineDeleted
+	// LineAdded is a line of the new file that the diff adds.
+	LineAdded
+)
+
+func (s LineState) String() string {
+	switch s {
+	case LineUnchanged:
+		return "unchanged"
+	case LineDeleted:
+		return "deleted"
+	case LineAdded:
+		return "added"
+	}
+	return "LineState(" + strconv.Itoa(int(s)) + ")"
+}
+
+// MapOrigToNew returns the line number in the new file of the given line
+// of the original file, as MapLine does, and whether d keeps the line
+// (LineUnchanged) or deletes it (LineDeleted), so that tools such as
+// coverage reporters and linters can translate line numbers across d.
+func (d *FileDiff) MapOrigToNew(line int) (int, LineState) {
+	newLine, deleted := d.mapLine(line, '-')
+	if deleted {
+		return newLine, LineDeleted
+	}
+	return newLine, LineUnchanged
+}
+
+// MapNewToOrig is the inverse of MapOrigToNew: it returns the line number in
+// the original file of the given line of the new file, as MapLineReverse
+// does, and whether d keeps the line (LineUnchanged) or adds it
+// (LineAdded).
+func (d *FileDiff) MapNewToOrig(line int) (int, LineState) {
+	origLine, added := d.mapLine(line, '+')
+	if added {
+		return origLine, LineAdded
+	}
+	return origLine, LineUnchanged
+}
+
+// mapLine maps a line number from one side of d to the other. The side is
+// given by op: '-' maps from the original file, '+' from the new file. The
+// result reports whether the line only exists on the given side.
+func (d *FileDiff) mapLine(line int, op byte) (int, bool) {
+	offset := 0 // to add to line numbers on the given side, outside hunks
+	for _, h := range d.Hunks {
+		from, to := h.lineStarts()
+		if op == '+' {
+			from, to = to, from
+		}
+		if line < from {
+			break
+		}
+		for _, l := range h.lines() {
+			switch l.op {
+			case ' ':
+				if from == line {
+					return to, false
+				}
+				from++
+				to++
+			case op:
+				if from == line {
+					return to, true
+				}
+				from++
+			default:
+				to++
+			}
+		}
+		offset = to - from
+	}
+	return line + offset, false
+}
+
+// lineStarts returns the numbers of the first original and new lines that
+// the hunk covers. An empty range refers to the line before it, so it starts
+// at the following line.
+func (h *Hunk) lineStarts() (origLine, newLine int) {
+	origLine, newLine = int(h.OrigStartLine), int(h.NewStartLine)
+	if h.OrigLines == 0 {
+		origLine++
+	}
+	if h.NewLines == 0 {
+		newLine++
+	}
+	return origLine, newLine
+}
+
+// DeletedText returns the contents of the lines removed by the hunk, with
+// their '-' prefixes stripped and their newlines preserved. It is the text
+// that would need to be restored to undo the hunk's removals. A hunk that
+// only adds lines returns an empty result.
+func (h *Hunk) DeletedText() []byte {
+	var text []byte
+	for _, l := range h.lines() {
+		if l.op != '-' {
+			continue
+		}
+		text = append(text, l.text...)
+		if !l.noNewline {
+			text = append(text, '\n')
+		}
+	}
+	return text
+}
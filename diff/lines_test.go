@@ -0,0 +1,272 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileDiff_ContextAnchors(t *testing.T) {
+	fd := parseFileDiffString(t, `--- a/f
++++ b/f
+@@ -1,4 +1,4 @@
+ a
+ b
+-c
++C
+ d
+@@ -10,3 +10,4 @@ section
+ j
++k
+ l
+ m
+@@ -20,1 +21,2 @@
+ t
++u
+@@ -30,1 +32,1 @@
+-x
++y
+`)
+	want := []Anchor{
+		{Hunk: 0, OrigLine: 1, Text: "a"},
+		{Hunk: 0, OrigLine: 4, Text: "d"},
+		{Hunk: 1, OrigLine: 10, Text: "j"},
+		{Hunk: 1, OrigLine: 12, Text: "m"},
+		{Hunk: 2, OrigLine: 20, Text: "t"},
+	}
+	if got := fd.ContextAnchors(); !cmp.Equal(got, want) {
+		t.Errorf("anchors mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestHunk_DeletedText(t *testing.T) {
+	tests := map[string]struct {
+		hunks string
+		want  string
+	}{
+		"removed lines": {
+			hunks: `@@ -1,4 +1,2 @@
+ a
+-b
+-c
+ d
++e
+`,
+			want: "b\nc\n",
+		},
+		"removed line without newline": {
+			hunks: `@@ -1,3 +1,2 @@
+ a
+-b
+-c
+\ No newline at end of file
++d
+`,
+			want: "b\nc",
+		},
+		"pure addition": {
+			hunks: `@@ -1,1 +1,2 @@
+ a
++b
+`,
+			want: "",
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			hunks, err := ParseHunks([]byte(test.hunks))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(hunks[0].DeletedText()); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHunk_ContextLines(t *testing.T) {
+	hunks, err := ParseHunks([]byte(`@@ -10,7 +10,8 @@
+ a
+ b
+-c
++C
++D
+ e
++f
+ g
+-h
+ i
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []NumberedLine{
+		{OrigLine: 10, NewLine: 10, Text: "a"},
+		{OrigLine: 11, NewLine: 11, Text: "b"},
+		{OrigLine: 13, NewLine: 14, Text: "e"},
+		{OrigLine: 14, NewLine: 16, Text: "g"},
+		{OrigLine: 16, NewLine: 17, Text: "i"},
+	}
+	if got := hunks[0].ContextLines(); !cmp.Equal(got, want) {
+		t.Errorf("context lines mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestFileDiff_MapLine(t *testing.T) {
+	fd := parseFileDiffString(t, `--- a/f
++++ b/f
+@@ -2,3 +2,4 @@
+ b
+-c
++C
++D
+ e
+@@ -10,2 +11,1 @@
+ j
+-k
+`)
+	tests := []struct {
+		orig, new int
+		changed   bool
+	}{
+		{orig: 1, new: 1},
+		{orig: 2, new: 2},
+		{orig: 3, new: 3, changed: true},
+		{orig: 4, new: 5},
+		{orig: 7, new: 8},
+		{orig: 10, new: 11},
+		{orig: 11, new: 12, changed: true},
+		{orig: 12, new: 12},
+	}
+	for _, test := range tests {
+		if newLine, deleted := fd.MapLine(test.orig); newLine != test.new || deleted != test.changed {
+			t.Errorf("MapLine(%d): got %d, %v, want %d, %v", test.orig, newLine, deleted, test.new, test.changed)
+		}
+	}
+
+	reverseTests := []struct {
+		new, orig int
+		changed   bool
+	}{
+		{new: 1, orig: 1},
+		{new: 2, orig: 2},
+		{new: 3, orig: 4, changed: true},
+		{new: 4, orig: 4, changed: true},
+		{new: 5, orig: 4},
+		{new: 8, orig: 7},
+		{new: 11, orig: 10},
+		{new: 12, orig: 12},
+	}
+	for _, test := range reverseTests {
+		if origLine, added := fd.MapLineReverse(test.new); origLine != test.orig || added != test.changed {
+			t.Errorf("MapLineReverse(%d): got %d, %v, want %d, %v", test.new, origLine, added, test.orig, test.changed)
+		}
+	}
+}
+
+func TestFileDiff_MapOrigToNew(t *testing.T) {
+	fd := parseFileDiffString(t, `--- a/f
++++ b/f
+@@ -2,3 +2,4 @@
+ b
+-c
++C
++D
+ e
+`)
+	tests := []struct {
+		line, want int
+		state      LineState
+	}{
+		{line: 2, want: 2, state: LineUnchanged},
+		{line: 3, want: 3, state: LineDeleted},
+		{line: 4, want: 5, state: LineUnchanged},
+		{line: 9, want: 10, state: LineUnchanged},
+	}
+	for _, test := range tests {
+		if got, state := fd.MapOrigToNew(test.line); got != test.want || state != test.state {
+			t.Errorf("MapOrigToNew(%d): got %d, %s, want %d, %s", test.line, got, state, test.want, test.state)
+		}
+	}
+
+	reverseTests := []struct {
+		line, want int
+		state      LineState
+	}{
+		{line: 2, want: 2, state: LineUnchanged},
+		{line: 4, want: 4, state: LineAdded},
+		{line: 5, want: 4, state: LineUnchanged},
+		{line: 10, want: 9, state: LineUnchanged},
+	}
+	for _, test := range reverseTests {
+		if got, state := fd.MapNewToOrig(test.line); got != test.want || state != test.state {
+			t.Errorf("MapNewToOrig(%d): got %d, %s, want %d, %s", test.line, got, state, test.want, test.state)
+		}
+	}
+}
+
+func TestFileDiff_Operations(t *testing.T) {
+	fd := parseFileDiffString(t, `--- a/f
++++ b/f
+@@ -2,3 +2,3 @@
+ b
+-c
++C
+ d
+@@ -10,2 +10,3 @@
+ j
++k
++l
+-m
+`)
+	want := []LineOp{
+		{OrigLine: 2, NewLine: 2, Op: OpKeep, Text: "b"},
+		{OrigLine: 3, Op: OpDelete, Text: "c"},
+		{NewLine: 3, Op: OpInsert, Text: "C"},
+		{OrigLine: 4, NewLine: 4, Op: OpKeep, Text: "d"},
+		{OrigLine: 10, NewLine: 10, Op: OpKeep, Text: "j"},
+		{NewLine: 11, Op: OpInsert, Text: "k"},
+		{NewLine: 12, Op: OpInsert, Text: "l"},
+		{OrigLine: 11, Op: OpDelete, Text: "m"},
+	}
+	if got := fd.Operations(); !cmp.Equal(got, want) {
+		t.Errorf("operations mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestFileDiff_AlignmentPairs(t *testing.T) {
+	fd := parseFileDiffString(t, `--- a/f
++++ b/f
+@@ -3,3 +3,4 @@
+ c
+-d
++D
++E
+ f
+@@ -9,3 +10,2 @@
+ i
+-j
+ k
+`)
+	want := [][2]int{
+		{1, 1}, {2, 2}, // before the first hunk
+		{3, 3}, {5, 6}, // first hunk
+		{6, 7}, {7, 8}, {8, 9}, // between the hunks
+		{9, 10}, {11, 11}, // second hunk
+	}
+	if got := fd.AlignmentPairs(); !cmp.Equal(got, want) {
+		t.Errorf("pairs mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestFileDiff_AddedTrailingWhitespaceLines(t *testing.T) {
+	fd := parseFileDiffString(t, "--- a/f\n+++ b/f\n@@ -1,4 +1,6 @@\n a  \n-b \n+b\n+c \n d\t\n+e\t\n+f\n g\n")
+	want := []NumberedLine{
+		{NewLine: 3, Text: "c "},
+		{NewLine: 5, Text: "e\t"},
+	}
+	if got := fd.AddedTrailingWhitespaceLines(); !cmp.Equal(got, want) {
+		t.Errorf("lines mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,141 @@
+package diff
+
+// myers computes a shortest edit script transforming a into b, using the
+// linear space variant of Myers' O(ND) algorithm (as GNU diff does). It
+// returns, for each element of a, whether it was deleted and, for each
+// element of b, whether it was inserted.
+//
+// See "An O(ND) Difference Algorithm and Its Variations", Eugene W. Myers,
+// Algorithmica 1 (1986).
+func myers(a, b []int) (deleted, inserted []bool) {
+	m := &myersState{
+		a:        a,
+		b:        b,
+		deleted:  make([]bool, len(a)),
+		inserted: make([]bool, len(b)),
+	}
+	// Diagonals range from -len(b) to len(a), and the search reads one
+	// diagonal beyond each end.
+	size := len(a) + len(b) + 3
+	m.fd = make([]int, size)
+	m.bd = make([]int, size)
+	m.off = len(b) + 1
+	m.compare(0, len(a), 0, len(b))
+	return m.deleted, m.inserted
+}
+
+type myersState struct {
+	a, b              []int
+	deleted, inserted []bool
+
+	// fd and bd hold the furthest reaching x on each diagonal (offset by
+	// off) for the forward and backward searches.
+	fd, bd []int
+	off    int
+}
+
+// compare marks the differences between a[xoff:xlim] and b[yoff:ylim].
+func (m *myersState) compare(xoff, xlim, yoff, ylim int) {
+	for xoff < xlim && yoff < ylim && m.a[xoff] == m.b[yoff] {
+		xoff++
+		yoff++
+	}
+	for xoff < xlim && yoff < ylim && m.a[xlim-1] == m.b[ylim-1] {
+		xlim--
+		ylim--
+	}
+
+	switch {
+	case xoff == xlim:
+		for y := yoff; y < ylim; y++ {
+			m.inserted[y] = true
+		}
+	case yoff == ylim:
+		for x := xoff; x < xlim; x++ {
+			m.deleted[x] = true
+		}
+	default:
+		x, y := m.middleSnake(xoff, xlim, yoff, ylim)
+		m.compare(xoff, x, yoff, y)
+		m.compare(x, xlim, y, ylim)
+	}
+}
+
+// middleSnake finds a point on a shortest edit path between a[xoff:xlim]
+// and b[yoff:ylim] where the forward and backward searches meet, which
+// splits the problem into two smaller ones. The ranges must be non-empty
+// and have no common prefix or suffix.
+func (m *myersState) middleSnake(xoff, xlim, yoff, ylim int) (int, int) {
+	a, b, fd, bd, off := m.a, m.b, m.fd, m.bd, m.off
+	dmin, dmax := xoff-ylim, xlim-yoff
+	fmid, bmid := xoff-yoff, xlim-ylim
+	fmin, fmax := fmid, fmid
+	bmin, bmax := bmid, bmid
+	odd := (fmid-bmid)&1 != 0
+	fd[off+fmid] = xoff
+	bd[off+bmid] = xlim
+
+	for {
+		// Extend the forward search by one edit.
+		if fmin > dmin {
+			fmin--
+			fd[off+fmin-1] = -1
+		} else {
+			fmin++
+		}
+		if fmax < dmax {
+			fmax++
+			fd[off+fmax+1] = -1
+		} else {
+			fmax--
+		}
+		for d := fmax; d >= fmin; d -= 2 {
+			var x int
+			if tlo, thi := fd[off+d-1], fd[off+d+1]; tlo >= thi {
+				x = tlo + 1
+			} else {
+				x = thi
+			}
+			y := x - d
+			for x < xlim && y < ylim && a[x] == b[y] {
+				x++
+				y++
+			}
+			fd[off+d] = x
+			if odd && bmin <= d && d <= bmax && bd[off+d] <= x {
+				return x, y
+			}
+		}
+
+		// Extend the backward search by one edit.
+		if bmin > dmin {
+			bmin--
+			bd[off+bmin-1] = xlim + 1
+		} else {
+			bmin++
+		}
+		if bmax < dmax {
+			bmax++
+			bd[off+bmax+1] = xlim + 1
+		} else {
+			bmax--
+		}
+		for d := bmax; d >= bmin; d -= 2 {
+			var x int
+			if tlo, thi := bd[off+d-1], bd[off+d+1]; tlo < thi {
+				x = tlo
+			} else {
+				x = thi - 1
+			}
+			y := x - d
+			for x > xoff && y > yoff && a[x-1] == b[y-1] {
+				x--
+				y--
+			}
+			bd[off+d] = x
+			if !odd && fmin <= d && d <= fmax && x <= fd[off+d] {
+				return x, y
+			}
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package diff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrBadNormalCommand is when a normal diff has a malformed or
+	// inconsistent command line, such as "3c3" or "5,7d4".
+	ErrBadNormalCommand = errors.New("bad normal diff command")
+
+	// ErrBadNormalLine is when a line of a normal diff command does not
+	// start with "< " or "> " (or, between the two sides of a change, is
+	// not "---").
+	ErrBadNormalLine = errors.New("bad normal diff line")
+)
+
+var normalCommand = regexp.MustCompile(`^(\d+)(?:,(\d+))?([acd])(\d+)(?:,(\d+))?$`)
+
+// ParseHunksNormal parses a diff in the normal format, the default output
+// of diff and the one POSIX specifies:
+//
+//	3c3
+//	< b
+//	---
+//	> B
+//	5a6,7
+//	> x
+//	> y
+//
+// Each command becomes a hunk without context lines, as in a unified diff
+// produced with -U0.
+func ParseHunksNormal(diff []byte) ([]*Hunk, error) {
+	p := newLineParser(diff)
+	hunks, err := p.readNormalHunks()
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(p.lines) {
+		return nil, p.parseError(ErrBadNormalCommand)
+	}
+	return hunks, nil
+}
+
+// ParseMultiFileDiffNormal parses a multi-file diff in the normal format,
+// such as the output of diff -r. Each file's commands follow a line such as
+// "diff -r a/foo.go b/foo.go", whose last two fields give the file names,
+// and which is kept in Extended, with any preceding lines that are not part
+// of a file's diff. A "Binary files a/x and b/x differ" line is a file of
+// its own, kept in Extended. "Only in" messages are parsed as by
+// ParseMultiFileDiff, and lines after the last file are ignored. See
+// ParseHunksNormal.
+func ParseMultiFileDiffNormal(diff []byte) ([]*FileDiff, error) {
+	p := newLineParser(diff)
+	var ds []*FileDiff
+	var extended []string
+	for p.i < len(p.lines) {
+		line := p.text(p.i)
+		if ok, source, filename := parseOnlyInMessage([]byte(line)); ok {
+			ds = append(ds, &FileDiff{OrigName: filepath.Join(string(source), string(filename)), Extended: extended})
+			extended = nil
+			p.next()
+			continue
+		}
+		if orig, new, ok := parseBinaryFilesMessage(line); ok {
+			ds = append(ds, &FileDiff{OrigName: orig, NewName: new, Extended: append(extended, line)})
+			extended = nil
+			p.next()
+			continue
+		}
+		if normalCommand.MatchString(line) {
+			return nil, p.parseError(ErrNoFileHeader)
+		}
+		fields := strings.Fields(line)
+		if !strings.HasPrefix(line, "diff ") || len(fields) < 3 {
+			extended = append(extended, line)
+			p.next()
+			continue
+		}
+
+		d := &FileDiff{OrigName: fields[len(fields)-2], NewName: fields[len(fields)-1]}
+		d.Extended = append(extended, line)
+		extended = nil
+		p.next()
+		for p.i < len(p.lines) && !p.atNormalFileEnd() && !normalCommand.MatchString(p.text(p.i)) {
+			d.Extended = append(d.Extended, p.text(p.i))
+			p.next()
+		}
+		var err error
+		if d.Hunks, err = p.readNormalHunks(); err != nil {
+			return nil, err
+		}
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
+
+// atNormalFileEnd reports whether the next line starts another file of a
+// multi-file normal diff.
+func (p *lineParser) atNormalFileEnd() bool {
+	line := p.text(p.i)
+	isOnlyIn, _, _ := parseOnlyInMessage([]byte(line))
+	_, _, isBinary := parseBinaryFilesMessage(line)
+	return isOnlyIn || isBinary || strings.HasPrefix(line, "diff ")
+}
+
+// parseBinaryFilesMessage parses a "Binary files <orig> and <new> differ"
+// line.
+func parseBinaryFilesMessage(line string) (orig, new string, ok bool) {
+	if !strings.HasPrefix(line, "Binary files ") || !strings.HasSuffix(line, " differ") {
+		return "", "", false
+	}
+	names := strings.SplitN(line[len("Binary files "):len(line)-len(" differ")], " and ", 2)
+	if len(names) != 2 {
+		return "", "", false
+	}
+	return names[0], names[1], true
+}
+
+// readNormalHunks reads normal diff commands for as long as the next line
+// is one.
+func (p *lineParser) readNormalHunks() ([]*Hunk, error) {
+	var hunks []*Hunk
+	var position int32
+	for p.i < len(p.lines) {
+		m := normalCommand.FindStringSubmatch(p.text(p.i))
+		if m == nil {
+			break
+		}
+		h, err := p.readNormalHunk(m)
+		if err != nil {
+			return nil, err
+		}
+		position++ // account for the hunk header line
+		h.StartPosition = position
+		position += int32(bytes.Count(h.Body, []byte{'\n'}))
+		hunks = append(hunks, h)
+	}
+	return hunks, nil
+}
+
+// readNormalHunk reads the command whose line matched normalCommand as m,
+// and its lines.
+func (p *lineParser) readNormalHunk(m []string) (*Hunk, error) {
+	cmdError := p.parseError(ErrBadNormalCommand)
+	origStart, origLines, ok1 := normalRange(m[1], m[2])
+	newStart, newLines, ok2 := normalRange(m[4], m[5])
+	if !ok1 || !ok2 {
+		return nil, cmdError
+	}
+	switch m[3] {
+	case "a":
+		if m[2] != "" {
+			return nil, cmdError
+		}
+		origLines = 0
+	case "d":
+		if m[5] != "" {
+			return nil, cmdError
+		}
+		newLines = 0
+	}
+	p.next()
+
+	var lines []hunkLine
+	readLines := func(prefix byte, op byte, n int) error {
+		for i := 0; i < n; i++ {
+			if p.i == len(p.lines) {
+				return p.parseError(ErrBadNormalLine)
+			}
+			line := p.text(p.i)
+			if len(line) == 0 || line[0] != prefix || (len(line) > 1 && line[1] != ' ') {
+				return p.parseError(ErrBadNormalLine)
+			}
+			l := hunkLine{op: op}
+			if len(line) > 1 {
+				l.text = []byte(line[2:])
+			}
+			p.next()
+			if p.i < len(p.lines) && strings.HasPrefix(p.lines[p.i], `\ `) {
+				l.noNewline = true
+				p.next()
+			}
+			lines = append(lines, l)
+		}
+		return nil
+	}
+	if err := readLines('<', '-', origLines); err != nil {
+		return nil, err
+	}
+	if m[3] == "c" {
+		if p.i == len(p.lines) || p.text(p.i) != "---" {
+			return nil, p.parseError(ErrBadNormalLine)
+		}
+		p.next()
+	}
+	if err := readLines('>', '+', newLines); err != nil {
+		return nil, err
+	}
+
+	h := &Hunk{
+		OrigStartLine: int32(origStart),
+		OrigLines:     int32(origLines),
+		NewStartLine:  int32(newStart),
+		NewLines:      int32(newLines),
+	}
+	h.setLines(lines)
+	return h, nil
+}
+
+// normalRange parses the range "start[,end]" of a normal diff command and
+// returns its start and number of lines.
+func normalRange(start, end string) (int, int, bool) {
+	a, _ := strconv.Atoi(start)
+	if end == "" {
+		return a, 1, true
+	}
+	b, _ := strconv.Atoi(end)
+	if b <= a {
+		return 0, 0, false
+	}
+	return a, b - a + 1, true
+}
+
+// PrintMultiFileDiffNormal prints a multi-file diff in the normal format
+// (see PrintFileDiffNormal).
+func PrintMultiFileDiffNormal(ds []*FileDiff) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, d := range ds {
+		diff, err := PrintFileDiffNormal(d)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(diff)
+	}
+	return buf.Bytes(), nil
+}
+
+// PrintFileDiffNormal prints a FileDiff in the normal format, which
+// ParseMultiFileDiffNormal parses: its extended headers, as they are, then
+// its hunks (see PrintHunksNormal). The normal format has no file header,
+// so the file names are only printed if they are in the extended headers.
+func PrintFileDiffNormal(d *FileDiff) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, xheader := range d.Extended {
+		fmt.Fprintln(&buf, xheader)
+	}
+	if d.isOnlyIn() {
+		fmt.Fprintf(&buf, onlyInMessage, filepath.Dir(d.OrigName), filepath.Base(d.OrigName))
+		return buf.Bytes(), nil
+	}
+	hunks, err := PrintHunksNormal(d.Hunks)
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(hunks)
+	return buf.Bytes(), nil
+}
+
+// PrintHunksNormal prints hunks in the normal format. Context lines are
+// left out, and each run of removed and added lines between them becomes a
+// command: "c" if the run has both, "d" if it only has removed lines and
+// "a" if it only has added lines.
+func PrintHunksNormal(hunks []*Hunk) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, h := range hunks {
+		// The numbers of the next original and new lines.
+		orig, new := int(h.OrigStartLine), int(h.NewStartLine)
+		if h.OrigLines == 0 {
+			orig++
+		}
+		if h.NewLines == 0 {
+			new++
+		}
+
+		lines := h.lines()
+		for i := 0; i < len(lines); {
+			if lines[i].op == ' ' {
+				orig, new = orig+1, new+1
+				i++
+				continue
+			}
+			var removed, added []hunkLine
+			for ; i < len(lines) && lines[i].op != ' '; i++ {
+				if lines[i].op == '-' {
+					removed = append(removed, lines[i])
+				} else {
+					added = append(added, lines[i])
+				}
+			}
+
+			switch {
+			case len(added) == 0:
+				fmt.Fprintf(&buf, "%sd%d\n", normalRangeString(orig, len(removed)), new-1)
+			case len(removed) == 0:
+				fmt.Fprintf(&buf, "%da%s\n", orig-1, normalRangeString(new, len(added)))
+			default:
+				fmt.Fprintf(&buf, "%sc%s\n", normalRangeString(orig, len(removed)), normalRangeString(new, len(added)))
+			}
+			printNormalLines(&buf, "< ", removed)
+			if len(removed) > 0 && len(added) > 0 {
+				buf.WriteString("---\n")
+			}
+			printNormalLines(&buf, "> ", added)
+			orig, new = orig+len(removed), new+len(added)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// normalRangeString formats the range of n lines starting at start as a
+// normal diff command does.
+func normalRangeString(start, n int) string {
+	if n == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, start+n-1)
+}
+
+// printNormalLines prints lines with the given prefix.
+func printNormalLines(buf *bytes.Buffer, prefix string, lines []hunkLine) {
+	for _, l := range lines {
+		buf.WriteString(prefix)
+		buf.Write(l.text)
+		buf.WriteByte('\n')
+		if l.noNewline {
+			buf.WriteString(noNewlineMessage + "\n")
+		}
+	}
+}
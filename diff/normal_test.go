@@ -0,0 +1,122 @@
+package diff
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseMultiFileDiffNormal(t *testing.T) {
+	diffData, err := ioutil.ReadFile(filepath.Join("testdata", "sample_normal.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := ParseMultiFileDiffNormal(diffData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var types []ChangeType
+	for _, d := range diffs {
+		types = append(types, d.Type())
+	}
+	if want := []ChangeType{Modified, Modified, Modified, OnlyIn}; !cmp.Equal(types, want) {
+		t.Errorf("types mismatch (-want +got):\n%s", cmp.Diff(want, types))
+	}
+	if !diffs[0].IsBinary() || diffs[0].OrigName != "a/bin" || diffs[0].NewName != "b/bin" {
+		t.Errorf("got binary diff of %q and %q (IsBinary %v), want a/bin and b/bin", diffs[0].OrigName, diffs[0].NewName, diffs[0].IsBinary())
+	}
+	if diffs[1].OrigName != "a/f.txt" || diffs[1].NewName != "b/f.txt" {
+		t.Errorf("got names %q and %q, want a/f.txt and b/f.txt", diffs[1].OrigName, diffs[1].NewName)
+	}
+
+	// The same hunks in unified format, as diff -U0 prints them.
+	want := []string{
+		"",
+		"@@ -2,1 +2,1 @@\n-b\n+B\n@@ -8,0 +9,1 @@\n+x\n\\ No newline at end of file\n",
+		"@@ -2,1 +1,0 @@\n-two\n",
+		"",
+	}
+	for i, d := range diffs {
+		if got := string(d.HunksOnly()); got != want[i] {
+			t.Errorf("file #%d: hunks mismatch (-want +got):\n%s", i, cmp.Diff(want[i], got))
+		}
+	}
+
+	printed, err := PrintMultiFileDiffNormal(diffs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(printed); got != string(diffData) {
+		t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(string(diffData), got))
+	}
+}
+
+func TestPrintHunksNormal(t *testing.T) {
+	hunks, err := ParseHunks([]byte(`@@ -1,7 +1,7 @@
++new
+ a
+-b
+ c
+-d
+-e
++E
+ f
+ g
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := PrintHunksNormal(hunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `0a1
+> new
+2d2
+< b
+4,5c4
+< d
+< e
+---
+> E
+`
+	if string(got) != want {
+		t.Errorf("normal diff mismatch (-want +got):\n%s", cmp.Diff(want, string(got)))
+	}
+}
+
+func TestParseHunksNormal_Error(t *testing.T) {
+	tests := map[string]struct {
+		diff string
+		want error
+	}{
+		"not a command": {
+			diff: "1c1\n< a\n---\n> b\nfoo\n",
+			want: &ParseError{5, 16, ErrBadNormalCommand},
+		},
+		"append with an original range": {
+			diff: "1,2a3\n> x\n",
+			want: &ParseError{1, 0, ErrBadNormalCommand},
+		},
+		"missing separator": {
+			diff: "1c1\n< a\n> b\n",
+			want: &ParseError{3, 8, ErrBadNormalLine},
+		},
+		"missing line": {
+			diff: "1,2d0\n< a\n",
+			want: &ParseError{3, 10, ErrBadNormalLine},
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			_, err := ParseHunksNormal([]byte(test.diff))
+			if !reflect.DeepEqual(err, test.want) {
+				t.Errorf("got error %v, want %v", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package diff
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// Normalize returns a copy of d without the metadata that differs between
+// diffs making the same change, so that such diffs print the same:
+//
+//   - timestamps, object names (and "index" lines) and similarity scores
+//     (and "similarity index" and "dissimilarity index" lines) are dropped,
+//     and the other extended headers are sorted as by WithCanonicalXHeaders;
+//   - each change (a run of removed and added lines) becomes a hunk without
+//     context lines, section heading or refinements, with its removed lines
+//     before its added lines. Changes stay where d places them, so that the
+//     same change printed with different numbers of context lines (as by
+//     git diff -U) normalizes the same.
+//
+// Hunks without changes are dropped. The file names, modes and binary patch
+// of d are kept, and the normalized diff still applies to the same file.
+func Normalize(d *FileDiff) (*FileDiff, error) {
+	if d.Hunks == nil && d.RawHunks != nil {
+		return nil, errHunksNotLoaded
+	}
+	n := &FileDiff{
+		OrigName:    d.OrigName,
+		NewName:     d.NewName,
+		OrigMode:    d.OrigMode,
+		NewMode:     d.NewMode,
+		BinaryPatch: d.BinaryPatch,
+	}
+	if d.Status != "" {
+		n.Status = d.Status[:1]
+	}
+	for _, xheader := range d.Extended {
+		if strings.HasPrefix(xheader, "index ") || strings.HasPrefix(xheader, "similarity index ") || strings.HasPrefix(xheader, "dissimilarity index ") {
+			continue
+		}
+		n.Extended = append(n.Extended, xheader)
+	}
+	sortXHeaders(n.Extended)
+
+	position := int32(0)
+	for _, h := range d.Hunks {
+		// Keep each change where the hunk places it, with its removed lines
+		// before its added lines, and split the hunk at its context lines.
+		lines := h.lines()
+		for i := 0; i < len(lines); {
+			if lines[i].op == ' ' {
+				i++
+				continue
+			}
+			end := i
+			for end < len(lines) && lines[end].op != ' ' {
+				end++
+			}
+			sort.SliceStable(lines[i:end], func(j, k int) bool {
+				return lines[i+j].op == '-' && lines[i+k].op == '+'
+			})
+			i = end
+		}
+		var a, b []fileLine
+		var ops []editOp
+		for _, l := range lines {
+			ops = append(ops, editOp{op: l.op, orig: len(a), new: len(b)})
+			line := fileLine{text: l.text, noNewline: l.noNewline}
+			if l.op != '+' {
+				a = append(a, line)
+			}
+			if l.op != '-' {
+				b = append(b, line)
+			}
+		}
+		origLine, newLine := h.lineStarts()
+		for _, nh := range editHunks(ops, a, b, 0) {
+			nh.OrigStartLine += int32(origLine - 1)
+			nh.NewStartLine += int32(newLine - 1)
+			position++ // account for the hunk header line
+			nh.StartPosition = position
+			position += int32(bytes.Count(nh.Body, []byte{'\n'}))
+			n.Hunks = append(n.Hunks, nh)
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,151 @@
+package diff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNormalize(t *testing.T) {
+	orig := "a\n\nb\nc\nd\ne\nf\n\n\ng\n"
+	// Two diffs adding a blank line between f and g, and changing c, with
+	// different context, timestamps and metadata.
+	diffs := []string{
+		`diff --git a/f b/f
+index 1111111..2222222 100644
+--- a/f	2009-10-11 15:12:20.000000000 -0700
++++ b/f	2009-10-11 15:12:30.000000000 -0700
+@@ -1,10 +1,11 @@ section
+ a
+ 
+ b
+-c
++C
+ d
+ e
+ f
+ 
+ 
++
+ g
+`,
+		`diff --git a/f b/f
+index 3333333..4444444 100644
+--- a/f
++++ b/f
+@@ -3,3 +3,3 @@
+ b
+-c
++C
+ d
+@@ -8,3 +8,4 @@ f
+ 
+ 
++
+ g
+`,
+	}
+	want := `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -4,1 +4,1 @@
+-c
++C
+@@ -9,0 +10,1 @@
++
+`
+	for i, s := range diffs {
+		d := parseFileDiffString(t, s)
+		n, err := Normalize(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		printed, err := PrintFileDiff(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(printed); got != want {
+			t.Errorf("diff %d: normalized diff mismatch (-want +got):\n%s", i, cmp.Diff(want, got))
+		}
+		applied, err := ApplyFileDiff([]byte(orig), n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "a\n\nb\nC\nd\ne\nf\n\n\n\ng\n"; string(applied) != want {
+			t.Errorf("diff %d: applied normalized diff mismatch (-want +got):\n%s", i, cmp.Diff(want, string(applied)))
+		}
+	}
+}
+
+func TestNormalize_Context(t *testing.T) {
+	// The same changes printed with different numbers of context lines, as
+	// by git diff -U, normalize the same.
+	rnd := rand.New(rand.NewSource(1))
+	randomContent := func() string {
+		var lines []string
+		for i := rnd.Intn(30); i > 0; i-- {
+			lines = append(lines, string(rune('a'+rnd.Intn(3))))
+		}
+		return strings.Join(lines, "\n")
+	}
+	for i := 0; i < 400; i++ {
+		orig, new := randomContent(), randomContent()
+		var want string
+		for _, context := range []int{0, 1, 3, 20} {
+			d, err := NewFileDiff("a/f", "b/f", []byte(orig), []byte(new), WithContext(context))
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := Normalize(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			printed, err := PrintFileDiff(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if context == 0 {
+				want = string(printed)
+			} else if got := string(printed); got != want {
+				t.Errorf("%q -> %q: normalized diff with context %d differs from that with context 0 (-want +got):\n%s", orig, new, context, cmp.Diff(want, got))
+			}
+		}
+	}
+}
+
+func TestNormalize_Metadata(t *testing.T) {
+	d := parseFileDiffString(t, `diff --git a/old b/new
+similarity index 90%
+rename from old
+rename to new
+old mode 100644
+new mode 100755
+index 1111111..2222222
+--- a/old
++++ b/new
+@@ -1 +1 @@
+-x
++y
+\ No newline at end of file
+`)
+	n, err := Normalize(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantExtended := []string{"diff --git a/old b/new", "old mode 100644", "new mode 100755", "rename from old", "rename to new"}
+	if !cmp.Equal(n.Extended, wantExtended) {
+		t.Errorf("extended headers mismatch (-want +got):\n%s", cmp.Diff(wantExtended, n.Extended))
+	}
+	if n.OrigSHA != "" || n.NewSHA != "" || n.OrigMode != 0100644 || n.NewMode != 0100755 {
+		t.Errorf("got object names %q..%q and modes %o..%o, want no object names and the modes", n.OrigSHA, n.NewSHA, n.OrigMode, n.NewMode)
+	}
+	if want := "-x\n+y"; string(n.Hunks[0].Body) != want {
+		t.Errorf("got body %q, want %q", n.Hunks[0].Body, want)
+	}
+
+	if _, err := Normalize(&FileDiff{RawHunks: []byte("@@ -1 +1 @@\n-x\n+y\n")}); err != errHunksNotLoaded {
+		t.Errorf("got error %v for unloaded hunks, want %v", err, errHunksNotLoaded)
+	}
+}
@@ -7,23 +7,95 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// A ParseOption configures how diffs are parsed.
+type ParseOption func(*parseOptions)
+
+type parseOptions struct {
+	zeroBasedLines bool
+	zeroCopy       bool
+	concurrency    int
+	lazyHunks      bool
+}
+
+func newParseOptions(opts []ParseOption) *parseOptions {
+	o := &parseOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithZeroBasedLines parses hunk headers whose line numbers are 0-based, as
+// some tools emit them, instead of 1-based as in GNU diff and git output.
+// The start line of each hunk range is the 0-based index of its first line
+// (or, for an empty range, of the line after it). The parsed hunks use the
+// usual 1-based numbering, so they are the same as those parsed from the
+// equivalent 1-based diff. See WithZeroBasedOutput for the inverse.
+func WithZeroBasedLines() ParseOption {
+	return func(o *parseOptions) {
+		o.zeroBasedLines = true
+	}
+}
+
+// WithZeroCopy makes ParseMultiFileDiff, ParseFileDiff and ParseHunks read
+// the lines of the diff in place, and keep each hunk's Body as a sub-slice
+// of the diff rather than a copy, which saves most of the allocations when
+// parsing large diffs. The diff must not be modified while the hunks are in
+// use. A body is only copied if its lines are not contiguous in the diff,
+// e.g., because they end in "\r\n" or are interrupted by a 'No newline at
+// end of file' mark. Names and extended headers are strings, so they are
+// still copied. The option has no effect on readers created from an
+// io.Reader.
+func WithZeroCopy() ParseOption {
+	return func(o *parseOptions) {
+		o.zeroCopy = true
+	}
+}
+
+// WithLazyHunks makes MultiFileDiffReader (and so ParseMultiFileDiff) keep
+// the text of each file's hunks in RawHunks instead of parsing them into
+// Hunks, for consumers that only need the file names and headers. The
+// hunks are still read to find where each file's diff ends, and hunk
+// headers are checked, but their bodies are only parsed when LoadHunks is
+// called. Stat uses RawHunks if Hunks is nil, but other methods that use
+// the hunks see none until they are loaded.
+func WithLazyHunks() ParseOption {
+	return func(o *parseOptions) {
+		o.lazyHunks = true
+	}
+}
+
+// lineReader returns a lineReader for diff, which reads the lines in place
+// with WithZeroCopy.
+func (o *parseOptions) lineReader(diff []byte) *lineReader {
+	if o.zeroCopy {
+		return &lineReader{data: diff}
+	}
+	return newLineReader(bytes.NewReader(diff))
+}
+
 // ParseMultiFileDiff parses a multi-file unified diff. It returns an error if
 // parsing failed as a whole, but does its best to parse as many files in the
 // case of per-file errors. If it cannot detect when the diff of the next file
 // begins, the hunks are added to the FileDiff of the previous file.
-func ParseMultiFileDiff(diff []byte) ([]*FileDiff, error) {
-	return NewMultiFileDiffReader(bytes.NewReader(diff)).ReadAllFiles()
+func ParseMultiFileDiff(diff []byte, opts ...ParseOption) ([]*FileDiff, error) {
+	o := newParseOptions(opts)
+	if o.concurrency > 1 {
+		return parseMultiFileDiffConcurrently(diff, o)
+	}
+	return (&MultiFileDiffReader{reader: o.lineReader(diff), opts: o}).ReadAllFiles()
 }
 
 // NewMultiFileDiffReader returns a new MultiFileDiffReader that reads
 // a multi-file unified diff from r.
-func NewMultiFileDiffReader(r io.Reader) *MultiFileDiffReader {
-	return &MultiFileDiffReader{reader: newLineReader(r)}
+func NewMultiFileDiffReader(r io.Reader, opts ...ParseOption) *MultiFileDiffReader {
+	return &MultiFileDiffReader{reader: newLineReader(r), opts: newParseOptions(opts)}
 }
 
 // MultiFileDiffReader reads a multi-file unified diff.
@@ -31,6 +103,7 @@ type MultiFileDiffReader struct {
 	line   int
 	offset int64
 	reader *lineReader
+	opts   *parseOptions
 
 	// TODO(sqs): line and offset tracking in multi-file diffs is broken; add tests and fix
 
@@ -54,10 +127,18 @@ func (r *MultiFileDiffReader) ReadFile() (*FileDiff, error) {
 // headers and all hunks) from r, also returning any trailing content. If there
 // are no more files in the diff, it returns error io.EOF.
 func (r *MultiFileDiffReader) ReadFileWithTrailingContent() (*FileDiff, string, error) {
+	fd, trailing, err := r.readFile()
+	return fd, strings.Join(trailing, "\n"), err
+}
+
+// readFile is like ReadFileWithTrailingContent, but returns the lines of
+// the trailing content.
+func (r *MultiFileDiffReader) readFile() (*FileDiff, []string, error) {
 	fr := &FileDiffReader{
 		line:           r.line,
 		offset:         r.offset,
 		reader:         r.reader,
+		opts:           r.opts,
 		fileHeaderLine: r.nextFileFirstLine,
 	}
 	r.nextFileFirstLine = nil
@@ -73,27 +154,27 @@ func (r *MultiFileDiffReader) ReadFileWithTrailingContent() (*FileDiff, string,
 				// different: it doesn't make sense to return a FileDiff with only
 				// extended headers populated. Instead, we return any trailing content
 				// in case the caller needs it.
-				trailing := ""
+				var trailing []string
 				if fd != nil {
-					trailing = strings.Join(fd.Extended, "\n")
+					trailing = fd.Extended
 				}
 				return nil, trailing, io.EOF
 			}
-			return nil, "", err
+			return nil, nil, err
 
 		case OverflowError:
 			r.nextFileFirstLine = []byte(e)
-			return fd, "", nil
+			return fd, nil, nil
 
 		default:
-			return nil, "", err
+			return nil, nil, err
 		}
 	}
 
-	// FileDiff is added/deleted file
+	// FileDiff is an "Only in" message
 	// No further collection of hunks needed
-	if fd.NewName == "" {
-		return fd, "", nil
+	if fd.isOnlyIn() {
+		return fd, nil, nil
 	}
 
 	// Before reading hunks, check to see if there are any. If there
@@ -105,12 +186,16 @@ func (r *MultiFileDiffReader) ReadFileWithTrailingContent() (*FileDiff, string,
 	hr := fr.HunksReader()
 	line, err := r.reader.readLine()
 	if err != nil && err != io.EOF {
-		return fd, "", err
+		return fd, nil, err
 	}
 	line = bytes.TrimSuffix(line, []byte{'\n'})
 	if bytes.HasPrefix(line, hunkPrefix) {
 		hr.nextHunkHeaderLine = line
+		hr.lazy = r.opts != nil && r.opts.lazyHunks
 		fd.Hunks, err = hr.ReadAllHunks()
+		if hr.lazy {
+			fd.Hunks, fd.RawHunks = nil, hr.raw
+		}
 		r.line = fr.line
 		r.offset = fr.offset
 		if err != nil {
@@ -119,10 +204,10 @@ func (r *MultiFileDiffReader) ReadFileWithTrailingContent() (*FileDiff, string,
 					// This just means we finished reading the hunks for the
 					// current file. See the ErrBadHunkLine doc for more info.
 					r.nextFileFirstLine = e.Line
-					return fd, "", nil
+					return fd, nil, nil
 				}
 			}
-			return nil, "", err
+			return nil, nil, err
 		}
 	} else {
 		// There weren't any hunks, so that line we peeked ahead at
@@ -130,7 +215,7 @@ func (r *MultiFileDiffReader) ReadFileWithTrailingContent() (*FileDiff, string,
 		r.nextFileFirstLine = line
 	}
 
-	return fd, "", nil
+	return fd, nil, nil
 }
 
 // ReadAllFiles reads all file unified diffs (including headers and all
@@ -151,15 +236,40 @@ func (r *MultiFileDiffReader) ReadAllFiles() ([]*FileDiff, error) {
 	}
 }
 
+// ScanMultiFileDiff reads a multi-file unified diff from r and calls fn with
+// each file's diff as soon as it is read, so that a large diff can be
+// processed without holding all of its FileDiffs in memory. If fn returns
+// an error, ScanMultiFileDiff stops and returns it. The files are parsed as
+// by ParseMultiFileDiff.
+func ScanMultiFileDiff(r io.Reader, fn func(*FileDiff) error, opts ...ParseOption) error {
+	mr := NewMultiFileDiffReader(r, opts...)
+	for {
+		d, err := mr.ReadFile()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			continue
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+}
+
 // ParseFileDiff parses a file unified diff.
-func ParseFileDiff(diff []byte) (*FileDiff, error) {
-	return NewFileDiffReader(bytes.NewReader(diff)).Read()
+func ParseFileDiff(diff []byte, opts ...ParseOption) (*FileDiff, error) {
+	o := newParseOptions(opts)
+	return (&FileDiffReader{reader: o.lineReader(diff), opts: o}).Read()
 }
 
 // NewFileDiffReader returns a new FileDiffReader that reads a file
 // unified diff.
-func NewFileDiffReader(r io.Reader) *FileDiffReader {
-	return &FileDiffReader{reader: &lineReader{reader: bufio.NewReader(r)}}
+func NewFileDiffReader(r io.Reader, opts ...ParseOption) *FileDiffReader {
+	return &FileDiffReader{reader: &lineReader{reader: bufio.NewReader(r)}, opts: newParseOptions(opts)}
 }
 
 // FileDiffReader reads a unified file diff.
@@ -167,6 +277,7 @@ type FileDiffReader struct {
 	line   int
 	offset int64
 	reader *lineReader
+	opts   *parseOptions
 
 	// fileHeaderLine is the first file header line, set by:
 	//
@@ -176,6 +287,10 @@ type FileDiffReader struct {
 	//     file header line while reading the previous file's hunks (in a
 	//     multi-file diff).
 	fileHeaderLine []byte
+
+	// origTimeLayout and newTimeLayout are the layouts that matched the
+	// timestamps read by ReadFileHeaders, if any.
+	origTimeLayout, newTimeLayout string
 }
 
 // Read reads a file unified diff, including headers and hunks, from r.
@@ -203,14 +318,18 @@ func (r *FileDiffReader) ReadAllHeaders() (*FileDiff, error) {
 	fd := &FileDiff{}
 
 	fd.Extended, err = r.ReadExtendedHeaders()
+	parseModes(fd)
 	if pe, ok := err.(*ParseError); ok && pe.Err == ErrExtendedHeadersEOF {
 		wasEmpty := handleEmpty(fd)
 		if wasEmpty {
-			return fd, nil
+			return fd, r.parseBinaryPatch(fd)
 		}
 		return fd, err
 	} else if _, ok := err.(OverflowError); ok {
 		handleEmpty(fd)
+		if err := r.parseBinaryPatch(fd); err != nil {
+			return fd, err
+		}
 		return fd, err
 	} else if err != nil {
 		return fd, err
@@ -223,9 +342,11 @@ func (r *FileDiffReader) ReadAllHeaders() (*FileDiff, error) {
 	}
 	if origTime != nil {
 		fd.OrigTime = origTime
+		fd.OrigTimeLayout = r.origTimeLayout
 	}
 	if newTime != nil {
 		fd.NewTime = newTime
+		fd.NewTimeLayout = r.newTimeLayout
 	}
 
 	return fd, nil
@@ -240,6 +361,7 @@ func (r *FileDiffReader) HunksReader() *HunksReader {
 		line:   r.line,
 		offset: r.offset,
 		reader: r.reader,
+		opts:   r.opts,
 	}
 }
 
@@ -255,12 +377,12 @@ func (r *FileDiffReader) ReadFileHeaders() (origName, newName string, origTimest
 		}
 	}
 
-	origName, origTimestamp, err = r.readOneFileHeader([]byte("--- "))
+	origName, origTimestamp, r.origTimeLayout, err = r.readOneFileHeader([]byte("--- "))
 	if err != nil {
 		return "", "", nil, nil, err
 	}
 
-	newName, newTimestamp, err = r.readOneFileHeader([]byte("+++ "))
+	newName, newTimestamp, r.newTimeLayout, err = r.readOneFileHeader([]byte("+++ "))
 	if err != nil {
 		return "", "", nil, nil, err
 	}
@@ -278,17 +400,18 @@ func (r *FileDiffReader) ReadFileHeaders() (origName, newName string, origTimest
 }
 
 // readOneFileHeader reads one of the file headers (prefix should be
-// either "+++ " or "--- ").
-func (r *FileDiffReader) readOneFileHeader(prefix []byte) (filename string, timestamp *time.Time, err error) {
+// either "+++ " or "--- "). It also returns the layout the timestamp
+// was parsed with, if present.
+func (r *FileDiffReader) readOneFileHeader(prefix []byte) (filename string, timestamp *time.Time, layout string, err error) {
 	var line []byte
 
 	if r.fileHeaderLine == nil {
 		var err error
 		line, err = r.reader.readLine()
 		if err == io.EOF {
-			return "", nil, &ParseError{r.line, r.offset, ErrNoFileHeader}
+			return "", nil, "", &ParseError{r.line, r.offset, ErrNoFileHeader}
 		} else if err != nil {
-			return "", nil, err
+			return "", nil, "", err
 		}
 	} else {
 		line = r.fileHeaderLine
@@ -296,7 +419,7 @@ func (r *FileDiffReader) readOneFileHeader(prefix []byte) (filename string, time
 	}
 
 	if !bytes.HasPrefix(line, prefix) {
-		return "", nil, &ParseError{r.line, r.offset, ErrBadFileHeader}
+		return "", nil, "", &ParseError{r.line, r.offset, ErrBadFileHeader}
 	}
 
 	r.offset += int64(len(line))
@@ -308,14 +431,38 @@ func (r *FileDiffReader) readOneFileHeader(prefix []byte) (filename string, time
 	filename = parts[0]
 	if len(parts) == 2 {
 		// Timestamp is optional, but this header has it.
-		ts, err := time.Parse(diffTimeParseLayout, parts[1])
+		ts, l, err := parseTimestamp(parts[1])
 		if err != nil {
-			return "", nil, err
+			return "", nil, "", err
 		}
-		timestamp = &ts
+		timestamp, layout = &ts, l
 	}
 
-	return filename, timestamp, err
+	return filename, timestamp, layout, err
+}
+
+// fractionalSeconds matches the fractional seconds of a timestamp.
+var fractionalSeconds = regexp.MustCompile(`:\d\d\.(\d+)`)
+
+// parseTimestamp parses a file header timestamp, trying each of
+// diffTimeParseLayouts in turn. It returns the layout that matched, with the
+// fractional seconds of value (which time.Parse accepts after the seconds
+// even if the layout has none) as many digits wide as they are.
+func parseTimestamp(value string) (time.Time, string, error) {
+	var firstErr error
+	for _, layout := range diffTimeParseLayouts {
+		ts, err := time.Parse(layout, value)
+		if err == nil {
+			if m := fractionalSeconds.FindStringSubmatch(value); m != nil && !strings.Contains(layout, "05.") {
+				layout = strings.Replace(layout, "05", "05."+strings.Repeat("0", len(m[1])), 1)
+			}
+			return ts, layout, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return time.Time{}, "", firstErr
 }
 
 // OverflowError is returned when we have overflowed into the start
@@ -368,10 +515,35 @@ func (r *FileDiffReader) ReadExtendedHeaders() ([]string, error) {
 
 		r.line++
 		r.offset += int64(len(line))
+		if isFileSummaryComment(line) {
+			continue
+		}
 		xheaders = append(xheaders, string(line))
 	}
 }
 
+// isFileSummaryComment reports whether line is a comment printed by
+// WithFileSummaryComment, such as "# path/to/file (+12 -3)".
+func isFileSummaryComment(line []byte) bool {
+	if !bytes.HasPrefix(line, []byte("# ")) || !bytes.HasSuffix(line, []byte(")")) {
+		return false
+	}
+	i := bytes.LastIndex(line, []byte(" (+"))
+	if i < len("# ") {
+		return false
+	}
+	counts := bytes.Split(line[i+len(" (+"):len(line)-1], []byte(" -"))
+	if len(counts) != 2 {
+		return false
+	}
+	for _, c := range counts {
+		if _, err := strconv.ParseUint(string(c), 10, 32); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
 // readQuotedFilename extracts a quoted filename from the beginning of a string,
 // returning the unquoted filename and any remaining text after the filename.
 func readQuotedFilename(text string) (value string, remainder string, err error) {
@@ -406,7 +578,11 @@ func parseDiffGitArgs(diffArgs string) (string, string, bool) {
 	}
 
 	if diffArgs[0] != '"' && diffArgs[length-1] != '"' {
-		// Both filenames are unquoted.
+		// Both filenames are unquoted. Git always quotes names containing
+		// tabs, as they would otherwise be ambiguous.
+		if strings.IndexByte(diffArgs, '\t') != -1 {
+			return "", "", false
+		}
 		firstSpace := strings.IndexByte(diffArgs, ' ')
 		if firstSpace <= 0 || firstSpace == length-1 {
 			return "", "", false
@@ -452,12 +628,15 @@ func parseDiffGitArgs(diffArgs string) (string, string, bool) {
 			}
 			return first, second, true
 		}
+		if strings.IndexByte(remainder, '\t') != -1 {
+			return "", "", false
+		}
 		return first, remainder[1:], true
 	}
 
 	// In this case, second argument MUST be quoted (or it's a syntax error)
 	i := strings.IndexByte(diffArgs, '"')
-	if i == -1 || i+2 >= length || diffArgs[i-1] != ' ' {
+	if i == -1 || i+2 >= length || diffArgs[i-1] != ' ' || strings.IndexByte(diffArgs[:i], '\t') != -1 {
 		return "", "", false
 	}
 
@@ -468,16 +647,67 @@ func parseDiffGitArgs(diffArgs string) (string, string, bool) {
 	return diffArgs[:i-1], second, true
 }
 
+// parseModes sets fd's OrigMode and NewMode from git's mode extended
+// headers, if any. Only the headers after the last "diff --git" line are
+// considered, since any before it are not part of this file's diff. A mode
+// that no header specifies is left zero, e.g., the original mode of an added
+// file.
+func parseModes(fd *FileDiff) {
+	xheaders := gitExtendedHeaders(fd.Extended)
+
+	parseMode := func(s string, mode *uint32) {
+		if m, err := strconv.ParseUint(s, 8, 32); err == nil {
+			*mode = uint32(m)
+		}
+	}
+	for _, xheader := range xheaders {
+		switch {
+		case strings.HasPrefix(xheader, "old mode "):
+			parseMode(xheader[len("old mode "):], &fd.OrigMode)
+		case strings.HasPrefix(xheader, "deleted file mode "):
+			parseMode(xheader[len("deleted file mode "):], &fd.OrigMode)
+		case strings.HasPrefix(xheader, "new mode "):
+			parseMode(xheader[len("new mode "):], &fd.NewMode)
+		case strings.HasPrefix(xheader, "new file mode "):
+			parseMode(xheader[len("new file mode "):], &fd.NewMode)
+		case strings.HasPrefix(xheader, "index "):
+			// "index <orig>..<new> <mode>" gives the mode of a file whose
+			// mode is unchanged.
+			if fields := strings.Fields(xheader); len(fields) == 3 {
+				var mode uint32
+				parseMode(fields[2], &mode)
+				if fd.OrigMode == 0 && fd.NewMode == 0 {
+					fd.OrigMode, fd.NewMode = mode, mode
+				}
+			}
+		}
+	}
+}
+
+// gitExtendedHeaders returns the extended headers starting at the last
+// "diff --git" line, if any, dropping any non-diff content that precedes
+// it (such as the email headers and commit message in git format-patch
+// output).
+func gitExtendedHeaders(xheaders []string) []string {
+	for i := len(xheaders) - 1; i >= 0; i-- {
+		if strings.HasPrefix(xheaders[i], "diff --git ") {
+			return xheaders[i:]
+		}
+	}
+	return xheaders
+}
+
 // handleEmpty detects when FileDiff was an empty diff and will not have any hunks
 // that follow. It updates fd fields from the parsed extended headers.
 func handleEmpty(fd *FileDiff) (wasEmpty bool) {
-	lineCount := len(fd.Extended)
-	if lineCount > 0 && !strings.HasPrefix(fd.Extended[0], "diff --git ") {
+	xheaders := gitExtendedHeaders(fd.Extended)
+	lineCount := len(xheaders)
+	if lineCount > 0 && !strings.HasPrefix(xheaders[0], "diff --git ") {
 		return false
 	}
 
 	lineHasPrefix := func(idx int, prefix string) bool {
-		return strings.HasPrefix(fd.Extended[idx], prefix)
+		return strings.HasPrefix(xheaders[idx], prefix)
 	}
 
 	linesHavePrefixes := func(idx1 int, prefix1 string, idx2 int, prefix2 string) bool {
@@ -507,18 +737,18 @@ func handleEmpty(fd *FileDiff) (wasEmpty bool) {
 	}
 
 	var success bool
-	fd.OrigName, fd.NewName, success = parseDiffGitArgs(fd.Extended[0][len("diff --git "):])
+	fd.OrigName, fd.NewName, success = parseDiffGitArgs(xheaders[0][len("diff --git "):])
 	if isNewFile {
-		fd.OrigName = "/dev/null"
+		fd.OrigName = devNull
 	}
 
 	if isDeletedFile {
-		fd.NewName = "/dev/null"
+		fd.NewName = devNull
 	}
 
 	// For ambiguous 'diff --git' lines, try to reconstruct filenames using extended headers.
 	if success && (isCopy || isRename) && fd.OrigName == "" && fd.NewName == "" {
-		diffArgs := fd.Extended[0][len("diff --git "):]
+		diffArgs := xheaders[0][len("diff --git "):]
 
 		tryReconstruct := func(header string, prefix string, whichFile int, result *string) {
 			if !strings.HasPrefix(header, prefix) {
@@ -540,7 +770,7 @@ func handleEmpty(fd *FileDiff) (wasEmpty bool) {
 			*result = diffArgs[prefixLetterIndex:prefixLetterIndex+2] + rawFilename
 		}
 
-		for _, header := range fd.Extended {
+		for _, header := range xheaders {
 			tryReconstruct(header, "copy from ", 1, &fd.OrigName)
 			tryReconstruct(header, "copy to ", 2, &fd.NewName)
 			tryReconstruct(header, "rename from ", 1, &fd.OrigName)
@@ -570,8 +800,9 @@ var (
 // ParseHunks parses hunks from a unified diff. The diff must consist
 // only of hunks and not include a file header; if it has a file
 // header, use ParseFileDiff.
-func ParseHunks(diff []byte) ([]*Hunk, error) {
-	r := NewHunksReader(bytes.NewReader(diff))
+func ParseHunks(diff []byte, opts ...ParseOption) ([]*Hunk, error) {
+	o := newParseOptions(opts)
+	r := &HunksReader{reader: o.lineReader(diff), opts: o}
 	hunks, err := r.ReadAllHunks()
 	if err != nil {
 		return nil, err
@@ -581,8 +812,8 @@ func ParseHunks(diff []byte) ([]*Hunk, error) {
 
 // NewHunksReader returns a new HunksReader that reads unified diff hunks
 // from r.
-func NewHunksReader(r io.Reader) *HunksReader {
-	return &HunksReader{reader: &lineReader{reader: bufio.NewReader(r)}}
+func NewHunksReader(r io.Reader, opts ...ParseOption) *HunksReader {
+	return &HunksReader{reader: &lineReader{reader: bufio.NewReader(r)}, opts: newParseOptions(opts)}
 }
 
 // A HunksReader reads hunks from a unified diff.
@@ -591,8 +822,18 @@ type HunksReader struct {
 	offset int64
 	hunk   *Hunk
 	reader *lineReader
+	opts   *parseOptions
 
 	nextHunkHeaderLine []byte
+
+	// bodyEnd is the offset in the input just after the hunk body, if the
+	// body is a sub-slice of the input (see WithZeroCopy), and -1 otherwise.
+	bodyEnd int
+
+	// lazy is true if the lines of the hunks are collected in raw instead
+	// of in the hunk bodies (see WithLazyHunks).
+	lazy bool
+	raw  []byte
 }
 
 // ReadHunk reads one hunk from r. If there are no more hunks, it
@@ -600,6 +841,7 @@ type HunksReader struct {
 func (r *HunksReader) ReadHunk() (*Hunk, error) {
 	r.hunk = nil
 	lastLineFromOrig := true
+	var origLines int32 // original lines read so far in the hunk body
 	var line []byte
 	var err error
 	for {
@@ -630,11 +872,12 @@ func (r *HunksReader) ReadHunk() (*Hunk, error) {
 
 			// Parse hunk header.
 			r.hunk = &Hunk{}
+			r.bodyEnd = -1
 			items := []interface{}{
 				&r.hunk.OrigStartLine, &r.hunk.OrigLines,
 				&r.hunk.NewStartLine, &r.hunk.NewLines,
 			}
-			header, section, err := normalizeHeader(string(line))
+			header, section, hasSection, err := normalizeHeader(string(line))
 			if err != nil {
 				return nil, &ParseError{r.line, r.offset, err}
 			}
@@ -646,7 +889,21 @@ func (r *HunksReader) ReadHunk() (*Hunk, error) {
 				return nil, &ParseError{r.line, r.offset, &ErrBadHunkHeader{header: string(line)}}
 			}
 
+			if r.opts != nil && r.opts.zeroBasedLines {
+				// Convert non-empty ranges to 1-based numbering. An empty
+				// range's 0-based start (the index of the line after it) is
+				// already the 1-based number of the line before it.
+				if r.hunk.OrigLines > 0 {
+					r.hunk.OrigStartLine++
+				}
+				if r.hunk.NewLines > 0 {
+					r.hunk.NewStartLine++
+				}
+			}
+
 			r.hunk.Section = section
+			r.hunk.EmptySection = hasSection && section == ""
+			r.appendRaw(line)
 		} else {
 			// Read hunk body line.
 
@@ -679,6 +936,14 @@ func (r *HunksReader) ReadHunk() (*Hunk, error) {
 				return r.hunk, nil
 			}
 
+			// In git format-patch output, the last hunk is followed by a
+			// "-- " line that introduces the signature. It looks like a
+			// removed line, so only take it as one if the hunk still
+			// expects removed lines.
+			if bytes.Equal(line, signatureSeparator) && origLines >= r.hunk.OrigLines {
+				return r.hunk, &ParseError{r.line, r.offset, &ErrBadHunkLine{Line: line}}
+			}
+
 			if len(line) >= 1 && !linePrefix(line[0]) {
 				// Bad hunk header line. If we're reading a multi-file
 				// diff, this may be the end of the current
@@ -687,6 +952,10 @@ func (r *HunksReader) ReadHunk() (*Hunk, error) {
 				return r.hunk, &ParseError{r.line, r.offset, &ErrBadHunkLine{Line: line}}
 			}
 			if bytes.Equal(line, []byte(noNewlineMessage)) {
+				if r.lazy {
+					r.appendRaw(line)
+					continue
+				}
 				if lastLineFromOrig {
 					// Retain the newline in the body (otherwise the
 					// diff line would be like "-a+b", where "+b" is
@@ -695,9 +964,12 @@ func (r *HunksReader) ReadHunk() (*Hunk, error) {
 					// no newline.
 					r.hunk.OrigNoNewlineAt = int32(len(r.hunk.Body))
 				} else {
-					// Remove previous line's newline.
-					if len(r.hunk.Body) != 0 {
-						r.hunk.Body = r.hunk.Body[:len(r.hunk.Body)-1]
+					// Remove previous line's newline. The capacity is
+					// trimmed too, so that appending to the body does not
+					// overwrite the input if it is a sub-slice of it.
+					if n := len(r.hunk.Body); n != 0 {
+						r.hunk.Body = r.hunk.Body[: n-1 : n-1]
+						r.bodyEnd = -1
 					}
 				}
 				continue
@@ -706,11 +978,47 @@ func (r *HunksReader) ReadHunk() (*Hunk, error) {
 			if len(line) > 0 {
 				lastLineFromOrig = line[0] == '-'
 			}
+			if len(line) == 0 || line[0] == ' ' || line[0] == '-' {
+				origLines++
+			}
 
-			r.hunk.Body = append(r.hunk.Body, line...)
-			r.hunk.Body = append(r.hunk.Body, '\n')
+			if r.lazy {
+				r.appendRaw(line)
+				continue
+			}
+			r.appendBody(line)
+		}
+	}
+}
+
+// appendRaw appends line and a newline to the raw hunks, if they are
+// collected.
+func (r *HunksReader) appendRaw(line []byte) {
+	if r.lazy {
+		r.raw = append(r.raw, line...)
+		r.raw = append(r.raw, '\n')
+	}
+}
+
+// appendBody appends line, which readLine just returned, and a newline to
+// the hunk body. With WithZeroCopy, the body is a sub-slice of the input
+// for as long as its lines are contiguous there. Its capacity is limited to
+// its length, so appending to it copies it rather than overwriting the
+// input.
+func (r *HunksReader) appendBody(line []byte) {
+	if data := r.reader.data; r.opts != nil && r.opts.zeroCopy && data != nil {
+		start := r.reader.lineStart
+		end := start + len(line)
+		if end < len(data) && data[end] == '\n' && (len(r.hunk.Body) == 0 || r.bodyEnd == start) {
+			bodyStart := start - len(r.hunk.Body)
+			r.hunk.Body = data[bodyStart : end+1 : end+1]
+			r.bodyEnd = end + 1
+			return
 		}
 	}
+	r.hunk.Body = append(r.hunk.Body, line...)
+	r.hunk.Body = append(r.hunk.Body, '\n')
+	r.bodyEnd = -1
 }
 
 const noNewlineMessage = `\ No newline at end of file`
@@ -736,19 +1044,20 @@ func linePrefix(c byte) bool {
 // and returns two strings, with the first in the form:
 // "@@ -linestart,chunksize +linestart,chunksize @@".
 // where linestart and chunksize are both integers. The second is the
-// optional section header. chunksize may be omitted from the header
-// if its value is 1. normalizeHeader returns an error if the header
-// is not in the correct format.
-func normalizeHeader(header string) (string, string, error) {
+// optional section header, and the boolean reports whether the header had
+// one (possibly empty, if the header ends in "@@ "). chunksize may be
+// omitted from the header if its value is 1. normalizeHeader returns an
+// error if the header is not in the correct format.
+func normalizeHeader(header string) (string, string, bool, error) {
 	// Split the header into five parts: the first '@@', the two
 	// ranges, the last '@@', and the optional section.
 	pieces := strings.SplitN(header, " ", 5)
 	if len(pieces) < 4 {
-		return "", "", &ErrBadHunkHeader{header: header}
+		return "", "", false, &ErrBadHunkHeader{header: header}
 	}
 
 	if pieces[0] != "@@" {
-		return "", "", &ErrBadHunkHeader{header: header}
+		return "", "", false, &ErrBadHunkHeader{header: header}
 	}
 	for i := 1; i < 3; i++ {
 		if !strings.ContainsRune(pieces[i], ',') {
@@ -756,14 +1065,15 @@ func normalizeHeader(header string) (string, string, error) {
 		}
 	}
 	if pieces[3] != "@@" {
-		return "", "", &ErrBadHunkHeader{header: header}
+		return "", "", false, &ErrBadHunkHeader{header: header}
 	}
 
-	var section string
+	// The section is kept verbatim, including any surrounding whitespace,
+	// so that the header is printed exactly as it was read.
 	if len(pieces) == 5 {
-		section = pieces[4]
+		return strings.Join(pieces[:4], " "), pieces[4], true, nil
 	}
-	return strings.Join(pieces, " "), strings.TrimSpace(section), nil
+	return strings.Join(pieces, " "), "", false, nil
 }
 
 // ReadAllHunks reads all remaining hunks from r. A successful call
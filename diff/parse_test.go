@@ -16,6 +16,7 @@ func TestReadQuotedFilename_Success(t *testing.T) {
 		{input: `"uh \"oh\""`, value: "uh \"oh\"", remainder: ""},
 		{input: `"uh \\"oh\\""`, value: "uh \\", remainder: `oh\\""`},
 		{input: `"uh \\\"oh\\\""`, value: "uh \\\"oh\\\"", remainder: ""},
+		{input: `"a/with\ttab" "b/with\ttab"`, value: "a/with\ttab", remainder: ` "b/with\ttab"`},
 	}
 	for _, tc := range tests {
 		value, remainder, err := readQuotedFilename(tc.input)
@@ -59,6 +60,9 @@ func TestParseDiffGitArgs_Success(t *testing.T) {
 		{input: `1/hello world 2/hello world`, first: "1/hello world", second: "2/hello world"},
 		{input: `"new\nline" and spaces`, first: "new\nline", second: "and spaces"},
 		{input: `a/existing file with spaces "b/new, complicated\nfilen\303\270me"`, first: "a/existing file with spaces", second: "b/new, complicated\nfilen\303\270me"},
+		{input: `"a/with\ttab" "b/with\ttab"`, first: "a/with\ttab", second: "b/with\ttab"},
+		{input: `"a/with\ttab" b/plain`, first: "a/with\ttab", second: "b/plain"},
+		{input: `a/plain "b/with\ttab"`, first: "a/plain", second: "b/with\ttab"},
 	}
 	for _, tc := range tests {
 		first, second, success := parseDiffGitArgs(tc.input)
@@ -83,6 +87,10 @@ func TestParseDiffGitArgs_Unsuccessful(t *testing.T) {
 		`"a/bad""b/bad"`,
 		`"a/bad" "b/bad" "c/bad"`,
 		`a/bad "b/bad" "c/bad"`,
+		// Names with tabs must be quoted.
+		"a/with\ttab b/with\ttab",
+		"\"a/with\\ttab\" b/with\ttab",
+		"a/with\ttab \"b/with\\ttab\"",
 	}
 	for _, input := range tests {
 		first, second, success := parseDiffGitArgs(input)
@@ -0,0 +1,142 @@
+package diff
+
+// patience computes an edit script transforming a into b using the patience
+// diff algorithm, returning its result in the same form as myers. It matches
+// up the elements that occur exactly once in both a and b, keeping the
+// longest sequence of such matches that appear in the same order in both,
+// and recurses on the ranges between them. Ranges without unique common
+// elements are compared with myers, whose results can differ from those of
+// the fallback of git diff --patience when several edit scripts are equally
+// short.
+func patience(a, b []int) (deleted, inserted []bool) {
+	p := &patienceState{
+		a:        a,
+		b:        b,
+		deleted:  make([]bool, len(a)),
+		inserted: make([]bool, len(b)),
+	}
+	p.compare(0, len(a), 0, len(b))
+	return p.deleted, p.inserted
+}
+
+type patienceState struct {
+	a, b              []int
+	deleted, inserted []bool
+}
+
+// compare marks the differences between a[xoff:xlim] and b[yoff:ylim].
+// Like git, it only strips the lines common to the ends of the ranges
+// between matches, not those of the whole range, as that would change which
+// elements are unique.
+func (p *patienceState) compare(xoff, xlim, yoff, ylim int) {
+	switch {
+	case xoff == xlim:
+		for y := yoff; y < ylim; y++ {
+			p.inserted[y] = true
+		}
+		return
+	case yoff == ylim:
+		for x := xoff; x < xlim; x++ {
+			p.deleted[x] = true
+		}
+		return
+	}
+
+	matches := p.uniqueMatches(xoff, xlim, yoff, ylim)
+	if len(matches) == 0 {
+		deleted, inserted := myers(p.a[xoff:xlim], p.b[yoff:ylim])
+		copy(p.deleted[xoff:], deleted)
+		copy(p.inserted[yoff:], inserted)
+		return
+	}
+	for _, m := range append(matches, [2]int{xlim, ylim}) {
+		x, y := m[0], m[1]
+		for x > xoff && y > yoff && p.a[x-1] == p.b[y-1] {
+			x--
+			y--
+		}
+		for xoff < x && yoff < y && p.a[xoff] == p.b[yoff] {
+			xoff++
+			yoff++
+		}
+		if xoff < x || yoff < y {
+			p.compare(xoff, x, yoff, y)
+		}
+		xoff, yoff = m[0]+1, m[1]+1
+	}
+}
+
+// uniqueMatches returns the positions in a and b of the elements that
+// occur exactly once in both a[xoff:xlim] and b[yoff:ylim], restricted to
+// the longest sequence of them that is increasing in both.
+func (p *patienceState) uniqueMatches(xoff, xlim, yoff, ylim int) [][2]int {
+	type occurrence struct {
+		countA, countB int
+		x, y           int
+	}
+	occurrences := make(map[int]*occurrence)
+	for x := xoff; x < xlim; x++ {
+		o := occurrences[p.a[x]]
+		if o == nil {
+			o = &occurrence{}
+			occurrences[p.a[x]] = o
+		}
+		o.countA++
+		o.x = x
+	}
+	for y := yoff; y < ylim; y++ {
+		if o := occurrences[p.b[y]]; o != nil {
+			o.countB++
+			o.y = y
+		}
+	}
+
+	// The unique common elements, in the order in which they appear in a.
+	var candidates [][2]int
+	for x := xoff; x < xlim; x++ {
+		if o := occurrences[p.a[x]]; o.countA == 1 && o.countB == 1 {
+			candidates = append(candidates, [2]int{x, o.y})
+		}
+	}
+	return longestIncreasing(candidates)
+}
+
+// longestIncreasing returns the longest subsequence of candidates, which
+// are sorted by their first element, whose second elements are increasing,
+// using patience sorting.
+func longestIncreasing(candidates [][2]int) [][2]int {
+	if len(candidates) == 0 {
+		return nil
+	}
+	// tops[k] is the index of the candidate on top of the k-th pile, and
+	// prev[i] the index of the top of the previous pile when candidate i
+	// was placed.
+	var tops []int
+	prev := make([]int, len(candidates))
+	for i, c := range candidates {
+		lo, hi := 0, len(tops)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if candidates[tops[mid]][1] < c[1] {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		prev[i] = -1
+		if lo > 0 {
+			prev[i] = tops[lo-1]
+		}
+		if lo == len(tops) {
+			tops = append(tops, i)
+		} else {
+			tops[lo] = i
+		}
+	}
+
+	seq := make([][2]int, len(tops))
+	for i, k := tops[len(tops)-1], len(tops)-1; k >= 0; i, k = prev[i], k-1 {
+		seq[k] = candidates[i]
+	}
+	return seq
+}
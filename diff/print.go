@@ -1,78 +1,644 @@
 package diff
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// A PrintOption configures how diffs are printed.
+type PrintOption func(*printOptions)
+
+type printOptions struct {
+	interHunkContext   int
+	fileSummaryComment bool
+	skipEmptyHunks     bool
+	quote              func(string) string
+	quoter             bool
+	zeroBasedOutput    bool
+	compactSummary     bool
+	color              *ColorScheme
+	intraLineHighlight bool
+	wrap               bool
+	omitTimestamps     bool
+	timeLayout         string
+	timeLocation       *time.Location
+	prefixes           *[2]string
+	nulTerminated      bool
+	linePrefix         string
+	canonicalXHeaders  bool
+	gitHeaders         bool
+}
+
+func newPrintOptions(opts []PrintOption) *printOptions {
+	o := &printOptions{interHunkContext: -1, quote: quoteFilename}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithInterHunkContext merges consecutive hunks that are adjacent, or that
+// overlap with matching context lines, into a single hunk when printing.
+// Unlike git's --inter-hunk-context, it cannot merge hunks with a gap
+// between them, as hunk bodies never contain the lines in the gap, so n
+// only enables the option: any n >= 0 behaves the same, and a negative n
+// leaves the hunks as they are.
+func WithInterHunkContext(n int) PrintOption {
+	return func(o *printOptions) {
+		o.interHunkContext = n
+	}
+}
+
+// WithFileSummaryComment prints a comment line summarizing each file's
+// changes before its diff, such as "# path/to/file (+12 -3)", where the
+// counts are the numbers of lines added and deleted. The parser skips these
+// lines, so the diff parses the same way with or without them. No comment
+// is printed for "Only in" messages.
+func WithFileSummaryComment() PrintOption {
+	return func(o *printOptions) {
+		o.fileSummaryComment = true
+	}
+}
+
+// WithSkipEmptyHunks omits hunks that have an empty body and whose line
+// counts are both zero, such as those left by programmatic trimming, since
+// their headers would make the printed diff invalid. A file diff whose hunks
+// are all omitted is printed without a file header, like one with no hunks.
+func WithSkipEmptyHunks() PrintOption {
+	return func(o *printOptions) {
+		o.skipEmptyHunks = true
+	}
+}
+
+// WithQuoter sets the function used to quote file names in the "---" and
+// "+++" file headers. It is called for every name (except /dev/null) and
+// returns it as it should be printed. By default, names are quoted the way
+// git does, with C-style escapes (octal for non-ASCII bytes), and only if
+// they contain special characters. When a quoter is given, the names in
+// the "diff --git", "rename from", "rename to", "copy from", "copy to" and
+// "Binary files" extended headers are quoted with it too.
+func WithQuoter(quote func(name string) string) PrintOption {
+	return func(o *printOptions) {
+		o.quote = quote
+		o.quoter = true
+	}
+}
+
+// WithQuotePath quotes file names like WithQuoter does by default, as git
+// does with its core.quotePath setting set to quotePath. With quotePath
+// true (the default), names with non-ASCII bytes are quoted, and the bytes
+// escaped in octal; with quotePath false, they are printed as they are, and
+// only names with double quotes, backslashes or control characters are
+// quoted.
+func WithQuotePath(quotePath bool) PrintOption {
+	return WithQuoter(func(name string) string {
+		return quoteGitPath(name, quotePath)
+	})
+}
+
+// WithNULTerminated makes the printers of file lists, such as PrintRaw,
+// produce output for machines like git's -z option does: paths are not
+// quoted, and are terminated by NUL bytes instead of being separated by
+// tabs and terminated by newlines, so that paths containing newlines,
+// tabs or quotes can be read back safely.
+func WithNULTerminated() PrintOption {
+	return func(o *printOptions) {
+		o.nulTerminated = true
+	}
+}
+
+// path returns a path to print in a file list: quoted, unless
+// WithNULTerminated was given.
+func (o *printOptions) path(path string) string {
+	if o.nulTerminated {
+		return path
+	}
+	return o.quote(path)
+}
+
+// separator returns the separator to print between the fields of a file
+// list, which is sep unless WithNULTerminated was given.
+func (o *printOptions) separator(sep byte) byte {
+	if o.nulTerminated {
+		return 0
+	}
+	return sep
+}
+
+// WithLinePrefix prints prefix at the start of every line of unified diffs,
+// like git's --line-prefix, for embedding diffs in emails (with "> ") or
+// Markdown (with four spaces).
+func WithLinePrefix(prefix string) PrintOption {
+	return func(o *printOptions) {
+		o.linePrefix = prefix
+	}
+}
+
+// writer returns the writer to print to w through, which adds the prefix
+// set by WithLinePrefix to each line.
+func (o *printOptions) writer(w textWriter) textWriter {
+	if o.linePrefix == "" {
+		return w
+	}
+	return &linePrefixWriter{w: w, prefix: o.linePrefix}
+}
+
+// WithZeroBasedOutput prints hunk headers with 0-based line numbers, the
+// inverse of the WithZeroBasedLines parse option.
+func WithZeroBasedOutput() PrintOption {
+	return func(o *printOptions) {
+		o.zeroBasedOutput = true
+	}
+}
+
+// WithCompactSummary makes PrintDiffStat annotate file names with the
+// changes to their existence and mode, like git's --compact-summary:
+// "(new)" for an added file ("(new +x)" if it is executable, "(new +l)" if
+// it is a symlink), "(gone)" for a deleted one, and "(mode +x)", "(mode -x)",
+// "(mode +l)" or "(mode -l)" for one whose executable bit or symlink status
+// changed.
+func WithCompactSummary() PrintOption {
+	return func(o *printOptions) {
+		o.compactSummary = true
+	}
+}
+
+// WithoutTimestamps omits the timestamps from file headers, as git does.
+func WithoutTimestamps() PrintOption {
+	return func(o *printOptions) {
+		o.omitTimestamps = true
+	}
+}
+
+// WithTimestampFormat prints the timestamps in file headers with layout
+// (see time.Time.Format), in the time zone loc, instead of with the layout
+// they were parsed with (or the default one) in their own time zone. An
+// empty layout or a nil loc leaves that part unchanged. For example,
+// WithTimestampFormat("", time.UTC) prints GNU diff timestamps in UTC.
+// File diffs without timestamps are printed without them.
+func WithTimestampFormat(layout string, loc *time.Location) PrintOption {
+	return func(o *printOptions) {
+		o.timeLayout, o.timeLocation = layout, loc
+	}
+}
+
+// timestamp returns the timestamp to print in a file header, and its
+// layout, given those of the file diff.
+func (o *printOptions) timestamp(t *time.Time, layout string) (*time.Time, string) {
+	if t == nil || o.omitTimestamps {
+		return nil, ""
+	}
+	if o.timeLayout != "" {
+		layout = o.timeLayout
+	}
+	if o.timeLocation != nil {
+		tz := t.In(o.timeLocation)
+		t = &tz
+	}
+	return t, layout
+}
+
+// WithPrefixes replaces git's "a/" and "b/" prefixes of the original and new
+// file names with src and dst, like git's --src-prefix and --dst-prefix.
+// The names are changed in the "---" and "+++" file headers and in the
+// "diff --git" and "Binary files" extended headers. Names without git's
+// prefixes (such as those in GNU diffs) get src and dst added, and
+// /dev/null is left as it is.
+func WithPrefixes(src, dst string) PrintOption {
+	return func(o *printOptions) {
+		o.prefixes = &[2]string{src, dst}
+	}
+}
+
+// WithNoPrefix prints file names without git's "a/" and "b/" prefixes, like
+// git's --no-prefix. It is the same as WithPrefixes("", "").
+func WithNoPrefix() PrintOption {
+	return WithPrefixes("", "")
+}
+
+// prefixed returns the original and new file names with the prefixes set by
+// WithPrefixes.
+func (o *printOptions) prefixed(orig, new string) (string, string) {
+	if o.prefixes == nil {
+		return orig, new
+	}
+	orig, new = trimGitPrefixes(orig, new)
+	if orig != devNull && orig != "" {
+		orig = o.prefixes[0] + orig
+	}
+	if new != devNull && new != "" {
+		new = o.prefixes[1] + new
+	}
+	return orig, new
+}
+
+// WithCanonicalXHeaders prints the extended headers after the "diff --git"
+// line of each file diff in the order in which git prints them, so that
+// diffs print the same regardless of the order of their extended headers.
+// The headers are sorted stably, and headers that git does not print are
+// placed after the others, but before any "Binary files" line or binary
+// patch. Headers before the "diff --git" line are left as they are.
+func WithCanonicalXHeaders() PrintOption {
+	return func(o *printOptions) {
+		o.canonicalXHeaders = true
+	}
+}
+
+// WithGitHeaders prints the extended headers returned by
+// FileDiff.GitHeaders for file diffs without any, such as those constructed
+// programmatically, so that they are printed like git prints them.
+func WithGitHeaders() PrintOption {
+	return func(o *printOptions) {
+		o.gitHeaders = true
+	}
+}
+
+// extended returns the extended headers of d to print, generated by
+// WithGitHeaders, reordered by WithCanonicalXHeaders, and with the file
+// names in them changed by WithPrefixes and quoted by WithQuoter.
+func (o *printOptions) extended(d *FileDiff) []string {
+	if o.gitHeaders && len(d.Extended) == 0 && !d.isOnlyIn() {
+		d = &FileDiff{OrigName: d.OrigName, NewName: d.NewName, Extended: d.GitHeaders()}
+	}
+	if o.prefixes == nil && !o.quoter && !o.canonicalXHeaders {
+		return d.Extended
+	}
+	xheaders := make([]string, len(d.Extended))
+	copy(xheaders, d.Extended)
+	if o.canonicalXHeaders {
+		sortXHeaders(xheaders)
+	}
+	if o.prefixes == nil && !o.quoter {
+		return xheaders
+	}
+	for i, xheader := range xheaders {
+		if name, ok := renameOrCopyName(xheader); ok {
+			xheaders[i] = xheader[:len(xheader)-len(name)] + o.quote(unquoteFilename(name))
+			continue
+		}
+		switch {
+		case strings.HasPrefix(xheader, "diff --git "):
+			if orig, new, ok := parseDiffGitArgs(xheader[len("diff --git "):]); ok {
+				orig, new = o.prefixed(orig, new)
+				xheader = "diff --git " + o.quote(orig) + " " + o.quote(new)
+			}
+		case strings.HasPrefix(xheader, "Binary files ") && strings.HasSuffix(xheader, " differ"):
+			orig, new := o.prefixed(d.OrigName, d.NewName)
+			if orig != devNull {
+				orig = o.quote(orig)
+			}
+			if new != devNull {
+				new = o.quote(new)
+			}
+			xheader = fmt.Sprintf("Binary files %s and %s differ", orig, new)
+		}
+		xheaders[i] = xheader
+	}
+	return xheaders
+}
+
+// renameOrCopyName returns the name in a "rename from", "rename to", "copy
+// from" or "copy to" extended header, as it appears in it.
+func renameOrCopyName(xheader string) (string, bool) {
+	for _, prefix := range []string{"rename from ", "rename to ", "copy from ", "copy to "} {
+		if strings.HasPrefix(xheader, prefix) {
+			return xheader[len(prefix):], true
+		}
+	}
+	return "", false
+}
+
+// GitHeaders returns the extended headers that git would print for d, based
+// on its names, modes, object names and status rather than on its Extended
+// headers: a "diff --git" line; "new file mode", "deleted file mode" or
+// "old mode" and "new mode" lines; "similarity index" (if the status has a
+// score) and "rename from" and "rename to" (or "copy from" and "copy to")
+// lines; and an "index" line if an object name is known, from OrigSHA and
+// NewSHA or the "index" line of a diff from git diff. Names without git's
+// "a/" and "b/" prefixes get them in the "diff --git" line. A file whose
+// names differ is taken to be renamed, unless its status says it was
+// copied. The modes of added and deleted files default to 100644.
+func (d *FileDiff) GitHeaders() []string {
+	orig, new := d.paths()
+	typ := d.Type()
+	switch typ {
+	case Added:
+		orig = new
+	case Deleted:
+		new = orig
+	case Modified:
+		if orig != new {
+			typ = Renamed
+		}
+	}
+	xheaders := []string{"diff --git " + quoteFilename("a/"+orig) + " " + quoteFilename("b/"+new)}
+
+	origMode, newMode := d.OrigMode, d.NewMode
+	switch typ {
+	case Added:
+		if newMode == 0 {
+			newMode = 0100644
+		}
+		xheaders = append(xheaders, fmt.Sprintf("new file mode %06o", newMode))
+	case Deleted:
+		if origMode == 0 {
+			origMode = 0100644
+		}
+		xheaders = append(xheaders, fmt.Sprintf("deleted file mode %06o", origMode))
+	default:
+		if d.ModeChanged() {
+			xheaders = append(xheaders, fmt.Sprintf("old mode %06o", origMode), fmt.Sprintf("new mode %06o", newMode))
+		}
+	}
+
+	if typ == Renamed || typ == Copied {
+		if len(d.Status) > 1 {
+			if score, err := strconv.Atoi(d.Status[1:]); err == nil {
+				xheaders = append(xheaders, fmt.Sprintf("similarity index %d%%", score))
+			}
+		}
+		verb := "rename"
+		if typ == Copied {
+			verb = "copy"
+		}
+		xheaders = append(xheaders, verb+" from "+quoteFilename(orig), verb+" to "+quoteFilename(new))
+	}
+
+	if origSHA, newSHA := d.objectNames(); origSHA != "" || newSHA != "" {
+		if origSHA == "" {
+			origSHA = strings.Repeat("0", len(newSHA))
+		}
+		if newSHA == "" {
+			newSHA = strings.Repeat("0", len(origSHA))
+		}
+		index := "index " + origSHA + ".." + newSHA
+		if typ != Added && typ != Deleted && origMode != 0 && origMode == newMode {
+			index += fmt.Sprintf(" %06o", origMode)
+		}
+		xheaders = append(xheaders, index)
+	}
+	return xheaders
+}
+
+// sortXHeaders sorts the extended headers in xheaders that follow the last
+// "diff --git" line (and precede any "Binary files" line or binary patch) in
+// the order in which git prints them.
+func sortXHeaders(xheaders []string) {
+	start := 0
+	for i := len(xheaders) - 1; i >= 0; i-- {
+		if strings.HasPrefix(xheaders[i], "diff --git ") {
+			start = i + 1
+			break
+		}
+	}
+	end := start
+	for end < len(xheaders) && xheaderRank(xheaders[end]) != binaryXHeaderRank {
+		end++
+	}
+	// Headers that git does not print go between the last ones it prints
+	// and binary patches.
+	key := func(xheader string) int {
+		if rank := xheaderRank(xheader); rank >= 0 {
+			return 2 * rank
+		}
+		return 2*binaryXHeaderRank - 1
+	}
+	sorted := xheaders[start:end]
+	sort.SliceStable(sorted, func(i, j int) bool { return key(sorted[i]) < key(sorted[j]) })
+}
+
+// colors returns the color scheme to print with, which has no colors
+// unless WithColor was given.
+func (o *printOptions) colors() *ColorScheme {
+	if o.color == nil {
+		return &ColorScheme{}
+	}
+	return o.color
+}
+
+// hunks returns the hunks to print, after applying the options that drop
+// or merge hunks.
+func (o *printOptions) hunks(hunks []*Hunk) []*Hunk {
+	if o.skipEmptyHunks {
+		var nonEmpty []*Hunk
+		for _, h := range hunks {
+			if len(h.Body) > 0 || h.OrigLines != 0 || h.NewLines != 0 {
+				nonEmpty = append(nonEmpty, h)
+			}
+		}
+		hunks = nonEmpty
+	}
+	if o.interHunkContext >= 0 {
+		hunks = mergeHunks(hunks)
+	}
+	return hunks
+}
+
+// errHunksNotLoaded is returned when printing a FileDiff parsed with
+// WithLazyHunks whose hunks have not been loaded.
+var errHunksNotLoaded = errors.New("hunks have not been loaded (see LoadHunks)")
+
 // PrintMultiFileDiff prints a multi-file diff in unified diff format.
-func PrintMultiFileDiff(ds []*FileDiff) ([]byte, error) {
+func PrintMultiFileDiff(ds []*FileDiff, opts ...PrintOption) ([]byte, error) {
 	var buf bytes.Buffer
+	o := newPrintOptions(opts)
+	if err := writeMultiFileDiff(o.writer(&buf), ds, o); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteMultiFileDiff is like PrintMultiFileDiff, but writes the diff to w
+// as it is printed instead of returning it, so that large diffs need not be
+// held in memory. w is written to through a bufio.Writer, so it may be
+// partially written if an error occurs.
+func WriteMultiFileDiff(w io.Writer, ds []*FileDiff, opts ...PrintOption) error {
+	bw := bufio.NewWriter(w)
+	o := newPrintOptions(opts)
+	if err := writeMultiFileDiff(o.writer(bw), ds, o); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func writeMultiFileDiff(w textWriter, ds []*FileDiff, o *printOptions) error {
 	for _, d := range ds {
-		diff, err := PrintFileDiff(d)
-		if err != nil {
-			return nil, err
-		}
-		if _, err := buf.Write(diff); err != nil {
-			return nil, err
+		if err := writeFileDiff(w, d, o); err != nil {
+			return err
 		}
 	}
-	return buf.Bytes(), nil
+	return nil
 }
 
 // PrintFileDiff prints a FileDiff in unified diff format.
-//
-// TODO(sqs): handle escaping whitespace/etc. chars in filenames
-func PrintFileDiff(d *FileDiff) ([]byte, error) {
+func PrintFileDiff(d *FileDiff, opts ...PrintOption) ([]byte, error) {
 	var buf bytes.Buffer
+	o := newPrintOptions(opts)
+	if err := writeFileDiff(o.writer(&buf), d, o); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
 
-	for _, xheader := range d.Extended {
-		if _, err := fmt.Fprintln(&buf, xheader); err != nil {
-			return nil, err
-		}
+// WriteFileDiff is like PrintFileDiff, but writes the diff to w as it is
+// printed (see WriteMultiFileDiff).
+func WriteFileDiff(w io.Writer, d *FileDiff, opts ...PrintOption) error {
+	bw := bufio.NewWriter(w)
+	o := newPrintOptions(opts)
+	if err := writeFileDiff(o.writer(bw), d, o); err != nil {
+		return err
 	}
+	return bw.Flush()
+}
 
-	// FileDiff is added/deleted file
-	// No further hunks printing needed
-	if d.NewName == "" {
-		_, err := fmt.Fprintf(&buf, onlyInMessage, filepath.Dir(d.OrigName), filepath.Base(d.OrigName))
+// WriteTo writes d to w in unified diff format, with the default print
+// options. It implements io.WriterTo.
+func (d *FileDiff) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := WriteFileDiff(cw, d)
+	return cw.n, err
+}
+
+// A textWriter is a writer that diffs are printed to, such as a
+// bytes.Buffer or a bufio.Writer. Since the errors of a bufio.Writer are
+// returned again when it is flushed, the results of its methods may be
+// ignored.
+type textWriter interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+}
+
+// A countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// A linePrefixWriter writes to w with prefix added to the start of each line.
+type linePrefixWriter struct {
+	w       textWriter
+	prefix  string
+	midLine bool // whether the last byte written was not a newline
+}
+
+func (pw *linePrefixWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if !pw.midLine {
+			if _, err := pw.w.WriteString(pw.prefix); err != nil {
+				return n, err
+			}
+		}
+		line := p
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line = p[:i+1]
+		}
+		m, err := pw.w.Write(line)
+		n += m
 		if err != nil {
-			return nil, err
+			return n, err
 		}
-		return buf.Bytes(), nil
+		pw.midLine = line[len(line)-1] != '\n'
+		p = p[len(line):]
 	}
+	return n, nil
+}
 
-	if d.Hunks == nil {
-		return buf.Bytes(), nil
+func (pw *linePrefixWriter) WriteString(s string) (int, error) {
+	return pw.Write([]byte(s))
+}
+
+func (pw *linePrefixWriter) WriteByte(c byte) error {
+	_, err := pw.Write([]byte{c})
+	return err
+}
+
+func writeFileDiff(w textWriter, d *FileDiff, o *printOptions) error {
+	if d.Hunks == nil && d.RawHunks != nil {
+		return errHunksNotLoaded
 	}
 
-	if err := printFileHeader(&buf, "--- ", d.OrigName, d.OrigTime); err != nil {
-		return nil, err
+	if o.fileSummaryComment && !d.isOnlyIn() {
+		added, deleted := d.Stat().lines()
+		if _, err := fmt.Fprintf(w, "# %s (+%d -%d)\n", d.path(), added, deleted); err != nil {
+			return err
+		}
 	}
-	if err := printFileHeader(&buf, "+++ ", d.NewName, d.NewTime); err != nil {
-		return nil, err
+
+	colors := o.colors()
+	for _, xheader := range o.extended(d) {
+		writeColored(w, colors.Meta, []byte(xheader+"\n"))
+	}
+	if d.BinaryPatch != nil && !d.hasExtended("GIT binary patch") {
+		bp, err := PrintBinaryPatch(d.BinaryPatch)
+		if err != nil {
+			return err
+		}
+		w.WriteString("GIT binary patch\n")
+		w.Write(bp)
 	}
 
-	ph, err := PrintHunks(d.Hunks)
-	if err != nil {
-		return nil, err
+	// FileDiff is an "Only in" message
+	// No further hunks printing needed
+	if d.isOnlyIn() {
+		_, err := fmt.Fprintf(w, onlyInMessage, filepath.Dir(d.OrigName), filepath.Base(d.OrigName))
+		return err
 	}
 
-	if _, err := buf.Write(ph); err != nil {
-		return nil, err
+	if d.Hunks == nil {
+		return nil
 	}
-	return buf.Bytes(), nil
+	if o.skipEmptyHunks && len(o.hunks(d.Hunks)) == 0 {
+		return nil
+	}
+
+	origName, newName := o.prefixed(d.OrigName, d.NewName)
+	if d.IsDevNullOrig() {
+		origName = devNull
+	} else {
+		origName = o.quote(origName)
+	}
+	if !d.IsDevNullNew() {
+		newName = o.quote(newName)
+	}
+	var header bytes.Buffer
+	origTime, origLayout := o.timestamp(d.OrigTime, d.OrigTimeLayout)
+	if err := printFileHeader(&header, "--- ", origName, origTime, origLayout); err != nil {
+		return err
+	}
+	newTime, newLayout := o.timestamp(d.NewTime, d.NewTimeLayout)
+	if err := printFileHeader(&header, "+++ ", newName, newTime, newLayout); err != nil {
+		return err
+	}
+	writeColored(w, colors.Meta, header.Bytes())
+
+	return writeHunks(w, d.Hunks, o)
 }
 
-func printFileHeader(w io.Writer, prefix string, filename string, timestamp *time.Time) error {
+func printFileHeader(w io.Writer, prefix string, filename string, timestamp *time.Time, layout string) error {
 	if _, err := fmt.Fprint(w, prefix, filename); err != nil {
 		return err
 	}
 	if timestamp != nil {
-		if _, err := fmt.Fprint(w, "\t", timestamp.Format(diffTimeFormatLayout)); err != nil {
+		if layout == "" {
+			layout = diffTimeFormatLayout
+		}
+		if _, err := fmt.Fprint(w, "\t", timestamp.Format(layout)); err != nil {
 			return err
 		}
 	}
@@ -83,59 +649,198 @@ func printFileHeader(w io.Writer, prefix string, filename string, timestamp *tim
 }
 
 // PrintHunks prints diff hunks in unified diff format.
-func PrintHunks(hunks []*Hunk) ([]byte, error) {
+func PrintHunks(hunks []*Hunk, opts ...PrintOption) ([]byte, error) {
 	var buf bytes.Buffer
+	o := newPrintOptions(opts)
+	if err := writeHunks(o.writer(&buf), hunks, o); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTo writes h to w in unified diff format, with the default print
+// options. It implements io.WriterTo.
+func (h *Hunk) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	if err := writeHunks(bw, []*Hunk{h}, newPrintOptions(nil)); err != nil {
+		return cw.n, err
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+func writeHunks(w textWriter, hunks []*Hunk, o *printOptions) error {
+	hunks = o.hunks(hunks)
+	colors := o.colors()
+
 	for _, hunk := range hunks {
-		_, err := fmt.Fprintf(&buf,
-			"@@ -%d,%d +%d,%d @@", hunk.OrigStartLine, hunk.OrigLines, hunk.NewStartLine, hunk.NewLines,
-		)
-		if err != nil {
-			return nil, err
+		origStart, newStart := hunk.OrigStartLine, hunk.NewStartLine
+		if o.zeroBasedOutput {
+			if hunk.OrigLines > 0 {
+				origStart--
+			}
+			if hunk.NewLines > 0 {
+				newStart--
+			}
 		}
-		if hunk.Section != "" {
-			_, err := fmt.Fprint(&buf, " ", hunk.Section)
+		header := fmt.Sprintf("@@ -%d,%d +%d,%d @@", origStart, hunk.OrigLines, newStart, hunk.NewLines)
+		writeColored(w, colors.Frag, []byte(header))
+		if hunk.Section != "" || hunk.EmptySection {
+			_, err := fmt.Fprint(w, " ", hunk.Section)
 			if err != nil {
-				return nil, err
+				return err
 			}
 		}
-		if _, err := fmt.Fprintln(&buf); err != nil {
-			return nil, err
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
 		}
 
+		refinements := o.lineRefinements(hunk)
 		if hunk.OrigNoNewlineAt == 0 {
-			if _, err := buf.Write(hunk.Body); err != nil {
-				return nil, err
-			}
+			writeColoredBody(w, colors, hunk.Body, 0, refinements)
 		} else {
-			if _, err := buf.Write(hunk.Body[:hunk.OrigNoNewlineAt]); err != nil {
-				return nil, err
-			}
-			if err := printNoNewlineMessage(&buf); err != nil {
-				return nil, err
-			}
-			if _, err := buf.Write(hunk.Body[hunk.OrigNoNewlineAt:]); err != nil {
-				return nil, err
-			}
+			line := writeColoredBody(w, colors, hunk.Body[:hunk.OrigNoNewlineAt], 0, refinements)
+			writeColored(w, colors.Context, []byte(noNewlineMessage+"\n"))
+			writeColoredBody(w, colors, hunk.Body[hunk.OrigNoNewlineAt:], line, refinements)
 		}
 
 		if !bytes.HasSuffix(hunk.Body, []byte{'\n'}) {
-			if _, err := fmt.Fprintln(&buf); err != nil {
-				return nil, err
-			}
-			if err := printNoNewlineMessage(&buf); err != nil {
-				return nil, err
+			w.WriteByte('\n')
+			writeColored(w, colors.Context, []byte(noNewlineMessage+"\n"))
+		}
+	}
+	return nil
+}
+
+// mergeHunks returns hunks with each run of consecutive hunks that are
+// adjacent, or that overlap with identical context lines, merged into one.
+// Other hunks cannot be merged, because the lines between them are not
+// present in their bodies.
+func mergeHunks(hunks []*Hunk) []*Hunk {
+	var merged []*Hunk
+	for _, h := range hunks {
+		if len(merged) > 0 {
+			if m, ok := mergeHunkPair(merged[len(merged)-1], h); ok {
+				merged[len(merged)-1] = m
+				continue
 			}
 		}
+		merged = append(merged, h)
 	}
-	return buf.Bytes(), nil
+	return merged
 }
 
-func printNoNewlineMessage(w io.Writer) error {
-	if _, err := w.Write([]byte(noNewlineMessage)); err != nil {
-		return err
+// mergeHunkPair merges b into a, which precedes it, if the merge is
+// possible (see mergeHunks).
+func mergeHunkPair(a, b *Hunk) (*Hunk, bool) {
+	aOrig, aNew := a.lineStarts()
+	bOrig, bNew := b.lineStarts()
+	gap := bOrig - (aOrig + int(a.OrigLines))
+	if gap > 0 || bNew-(aNew+int(a.NewLines)) != gap {
+		return nil, false
 	}
-	if _, err := fmt.Fprintln(w); err != nil {
-		return err
+
+	// The last -gap lines of a must be the same context lines as the first
+	// -gap lines of b.
+	aLines, bLines := a.lines(), b.lines()
+	overlap := -gap
+	if overlap > len(aLines) || overlap > len(bLines) {
+		return nil, false
 	}
-	return nil
+	for i := 0; i < overlap; i++ {
+		al, bl := aLines[len(aLines)-overlap+i], bLines[i]
+		if al.op != ' ' || bl.op != ' ' || !bytes.Equal(al.text, bl.text) || al.noNewline != bl.noNewline {
+			return nil, false
+		}
+	}
+	if len(aLines) > 0 && aLines[len(aLines)-1].noNewline && len(bLines) > overlap {
+		return nil, false
+	}
+
+	m := &Hunk{
+		OrigLines:     a.OrigLines + b.OrigLines - int32(overlap),
+		NewLines:      a.NewLines + b.NewLines - int32(overlap),
+		Section:       a.Section,
+		EmptySection:  a.EmptySection,
+		StartPosition: a.StartPosition,
+	}
+	m.OrigStartLine, m.NewStartLine = int32(aOrig), int32(aNew)
+	if m.OrigLines == 0 {
+		m.OrigStartLine--
+	}
+	if m.NewLines == 0 {
+		m.NewStartLine--
+	}
+	m.setLines(append(aLines[:len(aLines):len(aLines)], bLines[overlap:]...))
+	return m, true
+}
+
+// HunksOnly returns the hunks of d in unified diff format, omitting the
+// file header and any extended headers. It is useful for consumers that
+// receive the file names out of band.
+func (d *FileDiff) HunksOnly() []byte {
+	// PrintHunks only fails if writing to its bytes.Buffer fails, which
+	// it never does.
+	hunks, _ := PrintHunks(d.Hunks)
+	return hunks
+}
+
+// quoteFilename quotes name the way git does in diff headers if it contains
+// special characters (double quotes, backslashes, control characters or
+// non-ASCII bytes), using C-style escapes, and returns it unchanged
+// otherwise.
+func quoteFilename(name string) string {
+	return quoteGitPath(name, true)
+}
+
+// quoteGitPath quotes name like quoteFilename, as git does with its
+// core.quotePath setting set to quotePath. If quotePath is false, non-ASCII
+// bytes are neither escaped nor cause the name to be quoted.
+func quoteGitPath(name string, quotePath bool) string {
+	escape := func(c byte) bool {
+		return c < 0x20 || c == 0x7f || c >= 0x80 && quotePath
+	}
+	needsQuoting := false
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; escape(c) || c == '"' || c == '\\' {
+			needsQuoting = true
+			break
+		}
+	}
+	if !needsQuoting {
+		return name
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; c {
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\v':
+			b.WriteString(`\v`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			if escape(c) {
+				fmt.Fprintf(&b, `\%03o`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
 }
@@ -0,0 +1,568 @@
+package diff
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPrintHunks_InterHunkContext(t *testing.T) {
+	tests := map[string]struct {
+		hunks string
+		n     int
+		want  string
+	}{
+		"adjacent hunks are merged": {
+			hunks: `@@ -1,3 +1,3 @@ section
+ a
+-b
++B
+ c
+@@ -4,2 +4,3 @@
+ d
++e
+ f
+`,
+			n: 2,
+			want: `@@ -1,5 +1,6 @@ section
+ a
+-b
++B
+ c
+ d
++e
+ f
+`,
+		},
+		"overlapping hunks are merged": {
+			hunks: `@@ -1,3 +1,3 @@
+ a
+-b
++B
+ c
+@@ -3,2 +3,1 @@
+ c
+-d
+`,
+			n: 0,
+			want: `@@ -1,4 +1,3 @@
+ a
+-b
++B
+ c
+-d
+`,
+		},
+		"bridging lines missing": {
+			hunks: `@@ -1,2 +1,2 @@
+-a
++A
+ b
+@@ -4,2 +4,2 @@
+ d
+-e
++E
+`,
+			n: 2,
+			want: `@@ -1,2 +1,2 @@
+-a
++A
+ b
+@@ -4,2 +4,2 @@
+ d
+-e
++E
+`,
+		},
+		"overlapping context differs": {
+			hunks: `@@ -1,2 +1,2 @@
+-a
++A
+ b
+@@ -2,2 +2,2 @@
+ x
+-c
++C
+`,
+			n: 2,
+			want: `@@ -1,2 +1,2 @@
+-a
++A
+ b
+@@ -2,2 +2,2 @@
+ x
+-c
++C
+`,
+		},
+		"disabled": {
+			hunks: `@@ -1,1 +1,1 @@
+-a
++A
+@@ -2,1 +2,1 @@
+-b
++B
+`,
+			n: -1,
+			want: `@@ -1,1 +1,1 @@
+-a
++A
+@@ -2,1 +2,1 @@
+-b
++B
+`,
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			hunks, err := ParseHunks([]byte(test.hunks))
+			if err != nil {
+				t.Fatal(err)
+			}
+			printed, err := PrintHunks(hunks, WithInterHunkContext(test.n))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(printed); got != test.want {
+				t.Errorf("printed hunks mismatch (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestPrintMultiFileDiff_FileSummaryComment(t *testing.T) {
+	input := `diff --git a/foo.go b/foo.go
+index 1111111..2222222 100644
+--- a/foo.go
++++ b/foo.go
+@@ -1,3 +1,4 @@
+ a
+-b
++B
++c
+ d
+diff --git a/bar.go b/bar.go
+deleted file mode 100644
+index 3333333..0000000
+--- a/bar.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-x
+-y
+Only in a: baz.go
+`
+	ds, err := ParseMultiFileDiff([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed, err := PrintMultiFileDiff(ds, WithFileSummaryComment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# foo.go (+2 -1)\n" + input[:strings.Index(input, "diff --git a/bar.go")] +
+		"# bar.go (+0 -2)\n" + input[strings.Index(input, "diff --git a/bar.go"):]
+	if got := string(printed); got != want {
+		t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	reparsed, err := ParseMultiFileDiff(printed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(reparsed, ds) {
+		t.Errorf("reparsed diff mismatch (-want +got):\n%s", cmp.Diff(ds, reparsed))
+	}
+}
+
+func TestPrintMultiFileDiff_QuotePath(t *testing.T) {
+	// The output of git diff -M, with core.quotePath set to true (the
+	// default) and false.
+	quoted, err := ioutil.ReadFile(filepath.Join("testdata", "quote_path.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unquoted, err := ioutil.ReadFile(filepath.Join("testdata", "quote_path_false.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		input, want []byte
+		quotePath   bool
+	}{
+		"quotePath false": {input: quoted, want: unquoted, quotePath: false},
+		"quotePath true":  {input: unquoted, want: quoted, quotePath: true},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			ds, err := ParseMultiFileDiff(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			printed, err := PrintMultiFileDiff(ds, WithQuotePath(test.quotePath))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(string(test.want), string(printed)); diff != "" {
+				t.Errorf("printed diff mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPrintFileDiff_SkipEmptyHunks(t *testing.T) {
+	d := parseFileDiffString(t, "--- a/f\n+++ b/f\n@@ -1,1 +1,1 @@\n-a\n+b\n")
+	empty := &Hunk{OrigStartLine: 5, NewStartLine: 5}
+	d.Hunks = append(d.Hunks, empty)
+
+	printed, err := PrintFileDiff(d, WithSkipEmptyHunks())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--- a/f\n+++ b/f\n@@ -1,1 +1,1 @@\n-a\n+b\n"
+	if got := string(printed); got != want {
+		t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	d.Hunks = []*Hunk{empty}
+	printed, err = PrintFileDiff(d, WithSkipEmptyHunks())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(printed) != 0 {
+		t.Errorf("got %q for a diff with only empty hunks, want nothing", printed)
+	}
+}
+
+func TestPrintFileDiff_Quoter(t *testing.T) {
+	d := &FileDiff{
+		OrigName: "a/商品.txt",
+		NewName:  "b/with\ttab.txt",
+		Hunks:    []*Hunk{{OrigStartLine: 1, OrigLines: 1, NewStartLine: 1, NewLines: 1, Body: []byte("-a\n+b\n")}},
+	}
+	tests := map[string]struct {
+		opts []PrintOption
+		want string
+	}{
+		"git quoting by default": {
+			want: `--- "a/\345\225\206\345\223\201.txt"
++++ "b/with\ttab.txt"
+`,
+		},
+		"core.quotePath off": {
+			opts: []PrintOption{WithQuotePath(false)},
+			want: `--- a/商品.txt
++++ "b/with\ttab.txt"
+`,
+		},
+		"custom quoter": {
+			opts: []PrintOption{WithQuoter(strconv.QuoteToASCII)},
+			want: `--- "a/\u5546\u54c1.txt"
++++ "b/with\ttab.txt"
+`,
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			printed, err := PrintFileDiff(d, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := test.want + "@@ -1,1 +1,1 @@\n-a\n+b\n"
+			if got := string(printed); got != want {
+				t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+
+			reparsed, err := ParseFileDiff(printed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reparsed.OrigName != d.OrigName || reparsed.NewName != d.NewName {
+				t.Errorf("reparsed names %q and %q, want %q and %q", reparsed.OrigName, reparsed.NewName, d.OrigName, d.NewName)
+			}
+		})
+	}
+
+	printed, err := PrintFileDiff(&FileDiff{OrigName: devNull, NewName: "b/plain name.txt", Hunks: d.Hunks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "--- /dev/null\n+++ b/plain name.txt\n"; !strings.HasPrefix(string(printed), want) {
+		t.Errorf("got %q, want it to start with %q", printed, want)
+	}
+}
+
+func TestWriteMultiFileDiff(t *testing.T) {
+	diffData, err := ioutil.ReadFile(filepath.Join("testdata", "sample_multi_file.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := ParseMultiFileDiff(diffData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteMultiFileDiff(&buf, diffs); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != string(diffData) {
+		t.Errorf("written diff mismatch (-want +got):\n%s", cmp.Diff(string(diffData), got))
+	}
+
+	buf.Reset()
+	n, err := diffs[0].WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := PrintFileDiff(diffs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != string(want) || n != int64(len(want)) {
+		t.Errorf("got %d bytes %q, want %d bytes %q", n, got, len(want), want)
+	}
+
+	buf.Reset()
+	n, err = diffs[0].Hunks[0].WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err = PrintHunks(diffs[0].Hunks[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != string(want) || n != int64(len(want)) {
+		t.Errorf("got %d bytes %q, want %d bytes %q", n, got, len(want), want)
+	}
+
+	errWrite := errors.New("write failed")
+	if err := WriteMultiFileDiff(failingWriter{errWrite}, diffs); err != errWrite {
+		t.Errorf("got error %v, want %v", err, errWrite)
+	}
+}
+
+// A failingWriter fails every write with err.
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestPrintFileDiff_Timestamps(t *testing.T) {
+	d := parseFileDiffString(t, "--- a/f\t2020-01-02 03:04:05.000000000 +0100\n+++ b/f\t2020-01-02 04:05:06.000000000 +0100\n@@ -1,1 +1,1 @@\n-a\n+b\n")
+	tests := map[string]struct {
+		opts []PrintOption
+		want string
+	}{
+		"default": {
+			want: "--- a/f\t2020-01-02 03:04:05.000000000 +0100\n+++ b/f\t2020-01-02 04:05:06.000000000 +0100\n",
+		},
+		"without timestamps": {
+			opts: []PrintOption{WithoutTimestamps()},
+			want: "--- a/f\n+++ b/f\n",
+		},
+		"in UTC": {
+			opts: []PrintOption{WithTimestampFormat("", time.UTC)},
+			want: "--- a/f\t2020-01-02 02:04:05.000000000 +0000\n+++ b/f\t2020-01-02 03:05:06.000000000 +0000\n",
+		},
+		"custom layout": {
+			opts: []PrintOption{WithTimestampFormat(time.RFC3339, nil)},
+			want: "--- a/f\t2020-01-02T03:04:05+01:00\n+++ b/f\t2020-01-02T04:05:06+01:00\n",
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			printed, err := PrintFileDiff(d, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := test.want + "@@ -1,1 +1,1 @@\n-a\n+b\n"
+			if got := string(printed); got != want {
+				t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestPrintFileDiff_Prefixes(t *testing.T) {
+	git := parseFileDiffString(t, "diff --git a/f b/g\nsimilarity index 50%\nrename from f\nrename to g\n--- a/f\n+++ b/g\n@@ -1,1 +1,1 @@\n-a\n+b\n")
+	gnu := parseFileDiffString(t, "--- f.orig\n+++ f\n@@ -1,1 +1,1 @@\n-a\n+b\n")
+	added := parseFileDiffString(t, "diff --git a/h b/h\nnew file mode 100644\n--- /dev/null\n+++ b/h\n@@ -0,0 +1,1 @@\n+b\n")
+	binary := &FileDiff{OrigName: "a/x y", NewName: "b/x y", Extended: []string{`diff --git a/x y b/x y`, "index 0123456..789abcd 100644", "Binary files a/x y and b/x y differ"}}
+	tests := map[string]struct {
+		d    *FileDiff
+		opts []PrintOption
+		want string
+	}{
+		"git, no prefix": {
+			d:    git,
+			opts: []PrintOption{WithNoPrefix()},
+			want: "diff --git f g\nsimilarity index 50%\nrename from f\nrename to g\n--- f\n+++ g\n@@ -1,1 +1,1 @@\n-a\n+b\n",
+		},
+		"git, custom prefixes": {
+			d:    git,
+			opts: []PrintOption{WithPrefixes("old/", "new/")},
+			want: "diff --git old/f new/g\nsimilarity index 50%\nrename from f\nrename to g\n--- old/f\n+++ new/g\n@@ -1,1 +1,1 @@\n-a\n+b\n",
+		},
+		"gnu, added prefixes": {
+			d:    gnu,
+			opts: []PrintOption{WithPrefixes("a/", "b/")},
+			want: "--- a/f.orig\n+++ b/f\n@@ -1,1 +1,1 @@\n-a\n+b\n",
+		},
+		"dev null": {
+			d:    added,
+			opts: []PrintOption{WithNoPrefix()},
+			want: "diff --git h h\nnew file mode 100644\n--- /dev/null\n+++ h\n@@ -0,0 +1,1 @@\n+b\n",
+		},
+		"binary": {
+			d:    binary,
+			opts: []PrintOption{WithPrefixes("l/", "r/")},
+			want: "diff --git l/x y r/x y\nindex 0123456..789abcd 100644\nBinary files l/x y and r/x y differ\n",
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			printed, err := PrintFileDiff(test.d, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(printed); got != test.want {
+				t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestPrintFileDiff_LinePrefix(t *testing.T) {
+	d := parseFileDiffString(t, "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,1 +1,1 @@\n-a\n+b\n\\ No newline at end of file\n")
+	want := "> diff --git a/f b/f\n> --- a/f\n> +++ b/f\n> @@ -1,1 +1,1 @@\n> -a\n> +b\n> \\ No newline at end of file\n"
+	printed, err := PrintFileDiff(d, WithLinePrefix("> "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(printed); got != want {
+		t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	var buf bytes.Buffer
+	if err := WriteMultiFileDiff(&buf, []*FileDiff{d, d}, WithLinePrefix("> ")); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want+want {
+		t.Errorf("written diff mismatch (-want +got):\n%s", cmp.Diff(want+want, got))
+	}
+}
+
+func TestPrintFileDiff_CanonicalXHeaders(t *testing.T) {
+	d := &FileDiff{
+		OrigName: "a/f",
+		NewName:  "b/g",
+		Extended: []string{
+			"commit message",
+			"diff --git a/f b/g",
+			"index 0123456..789abcd",
+			"rename to g",
+			"x-custom header",
+			"similarity index 90%",
+			"rename from f",
+			"new mode 100755",
+			"old mode 100644",
+			"GIT binary patch",
+			"literal 1",
+			"IcmZo*00962",
+		},
+	}
+	printed, err := PrintFileDiff(d, WithCanonicalXHeaders())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "commit message\n" +
+		"diff --git a/f b/g\n" +
+		"old mode 100644\n" +
+		"new mode 100755\n" +
+		"similarity index 90%\n" +
+		"rename from f\n" +
+		"rename to g\n" +
+		"index 0123456..789abcd\n" +
+		"x-custom header\n" +
+		"GIT binary patch\n" +
+		"literal 1\n" +
+		"IcmZo*00962\n"
+	if got := string(printed); got != want {
+		t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+	if d.Extended[2] != "index 0123456..789abcd" {
+		t.Error("the extended headers of the diff were modified")
+	}
+}
+
+func TestFileDiff_GitHeaders(t *testing.T) {
+	tests := map[string]struct {
+		d    *FileDiff
+		want []string
+	}{
+		"modified": {
+			d:    &FileDiff{OrigName: "a/f", NewName: "b/f", OrigMode: 0100644, NewMode: 0100644, OrigSHA: "0123456", NewSHA: "789abcd"},
+			want: []string{"diff --git a/f b/f", "index 0123456..789abcd 100644"},
+		},
+		"object names from index line": {
+			d:    &FileDiff{OrigName: "a/f", NewName: "b/f", OrigMode: 0100644, NewMode: 0100644, Extended: []string{"diff --git a/f b/f", "index de98044..a7bc997 100644"}},
+			want: []string{"diff --git a/f b/f", "index de98044..a7bc997 100644"},
+		},
+		"mode change": {
+			d:    &FileDiff{OrigName: "f", NewName: "f", OrigMode: 0100644, NewMode: 0100755},
+			want: []string{"diff --git a/f b/f", "old mode 100644", "new mode 100755"},
+		},
+		"added": {
+			d:    &FileDiff{OrigName: "/dev/null", NewName: "b/f", NewSHA: "789abcd"},
+			want: []string{"diff --git a/f b/f", "new file mode 100644", "index 0000000..789abcd"},
+		},
+		"deleted": {
+			d:    &FileDiff{OrigName: "a/f", NewName: "/dev/null", OrigMode: 0100755},
+			want: []string{"diff --git a/f b/f", "deleted file mode 100755"},
+		},
+		"renamed": {
+			d:    &FileDiff{OrigName: "a/f", NewName: "b/g x", Status: "R086"},
+			want: []string{"diff --git a/f b/g x", "similarity index 86%", "rename from f", "rename to g x"},
+		},
+		"copied": {
+			d:    &FileDiff{OrigName: "f", NewName: "g\th", Status: "C100"},
+			want: []string{`diff --git a/f "b/g\th"`, "similarity index 100%", "copy from f", `copy to "g\th"`},
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			if got := test.d.GitHeaders(); !cmp.Equal(got, test.want) {
+				t.Errorf("headers mismatch (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestPrintFileDiff_GitHeaders(t *testing.T) {
+	d := &FileDiff{
+		OrigName: "/dev/null",
+		NewName:  "f",
+		NewMode:  0100755,
+		Hunks:    []*Hunk{{NewStartLine: 1, NewLines: 1, Body: []byte("+a\n")}},
+	}
+	printed, err := PrintFileDiff(d, WithGitHeaders(), WithPrefixes("a/", "b/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "diff --git a/f b/f\nnew file mode 100755\n--- /dev/null\n+++ b/f\n@@ -0,0 +1,1 @@\n+a\n"
+	if got := string(printed); got != want {
+		t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+	if d.Extended != nil {
+		t.Error("the extended headers of the diff were modified")
+	}
+}
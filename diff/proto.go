@@ -0,0 +1,460 @@
+package diff
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// ErrBadProto is when a protocol buffer message passed to FromProto or
+// StatFromProto is malformed, or has a field of the wrong type.
+var ErrBadProto = errors.New("bad protocol buffer message")
+
+// ToProto encodes d in the protocol buffer wire format, as the FileDiff
+// message defined in diff.proto, so that it can be sent to services (such
+// as gRPC services) that use the generated code for that file. Unlike
+// google.protobuf.Timestamp, the message also records the time zone offsets
+// of the timestamps. The object names of a diff from git diff are taken from
+// its "index" extended header if OrigSHA and NewSHA are empty.
+func ToProto(d *FileDiff) []byte {
+	var p protoBuffer
+	p.string(1, d.OrigName)
+	if d.OrigTime != nil {
+		p.message(2, timestampToProto(*d.OrigTime))
+	}
+	p.string(3, d.NewName)
+	if d.NewTime != nil {
+		p.message(4, timestampToProto(*d.NewTime))
+	}
+	for _, xheader := range d.Extended {
+		p.message(5, []byte(xheader))
+	}
+	for _, h := range d.Hunks {
+		p.message(6, hunkToProto(h))
+	}
+	p.string(7, d.OrigTimeLayout)
+	p.string(8, d.NewTimeLayout)
+	p.uint(9, uint64(d.OrigMode))
+	p.uint(10, uint64(d.NewMode))
+	origSHA, newSHA := d.objectNames()
+	p.string(11, origSHA)
+	p.string(12, newSHA)
+	p.string(13, d.Status)
+	p.bytes(14, d.RawHunks)
+	if bp := d.BinaryPatch; bp != nil {
+		var q protoBuffer
+		if bp.Forward != nil {
+			q.message(1, binaryHunkToProto(bp.Forward))
+		}
+		if bp.Reverse != nil {
+			q.message(2, binaryHunkToProto(bp.Reverse))
+		}
+		p.message(15, q.b)
+	}
+	if d.OrigTime != nil {
+		_, offset := d.OrigTime.Zone()
+		p.int(16, int64(offset))
+	}
+	if d.NewTime != nil {
+		_, offset := d.NewTime.Zone()
+		p.int(17, int64(offset))
+	}
+	return p.b
+}
+
+// FromProto decodes a FileDiff encoded by ToProto (or by any encoder of the
+// FileDiff message defined in diff.proto). Unknown fields are ignored.
+func FromProto(data []byte) (*FileDiff, error) {
+	d := &FileDiff{}
+	var origOffset, newOffset int64
+	err := parseProto(data, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1:
+			d.OrigName, err = f.string()
+		case 2:
+			d.OrigTime, err = f.timestamp()
+		case 3:
+			d.NewName, err = f.string()
+		case 4:
+			d.NewTime, err = f.timestamp()
+		case 5:
+			var xheader string
+			xheader, err = f.string()
+			d.Extended = append(d.Extended, xheader)
+		case 6:
+			var b []byte
+			if b, err = f.bytes(); err == nil {
+				var h *Hunk
+				h, err = hunkFromProto(b)
+				d.Hunks = append(d.Hunks, h)
+			}
+		case 7:
+			d.OrigTimeLayout, err = f.string()
+		case 8:
+			d.NewTimeLayout, err = f.string()
+		case 9:
+			d.OrigMode, err = f.uint32()
+		case 10:
+			d.NewMode, err = f.uint32()
+		case 11:
+			d.OrigSHA, err = f.string()
+		case 12:
+			d.NewSHA, err = f.string()
+		case 13:
+			d.Status, err = f.string()
+		case 14:
+			d.RawHunks, err = f.bytes()
+		case 15:
+			var b []byte
+			if b, err = f.bytes(); err == nil {
+				d.BinaryPatch, err = binaryPatchFromProto(b)
+			}
+		case 16:
+			origOffset, err = f.int()
+		case 17:
+			newOffset, err = f.int()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.OrigTime = inZone(d.OrigTime, origOffset)
+	d.NewTime = inZone(d.NewTime, newOffset)
+	return d, nil
+}
+
+// StatToProto encodes s in the protocol buffer wire format, as the Stat
+// message defined in diff.proto.
+func StatToProto(s Stat) []byte {
+	var p protoBuffer
+	p.int(1, int64(s.Added))
+	p.int(2, int64(s.Changed))
+	p.int(3, int64(s.Deleted))
+	return p.b
+}
+
+// StatFromProto decodes a Stat encoded by StatToProto.
+func StatFromProto(data []byte) (Stat, error) {
+	var s Stat
+	err := parseProto(data, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1:
+			s.Added, err = f.int32()
+		case 2:
+			s.Changed, err = f.int32()
+		case 3:
+			s.Deleted, err = f.int32()
+		}
+		return err
+	})
+	return s, err
+}
+
+func hunkToProto(h *Hunk) []byte {
+	var p protoBuffer
+	p.int(1, int64(h.OrigStartLine))
+	p.int(2, int64(h.OrigLines))
+	p.int(3, int64(h.OrigNoNewlineAt))
+	p.int(4, int64(h.NewStartLine))
+	p.int(5, int64(h.NewLines))
+	p.string(6, h.Section)
+	p.int(7, int64(h.StartPosition))
+	p.bytes(8, h.Body)
+	if h.EmptySection {
+		p.uint(9, 1)
+	}
+	for _, r := range h.Refinements {
+		var q protoBuffer
+		q.int(1, int64(r.Line))
+		q.int(2, int64(r.Start))
+		q.int(3, int64(r.End))
+		p.message(10, q.b)
+	}
+	return p.b
+}
+
+func hunkFromProto(data []byte) (*Hunk, error) {
+	h := &Hunk{}
+	err := parseProto(data, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1:
+			h.OrigStartLine, err = f.int32()
+		case 2:
+			h.OrigLines, err = f.int32()
+		case 3:
+			h.OrigNoNewlineAt, err = f.int32()
+		case 4:
+			h.NewStartLine, err = f.int32()
+		case 5:
+			h.NewLines, err = f.int32()
+		case 6:
+			h.Section, err = f.string()
+		case 7:
+			h.StartPosition, err = f.int32()
+		case 8:
+			h.Body, err = f.bytes()
+		case 9:
+			var v uint64
+			v, err = f.varint()
+			h.EmptySection = v != 0
+		case 10:
+			var b []byte
+			if b, err = f.bytes(); err == nil {
+				var r Refinement
+				r, err = refinementFromProto(b)
+				h.Refinements = append(h.Refinements, r)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func refinementFromProto(data []byte) (Refinement, error) {
+	var r Refinement
+	err := parseProto(data, func(f protoField) error {
+		v, err := f.int()
+		switch f.num {
+		case 1:
+			r.Line = int(v)
+		case 2:
+			r.Start = int(v)
+		case 3:
+			r.End = int(v)
+		default:
+			return nil
+		}
+		return err
+	})
+	return r, err
+}
+
+func binaryHunkToProto(h *BinaryHunk) []byte {
+	var p protoBuffer
+	p.uint(1, uint64(h.Type))
+	p.bytes(2, h.Data)
+	return p.b
+}
+
+func binaryPatchFromProto(data []byte) (*BinaryPatch, error) {
+	bp := &BinaryPatch{}
+	err := parseProto(data, func(f protoField) error {
+		if f.num != 1 && f.num != 2 {
+			return nil
+		}
+		b, err := f.bytes()
+		if err != nil {
+			return err
+		}
+		h := &BinaryHunk{}
+		err = parseProto(b, func(f protoField) error {
+			var err error
+			switch f.num {
+			case 1:
+				var v uint64
+				v, err = f.varint()
+				h.Type = BinaryHunkType(v)
+			case 2:
+				h.Data, err = f.bytes()
+			}
+			return err
+		})
+		if f.num == 1 {
+			bp.Forward = h
+		} else {
+			bp.Reverse = h
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bp, nil
+}
+
+// timestampToProto encodes t as a google.protobuf.Timestamp.
+func timestampToProto(t time.Time) []byte {
+	var p protoBuffer
+	p.int(1, t.Unix())
+	p.int(2, int64(t.Nanosecond()))
+	return p.b
+}
+
+// inZone returns t in the time zone offset seconds east of UTC.
+func inZone(t *time.Time, offset int64) *time.Time {
+	if t == nil {
+		return nil
+	}
+	loc := time.UTC
+	if offset != 0 {
+		loc = time.FixedZone("", int(offset))
+	}
+	tz := t.In(loc)
+	return &tz
+}
+
+// The protocol buffer wire types used by diff.proto.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// A protoBuffer builds a protocol buffer message. Fields with zero values
+// are omitted, as in proto3, except by message.
+type protoBuffer struct {
+	b []byte
+}
+
+func (p *protoBuffer) varint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	p.b = append(p.b, buf[:n]...)
+}
+
+func (p *protoBuffer) uint(num int, v uint64) {
+	if v != 0 {
+		p.varint(uint64(num)<<3 | protoVarint)
+		p.varint(v)
+	}
+}
+
+// int encodes an int32 or int64 field, whose negative values take 10 bytes.
+func (p *protoBuffer) int(num int, v int64) {
+	p.uint(num, uint64(v))
+}
+
+// message encodes a length-delimited field, even if it is empty.
+func (p *protoBuffer) message(num int, b []byte) {
+	p.varint(uint64(num)<<3 | protoBytes)
+	p.varint(uint64(len(b)))
+	p.b = append(p.b, b...)
+}
+
+func (p *protoBuffer) bytes(num int, b []byte) {
+	if len(b) > 0 {
+		p.message(num, b)
+	}
+}
+
+func (p *protoBuffer) string(num int, s string) {
+	p.bytes(num, []byte(s))
+}
+
+// A protoField is a field of a protocol buffer message.
+type protoField struct {
+	num      int
+	wireType int
+	v        uint64 // for the varint and fixed wire types
+	b        []byte // for the length-delimited wire type
+}
+
+// parseProto calls fn with each field of the message in data.
+func parseProto(data []byte, fn func(protoField) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 || key>>3 == 0 {
+			return ErrBadProto
+		}
+		data = data[n:]
+		f := protoField{num: int(key >> 3), wireType: int(key & 7)}
+		switch f.wireType {
+		case protoVarint:
+			if f.v, n = binary.Uvarint(data); n <= 0 {
+				return ErrBadProto
+			}
+			data = data[n:]
+		case protoFixed64, protoFixed32:
+			size := 8
+			if f.wireType == protoFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return ErrBadProto
+			}
+			var buf [8]byte
+			copy(buf[:], data[:size])
+			f.v = binary.LittleEndian.Uint64(buf[:])
+			data = data[size:]
+		case protoBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return ErrBadProto
+			}
+			f.b = data[n : n+int(size)]
+			data = data[n+int(size):]
+		default:
+			return ErrBadProto
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f protoField) varint() (uint64, error) {
+	if f.wireType != protoVarint {
+		return 0, ErrBadProto
+	}
+	return f.v, nil
+}
+
+func (f protoField) int() (int64, error) {
+	v, err := f.varint()
+	return int64(v), err
+}
+
+func (f protoField) int32() (int32, error) {
+	v, err := f.varint()
+	return int32(v), err
+}
+
+func (f protoField) uint32() (uint32, error) {
+	v, err := f.varint()
+	return uint32(v), err
+}
+
+func (f protoField) bytes() ([]byte, error) {
+	if f.wireType != protoBytes {
+		return nil, ErrBadProto
+	}
+	return f.b, nil
+}
+
+func (f protoField) string() (string, error) {
+	b, err := f.bytes()
+	return string(b), err
+}
+
+// timestamp decodes a google.protobuf.Timestamp field, in UTC.
+func (f protoField) timestamp() (*time.Time, error) {
+	b, err := f.bytes()
+	if err != nil {
+		return nil, err
+	}
+	var seconds, nanos int64
+	err = parseProto(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1:
+			seconds, err = f.int()
+		case 2:
+			nanos, err = f.int()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	t := time.Unix(seconds, nanos).UTC()
+	return &t, nil
+}
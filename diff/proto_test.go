@@ -0,0 +1,131 @@
+package diff
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestToProto(t *testing.T) {
+	filenames, err := filepath.Glob(filepath.Join("testdata", "*.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range filenames {
+		diffData, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diffs, err := ParseMultiFileDiff(diffData)
+		if err != nil {
+			continue
+		}
+		for _, d := range diffs {
+			got, err := FromProto(ToProto(d))
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			// Comparing the encodings (and printed diffs, which include the
+			// time zones) is much faster than comparing the diffs for large
+			// ones. Empty and nil slices are not distinguished.
+			want, _ := PrintFileDiff(d)
+			printed, _ := PrintFileDiff(got)
+			if string(ToProto(got)) != string(ToProto(d)) || string(printed) != string(want) {
+				t.Errorf("%s: decoded diff mismatch (-want +got):\n%s", filename, cmp.Diff(d, got))
+			}
+		}
+	}
+}
+
+func TestToProto_Encoding(t *testing.T) {
+	d := &FileDiff{
+		OrigName: "a",
+		NewName:  "b",
+		Hunks:    []*Hunk{{OrigStartLine: 1, OrigLines: 1, NewStartLine: 1, NewLines: 1, Body: []byte(" x\n")}},
+	}
+	want := "\x0a\x01a\x1a\x01b\x32\x0d\x08\x01\x10\x01\x20\x01\x28\x01\x42\x03 x\n"
+	if got := string(ToProto(d)); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Unknown fields (here, field 99) are skipped.
+	got, err := FromProto([]byte(want + "\x98\x06\x01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(got, d) {
+		t.Errorf("decoded diff mismatch (-want +got):\n%s", cmp.Diff(d, got))
+	}
+
+	// The object names of a diff from git diff come from its "index" line.
+	d = parseFileDiffString(t, "diff --git a/f b/f\nindex de98044..a7bc997 100644\n--- a/f\n+++ b/f\n@@ -1,1 +1,1 @@\n-a\n+b\n")
+	got, err = FromProto(ToProto(d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.OrigSHA != "de98044" || got.NewSHA != "a7bc997" {
+		t.Errorf("got object names %q and %q, want %q and %q", got.OrigSHA, got.NewSHA, "de98044", "a7bc997")
+	}
+
+	if _, err := FromProto([]byte(want[:len(want)-1])); err != ErrBadProto {
+		t.Errorf("got error %v for a truncated message, want %v", err, ErrBadProto)
+	}
+	if _, err := FromProto([]byte("\x08\x01")); err != ErrBadProto {
+		t.Errorf("got error %v for a field of the wrong type, want %v", err, ErrBadProto)
+	}
+}
+
+func TestToProto_Time(t *testing.T) {
+	origTime := time.Date(2020, 1, 2, 3, 4, 5, 6, time.FixedZone("", -7*60*60))
+	newTime := time.Unix(0, 0).UTC()
+	d := &FileDiff{OrigTime: &origTime, NewTime: &newTime}
+	got, err := FromProto(ToProto(d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.OrigTime.Equal(origTime) || got.OrigTime.Format(time.RFC3339Nano) != origTime.Format(time.RFC3339Nano) {
+		t.Errorf("got orig time %s, want %s", got.OrigTime, origTime)
+	}
+	if got.NewTime == nil || !got.NewTime.Equal(newTime) {
+		t.Errorf("got new time %v, want %s", got.NewTime, newTime)
+	}
+}
+
+func TestToProto_NonUTF8(t *testing.T) {
+	// A Latin-1 name and section heading, which are not valid UTF-8.
+	d := parseFileDiffString(t, "diff --git a/caf\xe9 b/caf\xe9\n--- a/caf\xe9\n+++ b/caf\xe9\n@@ -1 +1 @@ \xe9t\xe9\n-x\n+y\n")
+	got, err := FromProto(ToProto(d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(got, d) {
+		t.Errorf("decoded diff mismatch (-want +got):\n%s", cmp.Diff(d, got))
+	}
+
+	// The fields that hold them are declared as bytes, as proto3 strings
+	// must be valid UTF-8.
+	schema, err := ioutil.ReadFile("diff.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"bytes orig_name = 1;", "bytes new_name = 3;", "repeated bytes extended = 5;", "bytes status = 13;", "bytes section = 6;"} {
+		if !strings.Contains(string(schema), field) {
+			t.Errorf("diff.proto does not declare %q", field)
+		}
+	}
+}
+
+func TestStatToProto(t *testing.T) {
+	s := Stat{Added: -1, Changed: 0, Deleted: 300}
+	want := "\x08\xff\xff\xff\xff\xff\xff\xff\xff\xff\x01\x18\xac\x02"
+	if got := string(StatToProto(s)); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, err := StatFromProto([]byte(want)); err != nil || got != s {
+		t.Errorf("got %+v (error %v), want %+v", got, err, s)
+	}
+}
@@ -0,0 +1,279 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Patch is a patch of a series, such as one of the commits output by git
+// format-patch, for RangeDiff.
+type Patch struct {
+	// ID identifies the patch, such as its abbreviated commit name (it
+	// may be empty).
+	ID string
+	// Title describes the patch, such as the subject of its commit.
+	Title string
+	// Diffs are the diffs of the files the patch changes.
+	Diffs []*FileDiff
+}
+
+// A RangeDiffEntry is an entry of the report of RangeDiff: a patch of the
+// old series matched with one of the new series, or a patch of either
+// series that was not matched.
+type RangeDiffEntry struct {
+	// Old and New are the 0-based indexes of the patches in the old and new
+	// series, or -1 if the entry is for a patch of the other series only.
+	Old, New int
+	// Status is '=' for matched patches that are the same, '!' for matched
+	// patches that differ, '<' for a patch only in the old series and '>'
+	// for one only in the new series, as printed by git range-diff.
+	Status byte
+	// Hunks are the differences between the texts of the matched patches
+	// whose status is '!' (see RangeDiff). The section heading of each hunk
+	// is the last file or hunk header before it in the text of the old
+	// patch, such as "f.go" or "f.go: func main() {".
+	Hunks []*Hunk
+}
+
+// rangeDiffCreationFactor is the percentage of the size of a patch that
+// the differences from another patch may reach for the two to be matched,
+// as in git range-diff --creation-factor.
+const rangeDiffCreationFactor = 60
+
+// RangeDiff compares two versions of a series of patches, such as a branch
+// before and after a rebase, like git range-diff. Patches with the same
+// patch ID (see PatchID) are matched first. The other patches are then
+// matched by the size of the differences between their texts, the diffs
+// they contain without line numbers and metadata, from the most similar
+// pair on; as in git, a pair is only matched if the differences are
+// smaller than 60% of the sizes of the two patches combined.
+//
+// The report lists the patches of the new series in order, each matched
+// patch along with its match, and the patches of the old series that were
+// not matched where they were in the old series, as git range-diff does.
+func RangeDiff(old, new []Patch) ([]RangeDiffEntry, error) {
+	oldTexts, oldIDs, err := rangeDiffTexts(old)
+	if err != nil {
+		return nil, err
+	}
+	newTexts, newIDs, err := rangeDiffTexts(new)
+	if err != nil {
+		return nil, err
+	}
+
+	oldMatch, newMatch := make([]int, len(old)), make([]int, len(new))
+	for i := range oldMatch {
+		oldMatch[i] = -1
+	}
+	for j := range newMatch {
+		newMatch[j] = -1
+	}
+	for i := range old {
+		for j := range new {
+			if newMatch[j] < 0 && oldIDs[i] == newIDs[j] {
+				oldMatch[i], newMatch[j] = j, i
+				break
+			}
+		}
+	}
+
+	type candidate struct{ i, j, cost int }
+	var candidates []candidate
+	for i := range old {
+		if oldMatch[i] >= 0 {
+			continue
+		}
+		for j := range new {
+			if newMatch[j] >= 0 {
+				continue
+			}
+			cost := changedLines(oldTexts[i], newTexts[j])
+			creationCost := (lineCount(oldTexts[i]) + lineCount(newTexts[j])) * rangeDiffCreationFactor / 100
+			if cost < creationCost {
+				candidates = append(candidates, candidate{i, j, cost})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool { return candidates[a].cost < candidates[b].cost })
+	for _, c := range candidates {
+		if oldMatch[c.i] < 0 && newMatch[c.j] < 0 {
+			oldMatch[c.i], newMatch[c.j] = c.j, c.i
+		}
+	}
+
+	var entries []RangeDiffEntry
+	shown := make([]bool, len(old))
+	for i, j := 0, 0; i < len(old) || j < len(new); {
+		switch {
+		case i < len(old) && shown[i]:
+			i++
+		case i < len(old) && oldMatch[i] < 0:
+			entries = append(entries, RangeDiffEntry{Old: i, New: -1, Status: '<'})
+			i++
+		case newMatch[j] < 0:
+			entries = append(entries, RangeDiffEntry{Old: -1, New: j, Status: '>'})
+			j++
+		default:
+			e := RangeDiffEntry{Old: newMatch[j], New: j, Status: '='}
+			if !bytes.Equal(oldTexts[e.Old], newTexts[j]) {
+				e.Status = '!'
+				e.Hunks = rangeDiffHunks(oldTexts[e.Old], newTexts[j])
+			}
+			entries = append(entries, e)
+			shown[e.Old] = true
+			j++
+		}
+	}
+	return entries, nil
+}
+
+// PrintRangeDiff prints the report of RangeDiff for old and new like git
+// range-diff: a line per entry giving the number and ID of each patch (or
+// dashes for a missing patch), the status and the title of the patch (of
+// the new series, if there is one), such as "1:  abc1234 ! 1:  def5678
+// Fix the frobnicator", followed by the differences of patches that
+// differ, indented by 4 spaces. As in git, the headers of the differences
+// give their section headings instead of line numbers.
+func PrintRangeDiff(old, new []Patch) ([]byte, error) {
+	entries, err := RangeDiff(old, new)
+	if err != nil {
+		return nil, err
+	}
+	numWidth := len(strconv.Itoa(len(old)))
+	if w := len(strconv.Itoa(len(new))); w > numWidth {
+		numWidth = w
+	}
+	idWidth := 0
+	for _, ps := range [][]Patch{old, new} {
+		for _, p := range ps {
+			if len(p.ID) > idWidth {
+				idWidth = len(p.ID)
+			}
+		}
+	}
+	column := func(ps []Patch, i int) string {
+		num, id := "-", strings.Repeat("-", idWidth)
+		if i >= 0 {
+			num, id = strconv.Itoa(i+1), ps[i].ID
+		}
+		s := fmt.Sprintf("%*s:", numWidth, num)
+		if idWidth > 0 {
+			s += "  " + fmt.Sprintf("%-*s", idWidth, id)
+		}
+		return s
+	}
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		title := ""
+		if e.New >= 0 {
+			title = new[e.New].Title
+		} else {
+			title = old[e.Old].Title
+		}
+		fmt.Fprintf(&buf, "%s %c %s %s\n", column(old, e.Old), e.Status, column(new, e.New), title)
+		for _, h := range e.Hunks {
+			buf.WriteString("    @@")
+			if h.Section != "" {
+				buf.WriteString(" " + h.Section)
+			}
+			buf.WriteByte('\n')
+			for _, line := range bytes.SplitAfter(h.Body, []byte{'\n'}) {
+				if len(line) > 0 {
+					buf.WriteString("    ")
+					buf.Write(line)
+				}
+			}
+			if len(h.Body) > 0 && h.Body[len(h.Body)-1] != '\n' {
+				buf.WriteByte('\n')
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// rangeDiffTexts returns the texts and patch IDs of the patches ps. As in
+// git, the text of a patch has a "## path ##" line for each file it changes
+// (with the path as in a diff stat, followed by "(new)" or "(deleted)" for
+// added or deleted files), followed by the hunks of the file with
+// "@@ path: section" lines (or "@@" lines for hunks without a section
+// heading) instead of their headers.
+func rangeDiffTexts(ps []Patch) (texts [][]byte, ids []string, err error) {
+	for _, p := range ps {
+		var buf bytes.Buffer
+		for _, d := range p.Diffs {
+			if d.Hunks == nil && d.RawHunks != nil {
+				return nil, nil, errHunksNotLoaded
+			}
+			path := d.path()
+			switch d.Type() {
+			case Added:
+				buf.WriteString("## " + path + " (new) ##\n")
+			case Deleted:
+				buf.WriteString("## " + path + " (deleted) ##\n")
+			default:
+				buf.WriteString("## " + path + " ##\n")
+			}
+			for _, h := range d.Hunks {
+				buf.WriteString("@@")
+				if h.Section != "" {
+					buf.WriteString(" " + path + ": " + h.Section)
+				}
+				buf.WriteByte('\n')
+				buf.Write(h.Body)
+				if len(h.Body) > 0 && h.Body[len(h.Body)-1] != '\n' {
+					buf.WriteByte('\n')
+				}
+			}
+		}
+		id, err := PatchID(p.Diffs)
+		if err != nil {
+			return nil, nil, err
+		}
+		texts = append(texts, buf.Bytes())
+		ids = append(ids, id)
+	}
+	return texts, ids, nil
+}
+
+// rangeDiffHunks returns the hunks of the diff between the texts of two
+// patches, with the last file or hunk header of the old text before each
+// hunk as its section heading.
+func rangeDiffHunks(old, new []byte) []*Hunk {
+	oldLines := splitLines(old)
+	hunks := computeHunks(oldLines, splitLines(new), &diffOptions{context: 3})
+	for _, h := range hunks {
+		origLine, _ := h.lineStarts()
+		for i := origLine - 2; i >= 0; i-- {
+			text := string(oldLines[i].text)
+			if strings.HasPrefix(text, "## ") {
+				h.Section = strings.TrimSuffix(text[len("## "):], " ##")
+				break
+			}
+			if strings.HasPrefix(text, "@@") {
+				h.Section = strings.TrimPrefix(text[len("@@"):], " ")
+				break
+			}
+		}
+	}
+	return hunks
+}
+
+// changedLines returns the number of lines removed from a and added in b by
+// a diff between them.
+func changedLines(a, b []byte) int {
+	n := 0
+	for _, op := range computeEdits(splitLines(a), splitLines(b), Myers) {
+		if op.op != ' ' {
+			n++
+		}
+	}
+	return n
+}
+
+func lineCount(text []byte) int {
+	return len(splitLines(text))
+}
@@ -0,0 +1,116 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPrintRangeDiff(t *testing.T) {
+	patch := func(id, title, diff string) Patch {
+		ds, err := ParseMultiFileDiff([]byte(diff))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Patch{ID: id, Title: title, Diffs: ds}
+	}
+	changeThree := `diff --git a/f b/f
+index 0ff3bbb..d1810ac 100644
+--- a/f
++++ b/f
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+`
+	changeFifteen := `diff --git a/f b/f
+index d1810ac..34689b6 100644
+--- a/f
++++ b/f
+@@ -12,7 +12,7 @@ three
+ 12
+ 13
+ 14
+-15
++fifteen
+ 16
+ 17
+ 18
+`
+	old := []Patch{
+		patch("098af1c", "Change three", changeThree),
+		patch("dcf0d2e", "Add g", `diff --git a/g b/g
+new file mode 100644
+index 0000000..3e75765
+--- /dev/null
++++ b/g
+@@ -0,0 +1 @@
++new
+`),
+		patch("9190ec0", "Change fifteen", changeFifteen),
+	}
+	new := []Patch{
+		patch("098af1c", "Change three", changeThree),
+		patch("866d125", "Change fifteen", `diff --git a/f b/f
+index d1810ac..a3d0e18 100644
+--- a/f
++++ b/f
+@@ -12,7 +12,7 @@ three
+ 12
+ 13
+ 14
+-15
++FIFTEEN
+ 16
+ 17
+ 18
+`),
+		patch("f5751f0", "Add h", `diff --git a/h b/h
+new file mode 100644
+index 0000000..6e9f0da
+--- /dev/null
++++ b/h
+@@ -0,0 +1 @@
++h
+`),
+	}
+
+	printed, err := PrintRangeDiff(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The output of git range-diff.
+	want := `1:  098af1c = 1:  098af1c Change three
+2:  dcf0d2e < -:  ------- Add g
+3:  9190ec0 ! 2:  866d125 Change fifteen
+    @@ f: three
+      13
+      14
+     -15
+    -+fifteen
+    ++FIFTEEN
+      16
+      17
+      18
+-:  ------- > 3:  f5751f0 Add h
+`
+	if got := string(printed); got != want {
+		t.Errorf("range-diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	entries, err := RangeDiff(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statuses []byte
+	for _, e := range entries {
+		statuses = append(statuses, e.Status)
+	}
+	if want := "=<!>"; string(statuses) != want {
+		t.Errorf("got statuses %q, want %q", statuses, want)
+	}
+}
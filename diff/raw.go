@@ -0,0 +1,209 @@
+package diff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseRaw parses the output of git diff --raw, which lists changed files
+// without their contents:
+//
+//	:100644 100644 bcd1234 0123456 M	file0
+//	:100644 100644 abcd123 1234567 R086	file1	file3
+//
+// Each line yields a FileDiff with its modes, object names and status
+// populated, but no hunks. As in parsed unified diffs, the name of the
+// missing side of an added or deleted file is "/dev/null". Blank lines are
+// ignored.
+func ParseRaw(data []byte) ([]*FileDiff, error) {
+	var ds []*FileDiff
+	var offset int64
+	for i, line := range bytes.Split(data, []byte{'\n'}) {
+		lineOffset := offset
+		offset += int64(len(line)) + 1
+
+		line = dropCR(line)
+		if len(line) == 0 {
+			continue
+		}
+		d, err := parseRawLine(string(line))
+		if err != nil {
+			return nil, &ParseError{Line: i + 1, Offset: lineOffset, Err: err}
+		}
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
+
+// ErrBadRawLine is when a line of git diff --raw output is malformed.
+var ErrBadRawLine = errors.New("bad raw diff line")
+
+// parseRawLine parses a single line of git diff --raw output.
+func parseRawLine(line string) (*FileDiff, error) {
+	if !strings.HasPrefix(line, ":") || strings.HasPrefix(line, "::") {
+		// "::" introduces the combined format used for merges, which is not
+		// supported.
+		return nil, ErrBadRawLine
+	}
+
+	// The metadata is separated from the paths by a tab, and the paths
+	// from each other. Fall back to a space for hand-written input, in
+	// which case a rename's paths must not contain spaces.
+	var meta []string
+	var paths []string
+	if i := strings.IndexByte(line, '\t'); i >= 0 {
+		meta = strings.Fields(line[1:i])
+		paths = strings.Split(line[i+1:], "\t")
+	} else {
+		fields := strings.SplitN(line[1:], " ", 6)
+		if len(fields) < 6 {
+			return nil, ErrBadRawLine
+		}
+		meta, paths = fields[:5], fields[5:]
+		if s := meta[4]; s != "" && (s[0] == 'R' || s[0] == 'C') {
+			paths = strings.SplitN(paths[0], " ", 2)
+		}
+	}
+	if len(meta) != 5 || len(meta[4]) == 0 {
+		return nil, ErrBadRawLine
+	}
+
+	d := &FileDiff{
+		OrigSHA: meta[2],
+		NewSHA:  meta[3],
+		Status:  meta[4],
+	}
+	for i, mode := range []*uint32{&d.OrigMode, &d.NewMode} {
+		m, err := strconv.ParseUint(meta[i], 8, 32)
+		if err != nil {
+			return nil, ErrBadRawLine
+		}
+		*mode = uint32(m)
+	}
+	for i, p := range paths {
+		if unquoted, err := strconv.Unquote(p); err == nil {
+			paths[i] = unquoted
+		}
+	}
+
+	switch d.Status[0] {
+	case 'R', 'C':
+		// Renames and copies carry a similarity score and both paths.
+		if _, err := strconv.Atoi(d.Status[1:]); err != nil && len(d.Status) > 1 {
+			return nil, ErrBadRawLine
+		}
+		if len(paths) != 2 {
+			return nil, ErrBadRawLine
+		}
+		d.OrigName, d.NewName = paths[0], paths[1]
+	default:
+		if len(paths) != 1 || len(d.Status) != 1 {
+			return nil, ErrBadRawLine
+		}
+		d.OrigName, d.NewName = paths[0], paths[0]
+		switch d.Status {
+		case "A":
+			d.OrigName = devNull
+		case "D":
+			d.NewName = devNull
+		}
+	}
+	if d.OrigName == "" || d.NewName == "" {
+		return nil, ErrBadRawLine
+	}
+	return d, nil
+}
+
+// PrintRaw prints ds in the format of git diff --raw, which ParseRaw parses:
+// a line per file giving its modes, object names, status and paths. The
+// status is that of diffs from ParseRaw, and is otherwise derived from the
+// change type and any "similarity index" extended header. The object names
+// of diffs from git diff are taken from their "index" extended header.
+// Missing modes and object names are printed as zeros, and paths without
+// git's "a/" and "b/" prefixes, quoted like the names in file headers (see
+// WithQuoter). "Only in" messages are omitted. With WithNULTerminated, the
+// output is that of git diff --raw -z instead.
+func PrintRaw(ds []*FileDiff, opts ...PrintOption) ([]byte, error) {
+	o := newPrintOptions(opts)
+	var buf bytes.Buffer
+	for _, d := range ds {
+		if d.isOnlyIn() {
+			continue
+		}
+		status := rawStatus(d)
+		origSHA, newSHA := d.objectNames()
+		fmt.Fprintf(&buf, ":%06o %06o %s %s %s", d.OrigMode, d.NewMode, rawSHA(origSHA), rawSHA(newSHA), status)
+		orig, new := d.paths()
+		paths := []string{new}
+		switch status[0] {
+		case 'R', 'C':
+			paths = []string{orig, new}
+		case 'D':
+			paths = []string{orig}
+		}
+		for _, p := range paths {
+			buf.WriteByte(o.separator('\t'))
+			buf.WriteString(o.path(p))
+		}
+		buf.WriteByte(o.separator('\n'))
+	}
+	return buf.Bytes(), nil
+}
+
+// objectNames returns the object names of d's original and new blobs:
+// OrigSHA and NewSHA, or if both are empty, the names on the "index"
+// extended header of a diff parsed from git diff, if it has one.
+func (d *FileDiff) objectNames() (orig, new string) {
+	if d.OrigSHA != "" || d.NewSHA != "" {
+		return d.OrigSHA, d.NewSHA
+	}
+	for _, xheader := range gitExtendedHeaders(d.Extended) {
+		if !strings.HasPrefix(xheader, "index ") {
+			continue
+		}
+		if fields := strings.Fields(xheader); len(fields) >= 2 {
+			if names := strings.Split(fields[1], ".."); len(names) == 2 {
+				return names[0], names[1]
+			}
+		}
+	}
+	return "", ""
+}
+
+// rawStatus returns the status of d in the format of git diff --raw.
+func rawStatus(d *FileDiff) string {
+	if d.Status != "" {
+		return d.Status
+	}
+	switch d.Type() {
+	case Added:
+		return "A"
+	case Deleted:
+		return "D"
+	case Renamed, Copied:
+		status := "R"
+		if d.Type() == Copied {
+			status = "C"
+		}
+		for _, xheader := range d.Extended {
+			if score := strings.TrimPrefix(xheader, "similarity index "); score != xheader {
+				if n, err := strconv.Atoi(strings.TrimSuffix(score, "%")); err == nil {
+					status += fmt.Sprintf("%03d", n)
+				}
+			}
+		}
+		return status
+	}
+	return "M"
+}
+
+// rawSHA returns sha, or zeros if it is empty.
+func rawSHA(sha string) string {
+	if sha == "" {
+		return "0000000"
+	}
+	return sha
+}
@@ -0,0 +1,107 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseRaw(t *testing.T) {
+	input := ":100644 100644 bcd1234 0123456 M\tfile0\n" +
+		":100644 100644 abcd123 1234567 C68\tfile1\tfile2\n" +
+		":100644 100755 abcd123 1234567 R086\tfile1\t\"dir/new\\tname\"\n" +
+		":000000 100644 0000000 1234567 A\tfile4\n" +
+		":100644 000000 1234567 0000000 D\tfile5\n" +
+		"\n" +
+		":100644 100644 bcd1234 0123456 M file with space\r\n"
+	want := []*FileDiff{
+		{OrigName: "file0", NewName: "file0", OrigMode: 0100644, NewMode: 0100644, OrigSHA: "bcd1234", NewSHA: "0123456", Status: "M"},
+		{OrigName: "file1", NewName: "file2", OrigMode: 0100644, NewMode: 0100644, OrigSHA: "abcd123", NewSHA: "1234567", Status: "C68"},
+		{OrigName: "file1", NewName: "dir/new\tname", OrigMode: 0100644, NewMode: 0100755, OrigSHA: "abcd123", NewSHA: "1234567", Status: "R086"},
+		{OrigName: "/dev/null", NewName: "file4", OrigMode: 0, NewMode: 0100644, OrigSHA: "0000000", NewSHA: "1234567", Status: "A"},
+		{OrigName: "file5", NewName: "/dev/null", OrigMode: 0100644, NewMode: 0, OrigSHA: "1234567", NewSHA: "0000000", Status: "D"},
+		{OrigName: "file with space", NewName: "file with space", OrigMode: 0100644, NewMode: 0100644, OrigSHA: "bcd1234", NewSHA: "0123456", Status: "M"},
+	}
+	got, err := ParseRaw([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("got - want:\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestParseRaw_Error(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		wantLine int
+	}{
+		"not raw":      {input: "diff --git a/f b/f\n", wantLine: 1},
+		"combined":     {input: "::100644 100644 100644 fabadb8 cc95eb0 4866510 MM\tdesc.c\n", wantLine: 1},
+		"bad mode":     {input: ":100644 100644 a b M\tf\n:10064x 100644 a b M\tf\n", wantLine: 2},
+		"missing path": {input: ":100644 100644 a b R100\told\n", wantLine: 1},
+		"extra path":   {input: ":100644 100644 a b M\tf\tg\n", wantLine: 1},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			_, err := ParseRaw([]byte(test.input))
+			pe, ok := err.(*ParseError)
+			if !ok {
+				t.Fatalf("got error %v, want *ParseError", err)
+			}
+			if pe.Err != ErrBadRawLine || pe.Line != test.wantLine {
+				t.Errorf("got error %v on line %d, want %v on line %d", pe.Err, pe.Line, ErrBadRawLine, test.wantLine)
+			}
+		})
+	}
+}
+
+func TestPrintRaw(t *testing.T) {
+	input := ":100644 100644 bcd1234 0123456 M\tfile0\n" +
+		":100644 100755 abcd123 1234567 R086\tfile1\t\"dir/new\\tname\"\n" +
+		":000000 100644 0000000 1234567 A\tfile4\n" +
+		":100644 000000 1234567 0000000 D\tfile5\n"
+	ds, err := ParseRaw([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed, err := PrintRaw(ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(printed); got != input {
+		t.Errorf("printed raw diff mismatch (-want +got):\n%s", cmp.Diff(input, got))
+	}
+
+	printed, err = PrintRaw(ds, WithNULTerminated())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ":100644 100644 bcd1234 0123456 M\x00file0\x00" +
+		":100644 100755 abcd123 1234567 R086\x00file1\x00dir/new\tname\x00" +
+		":000000 100644 0000000 1234567 A\x00file4\x00" +
+		":100644 000000 1234567 0000000 D\x00file5\x00"
+	if got := string(printed); got != want {
+		t.Errorf("printed raw diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	// Unified diffs get their status from their change type.
+	d := parseFileDiffString(t, "diff --git a/f b/g\nsimilarity index 86%\nrename from f\nrename to g\n--- a/f\n+++ b/g\n@@ -1,1 +1,1 @@\n-a\n+b\n")
+	printed, err = PrintRaw([]*FileDiff{d})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(printed), ":000000 000000 0000000 0000000 R086\tf\tg\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Diffs from git diff get their object names from the "index" line.
+	d = parseFileDiffString(t, "diff --git a/f b/f\nindex de98044..a7bc997 100644\n--- a/f\n+++ b/f\n@@ -1,1 +1,1 @@\n-a\n+b\n")
+	printed, err = PrintRaw([]*FileDiff{d})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(printed), ":100644 100644 de98044 a7bc997 M\tf\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -18,15 +18,25 @@ func newLineReader(r io.Reader) *lineReader {
 type lineReader struct {
 	reader *bufio.Reader
 
-	cachedNextLine    []byte
-	cachedNextLineErr error
+	// data is the input, if the lines are read from it without copying
+	// them (see WithZeroCopy) rather than from reader.
+	data []byte
+	pos  int // the offset in data of the next line to read
+
+	// lineStart is the offset in data of the line last returned by
+	// readLine.
+	lineStart int
+
+	cachedNextLine      []byte
+	cachedNextLineErr   error
+	cachedNextLineStart int
 }
 
 // readLine returns the next unconsumed line and advances the internal cache of
 // the lineReader.
 func (l *lineReader) readLine() ([]byte, error) {
 	if l.cachedNextLine == nil && l.cachedNextLineErr == nil {
-		l.cachedNextLine, l.cachedNextLineErr = readLine(l.reader)
+		l.cacheNextLine()
 	}
 
 	if l.cachedNextLineErr != nil {
@@ -34,12 +44,36 @@ func (l *lineReader) readLine() ([]byte, error) {
 	}
 
 	next := l.cachedNextLine
+	l.lineStart = l.cachedNextLineStart
 
-	l.cachedNextLine, l.cachedNextLineErr = readLine(l.reader)
+	l.cacheNextLine()
 
 	return next, nil
 }
 
+// cacheNextLine reads the next line into the cache.
+func (l *lineReader) cacheNextLine() {
+	if l.reader != nil {
+		l.cachedNextLine, l.cachedNextLineErr = readLine(l.reader)
+		return
+	}
+
+	l.cachedNextLineStart = l.pos
+	if l.pos == len(l.data) {
+		l.cachedNextLine, l.cachedNextLineErr = nil, io.EOF
+		return
+	}
+	rest := l.data[l.pos:]
+	i := bytes.IndexByte(rest, '\n')
+	if i == -1 {
+		l.pos = len(l.data)
+		l.cachedNextLine, l.cachedNextLineErr = rest, nil
+		return
+	}
+	l.pos += i + 1
+	l.cachedNextLine, l.cachedNextLineErr = dropCR(rest[:i]), nil
+}
+
 // nextLineStartsWith looks at the line that would be returned by the next call
 // to readLine to check whether it has the given prefix.
 //
@@ -47,7 +81,7 @@ func (l *lineReader) readLine() ([]byte, error) {
 // be used when at the end of the file.
 func (l *lineReader) nextLineStartsWith(prefix string) (bool, error) {
 	if l.cachedNextLine == nil && l.cachedNextLineErr == nil {
-		l.cachedNextLine, l.cachedNextLineErr = readLine(l.reader)
+		l.cacheNextLine()
 	}
 
 	return l.lineHasPrefix(l.cachedNextLine, prefix, l.cachedNextLineErr)
@@ -64,9 +98,12 @@ func (l *lineReader) nextLineStartsWith(prefix string) (bool, error) {
 // returned.
 func (l *lineReader) nextNextLineStartsWith(prefix string) (bool, error) {
 	if l.cachedNextLine == nil && l.cachedNextLineErr == nil {
-		l.cachedNextLine, l.cachedNextLineErr = readLine(l.reader)
+		l.cacheNextLine()
 	}
 
+	if l.reader == nil {
+		return bytes.HasPrefix(l.data[l.pos:], []byte(prefix)), nil
+	}
 	next, err := l.reader.Peek(len(prefix))
 	return l.lineHasPrefix(next, prefix, err)
 }
@@ -0,0 +1,87 @@
+package diff
+
+import "fmt"
+
+// Recontext returns a copy of d whose hunks have n context lines around
+// each change, like diff -U with n, taking the context lines from orig, the
+// contents of the original file. This grows the context of a tight patch
+// into a review-friendly one, or trims the context of a patch down to what
+// it needs to apply. The changes d makes are kept as they are; hunks whose
+// changes end up close together are merged, and hunks whose changes are
+// separated by more than 2*n context lines are split, as diff does. Each
+// hunk keeps the section heading of the hunk of d with its first change.
+//
+// The context and removed lines of each hunk of d must match orig at their
+// line numbers; if they do not, Recontext returns an *ApplyError. A diff
+// without hunks (or a binary diff) is returned as is.
+func (d *FileDiff) Recontext(orig []byte, n int) (*FileDiff, error) {
+	if d.Hunks == nil && d.RawHunks != nil {
+		return nil, errHunksNotLoaded
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("invalid number of context lines: %d", n)
+	}
+	if len(d.Hunks) == 0 {
+		return d, nil
+	}
+
+	// Build the edit script of the whole file from the hunks, keeping the
+	// lines between them, and the new file along with it. owner records the
+	// hunk of d that each insertion or deletion comes from.
+	a := splitLines(orig)
+	var b []fileLine
+	var ops []editOp
+	var owner []int
+	next := 0 // index of the first line of orig not yet in ops
+	keep := func(end int) {
+		for ; next < end; next++ {
+			ops = append(ops, editOp{op: ' ', orig: next, new: len(b)})
+			owner = append(owner, -1)
+			b = append(b, a[next])
+		}
+	}
+	matcher := &applyOptions{}
+	for i, h := range d.Hunks {
+		origLine, _ := h.lineStarts()
+		start := origLine - 1
+		if start < next || start > len(a) {
+			return nil, &ApplyError{Hunk: i + 1, Line: origLine, Err: ErrHunkOutOfRange}
+		}
+		lines := h.lines()
+		if err := matcher.matchLines(a, start, lines); err != nil {
+			err.Hunk = i + 1
+			return nil, err
+		}
+		keep(start)
+		for _, l := range lines {
+			switch l.op {
+			case ' ':
+				keep(next + 1)
+			case '-':
+				ops = append(ops, editOp{op: '-', orig: next, new: len(b)})
+				owner = append(owner, i)
+				next++
+			case '+':
+				ops = append(ops, editOp{op: '+', orig: next, new: len(b)})
+				owner = append(owner, i)
+				b = append(b, fileLine{text: l.text, noNewline: l.noNewline})
+			}
+		}
+	}
+	keep(len(a))
+
+	r := *d
+	r.Hunks = editHunks(ops, a, b, n)
+	k := 0 // index of the first op of the hunk
+	for _, h := range r.Hunks {
+		origLine, newLine := h.lineStarts()
+		for ops[k].orig != origLine-1 || ops[k].new != newLine-1 {
+			k++
+		}
+		for ops[k].op == ' ' {
+			k++
+		}
+		h.Section, h.EmptySection = d.Hunks[owner[k]].Section, d.Hunks[owner[k]].EmptySection
+	}
+	return &r, nil
+}
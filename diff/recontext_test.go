@@ -0,0 +1,116 @@
+package diff
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileDiff_Recontext(t *testing.T) {
+	var orig strings.Builder
+	for i := 1; i <= 20; i++ {
+		orig.WriteString(strconv.Itoa(i) + "\n")
+	}
+	// The diff -U1 output for changes to lines 5, 9 and 17.
+	d := parseFileDiffString(t, `--- a
++++ b
+@@ -4,3 +4,3 @@ first
+ 4
+-5
++five
+ 6
+@@ -8,3 +8,4 @@ second
+ 8
+-9
++nine
++nine and a half
+ 10
+@@ -16,3 +17,2 @@ third
+ 16
+-17
+ 18
+`)
+
+	tests := map[string]struct {
+		context int
+		want    string
+	}{
+		"grow": {
+			context: 3,
+			want: `--- a
++++ b
+@@ -2,11 +2,12 @@ first
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+-9
++nine
++nine and a half
+ 10
+ 11
+ 12
+@@ -14,7 +15,6 @@ third
+ 14
+ 15
+ 16
+-17
+ 18
+ 19
+ 20
+`,
+		},
+		"trim": {
+			context: 0,
+			want: `--- a
++++ b
+@@ -5,1 +5,1 @@ first
+-5
++five
+@@ -9,1 +9,2 @@ second
+-9
++nine
++nine and a half
+@@ -17,1 +17,0 @@ third
+-17
+`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r, err := d.Recontext([]byte(orig.String()), test.context)
+			if err != nil {
+				t.Fatal(err)
+			}
+			printed, err := PrintFileDiff(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, string(printed)); diff != "" {
+				t.Errorf("printed mismatch (-want +got):\n%s", diff)
+			}
+			applied, err := ApplyFileDiff([]byte(orig.String()), r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := ApplyFileDiff([]byte(orig.String()), d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(applied) != string(want) {
+				t.Errorf("applied mismatch (-want +got):\n%s", cmp.Diff(string(want), string(applied)))
+			}
+		})
+	}
+
+	_, err := d.Recontext([]byte(strings.Replace(orig.String(), "9\n", "nein\n", 1)), 3)
+	if e, ok := err.(*ApplyError); !ok || e.Hunk != 2 || e.Line != 9 || e.Err != ErrLineMismatch {
+		t.Errorf("got error %v, want a line mismatch at line 9 of hunk 2", err)
+	}
+}
@@ -0,0 +1,120 @@
+package diff
+
+// A Refinement marks the changed part of a removed or added line of a hunk.
+type Refinement struct {
+	// Line is the 0-based index of the line in the hunk body.
+	Line int
+	// Start and End are the byte offsets of the changed part within the
+	// line's text, excluding its '-' or '+' prefix.
+	Start, End int
+}
+
+// Refine computes the refinements of h, as NewFileDiff does with
+// WithIntraLineRefinement: within each change, the i-th removed line is
+// compared word by word with the i-th added line, and the words that differ
+// are marked. It does not set h.Refinements.
+func (h *Hunk) Refine() []Refinement {
+	return refineLines(h.lines())
+}
+
+// refineLines computes the refinements of the hunk lines. Within each change,
+// the i-th removed line is compared word by word with the i-th added line;
+// removed or added lines without a counterpart are not refined.
+func refineLines(lines []hunkLine) []Refinement {
+	var refinements []Refinement
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			i++
+			continue
+		}
+		del := i
+		for i < len(lines) && lines[i].op == '-' {
+			i++
+		}
+		ins := i
+		for i < len(lines) && lines[i].op == '+' {
+			i++
+		}
+		for k := 0; del+k < ins && ins+k < i; k++ {
+			delRanges, insRanges := diffWords(lines[del+k].text, lines[ins+k].text)
+			for _, r := range delRanges {
+				refinements = append(refinements, Refinement{Line: del + k, Start: r[0], End: r[1]})
+			}
+			for _, r := range insRanges {
+				refinements = append(refinements, Refinement{Line: ins + k, Start: r[0], End: r[1]})
+			}
+		}
+	}
+	return refinements
+}
+
+// diffWords compares a and b as sequences of words (see splitWords) and
+// returns the byte ranges of the words removed from a and added in b.
+// Adjacent changed words are reported as a single range.
+func diffWords(a, b []byte) (deleted, inserted [][2]int) {
+	aWords, bWords := splitWords(a), splitWords(b)
+	ids := make(map[string]int)
+	tokens := func(text []byte, words []int) []int {
+		ts := make([]int, len(words)-1)
+		for i := range ts {
+			key := string(text[words[i]:words[i+1]])
+			id, ok := ids[key]
+			if !ok {
+				id = len(ids)
+				ids[key] = id
+			}
+			ts[i] = id
+		}
+		return ts
+	}
+	del, ins := myers(tokens(a, aWords), tokens(b, bWords))
+	return changedRanges(del, aWords), changedRanges(ins, bWords)
+}
+
+// changedRanges returns the byte ranges covered by runs of changed words,
+// where words holds the offsets of the word boundaries.
+func changedRanges(changed []bool, words []int) [][2]int {
+	var ranges [][2]int
+	for i := 0; i < len(changed); i++ {
+		if !changed[i] {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1][1] == words[i] {
+			ranges[n-1][1] = words[i+1]
+		} else {
+			ranges = append(ranges, [2]int{words[i], words[i+1]})
+		}
+	}
+	return ranges
+}
+
+// splitWords splits text into words and returns the offsets of their
+// boundaries, starting with 0 and ending with len(text). A word is a run of
+// letters, digits, underscores and non-ASCII bytes, a run of spaces and
+// tabs, or any other single byte.
+func splitWords(text []byte) []int {
+	bounds := []int{0}
+	for i := 0; i < len(text); {
+		class := wordClass(text[i])
+		i++
+		if class != 0 {
+			for i < len(text) && wordClass(text[i]) == class {
+				i++
+			}
+		}
+		bounds = append(bounds, i)
+	}
+	return bounds
+}
+
+// wordClass returns 1 for bytes that make up words, 2 for spaces and tabs,
+// and 0 for punctuation and other bytes, which are words by themselves.
+func wordClass(c byte) int {
+	switch {
+	case c == '_' || c >= 0x80 || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		return 1
+	case c == ' ' || c == '\t':
+		return 2
+	}
+	return 0
+}
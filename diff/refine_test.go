@@ -0,0 +1,38 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewFileDiff_IntraLineRefinement(t *testing.T) {
+	orig := "first\nthe quick brown fox jumps over the lazy dog, twice, before breakfast\nlast\n"
+	new := "first\nthe quick brown fox leaps over the lazy dog, twice, before breakfast\nlast\n"
+
+	plain, err := NewFileDiff("a/f", "b/f", []byte(orig), []byte(new))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewFileDiff("a/f", "b/f", []byte(orig), []byte(new), WithIntraLineRefinement())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(d.Hunks[0].Body), string(plain.Hunks[0].Body); got != want {
+		t.Errorf("refinement changed the hunk body (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	want := []Refinement{
+		{Line: 1, Start: 20, End: 25},
+		{Line: 2, Start: 20, End: 25},
+	}
+	if got := d.Hunks[0].Refinements; !cmp.Equal(got, want) {
+		t.Errorf("refinements mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+	if got := orig[len("first\n")+20 : len("first\n")+25]; got != "jumps" {
+		t.Errorf("removed line refinement covers %q, want %q", got, "jumps")
+	}
+	if plain.Hunks[0].Refinements != nil {
+		t.Errorf("got refinements without WithIntraLineRefinement: %v", plain.Hunks[0].Refinements)
+	}
+}
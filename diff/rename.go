@@ -0,0 +1,46 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FoldRenameAndEdit combines rename, a FileDiff that only renames a file
+// (with git's "rename from" and "rename to" headers and no hunks), with
+// edit, a diff of the contents of the renamed file under its new name, into
+// a single diff that renames and modifies the file, as git prints it.
+//
+// The result has the extended headers of rename followed by those of edit
+// (except its "diff --git" line), and the hunks, timestamps and new mode
+// and object name of edit, with OrigName set to the path before the rename.
+func FoldRenameAndEdit(rename, edit *FileDiff) (*FileDiff, error) {
+	if rename.Type() != Renamed {
+		return nil, fmt.Errorf("diff of %s is not a rename", rename.OrigName)
+	}
+	if len(rename.Hunks) > 0 {
+		return nil, fmt.Errorf("rename of %s to %s has hunks", rename.OrigName, rename.NewName)
+	}
+	_, renamed := rename.paths()
+	editOrig, editNew := edit.paths()
+	if editOrig != renamed || editNew != renamed {
+		return nil, fmt.Errorf("diff of %s does not apply to %s, the new name of %s", edit.OrigName, rename.NewName, rename.OrigName)
+	}
+
+	d := *edit
+	d.OrigName = rename.OrigName
+	d.NewName = rename.NewName
+	d.Extended = append([]string(nil), rename.Extended...)
+	for _, xheader := range edit.Extended {
+		if !strings.HasPrefix(xheader, "diff --git ") {
+			d.Extended = append(d.Extended, xheader)
+		}
+	}
+	if rename.OrigMode != 0 {
+		d.OrigMode = rename.OrigMode
+	}
+	if d.NewMode == 0 {
+		d.NewMode = rename.NewMode
+	}
+	d.Status = rename.Status
+	return &d, nil
+}
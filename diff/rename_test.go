@@ -0,0 +1,85 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const foldTestRename = `diff --git a/old.txt b/new.txt
+similarity index 100%
+rename from old.txt
+rename to new.txt
+`
+
+func TestFoldRenameAndEdit(t *testing.T) {
+	rename := parseFileDiffString(t, foldTestRename)
+	edit := parseFileDiffString(t, `diff --git a/new.txt b/new.txt
+index 1111111..2222222 100644
+--- a/new.txt
++++ b/new.txt
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+`)
+
+	d, err := FoldRenameAndEdit(rename, edit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed, err := PrintFileDiff(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `diff --git a/old.txt b/new.txt
+similarity index 100%
+rename from old.txt
+rename to new.txt
+index 1111111..2222222 100644
+--- a/old.txt
++++ b/new.txt
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+`
+	if got := string(printed); got != want {
+		t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+	if got := d.Type(); got != Renamed {
+		t.Errorf("got type %s, want %s", got, Renamed)
+	}
+	if edit.OrigName != "a/new.txt" {
+		t.Errorf("edit was modified: OrigName is %q", edit.OrigName)
+	}
+}
+
+func TestFoldRenameAndEdit_Error(t *testing.T) {
+	rename := parseFileDiffString(t, foldTestRename)
+	tests := map[string]struct {
+		rename, edit string
+	}{
+		"not a rename": {
+			rename: "--- a/old.txt\n+++ b/old.txt\n@@ -1,1 +1,1 @@\n-a\n+b\n",
+			edit:   "--- a/new.txt\n+++ b/new.txt\n@@ -1,1 +1,1 @@\n-a\n+b\n",
+		},
+		"other file": {
+			edit: "--- a/other.txt\n+++ b/other.txt\n@@ -1,1 +1,1 @@\n-a\n+b\n",
+		},
+		"edit renames again": {
+			edit: "--- a/new.txt\n+++ b/newer.txt\n@@ -1,1 +1,1 @@\n-a\n+b\n",
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			r := rename
+			if test.rename != "" {
+				r = parseFileDiffString(t, test.rename)
+			}
+			if _, err := FoldRenameAndEdit(r, parseFileDiffString(t, test.edit)); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package diff
+
+import "bytes"
+
+// RecalculateCounts recounts the lines of h after its body was edited, like
+// the rediff tool, and sets OrigLines and NewLines to match: context and
+// '-' lines count as original lines, and context and '+' lines as new lines.
+// The first original and new line numbers of h are kept, and its start
+// lines are adjusted if a side becomes empty or stops being empty. A
+// "\ No newline at end of file" line in the body marks the line before it
+// as having no newline, as in a parsed diff; the body is rewritten in the
+// form the parser gives, with such marks recorded in OrigNoNewlineAt or by
+// the lack of a final newline instead.
+func (h *Hunk) RecalculateCounts() {
+	origLine, newLine := h.lineStarts()
+
+	var lines []hunkLine
+	body := h.Body
+	offset := 0
+	for len(body) > 0 {
+		var line hunkLine
+		var raw []byte
+		if i := bytes.IndexByte(body, '\n'); i == -1 {
+			raw, body = body, nil
+			line.noNewline = true
+			offset += len(raw)
+		} else {
+			raw, body = body[:i], body[i+1:]
+			offset += i + 1
+			line.noNewline = h.OrigNoNewlineAt > 0 && offset == int(h.OrigNoNewlineAt)
+		}
+		if len(raw) == 0 {
+			line.op = ' '
+		} else {
+			line.op, line.text = raw[0], raw[1:]
+		}
+		if line.op == '\\' {
+			if len(lines) > 0 {
+				lines[len(lines)-1].noNewline = true
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	h.setLines(lines)
+
+	h.OrigLines, h.NewLines = 0, 0
+	for _, l := range lines {
+		if l.op != '+' {
+			h.OrigLines++
+		}
+		if l.op != '-' {
+			h.NewLines++
+		}
+	}
+	h.OrigStartLine, h.NewStartLine = startLine(origLine, h.OrigLines), startLine(newLine, h.NewLines)
+}
+
+// startLine returns the start line in a hunk header of a side of a hunk
+// with the given first line number and number of lines: the first line
+// number, or the one before it if the side is empty, as in
+// "@@ -5,0 +6,2 @@".
+func startLine(first int, lines int32) int32 {
+	if lines == 0 {
+		return int32(first - 1)
+	}
+	return int32(first)
+}
+
+// Renumber makes the hunk headers of d match their bodies after they were
+// edited, like the rediff tool: it recounts the lines of each hunk (see
+// Hunk.RecalculateCounts) and recomputes the new start line of each hunk
+// from its original start line and the lines added and removed by the hunks
+// before it, as well as the start positions of the hunks. The original start
+// lines are kept, as they locate the hunks in the original file.
+func (d *FileDiff) Renumber() {
+	shift := 0
+	position := int32(0)
+	for _, h := range d.Hunks {
+		h.RecalculateCounts()
+		origLine, _ := h.lineStarts()
+		h.NewStartLine = startLine(origLine+shift, h.NewLines)
+		shift += int(h.NewLines) - int(h.OrigLines)
+
+		position++ // account for the hunk header line
+		h.StartPosition = position
+		position += int32(bytes.Count(h.Body, []byte{'\n'}))
+	}
+}
@@ -0,0 +1,99 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHunk_RecalculateCounts(t *testing.T) {
+	tests := map[string]struct {
+		hunk *Hunk
+		want *Hunk
+	}{
+		"added lines": {
+			hunk: &Hunk{OrigStartLine: 3, OrigLines: 2, NewStartLine: 3, NewLines: 2, Body: []byte(" a\n-b\n+c\n+d\n")},
+			want: &Hunk{OrigStartLine: 3, OrigLines: 2, NewStartLine: 3, NewLines: 3, Body: []byte(" a\n-b\n+c\n+d\n")},
+		},
+		"removed side becomes empty": {
+			hunk: &Hunk{OrigStartLine: 5, OrigLines: 1, NewStartLine: 5, NewLines: 1, Body: []byte("+x\n")},
+			want: &Hunk{OrigStartLine: 4, OrigLines: 0, NewStartLine: 5, NewLines: 1, Body: []byte("+x\n")},
+		},
+		"empty side stops being empty": {
+			hunk: &Hunk{OrigStartLine: 4, OrigLines: 1, NewStartLine: 3, NewLines: 0, Body: []byte("-x\n+y\n")},
+			want: &Hunk{OrigStartLine: 4, OrigLines: 1, NewStartLine: 4, NewLines: 1, Body: []byte("-x\n+y\n")},
+		},
+		"no newline lines": {
+			hunk: &Hunk{OrigStartLine: 1, OrigLines: 1, NewStartLine: 1, NewLines: 1, Body: []byte("-a\n\\ No newline at end of file\n+b\n\\ No newline at end of file\n")},
+			want: &Hunk{OrigStartLine: 1, OrigLines: 1, NewStartLine: 1, NewLines: 1, Body: []byte("-a\n+b"), OrigNoNewlineAt: 3},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.hunk.RecalculateCounts()
+			if diff := cmp.Diff(test.want, test.hunk); diff != "" {
+				t.Errorf("hunk mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFileDiff_Renumber(t *testing.T) {
+	orig := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	d := parseFileDiffString(t, `--- a/f
++++ b/f
+@@ -1,3 +1,3 @@
+ 1
+-2
++two
+ 3
+@@ -9,3 +9,3 @@
+ 9
+-10
++ten
+ 11
+`)
+	// Edit the hunks: add a line to the first and drop the removal from
+	// the second.
+	d.Hunks[0].Body = []byte(" 1\n-2\n+two\n+two and a half\n 3\n")
+	d.Hunks[1].Body = []byte(" 9\n 10\n+ten\n 11\n")
+	d.Renumber()
+
+	applied, err := ApplyFileDiff([]byte(orig), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(strings.Replace(orig, "2\n", "two\ntwo and a half\n", 1), "10\n", "10\nten\n", 1)
+	if string(applied) != want {
+		t.Errorf("applied mismatch (-want +got):\n%s", cmp.Diff(want, string(applied)))
+	}
+
+	printed, err := PrintFileDiff(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPrinted := `--- a/f
++++ b/f
+@@ -1,3 +1,4 @@
+ 1
+-2
++two
++two and a half
+ 3
+@@ -9,3 +10,4 @@
+ 9
+ 10
++ten
+ 11
+`
+	if diff := cmp.Diff(wantPrinted, string(printed)); diff != "" {
+		t.Errorf("printed mismatch (-want +got):\n%s", diff)
+	}
+	if got := d.Hunks[1].StartPosition; got != 7 {
+		t.Errorf("got StartPosition %d for hunk 2, want 7", got)
+	}
+	if err := d.Validate(); err != nil {
+		t.Errorf("Validate: %s", err)
+	}
+}
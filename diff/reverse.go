@@ -0,0 +1,210 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReverseFileDiff returns a diff that undoes d: applying it to the new file
+// of d gives the original file. The file names, timestamps, modes, object
+// names and hunks of d are swapped, and its git extended headers are
+// rewritten to match, so that an added file becomes a deleted one, a rename
+// from x to y becomes a rename from y to x, and so on.
+//
+// A git binary patch is reversed by swapping its hunks (see BinaryPatch); its
+// payload lines are dropped from the extended headers, so that PrintFileDiff
+// encodes the swapped hunks. Copies, binary patches without a reverse hunk
+// and "Only in" messages cannot be reversed, and ReverseFileDiff returns an
+// error for them.
+func ReverseFileDiff(d *FileDiff) (*FileDiff, error) {
+	switch {
+	case d.isOnlyIn():
+		return nil, fmt.Errorf("cannot reverse %q: it is an Only in message", d.OrigName)
+	case d.Type() == Copied:
+		return nil, fmt.Errorf("cannot reverse the copy of %s to %s", d.OrigName, d.NewName)
+	case d.BinaryPatch != nil && d.BinaryPatch.Reverse == nil, d.BinaryPatch == nil && d.hasExtended("GIT binary patch"):
+		return nil, fmt.Errorf("cannot reverse the binary patch of %s", d.NewName)
+	}
+
+	r := &FileDiff{
+		OrigTime:       d.NewTime,
+		OrigTimeLayout: d.NewTimeLayout,
+		NewTime:        d.OrigTime,
+		NewTimeLayout:  d.OrigTimeLayout,
+		OrigMode:       d.NewMode,
+		NewMode:        d.OrigMode,
+		OrigSHA:        d.NewSHA,
+		NewSHA:         d.OrigSHA,
+		Status:         d.Status,
+	}
+	r.OrigName, r.NewName = reverseNames(d.OrigName, d.NewName)
+	if strings.HasPrefix(d.Status, "A") {
+		r.Status = "D" + d.Status[1:]
+	} else if strings.HasPrefix(d.Status, "D") {
+		r.Status = "A" + d.Status[1:]
+	}
+
+	xheaders := d.Extended
+	if d.BinaryPatch != nil {
+		r.BinaryPatch = &BinaryPatch{Forward: d.BinaryPatch.Reverse, Reverse: d.BinaryPatch.Forward}
+		for i, xheader := range xheaders {
+			if xheader == "GIT binary patch" {
+				xheaders = xheaders[:i]
+				break
+			}
+		}
+	}
+	xheaders, err := reverseExtended(xheaders, r)
+	if err != nil {
+		return nil, err
+	}
+	r.Extended = xheaders
+
+	for _, h := range d.Hunks {
+		r.Hunks = append(r.Hunks, h.reverse())
+	}
+	return r, nil
+}
+
+// Reverse returns a diff that undoes d, as ReverseFileDiff does.
+func (d *FileDiff) Reverse() (*FileDiff, error) {
+	return ReverseFileDiff(d)
+}
+
+// RevertPatch returns the diffs that undo ds, as git revert computes them:
+// the reverse of each diff (see ReverseFileDiff), in the same order.
+func RevertPatch(ds []*FileDiff) ([]*FileDiff, error) {
+	rs := make([]*FileDiff, 0, len(ds))
+	for _, d := range ds {
+		r, err := ReverseFileDiff(d)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+// reverseNames returns the names of the reverse of a diff from orig to new.
+// If the names have git's "a/" and "b/" prefixes, they are swapped too, so
+// that the reverse's original name still starts with "a/".
+func reverseNames(orig, new string) (string, string) {
+	if orig == "" {
+		orig = devNull
+	}
+	prefixed := (orig == devNull || strings.HasPrefix(orig, "a/")) && (new == devNull || strings.HasPrefix(new, "b/"))
+	if !prefixed {
+		return new, orig
+	}
+	return swapGitPrefix(new), swapGitPrefix(orig)
+}
+
+// swapGitPrefix replaces a leading "a/" in name with "b/" and vice versa. It
+// returns other names unchanged.
+func swapGitPrefix(name string) string {
+	switch {
+	case strings.HasPrefix(name, "a/"):
+		return "b/" + name[len("a/"):]
+	case strings.HasPrefix(name, "b/"):
+		return "a/" + name[len("b/"):]
+	}
+	return name
+}
+
+// reverseExtended rewrites the git extended headers of a diff for its
+// reverse, r. Headers before the "diff --git" line (if any) are kept as is.
+func reverseExtended(xheaders []string, r *FileDiff) ([]string, error) {
+	if xheaders == nil {
+		return nil, nil
+	}
+	out := make([]string, len(xheaders))
+	copy(out, xheaders)
+
+	start := 0
+	for i := len(out) - 1; i >= 0; i-- {
+		if strings.HasPrefix(out[i], "diff --git ") {
+			start = i
+			break
+		}
+	}
+
+	// swapValues exchanges the values of the lines with the given prefixes
+	// (such as "old mode " and "new mode "), which keep their positions.
+	swapValues := func(prefix1, prefix2 string) {
+		i, j := -1, -1
+		for k := start; k < len(out); k++ {
+			if strings.HasPrefix(out[k], prefix1) {
+				i = k
+			} else if strings.HasPrefix(out[k], prefix2) {
+				j = k
+			}
+		}
+		switch {
+		case i >= 0 && j >= 0:
+			out[i], out[j] = prefix1+out[j][len(prefix2):], prefix2+out[i][len(prefix1):]
+		case i >= 0:
+			out[i] = prefix2 + out[i][len(prefix1):]
+		case j >= 0:
+			out[j] = prefix1 + out[j][len(prefix2):]
+		}
+	}
+	swapValues("old mode ", "new mode ")
+	swapValues("rename from ", "rename to ")
+	swapValues("deleted file mode ", "new file mode ")
+
+	for i := start; i < len(out); i++ {
+		xheader := out[i]
+		switch {
+		case strings.HasPrefix(xheader, "diff --git "):
+			first, second, ok := parseDiffGitArgs(xheader[len("diff --git "):])
+			if !ok {
+				return nil, fmt.Errorf("cannot reverse ambiguous header %q", xheader)
+			}
+			out[i] = "diff --git " + quoteFilename(swapGitPrefix(second)) + " " + quoteFilename(swapGitPrefix(first))
+		case strings.HasPrefix(xheader, "index "):
+			fields := strings.Fields(xheader)
+			if len(fields) < 2 {
+				continue
+			}
+			if shas := strings.Split(fields[1], ".."); len(shas) == 2 {
+				fields[1] = shas[1] + ".." + shas[0]
+				out[i] = strings.Join(fields, " ")
+			}
+		case strings.HasPrefix(xheader, "Binary files "):
+			out[i] = fmt.Sprintf("Binary files %s and %s differ", quoteFilename(r.OrigName), quoteFilename(r.NewName))
+		}
+	}
+	return out, nil
+}
+
+// reverse returns a hunk that undoes h. Within each change, the removed
+// lines still precede the added ones.
+func (h *Hunk) reverse() *Hunk {
+	r := &Hunk{
+		OrigStartLine: h.NewStartLine,
+		OrigLines:     h.NewLines,
+		NewStartLine:  h.OrigStartLine,
+		NewLines:      h.OrigLines,
+		Section:       h.Section,
+		EmptySection:  h.EmptySection,
+		StartPosition: h.StartPosition,
+	}
+
+	var lines, added []hunkLine
+	for _, l := range h.lines() {
+		switch l.op {
+		case '-':
+			l.op = '+'
+			added = append(added, l)
+			continue
+		case '+':
+			l.op = '-'
+		case ' ':
+			lines = append(lines, added...)
+			added = added[:0]
+		}
+		lines = append(lines, l)
+	}
+	r.setLines(append(lines, added...))
+	return r
+}
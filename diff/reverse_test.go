@@ -0,0 +1,187 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const revertTestPatch = `diff --git a/modified.txt b/modified.txt
+index 1111111..2222222 100644
+--- a/modified.txt
++++ b/modified.txt
+@@ -1,4 +1,4 @@
+ a
+-b
+-c
++B
++C
+ d
+diff --git a/added.txt b/added.txt
+new file mode 100755
+index 0000000..3333333
+--- /dev/null
++++ b/added.txt
+@@ -0,0 +1,2 @@
++x
++y
+\ No newline at end of file
+diff --git a/deleted.txt b/deleted.txt
+deleted file mode 100644
+index 4444444..0000000
+--- a/deleted.txt
++++ /dev/null
+@@ -1,1 +0,0 @@
+-z
+diff --git a/old.txt b/new.txt
+old mode 100644
+new mode 100755
+similarity index 80%
+rename from old.txt
+rename to new.txt
+index 5555555..6666666
+--- a/old.txt
++++ b/new.txt
+@@ -1,2 +1,2 @@
+ p
+-q
+\ No newline at end of file
++q
+`
+
+func TestRevertPatch(t *testing.T) {
+	ds, err := ParseMultiFileDiff([]byte(revertTestPatch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	starts := []string{"a\nb\nc\nd\n", "", "z\n", "p\nq"}
+
+	reverts, err := RevertPatch(ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverts) != len(ds) {
+		t.Fatalf("got %d reverted diffs, want %d", len(reverts), len(ds))
+	}
+	for i, d := range ds {
+		applied, err := ApplyFileDiff([]byte(starts[i]), d)
+		if err != nil {
+			t.Fatalf("%s: applying the diff: %s", d.NewName, err)
+		}
+		reverted, err := ApplyFileDiff(applied, reverts[i])
+		if err != nil {
+			t.Fatalf("%s: applying the revert: %s", d.NewName, err)
+		}
+		if string(reverted) != starts[i] {
+			t.Errorf("%s: got %q after reverting, want %q", d.NewName, reverted, starts[i])
+		}
+	}
+
+	printed, err := PrintMultiFileDiff(reverts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `diff --git a/modified.txt b/modified.txt
+index 2222222..1111111 100644
+--- a/modified.txt
++++ b/modified.txt
+@@ -1,4 +1,4 @@
+ a
+-B
+-C
++b
++c
+ d
+diff --git a/added.txt b/added.txt
+deleted file mode 100755
+index 3333333..0000000
+--- a/added.txt
++++ /dev/null
+@@ -1,2 +0,0 @@
+-x
+-y
+\ No newline at end of file
+diff --git a/deleted.txt b/deleted.txt
+new file mode 100644
+index 0000000..4444444
+--- /dev/null
++++ b/deleted.txt
+@@ -0,0 +1,1 @@
++z
+diff --git a/new.txt b/old.txt
+old mode 100755
+new mode 100644
+similarity index 80%
+rename from new.txt
+rename to old.txt
+index 6666666..5555555
+--- a/new.txt
++++ b/old.txt
+@@ -1,2 +1,2 @@
+ p
+-q
++q
+\ No newline at end of file
+`
+	if got := string(printed); got != want {
+		t.Errorf("printed revert mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	reparsed, err := ParseMultiFileDiff(printed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(reparsed, reverts) {
+		t.Errorf("reparsed revert mismatch (-want +got):\n%s", cmp.Diff(reverts, reparsed))
+	}
+}
+
+func TestReverseFileDiff_Error(t *testing.T) {
+	tests := map[string]*FileDiff{
+		"only in": {OrigName: "a/f"},
+		"copy": parseFileDiffString(t, `diff --git a/f b/g
+similarity index 100%
+copy from f
+copy to g
+`),
+	}
+	for label, d := range tests {
+		t.Run(label, func(t *testing.T) {
+			if _, err := ReverseFileDiff(d); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
+
+func TestFileDiff_Reverse(t *testing.T) {
+	d := parseFileDiffString(t, `diff --git a/f b/f
+new file mode 100644
+index 0000000..1111111
+--- /dev/null
++++ b/f
+@@ -0,0 +1,1 @@
++x
+\ No newline at end of file
+`)
+	r, err := d.Reverse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed, err := PrintFileDiff(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `diff --git a/f b/f
+deleted file mode 100644
+index 1111111..0000000
+--- a/f
++++ /dev/null
+@@ -1,1 +0,0 @@
+-x
+\ No newline at end of file
+`
+	if got := string(printed); got != want {
+		t.Errorf("reversed diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,227 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// PrintSideBySide prints ds for display in a terminal, with the original and
+// new versions of each hunk in two columns, like delta or ydiff. Each file
+// starts with its path (as in a diff stat) and each hunk with its header.
+// Each line is shown with its number and its '-', '+' or ' ' prefix; within
+// each change, removed and added lines are paired up, and the column beside
+// unpaired ones is left blank.
+//
+// The rows are at most width characters wide, counting each rune as one
+// character and expanding tabs to 8 columns. Longer lines are truncated
+// with "…", or wrapped onto continuation rows with WithWrap. The output is
+// colored with WithColor, and the print options that drop or merge hunks
+// apply.
+func PrintSideBySide(ds []*FileDiff, width int, opts ...PrintOption) ([]byte, error) {
+	o := newPrintOptions(opts)
+	colors := o.colors()
+	var buf bytes.Buffer
+	for _, d := range ds {
+		if d.Hunks == nil && d.RawHunks != nil {
+			return nil, errHunksNotLoaded
+		}
+		writeColored(&buf, colors.Meta, []byte(truncateText(d.statPath(o.quote), width)+"\n"))
+		hunks := o.hunks(d.Hunks)
+		numWidth := sideBySideNumberWidth(hunks)
+		textWidth := (width-utf8.RuneCountInString(sideBySideSeparator))/2 - numWidth - 1
+		if textWidth < 2 {
+			return nil, fmt.Errorf("width %d is too narrow for a side-by-side diff", width)
+		}
+		for _, h := range hunks {
+			writeColored(&buf, colors.Frag, []byte(truncateText(hunkHeaderText(h), width)+"\n"))
+			for _, row := range sideBySideRows(h) {
+				orig := row.orig.textLines(textWidth, o.wrap)
+				new := row.new.textLines(textWidth, o.wrap)
+				for k := 0; k < len(orig) || k < len(new); k++ {
+					row.orig.write(&buf, colors, orig, k, numWidth, textWidth)
+					if k < len(new) {
+						buf.WriteString(sideBySideSeparator)
+					} else {
+						buf.WriteString(strings.TrimRight(sideBySideSeparator, " "))
+					}
+					row.new.write(&buf, colors, new, k, numWidth, 0)
+					buf.WriteByte('\n')
+				}
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// WithWrap makes PrintSideBySide wrap lines that are too long for their
+// column onto continuation rows, instead of truncating them.
+func WithWrap() PrintOption {
+	return func(o *printOptions) {
+		o.wrap = true
+	}
+}
+
+// sideBySideSeparator separates the columns of PrintSideBySide.
+const sideBySideSeparator = " │ "
+
+// A sideBySideRow is a row of a side-by-side diff.
+type sideBySideRow struct {
+	orig, new sideBySideCell
+}
+
+// A sideBySideCell is one side of a sideBySideRow: a line with its number
+// and prefix, or nothing if op is 0.
+type sideBySideCell struct {
+	line int
+	op   byte
+	text []byte
+}
+
+// sideBySideRows returns the rows of a side-by-side view of h, pairing up
+// the i-th removed line with the i-th added line of each change.
+func sideBySideRows(h *Hunk) []sideBySideRow {
+	var rows []sideBySideRow
+	lines := h.lines()
+	origLine, newLine := h.lineStarts()
+	for i := 0; i < len(lines); {
+		if l := lines[i]; l.op == ' ' {
+			rows = append(rows, sideBySideRow{
+				orig: sideBySideCell{origLine, ' ', l.text},
+				new:  sideBySideCell{newLine, ' ', l.text},
+			})
+			origLine++
+			newLine++
+			i++
+			continue
+		}
+		del := i
+		for i < len(lines) && lines[i].op == '-' {
+			i++
+		}
+		ins := i
+		for i < len(lines) && lines[i].op == '+' {
+			i++
+		}
+		for k := 0; del+k < ins || ins+k < i; k++ {
+			var row sideBySideRow
+			if del+k < ins {
+				row.orig = sideBySideCell{origLine, '-', lines[del+k].text}
+				origLine++
+			}
+			if ins+k < i {
+				row.new = sideBySideCell{newLine, '+', lines[ins+k].text}
+				newLine++
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// textLines returns the prefixed text of c, with the tabs in the text
+// expanded, as lines of at most width runes: wrapped if wrap is true, and
+// otherwise truncated to a single line.
+func (c sideBySideCell) textLines(width int, wrap bool) []string {
+	if c.op == 0 {
+		return nil
+	}
+	text := string(c.op) + expandTabs(string(c.text))
+	if !wrap {
+		return []string{truncateText(text, width)}
+	}
+	var lines []string
+	for {
+		n, i := 0, 0
+		for i < len(text) && n < width {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			i += size
+			n++
+		}
+		lines = append(lines, text[:i])
+		if text = text[i:]; text == "" {
+			return lines
+		}
+	}
+}
+
+// write writes the k-th row of the lines of c to buf, padded to width runes
+// unless width is 0, in which case nothing is written for a blank row. Only
+// the first row shows the line number.
+func (c sideBySideCell) write(buf *bytes.Buffer, colors *ColorScheme, lines []string, k, numWidth, width int) {
+	if width == 0 && k >= len(lines) {
+		return
+	}
+	if k == 0 && c.op != 0 {
+		fmt.Fprintf(buf, "%*d ", numWidth, c.line)
+	} else {
+		buf.WriteString(strings.Repeat(" ", numWidth+1))
+	}
+	var text string
+	if k < len(lines) {
+		text = lines[k]
+	}
+	color := colors.Context
+	switch c.op {
+	case '-':
+		color = colors.Old
+	case '+':
+		color = colors.New
+	}
+	writeColored(buf, color, []byte(text))
+	if pad := width - utf8.RuneCountInString(text); pad > 0 {
+		buf.WriteString(strings.Repeat(" ", pad))
+	}
+}
+
+// sideBySideNumberWidth returns the number of digits of the largest line
+// number in hunks.
+func sideBySideNumberWidth(hunks []*Hunk) int {
+	max := 1
+	for _, h := range hunks {
+		for _, end := range []int32{h.OrigStartLine + h.OrigLines, h.NewStartLine + h.NewLines} {
+			if n := len(fmt.Sprint(end)); n > max {
+				max = n
+			}
+		}
+	}
+	return max
+}
+
+// truncateText returns text truncated to width runes, ending with "…" if it
+// was truncated.
+func truncateText(text string, width int) string {
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	n := 0
+	for i := range text {
+		if n == width-1 {
+			return text[:i] + "…"
+		}
+		n++
+	}
+	return text
+}
+
+// expandTabs replaces the tabs in text with spaces up to the next multiple
+// of 8 columns.
+func expandTabs(text string) string {
+	if !strings.Contains(text, "\t") {
+		return text
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range text {
+		if r == '\t' {
+			n := 8 - col%8
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPrintSideBySide(t *testing.T) {
+	d := parseFileDiffString(t, "--- a/f\n+++ b/f\n@@ -8,4 +8,4 @@ func\n x\n-a long line that is cut\n-c\n+\tb\n y\n+añadido\n")
+	tests := map[string]struct {
+		opts []PrintOption
+		want string
+	}{
+		"truncated": {
+			want: "f\n" +
+				"@@ -8,4 +8,4 @@ func\n" +
+				" 8  x              │  8  x\n" +
+				" 9 -a long line t… │  9 +        b\n" +
+				"10 -c              │\n" +
+				"11  y              │ 10  y\n" +
+				"                   │ 11 +añadido\n",
+		},
+		"wrapped": {
+			opts: []PrintOption{WithWrap()},
+			want: "f\n" +
+				"@@ -8,4 +8,4 @@ func\n" +
+				" 8  x              │  8  x\n" +
+				" 9 -a long line th │  9 +        b\n" +
+				"   at is cut       │\n" +
+				"10 -c              │\n" +
+				"11  y              │ 10  y\n" +
+				"                   │ 11 +añadido\n",
+		},
+		"colored": {
+			opts: []PrintOption{WithColor(ColorScheme{Meta: "M", Old: "O", New: "N"})},
+			want: "Mf\x1b[m\n" +
+				"@@ -8,4 +8,4 @@ func\n" +
+				" 8  x              │  8  x\n" +
+				" 9 O-a long line t…\x1b[m │  9 N+        b\x1b[m\n" +
+				"10 O-c\x1b[m              │\n" +
+				"11  y              │ 10  y\n" +
+				"                   │ 11 N+añadido\x1b[m\n",
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			printed, err := PrintSideBySide([]*FileDiff{d}, 40, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(printed); got != test.want {
+				t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+
+	if _, err := PrintSideBySide([]*FileDiff{d}, 10); err == nil {
+		t.Error("got no error for a narrow width")
+	}
+
+	renamed := parseFileDiffString(t, "diff --git a/dir/ñ b/dir/n\nsimilarity index 100%\nrename from dir/ñ\nrename to dir/n\n")
+	printed, err := PrintSideBySide([]*FileDiff{renamed}, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(printed), `"dir/\303\261" => dir/n`+"\n"; got != want {
+		t.Errorf("got header %q for a rename, want %q", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+package diff
+
+import "strings"
+
+// SplitByHunk returns a diff for each hunk of d, that applies the hunk alone
+// to the original file, so that the hunks can be selected or applied
+// independently, as in git add -p. The new line numbers of each hunk are
+// shifted to leave out the lines added or removed by the hunks before it.
+// Each diff has the names, timestamps, modes and extended headers of d,
+// except for its object names and "index" line, which describe the whole
+// change. A diff without hunks gives a single diff equal to d, and one
+// whose hunks have not been loaded gives nil.
+func (d *FileDiff) SplitByHunk() []*FileDiff {
+	if d.Hunks == nil && d.RawHunks != nil {
+		return nil
+	}
+	if len(d.Hunks) == 0 {
+		return []*FileDiff{d}
+	}
+	var xheaders []string
+	for _, xheader := range d.Extended {
+		if !strings.HasPrefix(xheader, "index ") {
+			xheaders = append(xheaders, xheader)
+		}
+	}
+
+	ds := make([]*FileDiff, 0, len(d.Hunks))
+	shift := int32(0)
+	for _, h := range d.Hunks {
+		sh := *h
+		sh.Body = append([]byte(nil), h.Body...)
+		sh.NewStartLine -= shift
+		sh.StartPosition = 1
+		shift += h.NewLines - h.OrigLines
+		ds = append(ds, &FileDiff{
+			OrigName:       d.OrigName,
+			OrigTime:       d.OrigTime,
+			OrigTimeLayout: d.OrigTimeLayout,
+			NewName:        d.NewName,
+			NewTime:        d.NewTime,
+			NewTimeLayout:  d.NewTimeLayout,
+			Extended:       xheaders,
+			OrigMode:       d.OrigMode,
+			NewMode:        d.NewMode,
+			Status:         d.Status,
+			Hunks:          []*Hunk{&sh},
+		})
+	}
+	return ds
+}
+
+// Split splits h into smaller hunks wherever its changes are separated by
+// context lines, like the "s" command of git add -p. Each hunk has the
+// context lines before and after its change, so that the context lines
+// between two changes are in the hunks of both, and its line numbers are
+// those it has as part of h. Only the first hunk keeps h's section heading,
+// and the hunks have no start positions or refinements. A hunk that cannot
+// be split gives a single hunk equal to h.
+func (h *Hunk) Split() []*Hunk {
+	lines := h.lines()
+	// Find the runs of changed lines, as [start, end) indexes into lines.
+	var runs [][2]int
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			i++
+			continue
+		}
+		start := i
+		for i < len(lines) && lines[i].op != ' ' {
+			i++
+		}
+		runs = append(runs, [2]int{start, i})
+	}
+	if len(runs) < 2 {
+		return []*Hunk{h}
+	}
+
+	var hunks []*Hunk
+	origLine, newLine := h.lineStarts()
+	next := 0 // index of the line at origLine and newLine
+	for k := range runs {
+		start, end := 0, len(lines)
+		if k > 0 {
+			start = runs[k-1][1]
+		}
+		if k < len(runs)-1 {
+			end = runs[k+1][0]
+		}
+		for ; next < start; next++ {
+			if lines[next].op != '+' {
+				origLine++
+			}
+			if lines[next].op != '-' {
+				newLine++
+			}
+		}
+		part := &Hunk{OrigStartLine: int32(origLine), NewStartLine: int32(newLine)}
+		for _, l := range lines[start:end] {
+			if l.op != '+' {
+				part.OrigLines++
+			}
+			if l.op != '-' {
+				part.NewLines++
+			}
+		}
+		if part.OrigLines == 0 {
+			part.OrigStartLine--
+		}
+		if part.NewLines == 0 {
+			part.NewStartLine--
+		}
+		if k == 0 {
+			part.Section, part.EmptySection = h.Section, h.EmptySection
+		}
+		part.setLines(lines[start:end])
+		hunks = append(hunks, part)
+	}
+	return hunks
+}
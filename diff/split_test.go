@@ -0,0 +1,157 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileDiff_SplitByHunk(t *testing.T) {
+	orig := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n17\n18\n19\n20\n"
+	d := parseFileDiffString(t, `diff --git a/f b/f
+index 1111111..2222222 100644
+--- a/f
++++ b/f
+@@ -1,4 +1,6 @@
+ 1
++1a
++1b
+ 2
+ 3
+ 4
+@@ -9,6 +11,5 @@
+ 9
+ 10
+ 11
+-12
+ 13
+ 14
+@@ -17,4 +18,4 @@
+ 17
+ 18
+-19
++nineteen
+ 20
+`)
+	ds := d.SplitByHunk()
+	if len(ds) != 3 {
+		t.Fatalf("got %d diffs, want 3", len(ds))
+	}
+
+	// Each diff applies its hunk alone.
+	wants := []string{
+		strings.Replace(orig, "1\n", "1\n1a\n1b\n", 1),
+		strings.Replace(orig, "12\n", "", 1),
+		strings.Replace(orig, "19\n", "nineteen\n", 1),
+	}
+	for i, sd := range ds {
+		applied, err := ApplyFileDiff([]byte(orig), sd)
+		if err != nil {
+			t.Fatalf("diff %d: %s", i, err)
+		}
+		if string(applied) != wants[i] {
+			t.Errorf("diff %d: applied mismatch (-want +got):\n%s", i, cmp.Diff(wants[i], string(applied)))
+		}
+	}
+
+	printed, err := PrintFileDiff(ds[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -17,4 +17,4 @@
+ 17
+ 18
+-19
++nineteen
+ 20
+`
+	if got := string(printed); got != want {
+		t.Errorf("printed diff mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+	if d.Hunks[2].NewStartLine != 18 {
+		t.Errorf("got new start line %d for the original hunk, want it unchanged", d.Hunks[2].NewStartLine)
+	}
+}
+
+func TestHunk_Split(t *testing.T) {
+	tests := map[string]struct {
+		hunk string
+		want string
+	}{
+		"two changes": {
+			hunk: `@@ -1,7 +1,7 @@ section
+ a
+-b
++B
+ c
+ d
+-e
++E
+ f
+`,
+			want: `@@ -1,4 +1,4 @@ section
+ a
+-b
++B
+ c
+ d
+@@ -3,4 +3,4 @@
+ c
+ d
+-e
++E
+ f
+`,
+		},
+		"insertion and deletion without context at the ends": {
+			hunk: `@@ -10,3 +10,3 @@
++x
+ m
+ n
+-o
+`,
+			want: `@@ -10,2 +10,3 @@
++x
+ m
+ n
+@@ -10,3 +11,2 @@
+ m
+ n
+-o
+`,
+		},
+		"one change": {
+			hunk: `@@ -1,3 +1,3 @@
+ a
+-b
++B
+ c
+`,
+			want: `@@ -1,3 +1,3 @@
+ a
+-b
++B
+ c
+`,
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			hunks, err := ParseHunks([]byte(test.hunk))
+			if err != nil {
+				t.Fatal(err)
+			}
+			printed, err := PrintHunks(hunks[0].Split())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(printed); got != test.want {
+				t.Errorf("split hunks mismatch (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,640 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A ChangeType describes what a FileDiff does to its file.
+type ChangeType int
+
+const (
+	// Modified means the file's contents (or mode) were changed in place.
+	Modified ChangeType = iota
+	// Added means the file was created.
+	Added
+	// Deleted means the file was removed.
+	Deleted
+	// Renamed means the file was moved, and possibly changed.
+	Renamed
+	// Copied means the file was copied, and possibly changed.
+	Copied
+	// OnlyIn means the FileDiff is an "Only in" message, reporting a file
+	// present in only one of the compared directories.
+	OnlyIn
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case Modified:
+		return "modified"
+	case Added:
+		return "added"
+	case Deleted:
+		return "deleted"
+	case Renamed:
+		return "renamed"
+	case Copied:
+		return "copied"
+	case OnlyIn:
+		return "only-in"
+	}
+	return "ChangeType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// Type returns the kind of change d makes, based on its file names, its
+// extended headers and, for diffs from ParseRaw, its status.
+func (d *FileDiff) Type() ChangeType {
+	switch {
+	case d.isOnlyIn():
+		return OnlyIn
+	case d.IsDevNullOrig() || strings.HasPrefix(d.Status, "A"):
+		return Added
+	case d.IsDevNullNew() || strings.HasPrefix(d.Status, "D"):
+		return Deleted
+	case strings.HasPrefix(d.Status, "R") || d.hasExtended("rename from "):
+		return Renamed
+	case strings.HasPrefix(d.Status, "C") || d.hasExtended("copy from "):
+		return Copied
+	}
+	return Modified
+}
+
+// hasExtended reports whether any of d's extended headers has the given
+// prefix.
+func (d *FileDiff) hasExtended(prefix string) bool {
+	for _, xheader := range d.Extended {
+		if strings.HasPrefix(xheader, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBinary reports whether d describes a change to a binary file, i.e.,
+// whether it has a "Binary files ... differ" or "GIT binary patch" extended
+// header.
+func (d *FileDiff) IsBinary() bool {
+	return d.hasExtended("Binary files ") || d.hasExtended("GIT binary patch")
+}
+
+// paths returns the original and new paths of the file, without git's
+// "a/" and "b/" prefixes if both names have them (or are /dev/null).
+func (d *FileDiff) paths() (orig, new string) {
+	return trimGitPrefixes(d.OrigName, d.NewName)
+}
+
+// trimGitPrefixes returns orig and new without git's "a/" and "b/" prefixes
+// if both names have them (or are /dev/null), and unchanged otherwise.
+func trimGitPrefixes(orig, new string) (string, string) {
+	if (orig == devNull || strings.HasPrefix(orig, "a/")) && (new == devNull || strings.HasPrefix(new, "b/")) {
+		orig = strings.TrimPrefix(orig, "a/")
+		new = strings.TrimPrefix(new, "b/")
+	}
+	return orig, new
+}
+
+// path returns the path of the file d changes, as shown in a diff stat: the
+// new path (or the original one, if the file was deleted), or "old => new"
+// if the file was renamed or copied.
+func (d *FileDiff) path() string {
+	orig, new := d.paths()
+	switch d.Type() {
+	case Deleted, OnlyIn:
+		return orig
+	case Renamed, Copied:
+		if orig != new {
+			return orig + " => " + new
+		}
+	}
+	return new
+}
+
+// statPath is like path, but abbreviates the paths of a renamed or copied
+// file the way git does in diff stats: the directories (and the file name)
+// they have in common are printed once, around "{old => new}", as in
+// "dir/{old => new}/file". The paths are quoted with quote, and those of a
+// rename that quote changes are not abbreviated.
+func (d *FileDiff) statPath(quote func(string) string) string {
+	orig, new := d.paths()
+	if typ := d.Type(); (typ != Renamed && typ != Copied) || orig == new {
+		return quote(d.path())
+	}
+	if quote(orig) != orig || quote(new) != new {
+		return quote(orig) + " => " + quote(new)
+	}
+
+	// The common prefix ends with a slash, and the common suffix starts
+	// with one.
+	prefix := 0
+	for i := 0; i < len(orig) && i < len(new) && orig[i] == new[i]; i++ {
+		if orig[i] == '/' {
+			prefix = i + 1
+		}
+	}
+	// at returns the byte at i in s, or 0 at its end.
+	at := func(s string, i int) byte {
+		if i == len(s) {
+			return 0
+		}
+		return s[i]
+	}
+	adjust := 0
+	if prefix > 0 {
+		// Let the suffix share the slash that ends the prefix.
+		adjust = 1
+	}
+	suffix := 0
+	for i, j := len(orig), len(new); prefix-adjust <= i && prefix-adjust <= j && at(orig, i) == at(new, j); i, j = i-1, j-1 {
+		if at(orig, i) == '/' {
+			suffix = len(orig) - i
+		}
+	}
+
+	origMid, newMid := len(orig)-prefix-suffix, len(new)-prefix-suffix
+	if origMid < 0 {
+		origMid = 0
+	}
+	if newMid < 0 {
+		newMid = 0
+	}
+	mid := orig[prefix:prefix+origMid] + " => " + new[prefix:prefix+newMid]
+	if prefix+suffix == 0 {
+		return mid
+	}
+	return orig[:prefix] + "{" + mid + "}" + orig[len(orig)-suffix:]
+}
+
+// lines returns the total number of lines added and deleted, counting each
+// changed line as one deletion and one addition.
+func (s Stat) lines() (added, deleted int) {
+	return int(s.Added + s.Changed), int(s.Deleted + s.Changed)
+}
+
+// WriteStatCSV writes a summary of ds to w as CSV, with a header row and a
+// row per file giving its path, change type, the numbers of
+// lines added and deleted, and whether it is binary. The counts are left
+// empty for binary files. The path of a renamed or copied file is written
+// as "old => new".
+func WriteStatCSV(w io.Writer, ds []*FileDiff) error {
+	return writeStat(csv.NewWriter(w), ds)
+}
+
+// WriteStatTSV is like WriteStatCSV, but separates fields with tabs.
+func WriteStatTSV(w io.Writer, ds []*FileDiff) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = '\t'
+	return writeStat(cw, ds)
+}
+
+func writeStat(w *csv.Writer, ds []*FileDiff) error {
+	if err := w.Write([]string{"path", "type", "added", "deleted", "binary"}); err != nil {
+		return err
+	}
+	for _, d := range ds {
+		var added, deleted string
+		binary := d.IsBinary()
+		if !binary {
+			a, del := d.Stat().lines()
+			added, deleted = strconv.Itoa(a), strconv.Itoa(del)
+		}
+		if err := w.Write([]string{d.path(), d.Type().String(), added, deleted, strconv.FormatBool(binary)}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// statGraphWidth is the maximum width of the graph of +'s and -'s printed
+// by PrintDiffStat for a file.
+const statGraphWidth = 50
+
+// PrintDiffStat prints a summary of ds like git diff --stat: a line per file
+// giving its path, the number of lines changed and a graph of +'s and -'s
+// (scaled down to fit in 50 columns if needed), followed by the total
+// numbers of files changed, insertions and deletions. The path of a renamed
+// or copied file is abbreviated as git does, as in "dir/{old => new}/file".
+// Binary files are shown as "Bin", and "Only in" messages are omitted. The
+// paths are quoted like the names in file headers (see WithQuoter), and the
+// only other option it uses is WithCompactSummary.
+func PrintDiffStat(ds []*FileDiff, opts ...PrintOption) ([]byte, error) {
+	return printStat(ds, -1, newPrintOptions(opts)), nil
+}
+
+// PrintStat is like PrintDiffStat, but fits the lines in width columns (80
+// if width is 0), like git diff --stat=<width>. As git does, the graphs are
+// scaled down to fit if needed, taking no more than 3/8 of the width (but
+// at least 6 columns) if the paths are too long to leave them more room,
+// and paths that are still too long are shortened to their ends, after
+// "...".
+func PrintStat(ds []*FileDiff, width int, opts ...PrintOption) ([]byte, error) {
+	if width == 0 {
+		width = 80
+	}
+	return printStat(ds, width, newPrintOptions(opts)), nil
+}
+
+// printStat prints a diff stat of ds in width columns, or with an unlimited
+// width and graphs of up to statGraphWidth columns if width is negative.
+func printStat(ds []*FileDiff, width int, o *printOptions) []byte {
+	type row struct {
+		name           string
+		binary         bool
+		added, deleted int
+	}
+	var rows []row
+	nameWidth, maxChange := 0, 0
+	totalAdded, totalDeleted := 0, 0
+	for _, d := range ds {
+		if d.isOnlyIn() {
+			continue
+		}
+		r := row{name: d.statPath(o.quote), binary: d.IsBinary()}
+		if o.compactSummary {
+			if marker := d.compactSummary(); marker != "" {
+				r.name += " (" + marker + ")"
+			}
+		}
+		if !r.binary {
+			r.added, r.deleted = d.Stat().lines()
+		}
+		if n := utf8.RuneCountInString(r.name); n > nameWidth {
+			nameWidth = n
+		}
+		if r.added+r.deleted > maxChange {
+			maxChange = r.added + r.deleted
+		}
+		totalAdded += r.added
+		totalDeleted += r.deleted
+		rows = append(rows, r)
+	}
+	countWidth := len(strconv.Itoa(maxChange))
+	for _, r := range rows {
+		if r.binary && len("Bin") > countWidth {
+			countWidth = len("Bin")
+		}
+	}
+
+	graphWidth := maxChange
+	if width < 0 {
+		if graphWidth > statGraphWidth {
+			graphWidth = statGraphWidth
+		}
+	} else {
+		nameWidth, graphWidth = statWidths(width, nameWidth, countWidth, graphWidth)
+	}
+
+	var buf bytes.Buffer
+	for _, r := range rows {
+		name := shortenStatName(r.name, nameWidth)
+		pad := strings.Repeat(" ", nameWidth-utf8.RuneCountInString(name))
+		if r.binary {
+			fmt.Fprintf(&buf, " %s%s | %*s\n", name, pad, countWidth, "Bin")
+			continue
+		}
+		added, deleted := scaleStat(r.added, r.deleted, maxChange, graphWidth)
+		graph := strings.Repeat("+", added) + strings.Repeat("-", deleted)
+		if graph != "" {
+			graph = " " + graph
+		}
+		fmt.Fprintf(&buf, " %s%s | %*d%s\n", name, pad, countWidth, r.added+r.deleted, graph)
+	}
+
+	writeStatSummary(&buf, len(rows), totalAdded, totalDeleted)
+	return buf.Bytes()
+}
+
+// writeStatSummary writes the last line of a diff stat, giving the numbers
+// of files changed, insertions and deletions.
+func writeStatSummary(buf *bytes.Buffer, files, added, deleted int) {
+	fmt.Fprintf(buf, " %d %s changed", files, plural(files, "file", "files"))
+	if added > 0 || deleted == 0 {
+		fmt.Fprintf(buf, ", %d %s(+)", added, plural(added, "insertion", "insertions"))
+	}
+	if deleted > 0 || added == 0 {
+		fmt.Fprintf(buf, ", %d %s(-)", deleted, plural(deleted, "deletion", "deletions"))
+	}
+	buf.WriteByte('\n')
+}
+
+// PrintShortStat prints the last line of the diff stat of ds printed by
+// PrintDiffStat, like git diff --shortstat: the numbers of files changed,
+// insertions and deletions, as in
+// " 3 files changed, 10 insertions(+), 2 deletions(-)".
+func PrintShortStat(ds []*FileDiff) ([]byte, error) {
+	files, totalAdded, totalDeleted := 0, 0, 0
+	for _, d := range ds {
+		if d.isOnlyIn() {
+			continue
+		}
+		files++
+		if !d.IsBinary() {
+			added, deleted := d.Stat().lines()
+			totalAdded += added
+			totalDeleted += deleted
+		}
+	}
+	var buf bytes.Buffer
+	writeStatSummary(&buf, files, totalAdded, totalDeleted)
+	return buf.Bytes(), nil
+}
+
+// PrintNumStat prints the numbers of lines added and deleted in each file of
+// ds, like git diff --numstat: a line per file giving the numbers and the
+// path, separated by tabs, with "-" instead of the numbers for binary
+// files. The paths are quoted like the names in file headers (see
+// WithQuoter), and those of a renamed or copied file are printed as in a
+// diff stat, as "old => new" or "dir/{old => new}/file". With
+// WithNULTerminated, the output is that of git diff --numstat -z instead,
+// in which the paths of a renamed or copied file follow the numbers as
+// separate fields. "Only in" messages are omitted.
+func PrintNumStat(ds []*FileDiff, opts ...PrintOption) ([]byte, error) {
+	o := newPrintOptions(opts)
+	var buf bytes.Buffer
+	for _, d := range ds {
+		if d.isOnlyIn() {
+			continue
+		}
+		if d.IsBinary() {
+			buf.WriteString("-\t-\t")
+		} else {
+			added, deleted := d.Stat().lines()
+			fmt.Fprintf(&buf, "%d\t%d\t", added, deleted)
+		}
+		orig, new := d.paths()
+		switch typ := d.Type(); {
+		case (typ == Renamed || typ == Copied) && orig != new:
+			if o.nulTerminated {
+				fmt.Fprintf(&buf, "\x00%s\x00%s", orig, new)
+			} else {
+				buf.WriteString(d.statPath(o.quote))
+			}
+		case typ == Deleted:
+			buf.WriteString(o.path(orig))
+		default:
+			buf.WriteString(o.path(new))
+		}
+		buf.WriteByte(o.separator('\n'))
+	}
+	return buf.Bytes(), nil
+}
+
+// PrintSummary prints the changes ds makes to the existence, names and
+// modes of files, like git diff --summary, without their hunks: a line such
+// as "create mode 100644 foo" or "delete mode 100644 foo" for each added or
+// deleted file, "rename foo => bar (90%)" or "copy foo => bar (90%)" for
+// each renamed or copied one (with its paths abbreviated as in a diff stat,
+// and its similarity from its status or "similarity index" header, if
+// known), "rewrite foo (80%)" for each file with a "dissimilarity index"
+// header, and "mode change 100644 => 100755 foo" for each one whose mode
+// changed (without the path after a rename or copy). Files with none of
+// these changes, and "Only in" messages, are omitted.
+func PrintSummary(ds []*FileDiff) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, d := range ds {
+		orig, new := d.paths()
+		switch typ := d.Type(); typ {
+		case Added:
+			writeSummaryMode(&buf, "create", d.NewMode, new)
+		case Deleted:
+			writeSummaryMode(&buf, "delete", d.OrigMode, orig)
+		case Renamed, Copied:
+			verb := "rename"
+			if typ == Copied {
+				verb = "copy"
+			}
+			buf.WriteString(" " + verb + " " + d.statPath(quoteFilename))
+			if score, ok := d.similarity(); ok {
+				fmt.Fprintf(&buf, " (%d%%)", score)
+			}
+			buf.WriteByte('\n')
+			if d.ModeChanged() {
+				fmt.Fprintf(&buf, " mode change %06o => %06o\n", d.OrigMode, d.NewMode)
+			}
+		case Modified:
+			score, rewritten := d.xheaderPercent("dissimilarity index ")
+			if rewritten {
+				fmt.Fprintf(&buf, " rewrite %s (%d%%)\n", quoteFilename(new), score)
+			}
+			if d.ModeChanged() {
+				fmt.Fprintf(&buf, " mode change %06o => %06o", d.OrigMode, d.NewMode)
+				if !rewritten {
+					buf.WriteString(" " + quoteFilename(new))
+				}
+				buf.WriteByte('\n')
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// writeSummaryMode writes the summary line of an added or deleted file,
+// without its mode if it is not known.
+func writeSummaryMode(buf *bytes.Buffer, verb string, mode uint32, path string) {
+	if mode != 0 {
+		fmt.Fprintf(buf, " %s mode %06o %s\n", verb, mode, quoteFilename(path))
+	} else {
+		fmt.Fprintf(buf, " %s %s\n", verb, quoteFilename(path))
+	}
+}
+
+// similarity returns the similarity of the original and new versions of a
+// renamed or copied file, as a percentage, from its "similarity index"
+// header or its status, and whether it is known.
+func (d *FileDiff) similarity() (int, bool) {
+	if score, ok := d.xheaderPercent("similarity index "); ok {
+		return score, true
+	}
+	if len(d.Status) > 1 {
+		if score, err := strconv.Atoi(d.Status[1:]); err == nil {
+			return score, true
+		}
+	}
+	return 0, false
+}
+
+// xheaderPercent returns the percentage in d's first extended header with
+// the given prefix, such as "similarity index ", and whether it has one.
+func (d *FileDiff) xheaderPercent(prefix string) (int, bool) {
+	for _, xheader := range d.Extended {
+		if strings.HasPrefix(xheader, prefix) && strings.HasSuffix(xheader, "%") {
+			if score, err := strconv.Atoi(xheader[len(prefix) : len(xheader)-1]); err == nil {
+				return score, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// StatByExtension returns the total stats of the files in ds, by the
+// extension of their paths as returned by path.Ext (such as ".go", or ""
+// for paths without one). The path of a file is its new path, or its
+// original one if it was deleted. Binary files and "Only in" messages are
+// omitted.
+func StatByExtension(ds []*FileDiff) map[string]Stat {
+	return groupStats(ds, path.Ext)
+}
+
+// StatByDirectory returns the total stats of the files in ds, by the
+// directory of their paths, as in StatByExtension. With a positive depth,
+// only the first depth directories of each path are kept, so that the
+// stats of deeper directories are added to those of their ancestors at that
+// depth. Files at the top level are in the directory ".".
+func StatByDirectory(ds []*FileDiff, depth int) map[string]Stat {
+	return groupStats(ds, func(p string) string {
+		dir := path.Dir(p)
+		if depth > 0 && dir != "." {
+			if parts := strings.SplitN(dir, "/", depth+1); len(parts) > depth {
+				dir = strings.Join(parts[:depth], "/")
+			}
+		}
+		return dir
+	})
+}
+
+// groupStats returns the total stats of the files in ds, by the keys of
+// their paths.
+func groupStats(ds []*FileDiff, key func(path string) string) map[string]Stat {
+	stats := make(map[string]Stat)
+	for _, d := range ds {
+		if d.isOnlyIn() || d.IsBinary() {
+			continue
+		}
+		orig, new := d.paths()
+		p := new
+		if d.Type() == Deleted {
+			p = orig
+		}
+		k := key(p)
+		s := stats[k]
+		s.add(d.Stat())
+		stats[k] = s
+	}
+	return stats
+}
+
+// statWidths returns the widths of the names and graphs of a diff stat
+// that fits in width columns, given the widths they would like, the way
+// git does. Each line also needs 6 columns for the separators, and
+// countWidth for the count.
+func statWidths(width, nameWidth, countWidth, graphWidth int) (int, int) {
+	if min := 16 + 6 + countWidth; width < min {
+		width = min
+	}
+	if nameWidth+countWidth+6+graphWidth <= width {
+		return nameWidth, graphWidth
+	}
+	if max := width*3/8 - countWidth - 6; graphWidth > max {
+		graphWidth = max
+		if graphWidth < 6 {
+			graphWidth = 6
+		}
+	}
+	if max := width - countWidth - 6 - graphWidth; nameWidth > max {
+		nameWidth = max
+	} else {
+		graphWidth = width - countWidth - 6 - nameWidth
+	}
+	return nameWidth, graphWidth
+}
+
+// shortenStatName returns name shortened to width runes the way git does in
+// a diff stat: "..." followed by the end of the name, from its first slash
+// if there is one.
+func shortenStatName(name string, width int) string {
+	n := utf8.RuneCountInString(name)
+	if n <= width {
+		return name
+	}
+	keep := width - 3
+	if keep < 0 {
+		keep = 0
+	}
+	for n > keep {
+		_, size := utf8.DecodeRuneInString(name)
+		name = name[size:]
+		n--
+	}
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		name = name[i:]
+	}
+	return "..." + name
+}
+
+// scaleStat returns the numbers of +'s and -'s to print in the graph of a
+// file with the given numbers of added and deleted lines, scaled the way git
+// does so that maxChange changes fill graphWidth columns.
+func scaleStat(added, deleted, maxChange, graphWidth int) (int, int) {
+	if maxChange <= graphWidth {
+		return added, deleted
+	}
+	scale := func(n int) int {
+		if n == 0 {
+			return 0
+		}
+		return 1 + n*(graphWidth-1)/maxChange
+	}
+	total := scale(added + deleted)
+	if total < 2 && added > 0 && deleted > 0 {
+		total = 2
+	}
+	if added < deleted {
+		added = scale(added)
+		return added, total - added
+	}
+	deleted = scale(deleted)
+	return total - deleted, deleted
+}
+
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+// File type and permission bits of git modes.
+const (
+	modeTypeMask   = 0170000
+	modeSymlink    = 0120000
+	modeExecutable = 0100
+)
+
+// compactSummary returns the annotation of d in a diff stat with
+// WithCompactSummary, or "" if there is none.
+func (d *FileDiff) compactSummary() string {
+	switch d.Type() {
+	case Added:
+		switch {
+		case d.NewMode&modeTypeMask == modeSymlink:
+			return "new +l"
+		case d.NewMode&modeExecutable != 0:
+			return "new +x"
+		}
+		return "new"
+	case Deleted:
+		return "gone"
+	}
+	if !d.ModeChanged() {
+		return ""
+	}
+	origLink, newLink := d.OrigMode&modeTypeMask == modeSymlink, d.NewMode&modeTypeMask == modeSymlink
+	switch {
+	case origLink && !newLink:
+		return "mode -l"
+	case !origLink && newLink:
+		return "mode +l"
+	case d.OrigMode&modeExecutable == 0 && d.NewMode&modeExecutable != 0:
+		return "mode +x"
+	case d.OrigMode&modeExecutable != 0 && d.NewMode&modeExecutable == 0:
+		return "mode -x"
+	}
+	return ""
+}
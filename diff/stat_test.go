@@ -0,0 +1,447 @@
+package diff
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const statTestDiff = `diff --git a/modified.txt b/modified.txt
+index 1111111..2222222 100644
+--- a/modified.txt
++++ b/modified.txt
+@@ -1,3 +1,3 @@
+ a
+-b
++B
++c
+diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++x
++y
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+index 4444444..0000000
+--- a/gone.txt
++++ /dev/null
+@@ -1,1 +0,0 @@
+-z
+diff --git a/old name.txt b/new name.txt
+similarity index 90%
+rename from old name.txt
+rename to new name.txt
+index 5555555..6666666 100644
+--- a/old name.txt
++++ b/new name.txt
+@@ -1,1 +1,1 @@
+-q
++r
+diff --git a/image.png b/image.png
+index 7777777..8888888 100644
+Binary files a/image.png and b/image.png differ
+`
+
+func TestFileDiff_Type(t *testing.T) {
+	ds, err := ParseMultiFileDiff([]byte(statTestDiff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []ChangeType
+	for _, d := range ds {
+		got = append(got, d.Type())
+	}
+	want := []ChangeType{Modified, Added, Deleted, Renamed, Modified}
+	if !cmp.Equal(got, want) {
+		t.Errorf("types mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	raw, err := ParseRaw([]byte(":100644 100644 abcdef0 1234567 C75\told.txt\tcopy.txt\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := raw[0].Type(); got != Copied {
+		t.Errorf("raw copy: got type %s, want %s", got, Copied)
+	}
+	if got := (&FileDiff{OrigName: "Only in a: f"}).Type(); got != OnlyIn {
+		t.Errorf("only in: got type %s, want %s", got, OnlyIn)
+	}
+}
+
+func TestWriteStatCSV(t *testing.T) {
+	ds, err := ParseMultiFileDiff([]byte(statTestDiff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteStatCSV(&buf, ds); err != nil {
+		t.Fatal(err)
+	}
+	want := `path,type,added,deleted,binary
+modified.txt,modified,2,1,false
+new.txt,added,2,0,false
+gone.txt,deleted,0,1,false
+old name.txt => new name.txt,renamed,1,1,false
+image.png,modified,,,true
+`
+	if got := buf.String(); got != want {
+		t.Errorf("CSV mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	buf.Reset()
+	if err := WriteStatTSV(&buf, ds[3:4]); err != nil {
+		t.Fatal(err)
+	}
+	want = "path\ttype\tadded\tdeleted\tbinary\nold name.txt => new name.txt\trenamed\t1\t1\tfalse\n"
+	if got := buf.String(); got != want {
+		t.Errorf("TSV mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestPrintDiffStat_CompactSummary(t *testing.T) {
+	ds, err := ParseMultiFileDiff([]byte(`diff --git a/new.sh b/new.sh
+new file mode 100755
+index 0000000..1111111
+--- /dev/null
++++ b/new.sh
+@@ -0,0 +1,2 @@
++echo hi
++exit
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+index 2222222..0000000
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := PrintDiffStat(ds, WithCompactSummary())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ` new.sh (new +x)  | 2 ++
+ gone.txt (gone)  | 1 -
+ run.sh (mode +x) | 0
+ 3 files changed, 2 insertions(+), 1 deletion(-)
+`
+	if string(got) != want {
+		t.Errorf("stat mismatch (-want +got):\n%s", cmp.Diff(want, string(got)))
+	}
+
+	got, err = PrintDiffStat(ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = ` new.sh   | 2 ++
+ gone.txt | 1 -
+ run.sh   | 0
+ 3 files changed, 2 insertions(+), 1 deletion(-)
+`
+	if string(got) != want {
+		t.Errorf("stat without compact summary mismatch (-want +got):\n%s", cmp.Diff(want, string(got)))
+	}
+}
+
+func TestPrintDiffStat_Renames(t *testing.T) {
+	diffData, err := ioutil.ReadFile(filepath.Join("testdata", "compact_summary.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := ParseMultiFileDiff(diffData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The output of git diff --compact-summary and git diff --numstat.
+	printed, err := PrintDiffStat(ds, WithCompactSummary())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ` a/{b => d}/file.txt      | 1 +
+ added (new)              | 1 +
+ c/{old.go => new.go}     | 0
+ top => renamed (mode +x) | 0
+ 4 files changed, 2 insertions(+)
+`
+	if got := string(printed); got != want {
+		t.Errorf("printed stat mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	printed, err = PrintNumStat(ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "1\t0\ta/{b => d}/file.txt\n1\t0\tadded\n0\t0\tc/{old.go => new.go}\n0\t0\ttop => renamed\n"
+	if got := string(printed); got != want {
+		t.Errorf("printed numstat mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestFileDiff_statPath(t *testing.T) {
+	// Renames from git's t4013 and t4001 tests, and their abbreviations.
+	tests := map[string]string{
+		"a/b/c => a/d/c":           "a/{b => d}/c",
+		"a/b/c => a/c":             "a/{b => }/c",
+		"a/c => a/b/c":             "a/{ => b}/c",
+		"dir/file => dir2/file":    "{dir => dir2}/file",
+		"file => file2":            "file => file2",
+		"one/two => one/three":     "one/{two => three}",
+		"a/x/y/z => b/x/y/z":       "{a => b}/x/y/z",
+		"same/dir/a => same/dir/b": "same/dir/{a => b}",
+	}
+	for rename, want := range tests {
+		paths := strings.SplitN(rename, " => ", 2)
+		d := &FileDiff{OrigName: "a/" + paths[0], NewName: "b/" + paths[1], Extended: []string{"rename from " + paths[0], "rename to " + paths[1]}}
+		if got := d.statPath(quoteFilename); got != want {
+			t.Errorf("%s: got %q, want %q", rename, got, want)
+		}
+	}
+}
+
+func TestPrintStat(t *testing.T) {
+	diffData, err := ioutil.ReadFile(filepath.Join("testdata", "stat_width.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := ParseMultiFileDiff(diffData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The output of git diff --stat=<width>.
+	tests := map[int]string{
+		40: ` short                     |   4 +-
+ .../name/file.txt         | 149 ++++--
+`,
+		60: ` short                                  |   4 +-
+ some/very/long/directory/name/file.txt | 149 +++++++++----
+`,
+		0: ` short                                  |   4 +-
+ some/very/long/directory/name/file.txt | 149 ++++++++++++++++++++++-----------
+`,
+		200: ` short                                  |   4 ++--
+ some/very/long/directory/name/file.txt | 149 ` + strings.Repeat("+", 100) + strings.Repeat("-", 49) + `
+`,
+	}
+	for width, want := range tests {
+		printed, err := PrintStat(diffs, width)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want += " 2 files changed, 102 insertions(+), 51 deletions(-)\n"
+		if got := string(printed); got != want {
+			t.Errorf("width %d: printed stat mismatch (-want +got):\n%s", width, cmp.Diff(want, got))
+		}
+	}
+}
+
+func TestPrintDiffStat_Quoted(t *testing.T) {
+	diffData, err := ioutil.ReadFile(filepath.Join("testdata", "stat_quoted.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := ParseMultiFileDiff(diffData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The output of git diff --stat, with core.quotePath set to true (the
+	// default) and false.
+	tests := map[bool]string{
+		true: ` "caf\303\251.txt" | 2 +-
+ "nl\nx"           | 2 +-
+ plain             | 3 ++-
+`,
+		false: ` café.txt | 2 +-
+ "nl\nx"  | 2 +-
+ plain    | 3 ++-
+`,
+	}
+	for quotePath, want := range tests {
+		printed, err := PrintDiffStat(diffs, WithQuotePath(quotePath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want += " 3 files changed, 4 insertions(+), 3 deletions(-)\n"
+		if got := string(printed); got != want {
+			t.Errorf("quotePath %v: printed stat mismatch (-want +got):\n%s", quotePath, cmp.Diff(want, got))
+		}
+	}
+}
+
+func TestPrintNumStat(t *testing.T) {
+	// The output of git diff --cached for a binary file and a renamed one.
+	ds, err := ParseMultiFileDiff([]byte(`diff --git a/bin b/bin
+new file mode 100644
+index 0000000..bdc955b
+Binary files /dev/null and b/bin differ
+diff --git a/short b/renamed
+similarity index 79%
+rename from short
+rename to renamed
+index c58eae6..e5a1379 100644
+--- a/short
++++ b/renamed
+@@ -8,3 +8,4 @@
+ 10
+ 11
+ 12
++extra
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		opts []PrintOption
+		want string
+	}{
+		"default": {want: "-\t-\tbin\n1\t0\tshort => renamed\n"},
+		"-z":      {opts: []PrintOption{WithNULTerminated()}, want: "-\t-\tbin\x001\t0\t\x00short\x00renamed\x00"},
+		"quoter": {
+			opts: []PrintOption{WithQuoter(func(name string) string { return "<" + name + ">" })},
+			want: "-\t-\t<bin>\n1\t0\t<short> => <renamed>\n",
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			printed, err := PrintNumStat(ds, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(printed); got != test.want {
+				t.Errorf("printed numstat mismatch (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+
+	printed, err := PrintShortStat(ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(printed), " 2 files changed, 1 insertion(+)\n"; got != want {
+		t.Errorf("got shortstat %q, want %q", got, want)
+	}
+}
+
+func TestPrintSummary(t *testing.T) {
+	diffData, err := ioutil.ReadFile(filepath.Join("testdata", "compact_summary.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := ParseMultiFileDiff(diffData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds = append(ds, parseFileDiffString(t, `diff --git a/run b/run
+old mode 100644
+new mode 100755
+`), parseFileDiffString(t, `diff --git a/gone b/gone
+deleted file mode 120000
+index 1111111..0000000
+--- a/gone
++++ /dev/null
+@@ -1 +0,0 @@
+-target
+\ No newline at end of file
+`), parseFileDiffString(t, `diff --git a/rw b/rw
+dissimilarity index 100%
+index e8823e1..553efa7 100644
+--- a/rw
++++ b/rw
+@@ -1 +1 @@
+-1
++200
+`), &FileDiff{OrigName: "a/x", NewName: "b/y", Status: "C075"})
+
+	printed, err := PrintSummary(ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first lines are the output of git diff --summary.
+	want := ` rename a/{b => d}/file.txt (87%)
+ create mode 100644 added
+ rename c/{old.go => new.go} (100%)
+ rename top => renamed (100%)
+ mode change 100644 => 100755
+ mode change 100644 => 100755 run
+ delete mode 120000 gone
+ rewrite rw (100%)
+ copy x => y (75%)
+`
+	if got := string(printed); got != want {
+		t.Errorf("printed summary mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestStatByExtensionAndDirectory(t *testing.T) {
+	ds, err := ParseMultiFileDiff([]byte(`diff --git a/cmd/tool/main.go b/cmd/tool/main.go
+index 1111111..2222222 100644
+--- a/cmd/tool/main.go
++++ b/cmd/tool/main.go
+@@ -1,2 +1,3 @@
+-a
++b
+ c
++d
+diff --git a/cmd/config.yaml b/cmd/config.yaml
+deleted file mode 100644
+index 3333333..0000000
+--- a/cmd/config.yaml
++++ /dev/null
+@@ -1 +0,0 @@
+-x: 1
+diff --git a/diff.go b/diff.go
+index 4444444..5555555 100644
+--- a/diff.go
++++ b/diff.go
+@@ -1 +1,2 @@
+ package diff
++// comment
+diff --git a/cmd/logo.png b/cmd/logo.png
+index 6666666..7777777 100644
+Binary files a/cmd/logo.png and b/cmd/logo.png differ
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byExt := StatByExtension(ds)
+	wantByExt := map[string]Stat{
+		".go":   {Added: 2, Changed: 1},
+		".yaml": {Deleted: 1},
+	}
+	if diff := cmp.Diff(wantByExt, byExt); diff != "" {
+		t.Errorf("stats by extension mismatch (-want +got):\n%s", diff)
+	}
+
+	tests := map[int]map[string]Stat{
+		0: {
+			"cmd/tool": {Added: 1, Changed: 1},
+			"cmd":      {Deleted: 1},
+			".":        {Added: 1},
+		},
+		1: {
+			"cmd": {Added: 1, Changed: 1, Deleted: 1},
+			".":   {Added: 1},
+		},
+	}
+	for depth, want := range tests {
+		if diff := cmp.Diff(want, StatByDirectory(ds, depth)); diff != "" {
+			t.Errorf("depth %d: stats by directory mismatch (-want +got):\n%s", depth, diff)
+		}
+	}
+}
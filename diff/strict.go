@@ -0,0 +1,136 @@
+package diff
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrNoDiffGitLine is when a diff parsed strictly does not start with
+	// a "diff --git" line.
+	ErrNoDiffGitLine = errors.New(`diff does not start with a "diff --git" line`)
+
+	// ErrUnknownExtendedHeader is when a diff parsed strictly has an
+	// extended header line that git does not print.
+	ErrUnknownExtendedHeader = errors.New("unknown extended header")
+
+	// ErrExtendedHeaderOrder is when a diff parsed strictly has extended
+	// header lines in a different order than git prints them.
+	ErrExtendedHeaderOrder = errors.New("extended header out of order")
+
+	// ErrTimestampSeparator is when a diff parsed strictly has a file
+	// header timestamp that is not separated from the file name by a tab.
+	ErrTimestampSeparator = errors.New("file header timestamp not separated by a tab")
+
+	// ErrRedundantLineCount is when a diff parsed strictly has a hunk
+	// range with an explicit line count of 1, which git omits.
+	ErrRedundantLineCount = errors.New("hunk range has a line count of 1, which git omits")
+)
+
+// binaryXHeaderRank is the rank (see xheaderRank) of the extended header
+// lines that start a binary patch, which git prints last.
+const binaryXHeaderRank = 7
+
+// xheaderRank returns the position of the extended header line xheader in
+// the order in which git prints them, or -1 if git does not print it.
+func xheaderRank(xheader string) int {
+	ranks := []struct {
+		prefix string
+		rank   int
+	}{
+		{"diff --git ", 0},
+		{"old mode ", 1},
+		{"deleted file mode ", 1},
+		{"new file mode ", 1},
+		{"new mode ", 2},
+		{"similarity index ", 3},
+		{"dissimilarity index ", 3},
+		{"copy from ", 4},
+		{"rename from ", 4},
+		{"copy to ", 5},
+		{"rename to ", 5},
+		{"index ", 6},
+		{"Binary files ", binaryXHeaderRank},
+		{"GIT binary patch", binaryXHeaderRank},
+	}
+	for _, r := range ranks {
+		if strings.HasPrefix(xheader, r.prefix) {
+			return r.rank
+		}
+	}
+	return -1
+}
+
+// ParseFileDiffStrict is like ParseFileDiff, but also rejects diffs that
+// git diff would not print exactly that way: the diff must start with a
+// "diff --git" line, followed by extended headers that git prints in the
+// order it prints them; file header timestamps (which git does not print)
+// must be separated from the file names by a tab; and hunk ranges must omit
+// line counts of 1. Deviations are reported as a *ParseError whose Err is
+// one of ErrNoDiffGitLine, ErrUnknownExtendedHeader, ErrExtendedHeaderOrder,
+// ErrTimestampSeparator and ErrRedundantLineCount, and whose Offset is that
+// of the start of the offending line.
+func ParseFileDiffStrict(diff []byte) (*FileDiff, error) {
+	fd, err := ParseFileDiff(diff)
+	if err != nil {
+		return nil, err
+	}
+
+	inXHeaders := true
+	lastRank := 0
+	var offset int64
+	for i, line := range bytes.SplitAfter(diff, []byte{'\n'}) {
+		start := offset
+		offset += int64(len(line))
+		lineErr := func(err error) error {
+			return &ParseError{Line: i + 1, Offset: start, Err: err}
+		}
+		s := strings.TrimSuffix(string(line), "\n")
+		if i == 0 {
+			if !strings.HasPrefix(s, "diff --git ") {
+				return nil, lineErr(ErrNoDiffGitLine)
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(s, "--- ") || strings.HasPrefix(s, "+++ "):
+			inXHeaders = false
+			if strings.IndexByte(s, '\t') == -1 && hasSpaceSeparatedTimestamp(s[len("--- "):]) {
+				return nil, lineErr(ErrTimestampSeparator)
+			}
+		case inXHeaders:
+			rank := xheaderRank(s)
+			if rank == binaryXHeaderRank {
+				// The rest is the binary patch.
+				return fd, nil
+			}
+			if rank < 0 {
+				return nil, lineErr(ErrUnknownExtendedHeader)
+			}
+			if rank <= lastRank {
+				return nil, lineErr(ErrExtendedHeaderOrder)
+			}
+			lastRank = rank
+		case strings.HasPrefix(s, "@@ "):
+			if pieces := strings.SplitN(s, " ", 4); len(pieces) >= 3 &&
+				(strings.HasSuffix(pieces[1], ",1") || strings.HasSuffix(pieces[2], ",1")) {
+				return nil, lineErr(ErrRedundantLineCount)
+			}
+		}
+	}
+	return fd, nil
+}
+
+// hasSpaceSeparatedTimestamp reports whether the file header value s ends
+// with a timestamp separated from the file name by a space.
+func hasSpaceSeparatedTimestamp(s string) bool {
+	fields := strings.Split(s, " ")
+	for n := 2; n <= 3 && n < len(fields); n++ {
+		if _, _, err := parseTimestamp(strings.Join(fields[len(fields)-n:], " ")); err == nil {
+			return true
+		}
+	}
+	return false
+}
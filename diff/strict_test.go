@@ -0,0 +1,74 @@
+package diff
+
+import (
+	"testing"
+)
+
+func TestParseFileDiffStrict(t *testing.T) {
+	canonical := `diff --git a/f.txt b/f.txt
+old mode 100644
+new mode 100755
+index 1111111..2222222
+--- a/f.txt
++++ b/f.txt
+@@ -1 +1,2 @@
+-a
++b
++c
+`
+	if _, err := ParseFileDiffStrict([]byte(canonical)); err != nil {
+		t.Errorf("canonical diff: got error %v", err)
+	}
+
+	tests := map[string]struct {
+		diff    string
+		wantErr error
+		line    int
+		offset  int64
+	}{
+		"missing diff --git line": {
+			diff:    "--- a/f.txt\n+++ b/f.txt\n@@ -1 +1 @@\n-a\n+b\n",
+			wantErr: ErrNoDiffGitLine,
+			line:    1,
+		},
+		"unknown extended header": {
+			diff:    "diff --git a/f.txt b/f.txt\nfoo bar\n--- a/f.txt\n+++ b/f.txt\n@@ -1 +1 @@\n-a\n+b\n",
+			wantErr: ErrUnknownExtendedHeader,
+			line:    2,
+			offset:  27,
+		},
+		"extended header order": {
+			diff:    "diff --git a/f.txt b/f.txt\nindex 1111111..2222222\nnew mode 100755\nold mode 100644\n--- a/f.txt\n+++ b/f.txt\n@@ -1 +1 @@\n-a\n+b\n",
+			wantErr: ErrExtendedHeaderOrder,
+			line:    3,
+			offset:  50,
+		},
+		"timestamp separator": {
+			diff:    "diff --git a/f.txt b/f.txt\n--- a/f.txt 2009-10-11 15:12:20.000000000 -0700\n+++ b/f.txt\t2009-10-11 15:12:30.000000000 -0700\n@@ -1 +1 @@\n-a\n+b\n",
+			wantErr: ErrTimestampSeparator,
+			line:    2,
+			offset:  27,
+		},
+		"redundant line count": {
+			diff:    "diff --git a/f.txt b/f.txt\n--- a/f.txt\n+++ b/f.txt\n@@ -1,1 +1 @@\n-a\n+b\n",
+			wantErr: ErrRedundantLineCount,
+			line:    4,
+			offset:  51,
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			_, err := ParseFileDiffStrict([]byte(test.diff))
+			perr, ok := err.(*ParseError)
+			if !ok {
+				t.Fatalf("got error %v, want a *ParseError", err)
+			}
+			if perr.Err != test.wantErr {
+				t.Errorf("got error %v, want %v", perr.Err, test.wantErr)
+			}
+			if perr.Line != test.line || perr.Offset != test.offset {
+				t.Errorf("got line %d offset %d, want line %d offset %d", perr.Line, perr.Offset, test.line, test.offset)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package diff
+
+import (
+	"io"
+)
+
+// A Template renders data to w. It is satisfied by *text/template.Template
+// and *html/template.Template.
+type Template interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// TemplateData is the data passed to the template by ExecuteTemplate.
+type TemplateData struct {
+	Files []TemplateFile
+	// Added and Deleted are the total numbers of lines added and deleted,
+	// counting each changed line as one deletion and one addition.
+	Added, Deleted int
+}
+
+// A TemplateFile is a file diff, as passed to a template by ExecuteTemplate.
+type TemplateFile struct {
+	Diff *FileDiff
+	// Path is the path of the file, as shown in a diff stat: "old => new"
+	// for a renamed or copied file.
+	Path string
+	// Type is the kind of change made to the file.
+	Type ChangeType
+	// Added and Deleted are the numbers of lines added and deleted.
+	Added, Deleted int
+	Hunks          []TemplateHunk
+}
+
+// A TemplateHunk is a hunk, as passed to a template by ExecuteTemplate.
+type TemplateHunk struct {
+	Hunk *Hunk
+	// Header is the "@@ -1,2 +1,2 @@" header of the hunk, followed by its
+	// section heading.
+	Header string
+	// Lines are the lines of the hunk, with their line numbers.
+	Lines []LineOp
+}
+
+// ExecuteTemplate renders ds with t, for output in custom formats such as
+// Markdown reports, without having to iterate over hunks and lines and
+// number them. The template is executed once, with a TemplateData holding
+// the files, their hunks and their lines. The print options that drop or
+// merge hunks apply.
+//
+// For example, this template lists the added lines of each file:
+//
+//	{{range .Files}}## {{.Path}} (+{{.Added}} -{{.Deleted}})
+//	{{range .Hunks}}{{range .Lines}}{{if eq .Op.String "insert"}}{{.NewLine}}: {{.Text}}
+//	{{end}}{{end}}{{end}}{{end}}
+func ExecuteTemplate(w io.Writer, t Template, ds []*FileDiff, opts ...PrintOption) error {
+	o := newPrintOptions(opts)
+	var data TemplateData
+	for _, d := range ds {
+		if d.Hunks == nil && d.RawHunks != nil {
+			return errHunksNotLoaded
+		}
+		f := TemplateFile{Diff: d, Path: d.path(), Type: d.Type()}
+		f.Added, f.Deleted = d.Stat().lines()
+		for _, h := range o.hunks(d.Hunks) {
+			f.Hunks = append(f.Hunks, TemplateHunk{Hunk: h, Header: hunkHeaderText(h), Lines: h.operations()})
+		}
+		data.Files = append(data.Files, f)
+		data.Added += f.Added
+		data.Deleted += f.Deleted
+	}
+	return t.Execute(w, data)
+}
@@ -0,0 +1,45 @@
+package diff
+
+import (
+	htmltemplate "html/template"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExecuteTemplate(t *testing.T) {
+	ds, err := ParseMultiFileDiff([]byte("--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@ func\n x\n-<a>\n+<b>\n--- a/g\n+++ /dev/null\n@@ -1,1 +0,0 @@\n-y\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		tmpl Template
+		want string
+	}{
+		"text": {
+			tmpl: template.Must(template.New("").Parse(`{{range .Files}}## {{.Path}} ({{.Type}}, +{{.Added}} -{{.Deleted}})
+{{range .Hunks}}{{.Header}}
+{{range .Lines}}{{if eq .Op.String "insert"}}{{.NewLine}}: {{.Text}}
+{{end}}{{end}}{{end}}{{end}}total: +{{.Added}} -{{.Deleted}}
+`)),
+			want: "## f (modified, +1 -1)\n@@ -1,2 +1,2 @@ func\n2: <b>\n## g (deleted, +0 -1)\n@@ -1,1 +0,0 @@\ntotal: +1 -2\n",
+		},
+		"html": {
+			tmpl: htmltemplate.Must(htmltemplate.New("").Parse(`{{range .Files}}{{range .Hunks}}{{range .Lines}}<p>{{.Text}}</p>{{end}}{{end}}{{end}}`)),
+			want: "<p>x</p><p>&lt;a&gt;</p><p>&lt;b&gt;</p><p>y</p>",
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			var buf strings.Builder
+			if err := ExecuteTemplate(&buf, test.tmpl, ds); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("output mismatch (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
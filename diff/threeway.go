@@ -0,0 +1,110 @@
+package diff
+
+import "bytes"
+
+// ApplyThreeWay applies d to ours, the current contents of a file, falling
+// back to a three-way merge if it does not apply directly, like git apply
+// -3. The merge uses base, the contents of the file that d was made
+// against, to which d must apply: the changes from base to ours are merged
+// with those that d makes to base. Where both change the same or adjacent
+// lines differently, the result contains both versions between conflict
+// markers:
+//
+//	<<<<<<< ours
+//	(the lines of ours)
+//	=======
+//	(the lines of base with d applied)
+//	>>>>>>> theirs
+//
+// ApplyThreeWay returns the result and whether it has conflicts.
+func ApplyThreeWay(base, ours []byte, d *FileDiff, opts ...ApplyOption) ([]byte, bool, error) {
+	if content, err := ApplyFileDiff(ours, d, opts...); err == nil {
+		return content, false, nil
+	}
+	theirs, err := ApplyFileDiff(base, d, opts...)
+	if err != nil {
+		return nil, false, err
+	}
+	content, conflicts := merge3(splitLines(base), splitLines(ours), splitLines(theirs))
+	return content, conflicts, nil
+}
+
+// merge3 merges the changes from base to ours and from base to theirs,
+// marking the conflicting ones. It reports whether there were conflicts.
+func merge3(base, ours, theirs []fileLine) ([]byte, bool) {
+	ourMatch, theirMatch := baseMatches(base, ours), baseMatches(base, theirs)
+
+	var buf bytes.Buffer
+	write := func(lines []fileLine, forceNewline bool) {
+		for _, l := range lines {
+			buf.Write(l.text)
+			if !l.noNewline || forceNewline {
+				buf.WriteByte('\n')
+			}
+		}
+	}
+
+	conflicts := false
+	i, j, k := 0, 0, 0 // the next lines of base, ours and theirs
+	for i < len(base) || j < len(ours) || k < len(theirs) {
+		if i < len(base) && ourMatch[i] == j && theirMatch[i] == k {
+			write(base[i:i+1], false)
+			i, j, k = i+1, j+1, k+1
+			continue
+		}
+
+		// Find the end of the unstable region: the next base line that
+		// both sides kept.
+		e := i
+		for e < len(base) && (ourMatch[e] < 0 || theirMatch[e] < 0) {
+			e++
+		}
+		ourEnd, theirEnd := len(ours), len(theirs)
+		if e < len(base) {
+			ourEnd, theirEnd = ourMatch[e], theirMatch[e]
+		}
+		b, o, t := base[i:e], ours[j:ourEnd], theirs[k:theirEnd]
+		switch {
+		case equalLines(o, b):
+			write(t, false)
+		case equalLines(t, b), equalLines(o, t):
+			write(o, false)
+		default:
+			conflicts = true
+			buf.WriteString("<<<<<<< ours\n")
+			write(o, true)
+			buf.WriteString("=======\n")
+			write(t, true)
+			buf.WriteString(">>>>>>> theirs\n")
+		}
+		i, j, k = e, ourEnd, theirEnd
+	}
+	return buf.Bytes(), conflicts
+}
+
+// baseMatches returns, for each line of base, the index of the same line in
+// other according to a diff between them, or -1 if other does not have it.
+func baseMatches(base, other []fileLine) []int {
+	matches := make([]int, len(base))
+	for i := range matches {
+		matches[i] = -1
+	}
+	for _, op := range computeEdits(base, other, Myers) {
+		if op.op == ' ' {
+			matches[op.orig] = op.new
+		}
+	}
+	return matches
+}
+
+func equalLines(a, b []fileLine) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i].text, b[i].text) || a[i].noNewline != b[i].noNewline {
+			return false
+		}
+	}
+	return true
+}
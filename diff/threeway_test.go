@@ -0,0 +1,57 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestApplyThreeWay(t *testing.T) {
+	base := "a\nb\nc\nd\ne\nf\ng\n"
+	d, err := NewFileDiff("a/f", "b/f", []byte(base), []byte("a\nb\nc\nD\ne\nf\ng\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		ours      string
+		want      string
+		conflicts bool
+	}{
+		"applies directly": {
+			ours: "a\nb\nc\nd\ne\nf\ng\nh\n",
+			want: "a\nb\nc\nD\ne\nf\ng\nh\n",
+		},
+		"merges changes to the context": {
+			ours: "A\nb\nc\nd\ne\nf\nG\n",
+			want: "A\nb\nc\nD\ne\nf\nG\n",
+		},
+		"same change": {
+			ours: "A\nb\nc\nD\ne\nf\ng\n",
+			want: "A\nb\nc\nD\ne\nf\ng\n",
+		},
+		"conflict": {
+			ours:      "A\nb\nc\nX\ne\nf\ng\n",
+			want:      "A\nb\nc\n<<<<<<< ours\nX\n=======\nD\n>>>>>>> theirs\ne\nf\ng\n",
+			conflicts: true,
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			got, conflicts, err := ApplyThreeWay([]byte(base), []byte(test.ours), d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("merged content mismatch (-want +got):\n%s", cmp.Diff(test.want, string(got)))
+			}
+			if conflicts != test.conflicts {
+				t.Errorf("got conflicts %v, want %v", conflicts, test.conflicts)
+			}
+		})
+	}
+
+	if _, _, err := ApplyThreeWay([]byte("x\n"), []byte("y\n"), d); err == nil {
+		t.Error("diff does not apply to base: got no error")
+	}
+}
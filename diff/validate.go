@@ -0,0 +1,153 @@
+package diff
+
+import "fmt"
+
+// Validate checks that d is structurally consistent, so that it can be
+// printed and applied: every hunk must have at least one line, line counts
+// matching its body, and ranges that follow those of the previous hunk
+// without overlapping it.
+func (d *FileDiff) Validate() error {
+	var prevOrigEnd, prevNewEnd int
+	for i, h := range d.Hunks {
+		lines := h.lines()
+		if len(lines) == 0 {
+			return fmt.Errorf("hunk #%d is empty", i+1)
+		}
+		var origLines, newLines int32
+		for _, l := range lines {
+			switch l.op {
+			case ' ':
+				origLines++
+				newLines++
+			case '-':
+				origLines++
+			case '+':
+				newLines++
+			default:
+				return fmt.Errorf("hunk #%d: invalid line prefix %q", i+1, l.op)
+			}
+		}
+		if origLines != h.OrigLines || newLines != h.NewLines {
+			return fmt.Errorf("hunk #%d: header has %d original and %d new lines, but body has %d and %d", i+1, h.OrigLines, h.NewLines, origLines, newLines)
+		}
+
+		origStart, newStart := h.lineStarts()
+		if origStart < 1 || newStart < 1 {
+			return fmt.Errorf("hunk #%d: invalid start line", i+1)
+		}
+		if origStart < prevOrigEnd || newStart < prevNewEnd {
+			return fmt.Errorf("hunk #%d overlaps or precedes the previous hunk", i+1)
+		}
+		prevOrigEnd, prevNewEnd = origStart+int(h.OrigLines), newStart+int(h.NewLines)
+	}
+	return nil
+}
+
+// ValidateMultiFileDiff checks that each diff in ds is valid (see Validate)
+// and that the diffs are consistent with each other: no two of them may
+// produce the same file, and no file may be consumed (modified, deleted or
+// renamed) by more than one of them. Copies do not consume their source.
+// It returns the first inconsistency found, identifying the diffs by their
+// indexes in ds.
+func ValidateMultiFileDiff(ds []*FileDiff) error {
+	targets := make(map[string]int)
+	sources := make(map[string]int)
+	for i, d := range ds {
+		if err := d.Validate(); err != nil {
+			return fmt.Errorf("file #%d (%s): %s", i, d.path(), err)
+		}
+		typ := d.Type()
+		if typ == OnlyIn {
+			continue
+		}
+		orig, new := d.paths()
+		if typ != Deleted {
+			if j, ok := targets[new]; ok {
+				return fmt.Errorf("files #%d and #%d both produce %s", j, i, new)
+			}
+			targets[new] = i
+		}
+		if typ != Added && typ != Copied {
+			if j, ok := sources[orig]; ok {
+				return fmt.Errorf("files #%d and #%d both change or remove %s", j, i, orig)
+			}
+			sources[orig] = i
+		}
+	}
+	return nil
+}
+
+// SelfConsistent checks that the hunks of d agree with the contents they
+// imply, by rebuilding the original and new lines of each hunk and diffing
+// them again: it returns an error if a change (run of removed and added
+// lines between context lines) removes a line only to add it back, that is,
+// if a removed line of the change can be matched to one of its added lines
+// as a longest common subsequence. Diff algorithms pair up such lines as
+// context, so a hunk doing this, such as one that was corrupted or edited by
+// hand, contradicts itself. A hunk is accepted anyway if rediffing its lines
+// with one of the algorithms changes as many lines as it does, so that
+// hunks that are valid but not minimal, as computed by the Patience and
+// Histogram algorithms (and by git), pass the check. d must also be valid
+// (see Validate).
+func (d *FileDiff) SelfConsistent() error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	for i, h := range d.Hunks {
+		lines := h.lines()
+		for start := 0; start < len(lines); {
+			if lines[start].op == ' ' {
+				start++
+				continue
+			}
+			var removed, added []fileLine
+			end := start
+			for ; end < len(lines) && lines[end].op != ' '; end++ {
+				line := fileLine{text: lines[end].text, noNewline: lines[end].noNewline}
+				if lines[end].op == '-' {
+					removed = append(removed, line)
+				} else {
+					added = append(added, line)
+				}
+			}
+			for _, op := range computeEdits(removed, added, Myers) {
+				if op.op == ' ' && !regeneratesChanges(lines) {
+					return fmt.Errorf("hunk #%d removes and adds back the line %q", i+1, removed[op.orig].text)
+				}
+			}
+			start = end
+		}
+	}
+	return nil
+}
+
+// regeneratesChanges reports whether diffing the original and new lines of
+// a hunk with one of the algorithms changes as many lines as the hunk does.
+func regeneratesChanges(lines []hunkLine) bool {
+	var a, b []fileLine
+	changes := 0
+	for _, l := range lines {
+		line := fileLine{text: l.text, noNewline: l.noNewline}
+		if l.op != '+' {
+			a = append(a, line)
+		}
+		if l.op != '-' {
+			b = append(b, line)
+		}
+		if l.op != ' ' {
+			changes++
+		}
+	}
+	for _, algorithm := range []Algorithm{Myers, Patience, Histogram} {
+		n := 0
+		for _, op := range computeEdits(a, b, algorithm) {
+			if op.op != ' ' {
+				n++
+			}
+		}
+		if n >= changes {
+			return true
+		}
+	}
+	return false
+}
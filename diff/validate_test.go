@@ -0,0 +1,184 @@
+package diff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestFileDiff_Validate(t *testing.T) {
+	tests := map[string]struct {
+		hunks   []*Hunk
+		wantErr string
+	}{
+		"valid": {
+			hunks: []*Hunk{
+				{OrigStartLine: 1, OrigLines: 2, NewStartLine: 1, NewLines: 1, Body: []byte(" a\n-b\n")},
+				{OrigStartLine: 10, OrigLines: 0, NewStartLine: 8, NewLines: 1, Body: []byte("+c\n")},
+			},
+		},
+		"empty hunk": {
+			hunks:   []*Hunk{{OrigStartLine: 5, NewStartLine: 5}},
+			wantErr: "hunk #1 is empty",
+		},
+		"wrong line counts": {
+			hunks:   []*Hunk{{OrigStartLine: 1, OrigLines: 1, NewStartLine: 1, NewLines: 1, Body: []byte("-a\n+b\n+c\n")}},
+			wantErr: "hunk #1: header has 1 original and 1 new lines, but body has 1 and 2",
+		},
+		"bad prefix": {
+			hunks:   []*Hunk{{OrigStartLine: 1, OrigLines: 1, NewStartLine: 1, NewLines: 1, Body: []byte("*a\n")}},
+			wantErr: "hunk #1: invalid line prefix '*'",
+		},
+		"overlapping hunks": {
+			hunks: []*Hunk{
+				{OrigStartLine: 1, OrigLines: 3, NewStartLine: 1, NewLines: 3, Body: []byte(" a\n-b\n+B\n c\n")},
+				{OrigStartLine: 3, OrigLines: 1, NewStartLine: 3, NewLines: 1, Body: []byte("-c\n+C\n")},
+			},
+			wantErr: "hunk #2 overlaps or precedes the previous hunk",
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			err := (&FileDiff{OrigName: "a/f", NewName: "b/f", Hunks: test.hunks}).Validate()
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("got error %q, want none", err)
+				}
+			} else if err == nil || err.Error() != test.wantErr {
+				t.Errorf("got error %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMultiFileDiff(t *testing.T) {
+	tests := map[string]struct {
+		diff    string
+		wantErr string
+	}{
+		"clean": {
+			diff: `diff --git a/a.txt b/a.txt
+deleted file mode 100644
+index 1111111..0000000
+--- a/a.txt
++++ /dev/null
+@@ -1,1 +0,0 @@
+-a
+diff --git a/b.txt b/c.txt
+similarity index 100%
+rename from b.txt
+rename to c.txt
+diff --git a/b.txt b/b.txt
+new file mode 100644
+index 0000000..2222222
+--- /dev/null
++++ b/b.txt
+@@ -0,0 +1,1 @@
++b
+diff --git a/c.txt b/d.txt
+similarity index 100%
+copy from c.txt
+copy to d.txt
+`,
+		},
+		"duplicate target": {
+			diff: `--- a/x.txt
++++ b/x.txt
+@@ -1,1 +1,1 @@
+-a
++b
+--- /dev/null
++++ b/x.txt
+@@ -0,0 +1,1 @@
++c
+`,
+			wantErr: "files #0 and #1 both produce x.txt",
+		},
+		"conflicting renames": {
+			diff: `diff --git a/x.txt b/y.txt
+similarity index 100%
+rename from x.txt
+rename to y.txt
+diff --git a/x.txt b/z.txt
+similarity index 100%
+rename from x.txt
+rename to z.txt
+`,
+			wantErr: "files #0 and #1 both change or remove x.txt",
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			ds, err := ParseMultiFileDiff([]byte(test.diff))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ValidateMultiFileDiff(ds)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("got error %q, want none", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("got error %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+
+	invalid := []*FileDiff{{
+		OrigName: "a/x.txt",
+		NewName:  "b/x.txt",
+		Hunks:    []*Hunk{{OrigStartLine: 1, OrigLines: 1, NewStartLine: 1, NewLines: 2, Body: []byte("-a\n+b\n")}},
+	}}
+	want := "file #0 (x.txt): hunk #1: header has 1 original and 2 new lines, but body has 1 and 1"
+	if err := ValidateMultiFileDiff(invalid); err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestFileDiff_SelfConsistent(t *testing.T) {
+	consistent := parseFileDiffString(t, "--- a/f\n+++ b/f\n@@ -1,4 +1,4 @@\n a\n-b\n+B\n c\n d\n")
+	if err := consistent.SelfConsistent(); err != nil {
+		t.Errorf("consistent diff: got error %v", err)
+	}
+
+	// The hunk removes and adds "c", which both sides have as a context line.
+	corrupted := parseFileDiffString(t, "--- a/f\n+++ b/f\n@@ -1,4 +1,4 @@\n a\n-b\n-c\n+B\n+c\n d\n")
+	if err := corrupted.SelfConsistent(); err == nil {
+		t.Error("corrupted diff: got no error")
+	}
+
+	// The hunk removes "c" and adds it back in the middle of the change.
+	corrupted = parseFileDiffString(t, "--- a/f\n+++ b/f\n@@ -1,5 +1,5 @@\n a\n-b\n-c\n-d\n+B\n+c\n+D\n e\n")
+	if err := corrupted.SelfConsistent(); err == nil {
+		t.Error("corrupted diff with a line added back in the middle: got no error")
+	}
+
+	// The hunks computed by every algorithm are consistent, even when they
+	// are not minimal.
+	rnd := rand.New(rand.NewSource(1))
+	randomContent := func() string {
+		var lines []string
+		for i := rnd.Intn(20); i > 0; i-- {
+			lines = append(lines, string(rune('a'+rnd.Intn(4))))
+		}
+		return strings.Join(lines, "\n") + "\n"
+	}
+	for i := 0; i < 500; i++ {
+		orig, new := randomContent(), randomContent()
+		for _, algorithm := range []Algorithm{Myers, Patience, Histogram} {
+			d, err := NewFileDiff("a/f", "b/f", []byte(orig), []byte(new), WithAlgorithm(algorithm))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := d.SelfConsistent(); err != nil {
+				t.Errorf("%q -> %q (algorithm %d): got error %v", orig, new, algorithm, err)
+			}
+		}
+	}
+
+	invalid := parseFileDiffString(t, "--- a/f\n+++ b/f\n@@ -1,4 +1,4 @@\n a\n-b\n+B\n c\n d\n")
+	invalid.Hunks[0].OrigLines = 5
+	if err := invalid.SelfConsistent(); err == nil {
+		t.Error("invalid diff: got no error")
+	}
+}
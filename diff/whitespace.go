@@ -0,0 +1,164 @@
+package diff
+
+import (
+	"sort"
+	"strings"
+)
+
+// A WhitespaceMode selects the whitespace differences that
+// IsWhitespaceOnly ignores. Modes can be combined with |.
+type WhitespaceMode int
+
+const (
+	// IgnoreSpaceAtEOL ignores whitespace at the ends of lines, like git
+	// diff --ignore-space-at-eol.
+	IgnoreSpaceAtEOL WhitespaceMode = 1 << iota
+	// IgnoreLeadingSpace ignores whitespace at the starts of lines, such as
+	// changes in indentation.
+	IgnoreLeadingSpace
+	// IgnoreAllSpace ignores all whitespace, anywhere in lines, like git
+	// diff --ignore-all-space.
+	IgnoreAllSpace
+)
+
+// normalize returns line without the whitespace that mode ignores.
+func (mode WhitespaceMode) normalize(line string) string {
+	if mode&IgnoreAllSpace != 0 {
+		return strings.Join(strings.FieldsFunc(line, isSpace), "")
+	}
+	if mode&IgnoreLeadingSpace != 0 {
+		line = strings.TrimLeftFunc(line, isSpace)
+	}
+	if mode&IgnoreSpaceAtEOL != 0 {
+		line = strings.TrimRightFunc(line, isSpace)
+	}
+	return line
+}
+
+// isSpace reports whether r is a whitespace character, as in git's
+// whitespace options: a space, tab, carriage return, vertical tab or form
+// feed.
+func isSpace(r rune) bool {
+	switch r {
+	case ' ', '\t', '\r', '\v', '\f':
+		return true
+	}
+	return false
+}
+
+// IsWhitespaceOnly reports whether h changes anything and all of its changes
+// only differ in the whitespace that mode ignores: whether each run of
+// removed and added lines between context lines removes as many lines as
+// it adds, and each removed line is the same as the added line in its
+// position once that whitespace is left out. Insertions or deletions of
+// whole lines, even blank ones, are not whitespace-only, and neither are
+// lines moved from one place in h to another. Whether the last line has a
+// newline is not compared.
+func (h *Hunk) IsWhitespaceOnly(mode WhitespaceMode) bool {
+	changed := false
+	var removed, added []string
+	// flush compares the current run of changes, and starts a new one.
+	flush := func() bool {
+		if len(removed) != len(added) {
+			return false
+		}
+		for i := range removed {
+			if mode.normalize(removed[i]) != mode.normalize(added[i]) {
+				return false
+			}
+		}
+		removed, added = removed[:0], added[:0]
+		return true
+	}
+	for _, l := range h.lines() {
+		switch l.op {
+		case ' ':
+			if !flush() {
+				return false
+			}
+		case '-':
+			removed = append(removed, string(l.text))
+			changed = true
+		case '+':
+			added = append(added, string(l.text))
+			changed = true
+		}
+	}
+	return flush() && changed
+}
+
+// IsWhitespaceOnly reports whether d changes anything and all of its hunks
+// are whitespace-only, as reported by Hunk.IsWhitespaceOnly. Diffs of binary
+// files, and diffs without hunks (such as renames and mode changes), are
+// never whitespace-only.
+func (d *FileDiff) IsWhitespaceOnly(mode WhitespaceMode) bool {
+	if len(d.Hunks) == 0 || d.IsBinary() {
+		return false
+	}
+	for _, h := range d.Hunks {
+		if !h.IsWhitespaceOnly(mode) {
+			return false
+		}
+	}
+	return true
+}
+
+// IndentationOnlyLines returns the 0-based indices in h's body (as in
+// Refinement.Line) of the removed and added lines that only change the
+// indentation of a line, such as those of a block that was nested more or
+// less deeply. Within each run of removed and added lines between context
+// lines, the removed lines are aligned with the added ones by their text
+// without leading whitespace (as with Myers' algorithm), and the aligned
+// lines whose leading whitespace differs are reported. The other lines of
+// the run, such as the opening and closing lines of a new block, are not.
+// To tell whether all of h's changes are to indentation, use
+// IsWhitespaceOnly with IgnoreLeadingSpace.
+func (h *Hunk) IndentationOnlyLines() []int {
+	lines := h.lines()
+	var indices []int
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			i++
+			continue
+		}
+		var removed, added []int
+		for ; i < len(lines) && lines[i].op != ' '; i++ {
+			if lines[i].op == '-' {
+				removed = append(removed, i)
+			} else {
+				added = append(added, i)
+			}
+		}
+		ids := make(map[string]int)
+		tokens := func(run []int) []int {
+			ts := make([]int, len(run))
+			for k, j := range run {
+				key := strings.TrimLeftFunc(string(lines[j].text), isSpace)
+				id, ok := ids[key]
+				if !ok {
+					id = len(ids)
+					ids[key] = id
+				}
+				ts[k] = id
+			}
+			return ts
+		}
+		deleted, inserted := myers(tokens(removed), tokens(added))
+		for r, a := 0, 0; r < len(removed) && a < len(added); {
+			switch {
+			case deleted[r]:
+				r++
+			case inserted[a]:
+				a++
+			default:
+				if string(lines[removed[r]].text) != string(lines[added[a]].text) {
+					indices = append(indices, removed[r], added[a])
+				}
+				r++
+				a++
+			}
+		}
+	}
+	sort.Ints(indices)
+	return indices
+}
@@ -0,0 +1,125 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHunk_IsWhitespaceOnly(t *testing.T) {
+	tests := map[string]struct {
+		hunk string
+		want map[WhitespaceMode]bool
+	}{
+		"trailing space": {
+			hunk: "@@ -1,2 +1,2 @@\n a\n-b  \n+b\t\n",
+			want: map[WhitespaceMode]bool{IgnoreSpaceAtEOL: true, IgnoreLeadingSpace: false, IgnoreAllSpace: true},
+		},
+		"indentation": {
+			hunk: "@@ -1,3 +1,3 @@\n-\tif x {\n-\t\ty()\n+    if x {\n+        y()\n }\n",
+			want: map[WhitespaceMode]bool{IgnoreSpaceAtEOL: false, IgnoreLeadingSpace: true, IgnoreAllSpace: true},
+		},
+		"leading and trailing space": {
+			hunk: "@@ -1 +1 @@\n-  a b\n+a b \n",
+			want: map[WhitespaceMode]bool{IgnoreSpaceAtEOL: false, IgnoreLeadingSpace: false, IgnoreLeadingSpace | IgnoreSpaceAtEOL: true, IgnoreAllSpace: true},
+		},
+		"inner space": {
+			hunk: "@@ -1 +1 @@\n-a  b\n+a b\n",
+			want: map[WhitespaceMode]bool{IgnoreLeadingSpace | IgnoreSpaceAtEOL: false, IgnoreAllSpace: true},
+		},
+		"text change": {
+			hunk: "@@ -1 +1 @@\n-a\n+ b\n",
+			want: map[WhitespaceMode]bool{IgnoreAllSpace: false},
+		},
+		"added blank line": {
+			hunk: "@@ -1,2 +1,3 @@\n a\n+\n b\n",
+			want: map[WhitespaceMode]bool{IgnoreAllSpace: false},
+		},
+		"moved line": {
+			hunk: "@@ -1,3 +1,3 @@\n-a\n b\n c\n+a\n",
+			want: map[WhitespaceMode]bool{IgnoreAllSpace: false},
+		},
+		"context only": {
+			hunk: "@@ -1 +1 @@\n a\n",
+			want: map[WhitespaceMode]bool{IgnoreAllSpace: false},
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			hunks, err := ParseHunks([]byte(test.hunk))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for mode, want := range test.want {
+				if got := hunks[0].IsWhitespaceOnly(mode); got != want {
+					t.Errorf("mode %d: got %v, want %v", mode, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFileDiff_IsWhitespaceOnly(t *testing.T) {
+	d := parseFileDiffString(t, `--- a/f
++++ b/f
+@@ -1,2 +1,2 @@
+ a
+-b 
++b
+@@ -10,2 +10,2 @@
+-x
++  x
+ y
+`)
+	if d.IsWhitespaceOnly(IgnoreSpaceAtEOL) {
+		t.Error("got whitespace-only at EOL, want not")
+	}
+	if !d.IsWhitespaceOnly(IgnoreSpaceAtEOL | IgnoreLeadingSpace) {
+		t.Error("got not whitespace-only, want whitespace-only")
+	}
+	if (&FileDiff{OrigName: "a/f", NewName: "b/g", Extended: []string{"rename from f", "rename to g"}}).IsWhitespaceOnly(IgnoreAllSpace) {
+		t.Error("got whitespace-only rename, want not")
+	}
+}
+
+func TestHunk_IndentationOnlyLines(t *testing.T) {
+	tests := map[string]struct {
+		hunk string
+		want []int
+	}{
+		"nested block": {
+			hunk: `@@ -1,4 +1,6 @@
+ func f() {
+-	a()
+-	b()
++	if ok {
++		a()
++		b()
++	}
+ }
+`,
+			want: []int{1, 2, 4, 5},
+		},
+		"changed text": {
+			hunk: "@@ -1 +1 @@\n-  a\n+\tb\n",
+		},
+		"unchanged line": {
+			hunk: "@@ -1,2 +1,2 @@\n-a\n-b\n+a\n+c\n",
+		},
+		"separate runs": {
+			hunk: "@@ -1,3 +1,3 @@\n-  x\n+x\n m\n-y\n+ y \n",
+			want: []int{0, 1},
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			hunks, err := ParseHunks([]byte(test.hunk))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hunks[0].IndentationOnlyLines(); !cmp.Equal(got, test.want) {
+				t.Errorf("lines mismatch (-want +got):\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}